@@ -0,0 +1,728 @@
+package ballot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/takakv/msc-poc/bulletproofs"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/util"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate the ballot fixtures in testdata")
+
+// testVoter is the voter who casts the ballots of the tests.
+const testVoter = "voter"
+
+// fixturePath returns the path of the ballot fixture for g, e.g.
+// ../testdata/P256rp.json.
+func fixturePath(g group.Group) string {
+	return filepath.Join("..", "testdata", strings.ReplaceAll(g.Name(), "-", "")+"rp.json")
+}
+
+// writeFixture casts a ballot for a random choice, and writes it to the
+// fixture file of the group of pp.
+func writeFixture(pp PublicParameters) error {
+	vote, _, err := Cast(RandomChoice(pp), testVoter, pp)
+	if err != nil {
+		return err
+	}
+	if _, err = Verify(vote, pp); err != nil {
+		return fmt.Errorf("failed to verify generated data: %w", err)
+	}
+	jsonData, err := json.MarshalIndent(vote, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fixturePath(pp.ECGroupParams.I), jsonData, 0644)
+}
+
+func unmarshalAndVerify(b []byte, pp PublicParameters) error {
+	dataset, err := BallotDataUnmarshalJSON(b, pp)
+	if err != nil {
+		return err
+	}
+
+	if _, err = Verify(dataset, pp); err != nil {
+		return fmt.Errorf("failed to verify data: %w", err)
+	}
+
+	return nil
+}
+
+// TestTestData verifies the ballot fixture of every group. With -update,
+// the fixtures are regenerated first.
+func TestTestData(t *testing.T) {
+	for _, g := range curveGroups {
+		pp, err := Setup(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err = writeFixture(pp); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		data, err := os.ReadFile(fixturePath(g))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = unmarshalAndVerify(data, pp); err != nil {
+			t.Errorf("%s: %v", g.Name(), err)
+		}
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	groups := []group.Group{group.SecP256k1(), group.Ristretto255(), group.P256(), group.P384()}
+
+	for _, g := range groups {
+		t.Run(g.Name(), func(t *testing.T) {
+			pp, err := Setup(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vote, _, err := Cast(RandomChoice(pp), testVoter, pp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := vote.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jsonData, _ := json.Marshal(vote)
+			if len(encoded) >= len(jsonData) {
+				t.Errorf("binary encoding is not smaller than JSON: %d >= %d", len(encoded), len(jsonData))
+			}
+
+			decoded, err := BallotDataUnmarshalBinary(encoded, pp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = Verify(decoded, pp); err != nil {
+				t.Error("failed to verify decoded data:", err)
+			}
+
+			_, err = BallotDataUnmarshalBinary(append(encoded, 0), pp)
+			if err == nil {
+				t.Error("trailing data was accepted")
+			}
+			_, err = BallotDataUnmarshalBinary(encoded[:len(encoded)-1], pp)
+			if err == nil {
+				t.Error("truncated data was accepted")
+			}
+		})
+	}
+}
+
+// mutateJSON decodes b, applies f to the resulting object, and re-encodes it.
+func mutateJSON(t *testing.T, b []byte, f func(m map[string]any)) []byte {
+	t.Helper()
+	var m map[string]any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	f(m)
+	out, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestStrictJSON(t *testing.T) {
+	data, err := os.ReadFile("../testdata/P256rp.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp, err := Setup(group.P256())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	field := func(m map[string]any, path ...string) map[string]any {
+		for _, p := range path {
+			m = m[p].(map[string]any)
+		}
+		return m
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(m map[string]any)
+		path   string
+	}{
+		{"unknown field", func(m map[string]any) { m["extra"] = 1 }, ""},
+		{"missing field", func(m map[string]any) { delete(m, "ubProof") }, "ubProof"},
+		{"null element", func(m map[string]any) { field(m, "ballot")["u"] = nil }, "ballot.u"},
+		{"invalid element", func(m map[string]any) { field(m, "ballot")["v"] = 0 }, "ballot.v"},
+		{"point not on curve", func(m map[string]any) {
+			field(m, "lbProof", "A")["x"] = 1
+		}, "lbProof.A"},
+		{"short L", func(m map[string]any) {
+			ipp := field(m, "lbProof", "InnerProductProof")
+			ipp["L"] = ipp["L"].([]any)[1:]
+		}, "lbProof.InnerProductProof.L"},
+		{"scalar out of range", func(m map[string]any) {
+			field(m, "ubProof")["Mu"] = json.Number(pp.BPParams.GP.N().String())
+		}, "ubProof.Mu"},
+		{"negative response", func(m map[string]any) {
+			field(m, "voteProof")["Z"] = -1
+		}, "voteProof.Z"},
+		{"missing challenge", func(m map[string]any) {
+			delete(field(m, "voteProof"), "Challenge")
+		}, "voteProof.Challenge"},
+		{"modified generator", func(m map[string]any) {
+			field(m, "lbProof", "InnerProductProof", "Params")["Uu"] = field(m, "lbProof", "A")
+		}, "lbProof.InnerProductProof.Params.Uu"},
+		{"other parameters", func(m map[string]any) {
+			field(m, "voteProof", "Params")["RangeHi"] = 3000
+		}, "voteProof.Params"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BallotDataUnmarshalJSON(mutateJSON(t, data, tt.mutate), pp)
+			if err == nil {
+				t.Fatal("malformed ballot was accepted")
+			}
+			var pe *util.PathError
+			if tt.path != "" && (!errors.As(err, &pe) || !strings.HasPrefix(pe.Path, tt.path)) {
+				t.Errorf("error %q does not point to %s", err, tt.path)
+			}
+		})
+	}
+
+	if _, err = BallotDataUnmarshalJSON(append(data, []byte("{}")...), pp); err == nil {
+		t.Error("trailing data was accepted")
+	}
+}
+
+func FuzzBallotDataJSON(f *testing.F) {
+	pp, err := Setup(group.P256())
+	if err != nil {
+		f.Fatal(err)
+	}
+	data, err := os.ReadFile("../testdata/P256rp.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ballot, err := BallotDataUnmarshalJSON(data, pp)
+		if err != nil {
+			return
+		}
+		_, _ = Verify(ballot, pp)
+	})
+}
+
+func FuzzBallotDataBinary(f *testing.F) {
+	pp, err := Setup(group.P256())
+	if err != nil {
+		f.Fatal(err)
+	}
+	data, err := os.ReadFile("../testdata/P256rp.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	ballot, err := BallotDataUnmarshalJSON(data, pp)
+	if err != nil {
+		f.Fatal(err)
+	}
+	enc, err := ballot.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(enc)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ballot, err := BallotDataUnmarshalBinary(data, pp)
+		if err != nil {
+			return
+		}
+		_, _ = Verify(ballot, pp)
+	})
+}
+
+func TestParamsJSON(t *testing.T) {
+	pp, err := Setup(group.P256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ParamsUnmarshalJSON(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.RPParams.IsEqual(&pp.RPParams) || decoded.CandidateMin.Cmp(pp.CandidateMin) != 0 ||
+		decoded.CandidateMax.Cmp(pp.CandidateMax) != 0 || !decoded.EGPK.IsEqual(pp.EGPK) {
+		t.Error("decoded parameters differ")
+	}
+
+	// Ballots cast with the decoded parameters verify with the original ones.
+	vote, _, err := Cast(pp.CandidateMax, testVoter, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(vote, pp); err != nil {
+		t.Error(err)
+	}
+
+	other, _ := Setup(group.P384())
+	mixed := mutateJSON(t, encoded, func(m map[string]any) {
+		var o map[string]any
+		b, _ := json.Marshal(other)
+		_ = json.Unmarshal(b, &o)
+		m["bpParams"] = o["bpParams"]
+	})
+	if _, err = ParamsUnmarshalJSON(mixed); err == nil {
+		t.Error("parameters of different groups were accepted")
+	}
+}
+
+func TestCast(t *testing.T) {
+	pp, err := Setup(group.Ristretto255())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	one := big.NewInt(1)
+	for _, choice := range []*big.Int{new(big.Int).Sub(pp.CandidateMin, one), new(big.Int).Add(pp.CandidateMax, one)} {
+		if _, _, err = Cast(choice, testVoter, pp); err == nil {
+			t.Errorf("choice %d outside of the range was accepted", choice)
+		}
+	}
+
+	vote, secrets, err := Cast(pp.CandidateMin, testVoter, pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(vote, pp); err != nil {
+		t.Error(err)
+	}
+
+	encoded, err := json.Marshal(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := SecretsUnmarshalJSON(encoded, pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The secrets open the ciphertext.
+	FFG := pp.FFGroupParams.I
+	if !FFG.Element().BaseScale(decoded.R).IsEqual(vote.Ballot.U) {
+		t.Error("randomness does not match the ciphertext")
+	}
+	m := FFG.Element().BaseScale(FFG.NewScalar().SetBigInt(decoded.Choice))
+	if !FFG.Element().Add(m, FFG.Element().Scale(pp.EGPK, decoded.R)).IsEqual(vote.Ballot.V) {
+		t.Error("secrets do not open the ciphertext")
+	}
+}
+
+// TestBinding checks that a ballot does not verify for another voter or in
+// another election.
+func TestBinding(t *testing.T) {
+	pp, err := Setup(group.Ristretto255())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp.ElectionID = "election"
+	vote, secrets, err := Cast(pp.CandidateMin, "alice", pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(vote, pp); err != nil {
+		t.Fatal(err)
+	}
+
+	copied := vote
+	copied.VoterID = "mallory"
+	if _, err = Verify(copied, pp); !errors.Is(err, ErrPoK) {
+		t.Errorf("copied ballot: expected %v, got %v", ErrPoK, err)
+	}
+
+	// A voter cannot submit the ciphertext of another voter with proofs of
+	// their own.
+	own, _, err := Cast(pp.CandidateMax, "mallory", pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	own.Ballot, own.PoK = vote.Ballot, vote.PoK
+	if _, err = Verify(own, pp); !errors.Is(err, ErrPoK) {
+		t.Errorf("transplanted ciphertext: expected %v, got %v", ErrPoK, err)
+	}
+
+	// Even with the randomness, the range proofs are bound to the voter.
+	d := domain(pp.ElectionID, "mallory")
+	if copied.PoK, err = provePoK(vote.Ballot, secrets.Choice, secrets.R, d, pp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(copied, pp); !errors.Is(err, ErrLowerBound) {
+		t.Errorf("copied range proofs: expected %v, got %v", ErrLowerBound, err)
+	}
+
+	other := pp
+	other.ElectionID = "other"
+	if _, err = Verify(vote, other); err == nil {
+		t.Error("ballot was accepted in another election")
+	}
+	if bytes.Equal(domain("ab", "c"), domain("a", "bc")) {
+		t.Error("domains are ambiguous")
+	}
+}
+
+// curveGroups are the groups in which the range proofs can be computed.
+var curveGroups = []group.Group{group.SecP256k1(), group.Ristretto255(), group.P256(), group.P384()}
+
+// modes lists the setup of each ballot mode.
+var modes = []struct {
+	mode  Mode
+	setup func(group.Group) (PublicParameters, error)
+}{
+	{CrossGroup, Setup},
+	{SingleGroup, SetupSingleGroup},
+}
+
+func TestOperations(t *testing.T) {
+	for _, m := range modes {
+		pp, err := m.setup(group.P256())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops, err := Operations(pp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, op := range ops {
+			if err = op.Run(); err != nil {
+				t.Errorf("%s/%s: %v", m.mode, op.Name, err)
+			}
+		}
+	}
+}
+
+// BenchmarkOperations compares the steps of both modes, e.g. with
+// -bench 'Operations/.*/P-256/verify$'.
+func BenchmarkOperations(b *testing.B) {
+	for _, m := range modes {
+		for _, g := range curveGroups {
+			pp, err := m.setup(g)
+			if err != nil {
+				b.Fatal(err)
+			}
+			benchmarkOperations(b, pp)
+		}
+	}
+}
+
+func benchmarkOperations(b *testing.B, pp PublicParameters) {
+	ops, err := Operations(pp)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, op := range ops {
+		b.Run(string(pp.Mode)+"/"+pp.ECGroupParams.I.Name()+"/"+op.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := op.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestWideChoices(t *testing.T) {
+	if testing.Short() {
+		t.Skip("proofs in P-384 are slow")
+	}
+	if _, err := SetupRange(group.P256(), big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), 32)); err == nil {
+		t.Error("choices of 64 bits were accepted for P-256, which leaves no room for the abort parameter")
+	}
+
+	for _, bits := range []uint{32, 64, 128} {
+		bits := bits
+		t.Run(fmt.Sprint(bits), func(t *testing.T) {
+			t.Parallel()
+			testWideChoices(t, bits)
+		})
+	}
+}
+
+// testWideChoices casts and verifies ballots whose choices are bits long.
+func testWideChoices(t *testing.T, bits uint) {
+	lo := new(big.Int).Lsh(big.NewInt(1), bits-2)
+	hi := new(big.Int).Lsh(big.NewInt(1), bits)
+	hi.Sub(hi, big.NewInt(1))
+	pp, err := SetupRange(group.P384(), lo, hi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pp.RPParams.Bx != uint16(bits) || pp.BPParams.N != int64(bits) {
+		t.Errorf("unexpected parameters Bx=%d N=%d", pp.RPParams.Bx, pp.BPParams.N)
+	}
+
+	encoded, _ := json.Marshal(pp)
+	decoded, err := ParamsUnmarshalJSON(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, choice := range []*big.Int{lo, hi} {
+		vote, _, err := Cast(choice, testVoter, decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(vote)
+		if err = unmarshalAndVerify(data, pp); err != nil {
+			t.Errorf("choice %d: %v", choice, err)
+		}
+	}
+
+	// Choices just outside the range are refused, and cannot be proven
+	// by dishonest voters. Above the range, the choice does not even fit
+	// in the secret length.
+	below := new(big.Int).Sub(lo, big.NewInt(1))
+	above := new(big.Int).Add(hi, big.NewInt(1))
+	for _, choice := range []*big.Int{below, above} {
+		if _, _, err = Cast(choice, testVoter, pp); err == nil {
+			t.Errorf("choice %d outside of the range was accepted", choice)
+		}
+	}
+	if _, err = castUnchecked(above, pp); err == nil {
+		t.Errorf("choice %d was proven", above)
+	}
+	vote, err := castUnchecked(below, pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(vote, pp); err == nil {
+		t.Errorf("ballot for choice %d was accepted", below)
+	}
+}
+
+func TestSingleGroup(t *testing.T) {
+	for _, g := range curveGroups {
+		t.Run(g.Name(), func(t *testing.T) {
+			pp, err := SetupSingleGroup(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, choice := range []*big.Int{pp.CandidateMin, RandomChoice(pp), pp.CandidateMax} {
+				vote, secrets, err := Cast(choice, testVoter, pp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if vote.VoteProof != nil {
+					t.Error("single-group ballot has a vote proof")
+				}
+				if _, err = Verify(vote, pp); err != nil {
+					t.Errorf("choice %d: %v", choice, err)
+				}
+
+				// The secrets open the ciphertext on the curve.
+				m := g.Element().BaseScale(g.NewScalar().SetBigInt(secrets.Choice))
+				if !g.Element().BaseScale(secrets.R).IsEqual(vote.Ballot.U) ||
+					!g.Element().Add(m, g.Element().Scale(pp.EGPK, secrets.R)).IsEqual(vote.Ballot.V) {
+					t.Error("secrets do not open the ciphertext")
+				}
+			}
+
+			vote, _, err := Cast(RandomChoice(pp), testVoter, pp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := vote.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := BallotDataUnmarshalBinary(encoded, pp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = Verify(decoded, pp); err != nil {
+				t.Error("failed to verify decoded data:", err)
+			}
+			jsonData, err := json.Marshal(vote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = unmarshalAndVerify(jsonData, pp); err != nil {
+				t.Error(err)
+			}
+
+			// The ballots of one mode are not decoded in the other.
+			cross, err := Setup(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = BallotDataUnmarshalBinary(encoded, cross); err == nil {
+				t.Error("single-group ballot was decoded as a cross-group ballot")
+			}
+			if _, err = BallotDataUnmarshalJSON(jsonData, cross); err == nil {
+				t.Error("single-group ballot was decoded from JSON as a cross-group ballot")
+			}
+			crossVote, _, err := Cast(RandomChoice(cross), testVoter, cross)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crossEncoded, err := crossVote.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = BallotDataUnmarshalBinary(crossEncoded, pp); err == nil {
+				t.Error("cross-group ballot was decoded as a single-group ballot")
+			}
+			if len(encoded) >= len(crossEncoded) {
+				t.Errorf("single-group ballot is not smaller: %d >= %d", len(encoded), len(crossEncoded))
+			}
+		})
+	}
+}
+
+// TestSingleGroupTampering checks that the range proofs of a single-group
+// ballot must be for its ciphertext.
+func TestSingleGroupTampering(t *testing.T) {
+	pp, err := SetupSingleGroup(group.Ristretto255())
+	if err != nil {
+		t.Fatal(err)
+	}
+	choice := RandomChoice(pp)
+	vote, _, err := Cast(choice, testVoter, pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := domain(pp.ElectionID, testVoter)
+
+	// A valid range proof for a commitment of the voter's choosing.
+	tampered := vote
+	tampered.BpLower, _, err = bulletproofs.ProveInDomain(new(big.Int).Sub(choice, pp.CandidateMin), pp.BPParams, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(tampered, pp); !errors.Is(err, ErrLowerBound) {
+		t.Errorf("unrelated lower bound proof: expected %v, got %v", ErrLowerBound, err)
+	}
+	tampered = vote
+	tampered.BpUpper = vote.BpLower
+	if _, err = Verify(tampered, pp); !errors.Is(err, ErrUpperBound) {
+		t.Errorf("swapped upper bound proof: expected %v, got %v", ErrUpperBound, err)
+	}
+
+	// The first component of another ciphertext does not match the
+	// randomness of the commitments.
+	other, _, err := Cast(choice, testVoter, pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered = vote
+	tampered.Ballot.U = other.Ballot.U
+	if _, err = Verify(tampered, pp); !errors.Is(err, ErrPoK) {
+		t.Errorf("altered ciphertext: expected %v, got %v", ErrPoK, err)
+	}
+
+	// A choice outside of the range has a range proof of a negative value.
+	one := big.NewInt(1)
+	for _, c := range []struct {
+		choice *big.Int
+		err    error
+	}{
+		{new(big.Int).Sub(pp.CandidateMin, one), ErrLowerBound},
+		{new(big.Int).Add(pp.CandidateMax, one), ErrUpperBound},
+	} {
+		dishonest, _, err := castSingleGroup(c.choice, testVoter, pp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Verify(dishonest, pp); !errors.Is(err, c.err) {
+			t.Errorf("choice %d: expected %v, got %v", c.choice, c.err, err)
+		}
+
+		// The range proofs of the valid ballot, retargeted at the
+		// commitments of the out-of-range ciphertext. Their challenges
+		// hash V, so the transcripts no longer verify.
+		forged := dishonest
+		forged.BpLower, forged.BpUpper = vote.BpLower, vote.BpUpper
+		forged.BpLower.V = lowerCommitment(dishonest.Ballot, pp)
+		forged.BpUpper.V = upperCommitment(dishonest.Ballot, pp)
+		for _, bp := range []bulletproofs.BulletProof{forged.BpLower, forged.BpUpper} {
+			if ok, _ := bp.VerifyInDomain(d); ok {
+				t.Errorf("choice %d: range proof verified for another commitment", c.choice)
+			}
+		}
+		if _, err = Verify(forged, pp); !errors.Is(err, ErrLowerBound) {
+			t.Errorf("choice %d with forged range proofs: expected %v, got %v", c.choice, ErrLowerBound, err)
+		}
+	}
+}
+
+func TestSingleGroupParamsJSON(t *testing.T) {
+	pp, err := SetupSingleGroup(group.P256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp.ElectionID = "election"
+	encoded, err := json.Marshal(pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ParamsUnmarshalJSON(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Mode != SingleGroup || decoded.ElectionID != pp.ElectionID || !decoded.EGPK.IsEqual(pp.EGPK) ||
+		decoded.CandidateMin.Cmp(pp.CandidateMin) != 0 || decoded.CandidateMax.Cmp(pp.CandidateMax) != 0 {
+		t.Error("decoded parameters differ")
+	}
+
+	// Ballots cast with the decoded parameters verify with the original ones.
+	vote, _, err := Cast(pp.CandidateMax, testVoter, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(vote, pp); err != nil {
+		t.Error(err)
+	}
+
+	cross, _ := Setup(group.P256())
+	crossEncoded, _ := json.Marshal(cross)
+	var rpParams any
+	mutateJSON(t, crossEncoded, func(m map[string]any) { rpParams = m["rpParams"] })
+
+	for name, f := range map[string]func(m map[string]any){
+		"unknown mode":     func(m map[string]any) { m["mode"] = "other" },
+		"vote proof":       func(m map[string]any) { m["rpParams"] = rpParams },
+		"missing range":    func(m map[string]any) { delete(m, "candidateMax") },
+		"empty range":      func(m map[string]any) { m["candidateMax"] = 100 },
+		"negative range":   func(m map[string]any) { m["candidateMin"] = -1 },
+		"range too wide":   func(m map[string]any) { m["candidateMax"] = 1 << 16 },
+		"cross-group mode": func(m map[string]any) { m["mode"] = string(CrossGroup) },
+	} {
+		if _, err = ParamsUnmarshalJSON(mutateJSON(t, encoded, f)); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+	withRange := mutateJSON(t, crossEncoded, func(m map[string]any) { m["candidateMin"] = 101 })
+	if _, err = ParamsUnmarshalJSON(withRange); err == nil {
+		t.Error("range outside of the vote proof parameters was accepted")
+	}
+}
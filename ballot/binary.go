@@ -0,0 +1,107 @@
+package ballot
+
+import (
+	"github.com/takakv/msc-poc/bulletproofs"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/sigma"
+	"github.com/takakv/msc-poc/util"
+	"github.com/takakv/msc-poc/voteproof"
+)
+
+// MarshalBinary encodes the components U and V of the ciphertext.
+func (c ElGamalCiphertext) MarshalBinary() ([]byte, error) {
+	e := util.NewEncoder(util.WireElGamalCiphertext)
+	e.Element(c.U)
+	e.Element(c.V)
+	return e.Bytes()
+}
+
+func BallotUnmarshalBinary(b []byte, g group.Group) (ElGamalCiphertext, error) {
+	d := util.NewDecoder(b, util.WireElGamalCiphertext)
+	ballot := ElGamalCiphertext{
+		U: d.Element(g),
+		V: d.Element(g),
+	}
+	if err := d.Finish(); err != nil {
+		return ElGamalCiphertext{}, err
+	}
+	return ballot, nil
+}
+
+// MarshalBinary encodes the ballot and its proofs in the compact binary
+// wire format. Each component is a nested message, and SingleGroup ballots
+// have no vote proof.
+func (bd BallotData) MarshalBinary() ([]byte, error) {
+	e := util.NewEncoder(util.WireBallot)
+	e.Text(bd.VoterID)
+	e.Message(bd.Ballot.MarshalBinary())
+	if bd.VoteProof == nil {
+		// The ElGamal public key is the blinding generator of Bulletproofs.
+		params := bd.BpLower.Params
+		e.Message(sigma.MarshalBinary(linkRelation(bd.Ballot, params.GP, params.H), bd.PoK))
+	} else {
+		// The ElGamal group is that of the vote proof.
+		e.Message(sigma.MarshalBinary(pokRelation(bd.Ballot.U, bd.VoteProof.Params.GFF.I), bd.PoK))
+	}
+	e.Message(bd.BpLower.MarshalBinary())
+	e.Message(bd.BpUpper.MarshalBinary())
+	if bd.VoteProof != nil {
+		e.Message(bd.VoteProof.MarshalBinary())
+	}
+	return e.Bytes()
+}
+
+func BallotDataUnmarshalBinary(b []byte, pp PublicParameters) (BallotData, error) {
+	d := util.NewDecoder(b, util.WireBallot)
+	voterID := d.Text()
+	ballotBytes := d.Message()
+	pokBytes := d.Message()
+	bpLowerBytes := d.Message()
+	bpUpperBytes := d.Message()
+	var voteProofBytes []byte
+	if pp.Mode != SingleGroup {
+		voteProofBytes = d.Message()
+	}
+	if err := d.Finish(); err != nil {
+		return BallotData{}, err
+	}
+
+	ballot, err := BallotUnmarshalBinary(ballotBytes, pp.ElGamalParams().I)
+	if err != nil {
+		return BallotData{}, err
+	}
+
+	pok, err := sigma.UnmarshalBinary(ballotRelation(ballot, pp), pokBytes)
+	if err != nil {
+		return BallotData{}, err
+	}
+
+	bpLower, err := bulletproofs.BulletProofUnmarshalBinary(bpLowerBytes, pp.BPParams)
+	if err != nil {
+		return BallotData{}, err
+	}
+
+	bpUpper, err := bulletproofs.BulletProofUnmarshalBinary(bpUpperBytes, pp.BPParams)
+	if err != nil {
+		return BallotData{}, err
+	}
+
+	bd := BallotData{
+		VoterID: voterID,
+		Ballot:  ballot,
+		PoK:     pok,
+		BpLower: bpLower,
+		BpUpper: bpUpper,
+	}
+	if pp.Mode == SingleGroup {
+		return bd, nil
+	}
+
+	voteProof, err := voteproof.ProofUnmarshalBinary(voteProofBytes, pp.RPParams)
+	if err != nil {
+		return BallotData{}, err
+	}
+	bd.VoteProof = &voteProof
+
+	return bd, nil
+}
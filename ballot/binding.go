@@ -0,0 +1,65 @@
+package ballot
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/sigma"
+	"math/big"
+)
+
+// domain returns the domain that binds the proofs of a ballot to the
+// election and to the voter, so that they do not verify for a copy of the
+// ballot that is submitted by another voter or in another election.
+func domain(electionID, voterID string) []byte {
+	b := []byte("ballot")
+	b = binary.AppendUvarint(b, uint64(len(electionID)))
+	b = append(b, electionID...)
+	b = binary.AppendUvarint(b, uint64(len(voterID)))
+	return append(b, voterID...)
+}
+
+// pokRelation returns the relation of the proof of knowledge of the
+// randomness r of the ciphertext, whose first component is U = rG in g.
+func pokRelation(U group.Element, g group.Group) *sigma.Relation {
+	return sigma.DLog(g, nil, U)
+}
+
+// linkRelation returns the relation of the proof of knowledge of the
+// plaintext m and randomness r of the SingleGroup ciphertext
+// (U, V) = (rG, mG + rPK) in g. Since V is also the commitment of the range
+// proofs, it links the blinding factor of the range proofs to U. Its
+// witness is [m, r].
+func linkRelation(ciphertext ElGamalCiphertext, g group.Group, PK group.Element) *sigma.Relation {
+	return sigma.Plaintext(g, PK, ciphertext.U, ciphertext.V)
+}
+
+// ballotRelation returns the relation of the proof of knowledge of the
+// ballot in the mode of pp.
+func ballotRelation(ciphertext ElGamalCiphertext, pp PublicParameters) *sigma.Relation {
+	if pp.Mode == SingleGroup {
+		return linkRelation(ciphertext, pp.ECGroupParams.I, pp.EGPK)
+	}
+	return pokRelation(ciphertext.U, pp.FFGroupParams.I)
+}
+
+// provePoK proves knowledge of the randomness of the ciphertext in the
+// domain of the voter, and in SingleGroup mode also of the choice. Without
+// it, a voter could submit another voter's ciphertext under their own
+// identity with fresh proofs for it.
+func provePoK(ciphertext ElGamalCiphertext, choice *big.Int, r group.Scalar, d []byte, pp PublicParameters) (sigma.Proof, error) {
+	witness := []*big.Int{r.BigInt()}
+	if pp.Mode == SingleGroup {
+		witness = []*big.Int{choice, r.BigInt()}
+	}
+	return sigma.Prove(context.Background(), ballotRelation(ciphertext, pp), witness, sigma.HashOracle(string(d)), nil)
+}
+
+// verifyPoK verifies the proof of knowledge of the ciphertext in the
+// domain of the voter.
+func verifyPoK(ciphertext ElGamalCiphertext, proof sigma.Proof, d []byte, pp PublicParameters) bool {
+	if ciphertext.U == nil || ciphertext.V == nil {
+		return false
+	}
+	return proof.Verify(ballotRelation(ciphertext, pp), sigma.HashOracle(string(d)))
+}
@@ -1,7 +1,6 @@
-package main
+package ballot
 
 import (
-	"crypto/rand"
 	"github.com/takakv/msc-poc/group"
 	"math/big"
 )
@@ -11,14 +10,13 @@ type ElGamalCiphertext struct {
 	V group.Element `json:"v"`
 }
 
-func encryptVote(choice uint16, egPK group.Element, FFG group.Group) (ElGamalCiphertext, *big.Int) {
-	var tmp big.Int
+func encryptVote(choice *big.Int, egPK group.Element, FFG group.Group) (ElGamalCiphertext, group.Scalar) {
+	rnd := FFG.RandomScalar()
+	for rnd.IsZero() {
+		rnd = FFG.RandomScalar()
+	}
 
-	rnd, _ := rand.Int(rand.Reader, tmp.Sub(egPK.GroupOrder(), big.NewInt(1)))
-	rnd.Add(rnd, big.NewInt(1))
-
-	cBig := big.NewInt(int64(choice))
-	liftedMessage := FFG.Element().BaseScale(cBig)
+	liftedMessage := FFG.Element().BaseScale(FFG.NewScalar().SetBigInt(choice))
 	mask := FFG.Element().Scale(egPK, rnd)
 
 	var ciphertext ElGamalCiphertext
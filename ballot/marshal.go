@@ -0,0 +1,91 @@
+package ballot
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/takakv/msc-poc/bulletproofs"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/sigma"
+	"github.com/takakv/msc-poc/util"
+	"github.com/takakv/msc-poc/voteproof"
+)
+
+type elGamalCiphertextJSON struct {
+	U json.RawMessage `json:"u"`
+	V json.RawMessage `json:"v"`
+}
+
+type ballotDataJSON struct {
+	VoterID   string          `json:"voterId"`
+	Ballot    json.RawMessage `json:"ballot"`
+	PoK       json.RawMessage `json:"pok"`
+	BpLower   json.RawMessage `json:"lbProof"`
+	BpUpper   json.RawMessage `json:"ubProof"`
+	VoteProof json.RawMessage `json:"voteProof,omitempty"`
+}
+
+func BallotUnmarshalJSON(b []byte, g group.Group) (ElGamalCiphertext, error) {
+	tmp := elGamalCiphertextJSON{}
+	err := util.UnmarshalStrict(b, &tmp)
+	if err != nil {
+		return ElGamalCiphertext{}, err
+	}
+
+	var d util.FieldDecoder
+	ballot := ElGamalCiphertext{
+		U: d.Element("u", tmp.U, g),
+		V: d.Element("v", tmp.V, g),
+	}
+	if err = d.Err(); err != nil {
+		return ElGamalCiphertext{}, err
+	}
+	return ballot, nil
+}
+
+// BallotDataUnmarshalJSON recovers a ballot and its proofs from their JSON
+// representation. The parameters embedded in the proofs must match pp.
+func BallotDataUnmarshalJSON(b []byte, pp PublicParameters) (BallotData, error) {
+	tmp := ballotDataJSON{}
+	err := util.UnmarshalStrict(b, &tmp)
+	if err != nil {
+		return BallotData{}, err
+	}
+
+	bd := BallotData{VoterID: tmp.VoterID}
+	var d util.FieldDecoder
+	d.Object("ballot", func() (err error) {
+		bd.Ballot, err = BallotUnmarshalJSON(tmp.Ballot, pp.ElGamalParams().I)
+		return err
+	})
+	d.Object("pok", func() (err error) {
+		bd.PoK, err = sigma.UnmarshalJSON(ballotRelation(bd.Ballot, pp), tmp.PoK)
+		return err
+	})
+	d.Object("lbProof", func() (err error) {
+		bd.BpLower, err = bulletproofs.BulletProofUnmarshalJSON(tmp.BpLower, pp.BPParams)
+		return err
+	})
+	d.Object("ubProof", func() (err error) {
+		bd.BpUpper, err = bulletproofs.BulletProofUnmarshalJSON(tmp.BpUpper, pp.BPParams)
+		return err
+	})
+	d.Object("voteProof", func() error {
+		if pp.Mode == SingleGroup {
+			if tmp.VoteProof != nil {
+				return errors.New("single-group ballots have no vote proof")
+			}
+			return nil
+		}
+		proof, err := voteproof.ProofUnmarshalJSON(tmp.VoteProof)
+		if err == nil && !proof.Params.IsEqual(&pp.RPParams) {
+			err = util.WrapPath("Params", errors.New("parameters do not match the election"))
+		}
+		bd.VoteProof = &proof
+		return err
+	})
+	if err = d.Err(); err != nil {
+		return BallotData{}, err
+	}
+
+	return bd, nil
+}
@@ -0,0 +1,129 @@
+package ballot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/takakv/msc-poc/bulletproofs"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/voteproof"
+	"math/big"
+)
+
+// Operation is a step of casting, verifying or encoding a ballot.
+type Operation struct {
+	// Name identifies the step, e.g. "prove/lower".
+	Name string
+	// Run performs the step once.
+	Run func() error
+}
+
+// benchmarkVoter is the voter who casts the ballot of Operations.
+const benchmarkVoter = "benchmark"
+
+// Operations casts a ballot for pp, and returns each step of casting and
+// verifying it, and of encoding it, for benchmarking. Every run of a step
+// repeats it on the same inputs. In SingleGroup mode, there are no steps
+// of the vote proof.
+func Operations(pp PublicParameters) ([]Operation, error) {
+	choice := RandomChoice(pp)
+	bd, secrets, err := Cast(choice, benchmarkVoter, pp)
+	if err != nil {
+		return nil, err
+	}
+	d := domain(pp.ElectionID, benchmarkVoter)
+	jsonBallot, err := json.Marshal(bd)
+	if err != nil {
+		return nil, err
+	}
+	binaryBallot, err := bd.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	lower := new(big.Int).Sub(choice, pp.CandidateMin)
+	upper := new(big.Int).Sub(pp.CandidateMax, choice)
+	single := pp.Mode == SingleGroup
+	rangeProof := func(secret *big.Int, gamma group.Scalar) error {
+		if single {
+			_, err := bulletproofs.ProveBlindedInDomain(secret, gamma, pp.BPParams, d)
+			return err
+		}
+		_, _, err := bulletproofs.ProveInDomain(secret, pp.BPParams, d)
+		return err
+	}
+
+	check := func(ok bool, _ error) error {
+		if !ok {
+			return errors.New("proof does not verify")
+		}
+		return nil
+	}
+
+	ops := []Operation{
+		{"encrypt", func() error {
+			encryptVote(choice, pp.EGPK, pp.ElGamalParams().I)
+			return nil
+		}},
+		{"prove/pok", func() error {
+			_, err := provePoK(bd.Ballot, choice, secrets.R, d, pp)
+			return err
+		}},
+		{"prove/lower", func() error {
+			return rangeProof(lower, secrets.Rq1)
+		}},
+		{"prove/upper", func() error {
+			return rangeProof(upper, secrets.Rq2)
+		}},
+	}
+	if !single {
+		rq2inv := pp.ECGroupParams.I.NewScalar().Negate(secrets.Rq2)
+		ops = append(ops, Operation{"prove/vote", func() error {
+			_, err := voteproof.ProveInDomain(context.Background(), d, choice, secrets.R, secrets.Rq1, rq2inv,
+				pp.RPParams, nil)
+			return err
+		}})
+	}
+	ops = append(ops, []Operation{
+		{"cast", func() error {
+			_, _, err := Cast(choice, benchmarkVoter, pp)
+			return err
+		}},
+		{"verify/pok", func() error {
+			return check(verifyPoK(bd.Ballot, bd.PoK, d, pp), nil)
+		}},
+		{"verify/lower", func() error {
+			return check(bd.BpLower.VerifyInDomain(d))
+		}},
+		{"verify/upper", func() error {
+			return check(bd.BpUpper.VerifyInDomain(d))
+		}},
+	}...)
+	if !single {
+		ops = append(ops, Operation{"verify/vote", func() error {
+			return check(bd.VoteProof.VerifyInDomain(voteProofCommitments(bd, pp.RPParams), d), nil)
+		}})
+	}
+	return append(ops, []Operation{
+		{"verify", func() error {
+			_, err := Verify(bd, pp)
+			return err
+		}},
+		{"marshal/json", func() error {
+			_, err := json.Marshal(bd)
+			return err
+		}},
+		{"unmarshal/json", func() error {
+			_, err := BallotDataUnmarshalJSON(jsonBallot, pp)
+			return err
+		}},
+		{"marshal/binary", func() error {
+			_, err := bd.MarshalBinary()
+			return err
+		}},
+		{"unmarshal/binary", func() error {
+			_, err := BallotDataUnmarshalBinary(binaryBallot, pp)
+			return err
+		}},
+	}...), nil
+}
@@ -0,0 +1,316 @@
+// Package ballot implements the casting and verification of encrypted
+// ballots whose correctness is proven with range proofs.
+package ballot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/takakv/msc-poc/bulletproofs"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/util"
+	"github.com/takakv/msc-poc/voteproof"
+	"math/big"
+)
+
+// Mode is the profile of the ballots of an election.
+type Mode string
+
+const (
+	// CrossGroup ballots are encrypted in a finite field group, and a vote
+	// proof shows that the range proofs on the curve are for the plaintext.
+	CrossGroup Mode = "cross-group"
+	// SingleGroup ballots are encrypted on the curve of the range proofs,
+	// whose blinding generator is the ElGamal public key. The commitments
+	// of the range proofs are then derived from the ciphertext, and a proof
+	// of knowledge of the plaintext links them to its first component.
+	// The range proofs bind the ciphertext since their challenges hash V.
+	SingleGroup Mode = "single-group"
+)
+
+// PublicParameters holds the parameters of an election.
+type PublicParameters struct {
+	// Identifier of the election, to which the proofs of its ballots are
+	// bound. Setup leaves it empty.
+	ElectionID string
+	// Profile of the ballots. The zero value stands for CrossGroup.
+	Mode Mode
+	// Parameters of the Finite Field ElGamal group, in CrossGroup mode.
+	FFGroupParams voteproof.GroupParameters
+	// Parameters of the Elliptic Curve Bulletproofs group.
+	ECGroupParams voteproof.GroupParameters
+	// ElGamal public key, in the curve group in SingleGroup mode.
+	EGPK group.Element
+	// Lowest candidate number.
+	CandidateMin *big.Int
+	// Highest candidate number.
+	CandidateMax *big.Int
+	// Public parameters of Bulletproofs.
+	BPParams bulletproofs.BulletProofSetupParams
+	// Public parameters of the range proof protocol, in CrossGroup mode.
+	RPParams voteproof.ProofParams
+}
+
+type publicParametersJSON struct {
+	ElectionID string          `json:"electionId"`
+	Mode       Mode            `json:"mode,omitempty"`
+	BPParams   json.RawMessage `json:"bpParams"`
+	RPParams   json.RawMessage `json:"rpParams,omitempty"`
+	// The candidate range is only encoded in SingleGroup mode, since it is
+	// part of the vote proof parameters otherwise.
+	CandidateMin *big.Int `json:"candidateMin,omitempty"`
+	CandidateMax *big.Int `json:"candidateMax,omitempty"`
+}
+
+// ElGamalParams returns the parameters of the group in which the ballots
+// are encrypted, whose H is the ElGamal public key.
+func (pp *PublicParameters) ElGamalParams() voteproof.GroupParameters {
+	if pp.Mode == SingleGroup {
+		return pp.ECGroupParams
+	}
+	return pp.FFGroupParams
+}
+
+// DefaultRequirements are the requirements of the vote proof parameters of
+// Setup and SetupRange. In practice, since the proof is made
+// non-interactive with FS, the challenge should be 256 bits long for 128
+// bits of collision resistance, but 224 bits leave room for 16-bit choices
+// in 256-bit groups.
+var DefaultRequirements = voteproof.Requirements{Security: 128, ChallengeLen: 224}
+
+const (
+	// The first candidate number is fixed at 101.
+	candidateStart = 101
+	// The last candidate number varies depending on the election. The
+	// largest number of candidates so far in any Estonian election has been
+	// 15322. However, this does not reflect the highest candidate number
+	// available in any single electoral district. The largest number of
+	// candidates unified across an electoral district has been 1885.
+	candidateEnd = 2000
+)
+
+// W.l.o.g. this secret is not known to any one party.
+var elGamalPrivateKey = big.NewInt(13)
+
+// Setup generates the public parameters of an election whose range proofs
+// are computed in curveGroup.
+func Setup(curveGroup group.Group) (PublicParameters, error) {
+	return SetupFor(curveGroup, DefaultRequirements)
+}
+
+// SetupFor is Setup with vote proof parameters planned for req.
+func SetupFor(curveGroup group.Group, req voteproof.Requirements) (PublicParameters, error) {
+	return SetupRangeFor(curveGroup, big.NewInt(candidateStart), big.NewInt(candidateEnd), req)
+}
+
+// SetupRange generates the public parameters of an election whose choices
+// are the integers in [lo, hi], and whose range proofs are computed in
+// curveGroup. Choices are proven to be at most as long as the smallest
+// power of 2 that fits hi, which must leave room in the group order for
+// the challenge and the abort parameter.
+func SetupRange(curveGroup group.Group, lo, hi *big.Int) (PublicParameters, error) {
+	return SetupRangeFor(curveGroup, lo, hi, DefaultRequirements)
+}
+
+// SetupRangeFor is SetupRange with vote proof parameters planned for req.
+func SetupRangeFor(curveGroup group.Group, lo, hi *big.Int, req voteproof.Requirements) (PublicParameters, error) {
+	choiceLength, err := choiceLength(lo, hi)
+	if err != nil {
+		return PublicParameters{}, err
+	}
+
+	bpParams, err := bulletproofs.SetupBits(int64(choiceLength), curveGroup)
+	if err != nil {
+		return PublicParameters{}, err
+	}
+
+	var fieldGroupParams voteproof.GroupParameters
+	fieldGroupParams.I = group.ModPGroup3072q256()
+	fieldGroupParams.F = fieldGroupParams.I.P()
+	fieldGroupParams.N = fieldGroupParams.I.N()
+	fieldGroupParams.G = fieldGroupParams.I.Generator()
+	// The public key is used in every encryption, so precompute its multiples.
+	fieldGroupParams.H = group.Precompute(fieldGroupParams.I.Element().BaseScale(fieldGroupParams.I.NewScalar().SetBigInt(elGamalPrivateKey)))
+
+	var curveGroupParams voteproof.GroupParameters
+	curveGroupParams.I = curveGroup
+	curveGroupParams.F = curveGroupParams.I.P()
+	curveGroupParams.N = curveGroupParams.I.N()
+	curveGroupParams.G = curveGroupParams.I.Generator()
+	curveGroupParams.H = bpParams.H
+
+	var algebraicParams voteproof.AlgebraicParameters
+	algebraicParams.GFF = fieldGroupParams
+	algebraicParams.GEC = curveGroupParams
+
+	rpParams, err := voteproof.Plan(req, uint16(choiceLength), lo, hi, algebraicParams)
+	if err != nil {
+		return PublicParameters{}, fmt.Errorf("choices of %d bits in %s: %w", choiceLength, curveGroup.Name(), err)
+	}
+
+	return newPublicParameters(bpParams, rpParams), nil
+}
+
+// choiceLength returns the bit-length of the choices in [lo, hi]. Both the
+// choice and the distances to the range bounds fit in it, and it is a
+// power of 2 for Bulletproofs. For the Estonian candidate numbers, it is 16.
+func choiceLength(lo, hi *big.Int) (int, error) {
+	if lo.Sign() < 0 || lo.Cmp(hi) > 0 {
+		return 0, fmt.Errorf("invalid candidate range [%d, %d]", lo, hi)
+	}
+	n := 1
+	for n < hi.BitLen() {
+		n *= 2
+	}
+	return n, nil
+}
+
+// SetupSingleGroup generates the public parameters of a SingleGroup
+// election whose ballots are encrypted in curveGroup.
+func SetupSingleGroup(curveGroup group.Group) (PublicParameters, error) {
+	return SetupSingleGroupRange(curveGroup, big.NewInt(candidateStart), big.NewInt(candidateEnd))
+}
+
+// SetupSingleGroupRange is SetupRange for a SingleGroup election. Without
+// a vote proof, the group order only needs to exceed the range.
+func SetupSingleGroupRange(curveGroup group.Group, lo, hi *big.Int) (PublicParameters, error) {
+	choiceLength, err := choiceLength(lo, hi)
+	if err != nil {
+		return PublicParameters{}, err
+	}
+	pk := curveGroup.Element().BaseScale(curveGroup.NewScalar().SetBigInt(elGamalPrivateKey))
+	bpParams, err := bulletproofs.SetupBitsWithBlinding(int64(choiceLength), curveGroup, pk)
+	if err != nil {
+		return PublicParameters{}, err
+	}
+	return newSingleGroupParameters(bpParams, lo, hi), nil
+}
+
+// newPublicParameters derives the remaining parameters from those of the
+// proof systems.
+func newPublicParameters(bpParams bulletproofs.BulletProofSetupParams, rpParams voteproof.ProofParams) PublicParameters {
+	var pp PublicParameters
+	pp.Mode = CrossGroup
+	pp.FFGroupParams = rpParams.GFF
+	pp.ECGroupParams = rpParams.GEC
+	pp.EGPK = pp.FFGroupParams.H
+	pp.CandidateMin = rpParams.RangeLo
+	pp.CandidateMax = rpParams.RangeHi
+	pp.BPParams = bpParams
+	pp.RPParams = rpParams
+	return pp
+}
+
+// newSingleGroupParameters derives the parameters of a SingleGroup
+// election from those of Bulletproofs, whose blinding generator is the
+// ElGamal public key.
+func newSingleGroupParameters(bpParams bulletproofs.BulletProofSetupParams, lo, hi *big.Int) PublicParameters {
+	var pp PublicParameters
+	pp.Mode = SingleGroup
+	pp.ECGroupParams = voteproof.GroupParameters{
+		G: bpParams.G,
+		H: bpParams.H,
+		N: bpParams.GP.N(),
+		F: bpParams.GP.P(),
+		I: bpParams.GP,
+	}
+	pp.EGPK = bpParams.H
+	pp.CandidateMin = new(big.Int).Set(lo)
+	pp.CandidateMax = new(big.Int).Set(hi)
+	pp.BPParams = bpParams
+	return pp
+}
+
+// MarshalJSON encodes the parameters of both proof systems, from which
+// the others are derived. In SingleGroup mode, it encodes the parameters of
+// Bulletproofs and the candidate range.
+func (pp PublicParameters) MarshalJSON() ([]byte, error) {
+	bp, err := json.Marshal(pp.BPParams)
+	if err != nil {
+		return nil, err
+	}
+	if pp.Mode == SingleGroup {
+		return json.Marshal(publicParametersJSON{ElectionID: pp.ElectionID, Mode: pp.Mode, BPParams: bp,
+			CandidateMin: pp.CandidateMin, CandidateMax: pp.CandidateMax})
+	}
+	rp, err := json.Marshal(pp.RPParams)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(publicParametersJSON{ElectionID: pp.ElectionID, Mode: CrossGroup, BPParams: bp, RPParams: rp})
+}
+
+// ParamsUnmarshalJSON recovers the public parameters from their JSON
+// representation, and checks that the parameters of both proof systems
+// fit together.
+func ParamsUnmarshalJSON(b []byte) (PublicParameters, error) {
+	var tmp publicParametersJSON
+	if err := util.UnmarshalStrict(b, &tmp); err != nil {
+		return PublicParameters{}, err
+	}
+
+	switch tmp.Mode {
+	case "", CrossGroup:
+	case SingleGroup:
+		return singleGroupParamsFromJSON(tmp)
+	default:
+		return PublicParameters{}, util.WrapPath("mode", fmt.Errorf("unknown mode %q", tmp.Mode))
+	}
+	if tmp.CandidateMin != nil || tmp.CandidateMax != nil {
+		return PublicParameters{}, util.WrapPath("candidateMin", errors.New("range is part of rpParams"))
+	}
+
+	rpParams, err := voteproof.ParamsUnmarshalJSON(tmp.RPParams)
+	if err != nil {
+		return PublicParameters{}, util.WrapPath("rpParams", err)
+	}
+
+	// The range proofs must cover the distance between the range bounds,
+	// so they are for choices of the bit-length of the candidates.
+	n, err := choiceLength(rpParams.RangeLo, rpParams.RangeHi)
+	if err != nil {
+		return PublicParameters{}, util.WrapPath("rpParams", err)
+	}
+	bpParams, err := bulletproofs.SetupParamsUnmarshalJSONBits(tmp.BPParams, int64(n))
+	if err != nil {
+		return PublicParameters{}, util.WrapPath("bpParams", err)
+	}
+
+	if rpParams.GEC.I.Name() != bpParams.GP.Name() || !rpParams.GEC.H.IsEqual(bpParams.H) {
+		return PublicParameters{}, util.WrapPath("rpParams.GEC", errors.New("does not match the Bulletproofs parameters"))
+	}
+
+	pp := newPublicParameters(bpParams, rpParams)
+	pp.ElectionID = tmp.ElectionID
+	return pp, nil
+}
+
+// singleGroupParamsFromJSON recovers the parameters of a SingleGroup
+// election, whose ElGamal public key is the blinding generator of
+// Bulletproofs.
+func singleGroupParamsFromJSON(tmp publicParametersJSON) (PublicParameters, error) {
+	if tmp.RPParams != nil {
+		return PublicParameters{}, util.WrapPath("rpParams", errors.New("single-group elections have no vote proof"))
+	}
+	lo, hi := tmp.CandidateMin, tmp.CandidateMax
+	if lo == nil || lo.Sign() < 0 {
+		return PublicParameters{}, util.WrapPath("candidateMin", errors.New("missing or negative range bound"))
+	}
+	if hi == nil || lo.Cmp(hi) > 0 {
+		return PublicParameters{}, util.WrapPath("candidateMax", errors.New("missing bound or empty range"))
+	}
+	// The choices, and thus the distances to the range bounds, must fit in
+	// the range proofs.
+	n, err := choiceLength(lo, hi)
+	if err != nil {
+		return PublicParameters{}, err
+	}
+	bpParams, err := bulletproofs.BlindedSetupParamsUnmarshalJSONBits(tmp.BPParams, int64(n))
+	if err != nil {
+		return PublicParameters{}, util.WrapPath("bpParams", err)
+	}
+
+	pp := newSingleGroupParameters(bpParams, lo, hi)
+	pp.ElectionID = tmp.ElectionID
+	return pp, nil
+}
@@ -0,0 +1,186 @@
+package ballot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/takakv/msc-poc/bulletproofs"
+	"github.com/takakv/msc-poc/voteproof"
+	"math/big"
+	"os"
+	"testing"
+)
+
+// castUnchecked is Cast without the range check, so that a dishonest voter
+// can be simulated. One of the Bulletproofs of an out-of-range choice
+// commits to a negative value, and does not verify.
+func castUnchecked(choice *big.Int, pp PublicParameters) (BallotData, error) {
+	d := domain(pp.ElectionID, testVoter)
+	ffg := pp.FFGroupParams.I
+	rp := ffg.RandomScalar()
+	ciphertext := ElGamalCiphertext{
+		U: ffg.Element().BaseScale(rp),
+		V: ffg.Element().Add(
+			ffg.Element().BaseScale(ffg.NewScalar().SetBigInt(choice)),
+			ffg.Element().Scale(pp.EGPK, rp)),
+	}
+
+	pok, err := provePoK(ciphertext, choice, rp, d, pp)
+	if err != nil {
+		return BallotData{}, err
+	}
+
+	bp1, rq1, err := bulletproofs.ProveInDomain(new(big.Int).Sub(choice, pp.CandidateMin), pp.BPParams, d)
+	if err != nil {
+		return BallotData{}, err
+	}
+	bp2, rq2, err := bulletproofs.ProveInDomain(new(big.Int).Sub(pp.CandidateMax, choice), pp.BPParams, d)
+	if err != nil {
+		return BallotData{}, err
+	}
+	rq2inv := pp.ECGroupParams.I.NewScalar().Negate(rq2)
+	proof, err := voteproof.ProveInDomain(context.Background(), d, choice, rp, rq1, rq2inv, pp.RPParams, nil)
+	if err != nil {
+		return BallotData{}, err
+	}
+
+	return BallotData{VoterID: testVoter, Ballot: ciphertext, PoK: pok, BpLower: bp1, BpUpper: bp2,
+		VoteProof: &proof}, nil
+}
+
+// addToNumber adds delta to the JSON number at the given path of m.
+func addToNumber(t *testing.T, m map[string]any, delta *big.Int, path ...string) {
+	t.Helper()
+	for _, k := range path[:len(path)-1] {
+		m = m[k].(map[string]any)
+	}
+	k := path[len(path)-1]
+	x, ok := new(big.Int).SetString(m[k].(json.Number).String(), 10)
+	if !ok {
+		t.Fatalf("%v is not an integer", path)
+	}
+	m[k] = json.Number(x.Add(x, delta).String())
+}
+
+// setNumber replaces the JSON number at the given path of m.
+func setNumber(m map[string]any, x *big.Int, path ...string) {
+	for _, k := range path[:len(path)-1] {
+		m = m[k].(map[string]any)
+	}
+	m[path[len(path)-1]] = json.Number(x.String())
+}
+
+// TestTamperRejection mutates the fields of valid ballots, and checks that
+// the results are rejected, either when decoding or when verifying them.
+func TestTamperRejection(t *testing.T) {
+	one := big.NewInt(1)
+	minusOne := big.NewInt(-1)
+
+	for _, g := range curveGroups {
+		pp, err := Setup(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(fixturePath(g))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = unmarshalAndVerify(data, pp); err != nil {
+			t.Fatalf("%s: fixture is invalid: %v", g.Name(), err)
+		}
+
+		// Another valid ballot, to transplant proofs from.
+		other, _, err := Cast(RandomChoice(pp), testVoter, pp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherData, _ := json.Marshal(other)
+		var otherJSON map[string]any
+		mutateJSON(t, otherData, func(m map[string]any) { otherJSON = m })
+
+		zBound := new(big.Int).Lsh(one, uint(pp.RPParams.Bx)+uint(pp.RPParams.Bc)+uint(pp.RPParams.Bb))
+
+		mutations := []struct {
+			name   string
+			mutate func(m map[string]any)
+		}{
+			{"swapped U and V", func(m map[string]any) {
+				b := m["ballot"].(map[string]any)
+				b["u"], b["v"] = b["v"], b["u"]
+			}},
+			{"altered U", func(m map[string]any) {
+				addToNumber(t, m, one, "ballot", "u")
+			}},
+			{"altered challenge", func(m map[string]any) {
+				addToNumber(t, m, one, "voteProof", "Challenge")
+			}},
+			{"altered Z", func(m map[string]any) {
+				addToNumber(t, m, one, "voteProof", "Z")
+			}},
+			{"negative Z", func(m map[string]any) {
+				setNumber(m, minusOne, "voteProof", "Z")
+			}},
+			{"Z out of range", func(m map[string]any) {
+				setNumber(m, zBound, "voteProof", "Z")
+			}},
+			{"challenge out of range", func(m map[string]any) {
+				setNumber(m, new(big.Int).Lsh(one, uint(pp.RPParams.Bc)), "voteProof", "Challenge")
+			}},
+			{"altered Taux", func(m map[string]any) {
+				addToNumber(t, m, one, "lbProof", "Taux")
+			}},
+			{"swapped lower and upper proofs", func(m map[string]any) {
+				m["lbProof"], m["ubProof"] = m["ubProof"], m["lbProof"]
+			}},
+			{"transplanted vote proof", func(m map[string]any) {
+				m["voteProof"] = otherJSON["voteProof"]
+			}},
+			{"transplanted lower proof", func(m map[string]any) {
+				m["lbProof"] = otherJSON["lbProof"]
+			}},
+			{"transplanted upper proof", func(m map[string]any) {
+				m["ubProof"] = otherJSON["ubProof"]
+			}},
+			{"transplanted ciphertext", func(m map[string]any) {
+				m["ballot"] = otherJSON["ballot"]
+			}},
+			{"transplanted ciphertext and proof of knowledge", func(m map[string]any) {
+				m["ballot"], m["pok"] = otherJSON["ballot"], otherJSON["pok"]
+			}},
+			{"transplanted proof of knowledge", func(m map[string]any) {
+				m["pok"] = otherJSON["pok"]
+			}},
+			{"altered voter", func(m map[string]any) {
+				m["voterId"] = "mallory"
+			}},
+			{"lowered RangeLo", func(m map[string]any) {
+				addToNumber(t, m, minusOne, "voteProof", "Params", "RangeLo")
+			}},
+			{"raised RangeHi", func(m map[string]any) {
+				addToNumber(t, m, one, "voteProof", "Params", "RangeHi")
+			}},
+			{"swapped RangeLo and RangeHi", func(m map[string]any) {
+				p := m["voteProof"].(map[string]any)["Params"].(map[string]any)
+				p["RangeLo"], p["RangeHi"] = p["RangeHi"], p["RangeLo"]
+			}},
+		}
+
+		for _, tt := range mutations {
+			t.Run(g.Name()+"/"+tt.name, func(t *testing.T) {
+				tampered := mutateJSON(t, data, tt.mutate)
+				if unmarshalAndVerify(tampered, pp) == nil {
+					t.Error("tampered ballot was accepted")
+				}
+			})
+		}
+
+		for _, choice := range []*big.Int{new(big.Int).Sub(pp.CandidateMin, one), new(big.Int).Add(pp.CandidateMax, one)} {
+			vote, err := castUnchecked(choice, pp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = Verify(vote, pp); err == nil {
+				t.Errorf("%s: ballot for candidate %d was accepted", g.Name(), choice)
+			}
+		}
+	}
+}
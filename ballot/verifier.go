@@ -0,0 +1,127 @@
+package ballot
+
+import (
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/voteproof"
+	"time"
+)
+
+var (
+	ErrPoK        = errors.New("invalid proof of knowledge of the encryption randomness")
+	ErrLowerBound = errors.New("invalid lower bound proof")
+	ErrUpperBound = errors.New("invalid upper bound proof")
+	ErrVoteProof  = errors.New("invalid vote correctness proof")
+)
+
+// Timings records how long the verification of each proof took.
+type Timings struct {
+	PoK       time.Duration
+	BpLower   time.Duration
+	BpUpper   time.Duration
+	VoteProof time.Duration
+}
+
+// Bulletproofs returns the time spent verifying both Bulletproofs.
+func (t Timings) Bulletproofs() time.Duration {
+	return t.BpLower + t.BpUpper
+}
+
+// Total returns the time spent verifying all proofs.
+func (t Timings) Total() time.Duration {
+	return t.PoK + t.BpLower + t.BpUpper + t.VoteProof
+}
+
+// Verify checks all proofs of a ballot in the domain of its voter, and
+// returns nil if the ballot is valid. Verification stops at the first
+// invalid proof. In SingleGroup mode, the range proofs must be for the
+// commitments derived from the ciphertext, and there is no vote proof.
+func Verify(proofs BallotData, pp PublicParameters) (Timings, error) {
+	var timings Timings
+	d := domain(pp.ElectionID, proofs.VoterID)
+
+	start := time.Now()
+	// Verify the knowledge of the encryption randomness.
+	ok := verifyPoK(proofs.Ballot, proofs.PoK, d, pp)
+	timings.PoK = time.Since(start)
+	if !ok {
+		return timings, ErrPoK
+	}
+
+	single := pp.Mode == SingleGroup
+	start = time.Now()
+	// Verify the vote lower bound.
+	ok, _ = proofs.BpLower.VerifyInDomain(d)
+	if ok && single {
+		ok = proofs.BpLower.V.IsEqual(lowerCommitment(proofs.Ballot, pp))
+	}
+	timings.BpLower = time.Since(start)
+	if !ok {
+		return timings, ErrLowerBound
+	}
+
+	start = time.Now()
+	// Verify the vote upper bound.
+	ok, _ = proofs.BpUpper.VerifyInDomain(d)
+	if ok && single {
+		ok = proofs.BpUpper.V.IsEqual(upperCommitment(proofs.Ballot, pp))
+	}
+	timings.BpUpper = time.Since(start)
+	if !ok {
+		return timings, ErrUpperBound
+	}
+
+	if single {
+		return timings, nil
+	}
+	if proofs.VoteProof == nil {
+		return timings, ErrVoteProof
+	}
+	start = time.Now()
+	commitments := voteProofCommitments(proofs, pp.RPParams)
+
+	// Verify the consistency of the shifted commitments with the ElGamal ciphertext.
+	ok = proofs.VoteProof.VerifyInDomain(commitments, d)
+	timings.VoteProof = time.Since(start)
+	if !ok {
+		return timings, ErrVoteProof
+	}
+
+	return timings, nil
+}
+
+// lowerCommitment returns the commitment V - min*G of the lower bound
+// proof of a SingleGroup ballot.
+func lowerCommitment(ciphertext ElGamalCiphertext, pp PublicParameters) group.Element {
+	g := pp.ECGroupParams.I
+	shift := g.Element().BaseScale(g.NewScalar().SetBigInt(pp.CandidateMin))
+	return g.Element().Subtract(ciphertext.V, shift)
+}
+
+// upperCommitment returns the commitment max*G - V of the upper bound
+// proof of a SingleGroup ballot.
+func upperCommitment(ciphertext ElGamalCiphertext, pp PublicParameters) group.Element {
+	g := pp.ECGroupParams.I
+	shift := g.Element().BaseScale(g.NewScalar().SetBigInt(pp.CandidateMax))
+	return g.Element().Subtract(shift, ciphertext.V)
+}
+
+// voteProofCommitments shifts the Bulletproofs commitments back to the
+// secret, and pairs them with the ciphertext.
+func voteProofCommitments(proofs BallotData, rpParams voteproof.ProofParams) voteproof.VerCommitments {
+	// Shift back lower bound.
+	loShift := rpParams.GEC.I.Element().BaseScale(rpParams.GEC.I.NewScalar().SetBigInt(rpParams.RangeLo))
+	Xq1 := rpParams.GEC.I.Element().Add(loShift, proofs.BpLower.V)
+
+	// Shift back upper bound.
+	upShift := rpParams.GEC.I.Element().BaseScale(rpParams.GEC.I.NewScalar().SetBigInt(rpParams.RangeHi))
+	inv := rpParams.GEC.I.Element().Negate(proofs.BpUpper.V)
+	Xq2 := rpParams.GEC.I.Element().Add(upShift, inv)
+
+	return voteproof.VerCommitments{
+		Y:   proofs.Ballot.U, // First component of the ElGamal ciphertext
+		Xp:  proofs.Ballot.V, // Second component of the ElGamal ciphertext
+		Xq1: Xq1,             // Lower bound shifted back to the secret
+		Xq2: Xq2,             // Upper bound shifted back to the secret
+	}
+}
@@ -0,0 +1,175 @@
+package ballot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/takakv/msc-poc/bulletproofs"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/sigma"
+	"github.com/takakv/msc-poc/util"
+	"github.com/takakv/msc-poc/voteproof"
+	"math/big"
+)
+
+// BallotData contains elements that assert the correctness of a vote. All
+// proofs are bound to the voter and to the election, and the collector must
+// check that VoterID is the authenticated identity of the submitter, e.g.
+// with the Authenticate hook of collector.Server.
+type BallotData struct {
+	VoterID   string                   `json:"voterId"`             // Identity of the voter who cast the ballot.
+	Ballot    ElGamalCiphertext        `json:"ballot"`              // The ElGamal ciphertext, i.e. the encrypted ballot.
+	PoK       sigma.Proof              `json:"pok"`                 // Proof of knowledge of the encryption randomness.
+	BpLower   bulletproofs.BulletProof `json:"lbProof"`             // Bulletproof for the lower bound.
+	BpUpper   bulletproofs.BulletProof `json:"ubProof"`             // Bulletproof for the upper bound.
+	VoteProof *voteproof.SigmaProof    `json:"voteProof,omitempty"` // Proof of vote correctness, nil in SingleGroup mode.
+}
+
+// Secrets holds the choice and the randomness of a ballot. They allow the
+// voter to prove the ballot's content, and must not be published.
+type Secrets struct {
+	Choice *big.Int     `json:"choice"`
+	R      group.Scalar `json:"r"`   // ElGamal encryption randomness.
+	Rq1    group.Scalar `json:"rq1"` // Blinding factor of the lower bound commitment.
+	Rq2    group.Scalar `json:"rq2"` // Blinding factor of the upper bound commitment.
+}
+
+type secretsJSON struct {
+	Choice *big.Int        `json:"choice"`
+	R      json.RawMessage `json:"r"`
+	Rq1    json.RawMessage `json:"rq1"`
+	Rq2    json.RawMessage `json:"rq2"`
+}
+
+// SecretsUnmarshalJSON recovers the secrets of a ballot from their JSON
+// representation.
+func SecretsUnmarshalJSON(b []byte, pp PublicParameters) (Secrets, error) {
+	var tmp secretsJSON
+	if err := util.UnmarshalStrict(b, &tmp); err != nil {
+		return Secrets{}, err
+	}
+
+	var d util.FieldDecoder
+	if !inRange(tmp.Choice, pp) {
+		d.Fail("choice", errors.New("choice is not a candidate number"))
+	}
+	s := Secrets{
+		Choice: tmp.Choice,
+		R:      d.Scalar("r", tmp.R, pp.ElGamalParams().I),
+		Rq1:    d.Scalar("rq1", tmp.Rq1, pp.ECGroupParams.I),
+		Rq2:    d.Scalar("rq2", tmp.Rq2, pp.ECGroupParams.I),
+	}
+	if err := d.Err(); err != nil {
+		return Secrets{}, err
+	}
+	return s, nil
+}
+
+// inRange reports whether choice is a candidate number.
+func inRange(choice *big.Int, pp PublicParameters) bool {
+	return choice.Cmp(pp.CandidateMin) >= 0 && choice.Cmp(pp.CandidateMax) <= 0
+}
+
+// Cast encrypts the choice of the voter, and proves that it is a valid
+// candidate number.
+func Cast(choice *big.Int, voterID string, pp PublicParameters) (BallotData, Secrets, error) {
+	if !inRange(choice, pp) {
+		return BallotData{}, Secrets{}, fmt.Errorf("choice %d is not in [%d, %d]",
+			choice, pp.CandidateMin, pp.CandidateMax)
+	}
+	if pp.Mode == SingleGroup {
+		return castSingleGroup(choice, voterID, pp)
+	}
+
+	ciphertext, rp := encryptVote(choice, pp.EGPK, pp.FFGroupParams.I)
+	d := domain(pp.ElectionID, voterID)
+
+	// Prove knowledge of the randomness.
+	pok, err := provePoK(ciphertext, choice, rp, d, pp)
+	if err != nil {
+		return BallotData{}, Secrets{}, err
+	}
+	// Prove the lower bound.
+	bp1, rq1, err := bulletproofs.ProveInDomain(new(big.Int).Sub(choice, pp.CandidateMin), pp.BPParams, d)
+	if err != nil {
+		return BallotData{}, Secrets{}, err
+	}
+	// Prove the upper bound.
+	bp2, rq2, err := bulletproofs.ProveInDomain(new(big.Int).Sub(pp.CandidateMax, choice), pp.BPParams, d)
+	if err != nil {
+		return BallotData{}, Secrets{}, err
+	}
+	rq2inv := pp.ECGroupParams.I.NewScalar().Negate(rq2)
+	// Prove that Bulletproofs correspond to the ciphertext.
+	rangeProof, err := voteproof.ProveInDomain(context.Background(), d, choice, rp, rq1, rq2inv, pp.RPParams, nil)
+	if err != nil {
+		return BallotData{}, Secrets{}, err
+	}
+
+	bd := BallotData{
+		VoterID:   voterID,
+		Ballot:    ciphertext,
+		PoK:       pok,
+		BpLower:   bp1,
+		BpUpper:   bp2,
+		VoteProof: &rangeProof,
+	}
+	secrets := Secrets{
+		Choice: new(big.Int).Set(choice),
+		R:      rp,
+		Rq1:    rq1,
+		Rq2:    rq2,
+	}
+
+	return bd, secrets, nil
+}
+
+// castSingleGroup encrypts the choice on the curve. The range proofs are
+// for the second component of the ciphertext, shifted by the range bounds,
+// so no vote proof is needed.
+func castSingleGroup(choice *big.Int, voterID string, pp PublicParameters) (BallotData, Secrets, error) {
+	ciphertext, r := encryptVote(choice, pp.EGPK, pp.ECGroupParams.I)
+	d := domain(pp.ElectionID, voterID)
+
+	// Prove knowledge of the plaintext, which links the ciphertext to the
+	// commitments of the range proofs.
+	pok, err := provePoK(ciphertext, choice, r, d, pp)
+	if err != nil {
+		return BallotData{}, Secrets{}, err
+	}
+	// Prove the lower bound for V - min*G = (choice-min)*G + r*PK.
+	bp1, err := bulletproofs.ProveBlindedInDomain(new(big.Int).Sub(choice, pp.CandidateMin), r, pp.BPParams, d)
+	if err != nil {
+		return BallotData{}, Secrets{}, err
+	}
+	// Prove the upper bound for max*G - V = (max-choice)*G - r*PK.
+	rinv := pp.ECGroupParams.I.NewScalar().Negate(r)
+	bp2, err := bulletproofs.ProveBlindedInDomain(new(big.Int).Sub(pp.CandidateMax, choice), rinv, pp.BPParams, d)
+	if err != nil {
+		return BallotData{}, Secrets{}, err
+	}
+
+	bd := BallotData{
+		VoterID: voterID,
+		Ballot:  ciphertext,
+		PoK:     pok,
+		BpLower: bp1,
+		BpUpper: bp2,
+	}
+	secrets := Secrets{
+		Choice: new(big.Int).Set(choice),
+		R:      r,
+		Rq1:    r,
+		Rq2:    rinv,
+	}
+	return bd, secrets, nil
+}
+
+// RandomChoice returns a uniformly random candidate number.
+func RandomChoice(pp PublicParameters) *big.Int {
+	span := new(big.Int).Sub(pp.CandidateMax, pp.CandidateMin)
+	choice, _ := rand.Int(rand.Reader, span.Add(span, big.NewInt(1)))
+	return choice.Add(choice, pp.CandidateMin)
+}
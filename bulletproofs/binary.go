@@ -0,0 +1,171 @@
+package bulletproofs
+
+import (
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"math/bits"
+
+	. "github.com/takakv/msc-poc/util"
+)
+
+// encode appends the proof data of an inner product proof.
+// The parameters are not encoded, as the verifier derives them.
+func (proof *InnerProductProof) encode(e *Encoder) {
+	e.Element(proof.P)
+	e.Scalar(proof.Cc)
+	e.Scalar(proof.A)
+	e.Scalar(proof.B)
+	e.Elements(proof.L)
+	e.Elements(proof.R)
+}
+
+func decodeInnerProduct(d *Decoder, params InnerProductParams) InnerProductProof {
+	// The argument halves the generators in every round.
+	rounds := bits.Len(uint(len(params.Gg))) - 1
+	return InnerProductProof{
+		P:      d.Element(params.GP),
+		Cc:     d.Scalar(params.GP),
+		A:      d.Scalar(params.GP),
+		B:      d.Scalar(params.GP),
+		L:      d.Elements(params.GP, rounds),
+		R:      d.Elements(params.GP, rounds),
+		Params: params,
+	}
+}
+
+// proofInnerProductParams returns the parameters of the inner product
+// argument recorded in a range proof. These are the generators of the
+// setup, since the verifier switches the generators h to h' itself.
+func proofInnerProductParams(params BulletProofSetupParams) (InnerProductParams, error) {
+	return setupInnerProduct(params.Gg, params.Hh, params.N, params.GP)
+}
+
+// MarshalBinary encodes P, the inner product c, the final scalars a and b,
+// and the vectors L and R of the folding rounds. The generators are not
+// encoded, and the number of rounds follows from them when decoding.
+func (proof InnerProductProof) MarshalBinary() ([]byte, error) {
+	e := NewEncoder(WireInnerProductProof)
+	proof.encode(e)
+	return e.Bytes()
+}
+
+// InnerProductProofUnmarshalBinary decodes a proof in the binary wire format.
+func InnerProductProofUnmarshalBinary(b []byte, params InnerProductParams) (InnerProductProof, error) {
+	if !IsPowerOfTwo(int64(len(params.Gg))) || len(params.Gg) != len(params.Hh) {
+		return InnerProductProof{}, errors.New("invalid inner product parameters")
+	}
+	d := NewDecoder(b, WireInnerProductProof)
+	proof := decodeInnerProduct(d, params)
+	if err := d.Finish(); err != nil {
+		return InnerProductProof{}, err
+	}
+	return proof, nil
+}
+
+// MarshalBinary encodes the commitment V, the commitments A, S, T1 and T2,
+// and the scalars taux, mu and t', followed by the fields of the inner
+// product argument inline rather than as a nested message. The setup
+// parameters are not encoded.
+func (proof BulletProof) MarshalBinary() ([]byte, error) {
+	e := NewEncoder(WireBulletProof)
+	e.Element(proof.V)
+	e.Element(proof.A)
+	e.Element(proof.S)
+	e.Element(proof.T1)
+	e.Element(proof.T2)
+	e.Scalar(proof.Taux)
+	e.Scalar(proof.Mu)
+	e.Scalar(proof.Tprime)
+	proof.InnerProductProof.encode(e)
+	return e.Bytes()
+}
+
+// BulletProofUnmarshalBinary decodes a proof in the binary wire format.
+func BulletProofUnmarshalBinary(b []byte, params BulletProofSetupParams) (BulletProof, error) {
+	SP := params.GP
+	d := NewDecoder(b, WireBulletProof)
+	proof := BulletProof{
+		V:      d.Element(SP),
+		A:      d.Element(SP),
+		S:      d.Element(SP),
+		T1:     d.Element(SP),
+		T2:     d.Element(SP),
+		Taux:   d.Scalar(SP),
+		Mu:     d.Scalar(SP),
+		Tprime: d.Scalar(SP),
+		Params: params,
+	}
+
+	if err := d.Err(); err != nil {
+		return BulletProof{}, err
+	}
+	ipp, err := proofInnerProductParams(params)
+	if err != nil {
+		return BulletProof{}, err
+	}
+	proof.InnerProductProof = decodeInnerProduct(d, ipp)
+	if err = d.Finish(); err != nil {
+		return BulletProof{}, err
+	}
+	return proof, nil
+}
+
+// MarshalBinary encodes the proof as BulletProof.MarshalBinary does, but
+// starts with the count-prefixed vector of commitments Vs in place of V.
+func (proof MultiBulletProof) MarshalBinary() ([]byte, error) {
+	e := NewEncoder(WireMultiBulletProof)
+	e.Elements(proof.Vs)
+	e.Element(proof.A)
+	e.Element(proof.S)
+	e.Element(proof.T1)
+	e.Element(proof.T2)
+	e.Scalar(proof.Taux)
+	e.Scalar(proof.Mu)
+	e.Scalar(proof.Tprime)
+	proof.InnerProductProof.encode(e)
+	return e.Bytes()
+}
+
+// MultiBulletProofUnmarshalBinary decodes a proof in the binary wire format.
+func MultiBulletProofUnmarshalBinary(b []byte, params BulletProofSetupParams) (MultiBulletProof, error) {
+	SP := params.GP
+	d := NewDecoder(b, WireMultiBulletProof)
+
+	// The values share the bits of the range, so their number must divide it.
+	m := d.Count()
+	if err := d.Err(); err != nil {
+		return MultiBulletProof{}, err
+	}
+	if m == 0 || params.N%int64(m) != 0 || !IsPowerOfTwo(int64(m)) {
+		return MultiBulletProof{}, errors.New("invalid number of commitments")
+	}
+	Vs := make([]group.Element, m)
+	for i := range Vs {
+		Vs[i] = d.Element(SP)
+	}
+
+	proof := MultiBulletProof{
+		Vs:     Vs,
+		A:      d.Element(SP),
+		S:      d.Element(SP),
+		T1:     d.Element(SP),
+		T2:     d.Element(SP),
+		Taux:   d.Scalar(SP),
+		Mu:     d.Scalar(SP),
+		Tprime: d.Scalar(SP),
+		Params: params,
+	}
+
+	if err := d.Err(); err != nil {
+		return MultiBulletProof{}, err
+	}
+	ipp, err := proofInnerProductParams(params)
+	if err != nil {
+		return MultiBulletProof{}, err
+	}
+	proof.InnerProductProof = decodeInnerProduct(d, ipp)
+	if err = d.Finish(); err != nil {
+		return MultiBulletProof{}, err
+	}
+	return proof, nil
+}
@@ -22,10 +22,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/takakv/msc-poc/group"
-	"math/big"
-
-	"github.com/ing-bank/zkrp/util/bn"
-	"github.com/ing-bank/zkrp/util/byteconversion"
 )
 
 var SEEDU = "BulletproofsDoesNotNeedTrustedSetupU"
@@ -46,9 +42,9 @@ InnerProductProof contains the elements used to verify the Inner Product Proof.
 */
 type InnerProductProof struct {
 	P      group.Element // Commitment g^a . h^b
-	Cc     *big.Int      // Inner product of <a,b>
-	A      *big.Int      `json:"a"`
-	B      *big.Int      `json:"b"`
+	Cc     group.Scalar  // Inner product of <a,b>
+	A      group.Scalar  `json:"a"`
+	B      group.Scalar  `json:"b"`
 	L      []group.Element
 	R      []group.Element
 	Params InnerProductParams
@@ -89,7 +85,7 @@ func setupInnerProduct(g, h []group.Element, N int64, SP group.Group) (InnerProd
 }
 
 // computePP computes P' as P' = P.u^(x.c) and returns P' and u^x
-func computePP(P group.Element, c *big.Int, x *big.Int, params InnerProductParams) (group.Element, group.Element) {
+func computePP(P group.Element, c group.Scalar, x group.Scalar, params InnerProductParams) (group.Element, group.Element) {
 	ux := params.GP.Element().Scale(params.Uu, x)
 	uxc := params.GP.Element().Scale(ux, c)
 	PP := params.GP.Element().Add(P, uxc)
@@ -99,30 +95,18 @@ func computePP(P group.Element, c *big.Int, x *big.Int, params InnerProductParam
 /*
 proveInnerProduct calculates the Zero Knowledge Proof for the Inner Product argument.
 */
-func proveInnerProduct(a, b []*big.Int, P group.Element, c *big.Int, params InnerProductParams) (InnerProductProof, error) {
-	var (
-		proof InnerProductProof
-		n, m  int64
-		Ls    []group.Element
-		Rs    []group.Element
-	)
-
-	n = int64(len(a))
-	m = int64(len(b))
-
-	if n != m {
+func proveInnerProduct(a, b []group.Scalar, P group.Element, c group.Scalar, params InnerProductParams) (InnerProductProof, error) {
+	var proof InnerProductProof
+
+	if len(a) != len(b) {
 		return proof, errors.New("size of first array argument must be equal to the second")
 	}
 
 	// Fiat-Shamir
-	x, _ := hashIP(params.Gg, params.Hh, P, c) // (6) & (7)
-
-	// P' = P.u^(x.c)
-	PP, ux := computePP(P, c, x, params) // (8)
-
-	// Execute Protocol 2 recursively
-	proof = computeBipRecursive(a, b, params.Gg, params.Hh, ux, PP, n, Ls, Rs, params.GP) // 9
+	t := newIPTranscript(params)
+	x := t.challenge(P, c) // (6) & (7)
 
+	proof = proveIPRounds(a, b, x, params, t)
 	proof.Params = params
 	proof.P = P
 	proof.Cc = c
@@ -130,165 +114,190 @@ func proveInnerProduct(a, b []*big.Int, P group.Element, c *big.Int, params Inne
 	return proof, nil
 }
 
+// proveIPRounds runs Protocol 2 for the vectors a and b, given the challenge
+// x of (6), and derives the challenges of the rounds from the transcript.
+func proveIPRounds(a, b []group.Scalar, x group.Scalar, params InnerProductParams, t *transcript) InnerProductProof {
+	var proof InnerProductProof
+
+	// Execute Protocol 2 iteratively                                     // (9)
+	ip := newIPProver(a, b, params.Gg, params.Hh, params.GP.Element().Scale(params.Uu, x), params.GP)
+	for !ip.last() {
+		L, R := ip.round()
+		proof.L = append(proof.L, L)
+		proof.R = append(proof.R, R)
+		x = t.challenge(L, R) // (26)
+		ip.fold(x)
+	}
+
+	proof.A = ip.a[0]
+	proof.B = ip.b[0]
+	return proof
+}
+
 /*
-computeBipRecursive is the main recursive function that will be used to compute the inner product argument.
+ipProver is the prover of Protocol 2 for the vectors a and b, which folds
+them in half in every round until single scalars remain.
 */
-func computeBipRecursive(a, b []*big.Int, g, h []group.Element, u, P group.Element, n int64, Ls, Rs []group.Element, SP group.Group) InnerProductProof {
-	var (
-		proof                            InnerProductProof
-		cL, cR, x, xinv, x2, x2inv       *big.Int
-		L, R, Lh, Rh, Pprime             group.Element
-		gprime, hprime, gprime2, hprime2 []group.Element
-		aprime, bprime, aprime2, bprime2 []*big.Int
-	)
-
-	if n == 1 {
-		// recursion end
-		proof.A = a[0]
-		proof.B = b[0]
-		proof.P = P
-		proof.L = Ls
-		proof.R = Rs
-		return proof
-	}
+type ipProver struct {
+	a, b []group.Scalar
+	g, h []group.Element
+	u    group.Element // u^x of (8)
+	SP   group.Group
+}
 
-	// recursion
+func newIPProver(a, b []group.Scalar, g, h []group.Element, u group.Element, SP group.Group) *ipProver {
+	return &ipProver{a: a, b: b, g: g, h: h, u: u, SP: SP}
+}
 
-	nprime := n / 2 // (20)
+// last returns true if the vectors can no longer be folded.
+func (ip *ipProver) last() bool {
+	return len(ip.a) == 1
+}
+
+// round computes the commitments L and R of a round.
+func (ip *ipProver) round() (group.Element, group.Element) {
+	a, b, g, h, SP := ip.a, ip.b, ip.g, ip.h, ip.SP
+	nprime := len(a) / 2 // (20)
 
 	// Compute cL = < a[:n'], b[n':] >                                    // (21)
-	cL, _ = ScalarProduct(a[:nprime], b[nprime:], SP)
+	cL, _ := ScalarProduct(a[:nprime], b[nprime:], SP)
 	// Compute cR = < a[n':], b[:n'] >                                    // (22)
-	cR, _ = ScalarProduct(a[nprime:], b[:nprime], SP)
+	cR, _ := ScalarProduct(a[nprime:], b[:nprime], SP)
 	// Compute L = g[n':]^(a[:n']).h[:n']^(b[n':]).u^cL                   // (23)
-	L, _ = VectorExp(g[nprime:], a[:nprime], SP)
-	Lh, _ = VectorExp(h[:nprime], b[nprime:], SP)
+	L, _ := VectorExp(g[nprime:], a[:nprime], SP)
+	Lh, _ := VectorExp(h[:nprime], b[nprime:], SP)
 	L.Add(L, Lh)
-	L.Add(L, SP.Element().Scale(u, cL))
+	L.Add(L, SP.Element().Scale(ip.u, cL))
 
 	// Compute R = g[:n']^(a[n':]).h[n':]^(b[:n']).u^cR                   // (24)
-	R, _ = VectorExp(g[:nprime], a[nprime:], SP)
-	Rh, _ = VectorExp(h[nprime:], b[:nprime], SP)
+	R, _ := VectorExp(g[:nprime], a[nprime:], SP)
+	Rh, _ := VectorExp(h[nprime:], b[:nprime], SP)
 	R.Add(R, Rh)
-	R.Add(R, SP.Element().Scale(u, cR))
+	R.Add(R, SP.Element().Scale(ip.u, cR))
 
-	// Fiat-Shamir:                                                       // (26)
-	x, _, _ = HashBP(L, R)
-	xinv = bn.ModInverse(x, SP.N())
+	return L, R
+}
 
-	// Compute g' = g[:n']^(x^-1) * g[n':]^(x)                            // (29)
-	gprime = vectorScalarExp(g[:nprime], xinv, SP)
-	gprime2 = vectorScalarExp(g[nprime:], x, SP)
-	gprime, _ = VectorECAdd(gprime, gprime2, SP)
-	// Compute h' = h[:n']^(x)    * h[n':]^(x^-1)                         // (30)
-	hprime = vectorScalarExp(h[:nprime], x, SP)
-	hprime2 = vectorScalarExp(h[nprime:], xinv, SP)
-	hprime, _ = VectorECAdd(hprime, hprime2, SP)
+// fold halves the vectors and the generators with the challenge x of the round.
+func (ip *ipProver) fold(x group.Scalar) {
+	SP := ip.SP
+	nprime := len(ip.a) / 2
+	xinv := SP.NewScalar().Invert(x)
 
-	// Compute P' = L^(x^2).P.R^(x^-2)                                    // (31)
-	x2 = bn.Mod(bn.Multiply(x, x), SP.N())
-	x2inv = bn.ModInverse(x2, SP.N())
-	Pprime = SP.Element().Scale(L, x2)
-	Pprime.Add(Pprime, P)
-	Pprime.Add(Pprime, SP.Element().Scale(R, x2inv))
+	ip.g, ip.h = foldGenerators(ip.g, ip.h, x, xinv, SP)
 
 	// Compute a' = a[:n'].x      + a[n':].x^(-1)                         // (33)
-	aprime, _ = VectorScalarMul(a[:nprime], x, SP.N())
-	aprime2, _ = VectorScalarMul(a[nprime:], xinv, SP.N())
-	aprime, _ = VectorAdd(aprime, aprime2, SP.N())
+	aprime, _ := VectorScalarMul(ip.a[:nprime], x, SP)
+	aprime2, _ := VectorScalarMul(ip.a[nprime:], xinv, SP)
+	ip.a, _ = VectorAdd(aprime, aprime2, SP)
 	// Compute b' = b[:n'].x^(-1) + b[n':].x                              // (34)
-	bprime, _ = VectorScalarMul(b[:nprime], xinv, SP.N())
-	bprime2, _ = VectorScalarMul(b[nprime:], x, SP.N())
-	bprime, _ = VectorAdd(bprime, bprime2, SP.N())
-
-	Ls = append(Ls, L)
-	Rs = append(Rs, R)
-	// recursion computeBipRecursive(g',h',u,P'; a', b')                  // (35)
-	proof = computeBipRecursive(aprime, bprime, gprime, hprime, u, Pprime, nprime, Ls, Rs, SP)
+	bprime, _ := VectorScalarMul(ip.b[:nprime], xinv, SP)
+	bprime2, _ := VectorScalarMul(ip.b[nprime:], x, SP)
+	ip.b, _ = VectorAdd(bprime, bprime2, SP)
+}
 
-	return proof
+/*
+foldGenerators halves the generators g and h with the challenge x of a round.
+*/
+func foldGenerators(g, h []group.Element, x, xinv group.Scalar, SP group.Group) ([]group.Element, []group.Element) {
+	nprime := len(g) / 2
+	// Compute g' = g[:n']^(x^-1) * g[n':]^(x)                            // (29)
+	gprime, _ := VectorECAdd(vectorScalarExp(g[:nprime], xinv, SP), vectorScalarExp(g[nprime:], x, SP), SP)
+	// Compute h' = h[:n']^(x)    * h[n':]^(x^-1)                         // (30)
+	hprime, _ := VectorECAdd(vectorScalarExp(h[:nprime], x, SP), vectorScalarExp(h[nprime:], xinv, SP), SP)
+	return gprime, hprime
 }
 
 /*
-Verify is responsible for the verification of the Inner Product Proof.
+ipVerifier is the verifier of Protocol 2, which folds the generators and
+the commitment P' along with the prover.
 */
-func (proof InnerProductProof) Verify() (bool, error) {
+type ipVerifier struct {
+	g, h   []group.Element
+	Pprime group.Element
+	ux     group.Element
+	SP     group.Group
+}
 
-	logn := len(proof.L)
-	var (
-		x, xinv, x2, x2inv                   *big.Int
-		ngprime, nhprime, ngprime2, nhprime2 []group.Element
-	)
+// newIPVerifier starts the verification of the commitment P to vectors with
+// the inner product c, given the challenge x of (6).
+func newIPVerifier(P group.Element, c, x group.Scalar, params InnerProductParams) *ipVerifier {
+	Pprime, ux := computePP(P, c, x, params) // (8)
+	return &ipVerifier{g: params.Gg, h: params.Hh, Pprime: Pprime, ux: ux, SP: params.GP}
+}
 
-	gprime := proof.Params.Gg
-	hprime := proof.Params.Hh
+// last returns true if the generators can no longer be folded.
+func (ip *ipVerifier) last() bool {
+	return len(ip.g) == 1
+}
 
-	// Fiat-Shamir
-	x, _ = hashIP(gprime, hprime, proof.P, proof.Cc) // (6) & (7)
-
-	Pprime, ux := computePP(proof.P, proof.Cc, x, proof.Params) // (8)
-
-	nprime := len(gprime)
-	for i := int64(0); i < int64(logn); i++ {
-		nprime = nprime / 2                      // (20)
-		x, _, _ = HashBP(proof.L[i], proof.R[i]) // (26)
-		xinv = bn.ModInverse(x, proof.Params.GP.N())
-		// Compute g' = g[:n']^(x^-1) * g[n':]^(x)                            // (29)
-		ngprime = vectorScalarExp(gprime[:nprime], xinv, proof.Params.GP)
-		ngprime2 = vectorScalarExp(gprime[nprime:], x, proof.Params.GP)
-		gprime, _ = VectorECAdd(ngprime, ngprime2, proof.Params.GP)
-		// Compute h' = h[:n']^(x)    * h[n':]^(x^-1)                         // (30)
-		nhprime = vectorScalarExp(hprime[:nprime], x, proof.Params.GP)
-		nhprime2 = vectorScalarExp(hprime[nprime:], xinv, proof.Params.GP)
-		hprime, _ = VectorECAdd(nhprime, nhprime2, proof.Params.GP)
-		// Compute P' = L^(x^2).P.R^(x^-2)                                    // (31)
-		x2 = bn.Mod(bn.Multiply(x, x), proof.Params.GP.N())
-		x2inv = bn.ModInverse(x2, proof.Params.GP.N())
-		Pprime.Add(Pprime, proof.Params.GP.Element().Scale(proof.L[i], x2))
-		Pprime.Add(Pprime, proof.Params.GP.Element().Scale(proof.R[i], x2inv))
-	}
+// fold folds the generators and P' with the commitments and the challenge x
+// of a round.
+func (ip *ipVerifier) fold(L, R group.Element, x group.Scalar) {
+	SP := ip.SP
+	xinv := SP.NewScalar().Invert(x)
+	ip.g, ip.h = foldGenerators(ip.g, ip.h, x, xinv, SP)
+	// Compute P' = L^(x^2).P.R^(x^-2)                                    // (31)
+	x2 := SP.NewScalar().Multiply(x, x)
+	x2inv := SP.NewScalar().Invert(x2)
+	ip.Pprime.Add(ip.Pprime, SP.Element().Scale(L, x2))
+	ip.Pprime.Add(ip.Pprime, SP.Element().Scale(R, x2inv))
+}
 
+// check returns true if P' = g^a.h^b.u^(a.b) for the final scalars a and b.
+func (ip *ipVerifier) check(a, b group.Scalar) bool {
+	SP := ip.SP
 	// c == a*b and checks if P = g^a.h^b.u^c                                     // (16)
-	ab := bn.Multiply(proof.A, proof.B)
-	ab = bn.Mod(ab, proof.Params.GP.N())
+	ab := SP.NewScalar().Multiply(a, b)
 	// Compute right hand side
-	rhs := proof.Params.GP.Element().Scale(gprime[0], proof.A)
-	hb := proof.Params.GP.Element().Scale(hprime[0], proof.B)
-	rhs = proof.Params.GP.Element().Add(rhs, hb)
-	rhs = proof.Params.GP.Element().Add(rhs, proof.Params.GP.Element().Scale(ux, ab))
-	// Compute inverse of left hand side
-	nP := proof.Params.GP.Element().Negate(Pprime)
-	nP.Add(nP, rhs)
-	// If both sides are equal then nP must be zero                               // (17)
-	c := nP.IsIdentity()
-
-	return c, nil
+	rhs := SP.Element().Scale(ip.g[0], a)
+	rhs.Add(rhs, SP.Element().Scale(ip.h[0], b))
+	rhs.Add(rhs, SP.Element().Scale(ip.ux, ab))
+	// If both sides are equal then the difference must be zero                   // (17)
+	return rhs.Subtract(rhs, ip.Pprime).IsIdentity()
 }
 
 /*
-hashIP is responsible for the computing a Zp element given elements from GT and G1.
+Verify is responsible for the verification of the Inner Product Proof.
 */
-func hashIP(g, h []group.Element, P group.Element, c *big.Int) (*big.Int, error) {
-	digest := sha256.New()
-	digest.Write([]byte(P.String()))
-
-	for i := 0; i < len(g); i++ {
-		digest.Write([]byte(g[i].String()))
-		digest.Write([]byte(h[i].String()))
+func (proof InnerProductProof) Verify() (bool, error) {
+	if err := proof.validate(); err != nil {
+		return false, err
 	}
 
-	digest.Write([]byte(c.String()))
-	output := digest.Sum(nil)
-	tmp := output[0:]
-	result, err := byteconversion.FromByteArray(tmp)
+	// Fiat-Shamir
+	t := newIPTranscript(proof.Params)
+	x := t.challenge(proof.P, proof.Cc) // (6) & (7)
 
-	return result, err
+	return proof.verifyRounds(x, proof.Params, t), nil
+}
+
+// verifyRounds verifies the rounds of the proof for the parameters params,
+// given the challenge x of (6), and derives the challenges of the rounds
+// from the transcript.
+func (proof InnerProductProof) verifyRounds(x group.Scalar, params InnerProductParams, t *transcript) bool {
+	ip := newIPVerifier(proof.P, proof.Cc, x, params)
+	for i := range proof.L {
+		x = t.challenge(proof.L[i], proof.R[i]) // (26)
+		ip.fold(proof.L[i], proof.R[i], x)
+	}
+	return ip.check(proof.A, proof.B)
+}
+
+// newIPTranscript starts the transcript of a standalone inner product
+// argument for params.
+func newIPTranscript(params InnerProductParams) *transcript {
+	digest := sha256.New()
+	writeElements(digest, params.Uu)
+	writeElements(digest, params.Gg...)
+	writeElements(digest, params.Hh...)
+	return &transcript{state: digest.Sum(nil), SP: params.GP}
 }
 
 /*
 commitInnerProduct is responsible for calculating g^a.h^b.
 */
-func commitInnerProduct(g, h []group.Element, a, b []*big.Int, SP group.Group) group.Element {
+func commitInnerProduct(g, h []group.Element, a, b []group.Scalar, SP group.Group) group.Element {
 	ga, _ := VectorExp(g, a, SP)
 	hb, _ := VectorExp(h, b, SP)
 	return SP.Element().Add(ga, hb)
@@ -297,7 +306,7 @@ func commitInnerProduct(g, h []group.Element, a, b []*big.Int, SP group.Group) g
 /*
 vectorScalarExp computes a[i]^b for each i.
 */
-func vectorScalarExp(a []group.Element, b *big.Int, SP group.Group) []group.Element {
+func vectorScalarExp(a []group.Element, b group.Scalar, SP group.Group) []group.Element {
 	n := int64(len(a))
 	result := make([]group.Element, n)
 	for i := int64(0); i < n; i++ {
@@ -19,7 +19,6 @@ package bulletproofs
 
 import (
 	"github.com/takakv/msc-poc/group"
-	"math/big"
 	"testing"
 )
 
@@ -27,22 +26,13 @@ import (
 Test Inner Product argument where <a,b>=c.
 */
 func TestInnerProduct(t *testing.T) {
-	N := 4
+	var testGroup = group.Ristretto255()
 
-	a := make([]*big.Int, N)
-	a[0] = new(big.Int).SetInt64(2)
-	a[1] = new(big.Int).SetInt64(-1)
-	a[2] = new(big.Int).SetInt64(10)
-	a[3] = new(big.Int).SetInt64(6)
-	b := make([]*big.Int, N)
-	b[0] = new(big.Int).SetInt64(1)
-	b[1] = new(big.Int).SetInt64(2)
-	b[2] = new(big.Int).SetInt64(10)
-	b[3] = new(big.Int).SetInt64(7)
+	a, _ := VectorConvertToScalar([]int64{2, -1, 10, 6}, 4, testGroup)
+	b, _ := VectorConvertToScalar([]int64{1, 2, 10, 7}, 4, testGroup)
 
-	c := new(big.Int).SetInt64(142)
+	c := testGroup.NewScalar().SetUint64(142)
 
-	var testGroup = group.Ristretto255()
 	innerProductParams, _ := setupInnerProduct(nil, nil, 4, testGroup)
 	commitment := commitInnerProduct(innerProductParams.Gg, innerProductParams.Hh, a, b, innerProductParams.GP)
 
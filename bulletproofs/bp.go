@@ -18,10 +18,11 @@
 package bulletproofs
 
 import (
-	"crypto/rand"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/interactive"
 	"math"
 	"math/big"
 
@@ -59,34 +60,45 @@ type BulletProof struct {
 	S                 group.Element
 	T1                group.Element
 	T2                group.Element
-	Taux              *big.Int
-	Mu                *big.Int
-	Tprime            *big.Int
+	Taux              group.Scalar
+	Mu                group.Scalar
+	Tprime            group.Scalar
 	InnerProductProof InnerProductProof
 	Params            BulletProofSetupParams
 }
 
 /*
 Setup is responsible for computing the common parameters.
-Only works for ranges to 0 to 2^n, where n is a power of 2 and n <= 32
-TODO: allow n > 32 (need uint64 for that).
+Only works for ranges to 0 to 2^n, where n is a power of 2. Ranges of 2^64
+and beyond do not fit b, and require SetupBits.
 */
 func Setup(b int64, SP group.Group) (BulletProofSetupParams, error) {
 	if !IsPowerOfTwo(b) {
 		return BulletProofSetupParams{}, errors.New("range end is not a power of 2")
 	}
+	n := int64(math.Log2(float64(b)))
+	if !IsPowerOfTwo(n) {
+		return BulletProofSetupParams{}, fmt.Errorf("range end is a power of 2, but it's exponent should also be. Exponent: %d", n)
+	}
+	return SetupBits(n, SP)
+}
+
+// SetupBits computes the common parameters for the range from 0 to 2^n.
+// The bit-length n must be a power of 2, and 2^n must be smaller than the
+// order of the group so that the range does not wrap around.
+func SetupBits(n int64, SP group.Group) (BulletProofSetupParams, error) {
+	if !IsPowerOfTwo(n) {
+		return BulletProofSetupParams{}, fmt.Errorf("range bit-length %d is not a power of 2", n)
+	}
+	if n >= int64(SP.N().BitLen()) {
+		return BulletProofSetupParams{}, fmt.Errorf("range end 2^%d exceeds the order of %s", n, SP.Name())
+	}
 
 	params := BulletProofSetupParams{}
 	params.GP = SP
-	params.G = SP.Element().BaseScale(big.NewInt(1))
+	params.G = SP.Generator()
 	params.H, _ = SP.Element().MapToGroup(SEEDH)
-	params.N = int64(math.Log2(float64(b)))
-	if !IsPowerOfTwo(params.N) {
-		return BulletProofSetupParams{}, fmt.Errorf("range end is a power of 2, but it's exponent should also be. Exponent: %d", params.N)
-	}
-	if params.N > 32 {
-		return BulletProofSetupParams{}, errors.New("range end can not be greater than 2**32")
-	}
+	params.N = n
 	params.Gg = make([]group.Element, params.N)
 	params.Hh = make([]group.Element, params.N)
 	for i := int64(0); i < params.N; i++ {
@@ -96,248 +108,135 @@ func Setup(b int64, SP group.Group) (BulletProofSetupParams, error) {
 	return params, nil
 }
 
+// SetupBitsWithBlinding is SetupBits with H as the blinding generator of
+// the commitments, such as an ElGamal public key, so that the second
+// component of a ciphertext is the commitment to its plaintext. Nobody who
+// proves ranges may know the discrete logarithm of H.
+func SetupBitsWithBlinding(n int64, SP group.Group, H group.Element) (BulletProofSetupParams, error) {
+	if H == nil || H.IsIdentity() {
+		return BulletProofSetupParams{}, errors.New("blinding generator is the identity")
+	}
+	params, err := SetupBits(n, SP)
+	if err != nil {
+		return BulletProofSetupParams{}, err
+	}
+	params.H = H
+	return params, nil
+}
+
 /*
 Prove computes the Bulletproof range proof.
 The documentation and comments are based on the ePrint version of Bulletproofs:
 https://eprint.iacr.org/2017/1066.pdf
 */
-func Prove(secret *big.Int, params BulletProofSetupParams) (BulletProof, *big.Int, error) {
-	proof := BulletProof{}
-
-	mod := params.GP.N()
-
-	// ////////////////////////////////////////////////////////////////////////////
-	// First phase: page 19                                                      //
-	// ////////////////////////////////////////////////////////////////////////////
+func Prove(secret *big.Int, params BulletProofSetupParams) (BulletProof, group.Scalar, error) {
+	return ProveInDomain(secret, params, nil)
+}
 
+/*
+ProveInDomain is Prove for a proof that only verifies in the given domain,
+such as the identity of the prover. The domain is hashed into every
+challenge.
+*/
+func ProveInDomain(secret *big.Int, params BulletProofSetupParams, domain []byte) (BulletProof, group.Scalar, error) {
 	// Sample randomness gamma and commit to v.
-	gamma, _ := rand.Int(rand.Reader, mod)
-	V := PedersenCommit(secret, gamma, params.H, params.GP)
-
-	// aL, aR and commitment: (A, alpha)
-	aL := Decompose(secret, 2, params.N)                                                  // (41)
-	aR, _ := computeAR(aL)                                                                // (42)
-	alpha, _ := rand.Int(rand.Reader, mod)                                                // (43)
-	A := commitVector(aL, aR, alpha, params.H, params.Gg, params.Hh, params.N, params.GP) // (44)
-
-	// sL, sR and commitment: (S, rho)
-	sL := sampleRandomVector(params.N, params.GP)                                          // (45)
-	sR := sampleRandomVector(params.N, params.GP)                                          // (45)
-	rho, _ := rand.Int(rand.Reader, mod)                                                   // (46)
-	S := commitVectorBig(sL, sR, rho, params.H, params.Gg, params.Hh, params.N, params.GP) // (47)
-
-	proof.A = A // (48)
-	proof.S = S // (48)
-
-	// Fiat-Shamir heuristic to compute challenges y and z.
-	y, z, _ := HashBP(A, S) // (49) & (50)
-
-	// ////////////////////////////////////////////////////////////////////////////
-	// Second phase: page 20                                                     //
-	// ////////////////////////////////////////////////////////////////////////////
-
-	tau1, _ := rand.Int(rand.Reader, mod) // (52)
-	tau2, _ := rand.Int(rand.Reader, mod) // (52)
-
-	// The paper does not describe how to compute t1 and t2.
-	// The below approach is taken from Bünz's own reference code.
-
-	// yPow = (y^0, y^1, ..., y^(n-1))
-	// l0 = aL - z
-	// l1 = sL
-	// r0 = (yPow ∘ (aR + z)) + 2Pow . z^2
-	// r1 = sR ∘ yPow
-	// t1 = < l1, r0 > + < l0, r1 >
-	// t2 = < l1, r1 >
-
-	yPow := powerOf(y, params.N, params.GP)
-
-	// 2Pow . z ^ 2
-	powersOf2 := powerOf(big.NewInt(2), params.N, params.GP)
-	zSquared := new(big.Int).Mul(z, z)
-	powersOf2TimesZSquared, _ := VectorScalarMul(powersOf2, zSquared, mod)
-
-	// Vectors of big integers are needed for some functions.
-	aLb, _ := VectorConvertToBig(aL, params.N)
-	aRb, _ := VectorConvertToBig(aR, params.N)
-
-	// l(x) = (aL - z . 1Pow) + sL . x
-	l0 := VectorAddConst(aLb, new(big.Int).Neg(z), mod)
-	l1 := sL
-
-	// aRzn = aR + z . 1Pow
-	vecZ, _ := VectorCopy(z, params.N)
-	aRzn, _ := VectorAdd(vecZ, aRb, mod)
-
-	// r(x) = yPow ∘ (aR + z . 1Pow + sR . x) + z^2 . 2Pow
-	r0, _ := VectorMul(yPow, aRzn, mod)
-	r0, _ = VectorAdd(r0, powersOf2TimesZSquared, mod)
-	r1, _ := VectorMul(yPow, sR, mod)
-
-	t1left := VectorInnerProduct(l1, r0, mod)  // <l1, r0>
-	t1right := VectorInnerProduct(l0, r1, mod) // <l0, r1>
-
-	t1 := new(big.Int).Mod(new(big.Int).Add(t1left, t1right), mod)
-	t2 := VectorInnerProduct(l1, r1, mod)
-
-	T1 := PedersenCommit(t1, tau1, params.H, params.GP) // (53)
-	T2 := PedersenCommit(t2, tau2, params.H, params.GP) // (53)
-
-	proof.T1 = T1 // (54)
-	proof.T2 = T2 // (54)
-
-	// Fiat-Shamir heuristic to compute 'random' challenge x
-	x, _, _ := HashBP(T1, T2) // (55) & (56)
-
-	// ////////////////////////////////////////////////////////////////////////////
-	// Third phase: page 20                                                      //
-	// ////////////////////////////////////////////////////////////////////////////
-
-	// l = l(x) = (aL - z . 1Pow) + sL . x // (58)
-	sLx, _ := VectorScalarMul(sL, x, mod) // sL . x
-	bl, _ := VectorAdd(l0, sLx, mod)      // l(x)
-
-	// r = r(x) = yPow ∘ (aR + z . 1Pow + sR . x) + z^2 . 2Pow // (59)
-	sRx, _ := VectorScalarMul(sR, x, mod)                // sR . x
-	tmp, _ := VectorAdd(aRzn, sRx, mod)                  // (aR + z . 1Pow + sR . x)
-	tmp, _ = VectorMul(yPow, tmp, mod)                   // yPow ∘ (aR + z . 1Pow + sR . x)
-	br, _ := VectorAdd(tmp, powersOf2TimesZSquared, mod) // r(x)
-
-	// th = <bl, br>
-	th, _ := ScalarProduct(bl, br, params.GP) // (60)
-
-	// tau_x = tau2 . x^2 + tau1 . x + z^2 . gamma // (61)
-	tauX := new(big.Int).Mul(tau2, new(big.Int).Mul(x, x))
-	tauX.Add(tauX, new(big.Int).Mul(tau1, x))
-	tauX.Add(tauX, new(big.Int).Mul(zSquared, gamma))
-	tauX.Mod(tauX, mod)
-
-	// mu = alpha + rho . x // (62)
-	mu := new(big.Int).Mul(rho, x)
-	mu.Add(mu, alpha)
-	mu.Mod(mu, mod)
-
-	// ////////////////////////////////////////////////////////////////////////////
-	// Logarithmic phase: Section 4.2                                            //
-	// ////////////////////////////////////////////////////////////////////////////
+	gamma := params.GP.RandomScalar()
+	proof, err := ProveBlindedInDomain(secret, gamma, params, domain)
+	return proof, gamma, err
+}
 
-	// h' = h^(y^(-n))
-	hp := updateGenerators(params.Hh, y, params.N, params.GP)
+/*
+ProveBlindedInDomain is ProveInDomain for the commitment
+V = secret*G + gamma*H with the given blinding factor, e.g. to prove the
+range of the plaintext of an ElGamal ciphertext whose public key is H.
+*/
+func ProveBlindedInDomain(secret *big.Int, gamma group.Scalar, params BulletProofSetupParams, domain []byte) (BulletProof, error) {
+	SP := params.GP
+	V := PedersenCommit(SP.NewScalar().SetBigInt(secret), gamma, params.H, params.GP)
 
-	// Inner product over (g, h', P.h^-mu, t')
-	ipp, setupErr := setupInnerProduct(params.Gg, hp, params.N, params.GP)
-	if setupErr != nil {
-		return proof, gamma, setupErr
+	p, err := NewProver(secret, gamma, params)
+	if err != nil {
+		return BulletProof{}, err
+	}
+	messages, _, err := interactive.Run(context.Background(), p, fiatShamir(params, V, domain))
+	if err != nil {
+		return BulletProof{}, err
 	}
-	commit := commitInnerProduct(params.Gg, hp, bl, br, params.GP)
-	ipProof, _ := proveInnerProduct(bl, br, commit, th, ipp)
 
-	proof.V = V
-	proof.Taux = tauX
-	proof.Mu = mu
-	proof.Tprime = th
+	proof := BulletProof{V: V, Params: params}
+	commit := messages[0].(BPCommit)
+	poly := messages[1].(BPPolyCommit)
+	opening := messages[2].(BPOpening)
+	proof.A, proof.S = commit.A, commit.S
+	proof.T1, proof.T2 = poly.T1, poly.T2
+	proof.Taux, proof.Mu, proof.Tprime = opening.Taux, opening.Mu, opening.Tprime
+
+	// The proof records the generators of the setup, as the verifier
+	// switches the generators h to h' itself.
+	ipp := p.(*prover).ipp
+	ipp.Hh = params.Hh
+	ipProof := InnerProductProof{P: opening.P, Cc: opening.Tprime, Params: ipp}
+	for _, m := range messages[3 : len(messages)-1] {
+		round := m.(IPRound)
+		ipProof.L = append(ipProof.L, round.L)
+		ipProof.R = append(ipProof.R, round.R)
+	}
+	final := messages[len(messages)-1].(IPFinal)
+	ipProof.A, ipProof.B = final.A, final.B
 	proof.InnerProductProof = ipProof
-	proof.Params = params
 
-	return proof, gamma, nil
+	return proof, nil
+}
+
+// messages returns the prover's messages of the proof.
+func (proof *BulletProof) messages() []interactive.Message {
+	ipProof := &proof.InnerProductProof
+	messages := []interactive.Message{
+		BPCommit{A: proof.A, S: proof.S},
+		BPPolyCommit{T1: proof.T1, T2: proof.T2},
+		BPOpening{Taux: proof.Taux, Mu: proof.Mu, Tprime: proof.Tprime, P: ipProof.P},
+	}
+	for i := range ipProof.L {
+		messages = append(messages, IPRound{L: ipProof.L[i], R: ipProof.R[i]})
+	}
+	return append(messages, IPFinal{A: ipProof.A, B: ipProof.B})
 }
 
 /*
-Verify returns true if and only if the proof is valid.
+Verify returns true if and only if the proof is valid. The inner product
+argument is verified for the generators of the parameters, and must be for
+the inner product t' of the range proof.
 */
 func (proof *BulletProof) Verify() (bool, error) {
-	params := proof.Params
-	mod := params.GP.N()
-
-	// Recover x, y, z using Fiat-Shamir heuristic
-	x, _, _ := HashBP(proof.T1, proof.T2)
-	y, z, _ := HashBP(proof.A, proof.S)
-
-	zSquared := new(big.Int).Mod(new(big.Int).Mul(z, z), mod)
-	xSquared := new(big.Int).Mod(new(big.Int).Mul(x, x), mod)
-
-	// Switch generators
-	hp := updateGenerators(params.Hh, y, params.N, params.GP) // (64)
-
-	// ////////////////////////////////////////////////////////////////////////////
-	// Check that tprime  = t(x) = t0 + t1x + t2x^2  ----------  Condition (65) //
-	// ////////////////////////////////////////////////////////////////////////////
-
-	// Compute left hand side
-	lhs := PedersenCommit(proof.Tprime, proof.Taux, params.H, params.GP)
-
-	// Compute right hand side
-	rhs := params.GP.Element().Scale(proof.V, zSquared)
-
-	delta := params.delta(y, z)
-	gDelta := params.GP.Element().BaseScale(delta)
-
-	rhs.Add(rhs, gDelta)
-
-	T1x := params.GP.Element().Scale(proof.T1, x)
-	T2x2 := params.GP.Element().Scale(proof.T2, xSquared)
-
-	rhs.Add(rhs, T1x)
-	rhs.Add(rhs, T2x2)
-
-	c65 := rhs.IsEqual(lhs) // (65)
-
-	// Compute P - lhs  #################### Condition (66) ######################
-	// P = A . S^x . g^(-z) . (h')^(z . yPow + z^2 . 2Pow)
-
-	// S^x
-	Sx := params.GP.Element().Scale(proof.S, x)
-	// A.S^x
-	ASx := params.GP.Element().Add(proof.A, Sx)
-
-	// g^-z
-	mz := new(big.Int).Sub(mod, z)
-	vmz, _ := VectorCopy(mz, params.N)
-	gpmz, _ := VectorExp(params.Gg, vmz, params.GP)
-
-	// z.y^n
-	vz, _ := VectorCopy(z, params.N)
-	vy := powerOf(y, params.N, params.GP)
-	zyn, _ := VectorMul(vy, vz, mod)
-
-	p2n := powerOf(new(big.Int).SetInt64(2), params.N, params.GP)
-	z22n, _ := VectorScalarMul(p2n, zSquared, mod)
-
-	// z.y^n + z^2.2^n
-	zynz22n, _ := VectorAdd(zyn, z22n, mod)
-
-	lP := params.GP.Element().Add(ASx, gpmz)
-
-	// h'^(z.y^n + z^2.2^n)
-	hpExp, _ := VectorExp(hp, zynz22n, params.GP)
-
-	lP.Add(lP, hpExp)
-
-	// Compute P - rhs  #################### Condition (67) ######################
-
-	// h^mu
-	rP := params.GP.Element().Scale(params.H, proof.Mu)
-	rP.Add(rP, proof.InnerProductProof.P)
-
-	// Subtract lhs and rhs and compare with point at infinity
-	rP.Subtract(rP, lP)
-	c67 := rP.IsIdentity()
-
-	// Verify Inner Product Proof ################################################
-	ok, _ := proof.InnerProductProof.Verify()
-
-	result := c65 && c67 && ok
+	return proof.VerifyInDomain(nil)
+}
 
-	return result, nil
+/*
+VerifyInDomain is Verify for a proof computed by ProveInDomain.
+*/
+func (proof *BulletProof) VerifyInDomain(domain []byte) (bool, error) {
+	if err := proof.validate(); err != nil {
+		return false, err
+	}
+	if !proof.InnerProductProof.Cc.IsEqual(proof.Tprime) {
+		return false, nil
+	}
+	v, err := newVerifier(proof.Params, proof.V)
+	if err != nil {
+		return false, err
+	}
+	v.challenger = fiatShamir(proof.Params, proof.V, domain)
+	_, ok, err := interactive.Replay(v, proof.messages())
+	return ok, err
 }
 
 /*
 sampleRandomVector generates a vector composed by random big numbers.
 */
-func sampleRandomVector(N int64, GP group.Group) []*big.Int {
-	s := make([]*big.Int, N)
+func sampleRandomVector(N int64, GP group.Group) []group.Scalar {
+	s := make([]group.Scalar, N)
 	for i := int64(0); i < N; i++ {
-		s[i], _ = rand.Int(rand.Reader, GP.N())
+		s[i] = GP.RandomScalar()
 	}
 	return s
 }
@@ -349,18 +248,18 @@ vector of generators. This method is used both by prover and verifier. After thi
 update we have that A is a vector commitments to (aL, aR . y^n). Also, S is a vector
 commitment to (sL, sR . y^n).
 */
-func updateGenerators(Hh []group.Element, y *big.Int, N int64, GP group.Group) []group.Element {
+func updateGenerators(Hh []group.Element, y group.Scalar, N int64, GP group.Group) []group.Element {
 	// Compute h' // (64)
 	hp := make([]group.Element, N)
 
 	// Switch generators
-	yInv := new(big.Int).ModInverse(y, GP.N())
-	yExp := yInv
+	yInv := GP.NewScalar().Invert(y)
+	yExp := GP.NewScalar().Set(yInv)
 	hp[0] = Hh[0]
 
 	for i := int64(1); i < N; i++ {
 		hp[i] = GP.Element().Scale(Hh[i], yExp)
-		yExp = new(big.Int).Mul(yExp, yInv)
+		yExp.Multiply(yExp, yInv)
 	}
 
 	return hp
@@ -383,7 +282,7 @@ func computeAR(x []int64) ([]int64, error) {
 	return result, nil
 }
 
-func commitVectorBig(aL, aR []*big.Int, alpha *big.Int, H group.Element,
+func commitVectorScalar(aL, aR []group.Scalar, alpha group.Scalar, H group.Element,
 	g, h []group.Element, n int64, GP group.Group) group.Element {
 	// Compute h^alpha.vg^aL.vh^aR
 	R := GP.Element().Scale(H, alpha)
@@ -397,13 +296,13 @@ func commitVectorBig(aL, aR []*big.Int, alpha *big.Int, H group.Element,
 /*
 commitVector computes a commitment to the bit of the secret.
 */
-func commitVector(aL, aR []int64, alpha *big.Int, H group.Element,
+func commitVector(aL, aR []int64, alpha group.Scalar, H group.Element,
 	g, h []group.Element, n int64, GP group.Group) group.Element {
 	// Compute h^alpha.vg^aL.vh^aR
 	R := GP.Element().Scale(H, alpha)
 	for i := int64(0); i < n; i++ {
-		gaL := GP.Element().Scale(g[i], big.NewInt(aL[i]))
-		haR := GP.Element().Scale(h[i], big.NewInt(aR[i]))
+		gaL := GP.Element().Scale(g[i], GP.NewScalar().SetBigInt(big.NewInt(aL[i])))
+		haR := GP.Element().Scale(h[i], GP.NewScalar().SetBigInt(big.NewInt(aR[i])))
 		R.Add(R, gaL)
 		R.Add(R, haR)
 	}
@@ -411,19 +310,18 @@ func commitVector(aL, aR []int64, alpha *big.Int, H group.Element,
 }
 
 // delta(y,z) = (z - z^2) . < 1Pow, yPow > - z^3 . < 1Pow, 2Pow >
-func (params *BulletProofSetupParams) delta(y, z *big.Int) *big.Int {
-	mod := params.GP.N()
-	result := new(big.Int)
+func (params *BulletProofSetupParams) delta(y, z group.Scalar) group.Scalar {
+	SP := params.GP
 
-	onePow, _ := VectorCopy(new(big.Int).SetInt64(1), params.N)
-	twoPow := powerOf(big.NewInt(2), params.N, params.GP)
+	onePow, _ := VectorCopy(SP.NewScalar().SetUint64(1), params.N)
+	twoPow := powerOf(SP.NewScalar().SetUint64(2), params.N, params.GP)
 	yPow := powerOf(y, params.N, params.GP)
 
-	zSquared := new(big.Int).Mod(new(big.Int).Mul(z, z), mod)
-	zCubed := new(big.Int).Mod(new(big.Int).Mul(zSquared, z), mod)
+	zSquared := SP.NewScalar().Multiply(z, z)
+	zCubed := SP.NewScalar().Multiply(zSquared, z)
 
 	// (z-z^2)
-	t1 := new(big.Int).Mod(new(big.Int).Sub(z, zSquared), mod)
+	t1 := SP.NewScalar().Subtract(z, zSquared)
 
 	// < 1Pow, yPow >
 	t2, _ := ScalarProduct(onePow, yPow, params.GP)
@@ -432,10 +330,8 @@ func (params *BulletProofSetupParams) delta(y, z *big.Int) *big.Int {
 	sp12, _ := ScalarProduct(onePow, twoPow, params.GP)
 
 	// z3 . < 1Pow, 2Pow >
-	t3 := new(big.Int).Mod(new(big.Int).Mul(zCubed, sp12), mod)
-
-	result.Mod(t2.Mul(t2, t1), mod)
-	result.Mod(result.Sub(result, t3), mod)
+	t3 := SP.NewScalar().Multiply(zCubed, sp12)
 
-	return result
+	result := SP.NewScalar().Multiply(t2, t1)
+	return result.Subtract(result, t3)
 }
@@ -18,6 +18,7 @@
 package bulletproofs
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"github.com/takakv/msc-poc/group"
@@ -76,6 +77,44 @@ func TestXWithinRange(t *testing.T) {
 	}
 }
 
+func TestWideRanges(t *testing.T) {
+	for _, n := range []int64{64, 128} {
+		params, err := SetupBits(n, group.P256())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rangeEnd := new(big.Int).Lsh(big.NewInt(1), uint(n))
+		if !proveAndVerifyRange(new(big.Int).Sub(rangeEnd, big.NewInt(1)), params) {
+			t.Errorf("%d bits: x below range end should verify successfully", n)
+		}
+		if proveAndVerifyRange(rangeEnd, params) {
+			t.Errorf("%d bits: x equal to range end should not verify", n)
+		}
+
+		data, _ := json.Marshal(params)
+		_, err = SetupParamsUnmarshalJSON(data)
+		if n <= MaxDecodedBits && err != nil {
+			t.Errorf("%d bits: %v", n, err)
+		}
+		if n > MaxDecodedBits && err == nil {
+			t.Errorf("%d bits: parameters beyond the decoded bit-length were accepted", n)
+		}
+		if _, err = SetupParamsUnmarshalJSONBits(data, n); err != nil {
+			t.Errorf("%d bits: %v", n, err)
+		}
+		if _, err = SetupParamsUnmarshalJSONBits(data, 2*n); err == nil {
+			t.Errorf("%d bits: parameters of another bit-length were accepted", n)
+		}
+	}
+
+	if _, err := SetupBits(48, group.P256()); err == nil {
+		t.Error("bit-length that is not a power of 2 was accepted")
+	}
+	if _, err := SetupBits(256, group.P256()); err == nil {
+		t.Error("range exceeding the group order was accepted")
+	}
+}
+
 func setupRange(t *testing.T, rangeEnd int64) BulletProofSetupParams {
 	params, err := Setup(rangeEnd, group.Ristretto255())
 	if err != nil {
@@ -114,3 +153,156 @@ func TestJsonEncodeDecode(t *testing.T) {
 	}
 	assert.True(t, ok, "should verify")
 }
+
+func TestSetupParamsJsonEncodeDecode(t *testing.T) {
+	params, _ := Setup(MAX_RANGE_END, group.P256())
+	jsonEncoded, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal("encode error:", err)
+	}
+
+	decodedParams, err := SetupParamsUnmarshalJSON(jsonEncoded)
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+	assert.Equal(t, params.N, decodedParams.N)
+	assert.Equal(t, params.GP.Name(), decodedParams.GP.Name())
+
+	otherParams, _ := Setup(MAX_RANGE_END, group.P384())
+	otherParams.GP = params.GP
+	jsonEncoded, _ = json.Marshal(otherParams)
+	_, err = SetupParamsUnmarshalJSON(jsonEncoded)
+	assert.Error(t, err, "mismatched generators should be rejected")
+
+	// The bit-length is checked before the generators are derived.
+	for _, n := range []int64{48, 1 << 40} {
+		var m map[string]json.RawMessage
+		jsonEncoded, _ = json.Marshal(params)
+		_ = json.Unmarshal(jsonEncoded, &m)
+		m["N"], _ = json.Marshal(n)
+		jsonEncoded, _ = json.Marshal(m)
+		_, err = SetupParamsUnmarshalJSON(jsonEncoded)
+		assert.Error(t, err, "invalid bit-length %d should be rejected", n)
+	}
+}
+
+func TestBlinding(t *testing.T) {
+	g := group.P256()
+	pk := g.Element().BaseScale(g.NewScalar().SetUint64(13))
+	params, err := SetupBitsWithBlinding(16, g, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = SetupBitsWithBlinding(16, g, g.Identity()); err == nil {
+		t.Error("identity was accepted as the blinding generator")
+	}
+
+	// The commitment is the second component of an ElGamal ciphertext.
+	r := g.RandomScalar()
+	V := g.Element().Add(g.Element().BaseScale(g.NewScalar().SetUint64(1234)), g.Element().Scale(pk, r))
+	proof, err := ProveBlindedInDomain(big.NewInt(1234), r, params, []byte("domain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, proof.V.IsEqual(V), "commitment should be the ciphertext component")
+	ok, err := proof.VerifyInDomain([]byte("domain"))
+	assert.True(t, ok && err == nil, "should verify")
+
+	jsonEncoded, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = SetupParamsUnmarshalJSON(jsonEncoded)
+	assert.Error(t, err, "blinding generator should not match the derived one")
+	decodedParams, err := BlindedSetupParamsUnmarshalJSON(jsonEncoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, decodedParams.H.IsEqual(pk), "blinding generator should be decoded")
+}
+
+func TestBinaryEncodeDecode(t *testing.T) {
+	params, _ := Setup(MAX_RANGE_END, group.P256())
+	proof, _, _ := Prove(new(big.Int).SetInt64(18), params)
+	encoded, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal("encode error:", err)
+	}
+
+	jsonEncoded, _ := json.Marshal(proof)
+	assert.Less(t, len(encoded), len(jsonEncoded), "binary encoding should be smaller")
+
+	decodedProof, err := BulletProofUnmarshalBinary(encoded, params)
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+
+	ok, err := decodedProof.Verify()
+	if err != nil {
+		t.Fatal("verify error:", err)
+	}
+	assert.True(t, ok, "should verify")
+
+	reencoded, _ := decodedProof.MarshalBinary()
+	assert.Equal(t, encoded, reencoded, "encoding should be canonical")
+}
+
+func TestBinaryDecodeStrict(t *testing.T) {
+	params, _ := Setup(MAX_RANGE_END, group.P256())
+	proof, _, _ := Prove(new(big.Int).SetInt64(18), params)
+	encoded, _ := proof.MarshalBinary()
+
+	_, err := BulletProofUnmarshalBinary(encoded[:len(encoded)-1], params)
+	assert.Error(t, err, "truncated input should be rejected")
+
+	_, err = BulletProofUnmarshalBinary(append(encoded, 0), params)
+	assert.Error(t, err, "trailing data should be rejected")
+
+	modified := append([]byte{}, encoded...)
+	modified[0]++
+	_, err = BulletProofUnmarshalBinary(modified, params)
+	assert.Error(t, err, "unknown versions should be rejected")
+
+	_, err = MultiBulletProofUnmarshalBinary(encoded, params)
+	assert.Error(t, err, "other message kinds should be rejected")
+
+	otherParams, _ := Setup(MAX_RANGE_END, group.P384())
+	_, err = BulletProofUnmarshalBinary(encoded, otherParams)
+	assert.Error(t, err, "elements of other groups should be rejected")
+
+	// V follows the version and the kind, prefixed by its length.
+	compressed, _ := proof.V.MarshalBinaryCompress()
+	uncompressed, _ := proof.V.MarshalBinary()
+	modified = append([]byte{}, encoded[:2]...)
+	modified = binary.AppendUvarint(modified, uint64(len(uncompressed)))
+	modified = append(modified, uncompressed...)
+	modified = append(modified, encoded[3+len(compressed):]...)
+	_, err = BulletProofUnmarshalBinary(modified, params)
+	assert.Error(t, err, "uncompressed elements should be rejected")
+}
+
+func TestJsonDecodeStrict(t *testing.T) {
+	params, _ := Setup(MAX_RANGE_END, group.P256())
+	proof, _, _ := Prove(new(big.Int).SetInt64(18), params)
+
+	// Generators chosen by the prover must not be accepted.
+	// The generators are shared with params, so modify a copy.
+	Gg := append([]group.Element{}, proof.InnerProductProof.Params.Gg...)
+	Gg[0] = group.P256().Random()
+	proof.InnerProductProof.Params.Gg = Gg
+	jsonEncoded, _ := json.Marshal(proof)
+	_, err := BulletProofUnmarshalJSON(jsonEncoded, params)
+	assert.ErrorContains(t, err, "InnerProductProof.Params.Gg[0]")
+
+	proof, _, _ = Prove(new(big.Int).SetInt64(18), params)
+	proof.InnerProductProof.L = proof.InnerProductProof.L[1:]
+	jsonEncoded, _ = json.Marshal(proof)
+	_, err = BulletProofUnmarshalJSON(jsonEncoded, params)
+	assert.ErrorContains(t, err, "InnerProductProof.L")
+
+	otherParams, _ := Setup(1<<16, group.P256())
+	proof, _, _ = Prove(new(big.Int).SetInt64(18), otherParams)
+	jsonEncoded, _ = json.Marshal(proof)
+	_, err = BulletProofUnmarshalJSON(jsonEncoded, params)
+	assert.Error(t, err, "proofs for other parameters should be rejected")
+}
@@ -0,0 +1,86 @@
+package bulletproofs
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/takakv/msc-poc/group"
+)
+
+// fixtureProofs returns the range proofs in the ballot fixtures.
+func fixtureProofs(f *testing.F) [][]byte {
+	var proofs [][]byte
+	for _, name := range []string{"../testdata/P256rp.json"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		var ballot struct {
+			BpLower json.RawMessage `json:"lbProof"`
+			BpUpper json.RawMessage `json:"ubProof"`
+		}
+		if err = json.Unmarshal(data, &ballot); err != nil {
+			f.Fatal(err)
+		}
+		proofs = append(proofs, ballot.BpLower, ballot.BpUpper)
+	}
+	return proofs
+}
+
+func FuzzBulletProofJSON(f *testing.F) {
+	params, err := Setup(65536, group.P256())
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, proof := range fixtureProofs(f) {
+		f.Add(proof)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		proof, err := BulletProofUnmarshalJSON(data, params)
+		if err != nil {
+			return
+		}
+		_, _ = proof.Verify()
+	})
+}
+
+func FuzzBulletProofBinary(f *testing.F) {
+	params, err := Setup(65536, group.P256())
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, x := range []int64{0, 18, 65535} {
+		proof, _, _ := Prove(big.NewInt(x), params)
+		enc, _ := proof.MarshalBinary()
+		f.Add(enc)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		proof, err := BulletProofUnmarshalBinary(data, params)
+		if err != nil {
+			return
+		}
+		_, _ = proof.Verify()
+	})
+}
+
+func FuzzMultiBulletProofBinary(f *testing.F) {
+	params, err := Setup(65536, group.P256())
+	if err != nil {
+		f.Fatal(err)
+	}
+	proof, _, _ := MultiProve([]*big.Int{big.NewInt(3), big.NewInt(15)}, params)
+	enc, _ := proof.MarshalBinary()
+	f.Add(enc)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		proof, err := MultiBulletProofUnmarshalBinary(data, params)
+		if err != nil {
+			return
+		}
+		_, _ = proof.Verify()
+	})
+}
@@ -0,0 +1,450 @@
+package bulletproofs
+
+import (
+	"crypto/rand"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/interactive"
+	"io"
+	"math/big"
+
+	. "github.com/takakv/msc-poc/util"
+)
+
+// The messages of the interactive range proof, in order. The prover sends
+// a BPCommit, a BPPolyCommit, a BPOpening, an IPRound for every halving of
+// the vectors, and an IPFinal. The verifier answers all but the last.
+
+// BPCommit commits to the bits of the secret and to the blinding vectors.
+type BPCommit struct {
+	A group.Element
+	S group.Element
+}
+
+// BPChallengeYZ answers a BPCommit.
+type BPChallengeYZ struct {
+	Y group.Scalar
+	Z group.Scalar
+}
+
+// BPPolyCommit commits to the coefficients of t(X).
+type BPPolyCommit struct {
+	T1 group.Element
+	T2 group.Element
+}
+
+// BPChallenge answers a BPPolyCommit.
+type BPChallenge struct {
+	X group.Scalar
+}
+
+// BPOpening opens t(x), and commits to the vectors l(x) and r(x) of the
+// inner product argument.
+type BPOpening struct {
+	Taux   group.Scalar
+	Mu     group.Scalar
+	Tprime group.Scalar
+	P      group.Element
+}
+
+// IPChallenge answers a BPOpening or an IPRound.
+type IPChallenge struct {
+	X group.Scalar
+}
+
+// IPRound is a round of the inner product argument.
+type IPRound struct {
+	L group.Element
+	R group.Element
+}
+
+// IPFinal is the last message of the inner product argument.
+type IPFinal struct {
+	A group.Scalar
+	B group.Scalar
+}
+
+// prover is the state machine of the prover of the range proof.
+type prover struct {
+	params  BulletProofSetupParams
+	ipp     InnerProductParams
+	secret  *big.Int
+	gamma   group.Scalar
+	aL, aR  []int64
+	sL, sR  []group.Scalar
+	alpha   group.Scalar
+	rho     group.Scalar
+	y, z    group.Scalar
+	tau1    group.Scalar
+	tau2    group.Scalar
+	hp      []group.Element
+	bl, br  []group.Scalar
+	ip      *ipProver
+	started bool
+	done    bool
+}
+
+// NewProver returns the prover of Prove as a state machine, which proves
+// that the commitment to secret with randomness gamma is in the range of
+// params.
+func NewProver(secret *big.Int, gamma group.Scalar, params BulletProofSetupParams) (interactive.Prover, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	ipp, err := setupInnerProduct(params.Gg, nil, params.N, params.GP)
+	if err != nil {
+		return nil, err
+	}
+	return &prover{params: params, ipp: ipp, secret: secret, gamma: gamma}, nil
+}
+
+func (p *prover) Next(challenge interactive.Message) (interactive.Message, error) {
+	switch c := challenge.(type) {
+	case nil:
+		if p.started {
+			return nil, interactive.Unexpected(challenge)
+		}
+		p.started = true
+		return p.commit(), nil
+	case BPChallengeYZ:
+		if p.aL == nil || p.y != nil {
+			return nil, interactive.Unexpected(challenge)
+		}
+		return p.polyCommit(c.Y, c.Z), nil
+	case BPChallenge:
+		if p.y == nil || p.bl != nil {
+			return nil, interactive.Unexpected(challenge)
+		}
+		return p.open(c.X), nil
+	case IPChallenge:
+		if p.bl == nil || p.done {
+			return nil, interactive.Unexpected(challenge)
+		}
+		if p.ip == nil {
+			// u^x of (8)
+			u := p.params.GP.Element().Scale(p.ipp.Uu, c.X)
+			p.ip = newIPProver(p.bl, p.br, p.params.Gg, p.hp, u, p.params.GP)
+		} else {
+			p.ip.fold(c.X)
+		}
+		if p.ip.last() {
+			p.done = true
+			return IPFinal{A: p.ip.a[0], B: p.ip.b[0]}, nil
+		}
+		L, R := p.ip.round()
+		return IPRound{L: L, R: R}, nil
+	default:
+		return nil, interactive.Unexpected(challenge)
+	}
+}
+
+func (p *prover) Done() bool {
+	return p.done
+}
+
+// commit computes the first phase of Prove: page 19.
+func (p *prover) commit() BPCommit {
+	params := p.params
+	SP := params.GP
+
+	// aL, aR and commitment: (A, alpha)
+	p.aL = Decompose(p.secret, 2, params.N)                                                     // (41)
+	p.aR, _ = computeAR(p.aL)                                                                   // (42)
+	p.alpha = SP.RandomScalar()                                                                 // (43)
+	A := commitVector(p.aL, p.aR, p.alpha, params.H, params.Gg, params.Hh, params.N, params.GP) // (44)
+
+	// sL, sR and commitment: (S, rho)
+	p.sL = sampleRandomVector(params.N, params.GP)                                                  // (45)
+	p.sR = sampleRandomVector(params.N, params.GP)                                                  // (45)
+	p.rho = SP.RandomScalar()                                                                       // (46)
+	S := commitVectorScalar(p.sL, p.sR, p.rho, params.H, params.Gg, params.Hh, params.N, params.GP) // (47)
+
+	return BPCommit{A: A, S: S} // (48)
+}
+
+// l0, r0 and r1 are the coefficients of l(X) = l0 + sL.X and r(X) = r0 + r1.X.
+func (p *prover) coefficients() (l0, r0, r1 []group.Scalar) {
+	params := p.params
+	SP := params.GP
+
+	// yPow = (y^0, y^1, ..., y^(n-1))
+	yPow := powerOf(p.y, params.N, params.GP)
+
+	// 2Pow . z ^ 2
+	powersOf2 := powerOf(SP.NewScalar().SetUint64(2), params.N, params.GP)
+	zSquared := SP.NewScalar().Multiply(p.z, p.z)
+	powersOf2TimesZSquared, _ := VectorScalarMul(powersOf2, zSquared, SP)
+
+	// Vectors of scalars are needed for some functions.
+	aLs, _ := VectorConvertToScalar(p.aL, params.N, SP)
+	aRs, _ := VectorConvertToScalar(p.aR, params.N, SP)
+
+	// l(x) = (aL - z . 1Pow) + sL . x
+	l0 = VectorAddConst(aLs, SP.NewScalar().Negate(p.z), SP)
+
+	// r(x) = yPow ∘ (aR + z . 1Pow + sR . x) + z^2 . 2Pow
+	vecZ, _ := VectorCopy(p.z, params.N)
+	aRzn, _ := VectorAdd(vecZ, aRs, SP)
+	r0, _ = VectorMul(yPow, aRzn, SP)
+	r0, _ = VectorAdd(r0, powersOf2TimesZSquared, SP)
+	r1, _ = VectorMul(yPow, p.sR, SP)
+	return l0, r0, r1
+}
+
+// polyCommit computes the second phase of Prove: page 20.
+func (p *prover) polyCommit(y, z group.Scalar) BPPolyCommit {
+	params := p.params
+	SP := params.GP
+	p.y, p.z = y, z
+
+	p.tau1 = SP.RandomScalar() // (52)
+	p.tau2 = SP.RandomScalar() // (52)
+
+	// The paper does not describe how to compute t1 and t2.
+	// The below approach is taken from Bünz's own reference code.
+	// t1 = < l1, r0 > + < l0, r1 >
+	// t2 = < l1, r1 >
+	l0, r0, r1 := p.coefficients()
+	t1 := SP.NewScalar().Add(VectorInnerProduct(p.sL, r0, SP), VectorInnerProduct(l0, r1, SP))
+	t2 := VectorInnerProduct(p.sL, r1, SP)
+
+	T1 := PedersenCommit(t1, p.tau1, params.H, params.GP) // (53)
+	T2 := PedersenCommit(t2, p.tau2, params.H, params.GP) // (53)
+	return BPPolyCommit{T1: T1, T2: T2}                   // (54)
+}
+
+// open computes the third phase of Prove: page 20, and commits to the
+// vectors of the inner product argument.
+func (p *prover) open(x group.Scalar) BPOpening {
+	params := p.params
+	SP := params.GP
+
+	// l = l(x) = (aL - z . 1Pow) + sL . x // (58)
+	// r = r(x) = r0 + r1 . x              // (59)
+	l0, r0, r1 := p.coefficients()
+	sLx, _ := VectorScalarMul(p.sL, x, SP)
+	p.bl, _ = VectorAdd(l0, sLx, SP)
+	r1x, _ := VectorScalarMul(r1, x, SP)
+	p.br, _ = VectorAdd(r0, r1x, SP)
+
+	// th = <bl, br>
+	th, _ := ScalarProduct(p.bl, p.br, params.GP) // (60)
+
+	// tau_x = tau2 . x^2 + tau1 . x + z^2 . gamma // (61)
+	zSquared := SP.NewScalar().Multiply(p.z, p.z)
+	tauX := SP.NewScalar().Multiply(p.tau2, SP.NewScalar().Multiply(x, x))
+	tauX.Add(tauX, SP.NewScalar().Multiply(p.tau1, x))
+	tauX.Add(tauX, SP.NewScalar().Multiply(zSquared, p.gamma))
+
+	// mu = alpha + rho . x // (62)
+	mu := SP.NewScalar().Multiply(p.rho, x)
+	mu.Add(mu, p.alpha)
+
+	// h' = h^(y^(-n))
+	p.hp = updateGenerators(params.Hh, p.y, params.N, params.GP)
+	p.ipp.Hh = p.hp
+	P := commitInnerProduct(params.Gg, p.hp, p.bl, p.br, params.GP)
+
+	return BPOpening{Taux: tauX, Mu: mu, Tprime: th, P: P}
+}
+
+// verifier is the state machine of the verifier of the range proof.
+type verifier struct {
+	params     BulletProofSetupParams
+	ipp        InnerProductParams
+	V          group.Element
+	challenger interactive.Challenger
+	commit     *BPCommit
+	poly       *BPPolyCommit
+	y, z, x    group.Scalar
+	ip         *ipVerifier
+	done       bool
+	accepted   bool
+}
+
+// NewVerifier returns the verifier of the range proof for the commitment V
+// as a state machine, which samples its challenges from r. If r is nil,
+// crypto/rand is used.
+func NewVerifier(params BulletProofSetupParams, V group.Element, r io.Reader) (interactive.Verifier, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	v, err := newVerifier(params, V)
+	if err != nil {
+		return nil, err
+	}
+	v.challenger = randomChallenger(params.GP, r)
+	return v, nil
+}
+
+// newVerifier returns a verifier without a challenger.
+func newVerifier(params BulletProofSetupParams, V group.Element) (*verifier, error) {
+	if err := params.validate(); err != nil || V == nil {
+		return nil, errMalformedProof
+	}
+	ipp, err := setupInnerProduct(params.Gg, nil, params.N, params.GP)
+	if err != nil {
+		return nil, err
+	}
+	return &verifier{params: params, ipp: ipp, V: V}, nil
+}
+
+func (v *verifier) Next(m interactive.Message) (interactive.Message, bool, error) {
+	if v.done {
+		return nil, false, interactive.Unexpected(m)
+	}
+	var valid bool
+	switch m := m.(type) {
+	case BPCommit:
+		valid = v.commit == nil && !hasNil([]group.Element{m.A, m.S})
+	case BPPolyCommit:
+		valid = v.commit != nil && v.poly == nil && !hasNil([]group.Element{m.T1, m.T2})
+	case BPOpening:
+		valid = v.poly != nil && v.ip == nil && !hasNil([]group.Element{m.P}, m.Taux, m.Mu, m.Tprime)
+	case IPRound:
+		valid = v.ip != nil && !v.ip.last() && !hasNil([]group.Element{m.L, m.R})
+	case IPFinal:
+		valid = v.ip != nil && v.ip.last() && !hasNil(nil, m.A, m.B)
+	}
+	if !valid {
+		return nil, false, interactive.Unexpected(m)
+	}
+
+	if final, ok := m.(IPFinal); ok {
+		v.done = true
+		v.accepted = v.ip.check(final.A, final.B)
+		return nil, true, nil
+	}
+	if opening, ok := m.(BPOpening); ok && !v.checkOpening(opening) {
+		v.done = true
+		return nil, true, nil
+	}
+
+	challenge, err := v.challenger(m)
+	if err != nil {
+		return nil, false, err
+	}
+	switch m := m.(type) {
+	case BPCommit:
+		c := challenge.(BPChallengeYZ)
+		v.commit, v.y, v.z = &m, c.Y, c.Z
+		v.ipp.Hh = updateGenerators(v.params.Hh, v.y, v.params.N, v.params.GP) // (64)
+	case BPPolyCommit:
+		v.poly, v.x = &m, challenge.(BPChallenge).X
+	case BPOpening:
+		v.ip = newIPVerifier(m.P, m.Tprime, challenge.(IPChallenge).X, v.ipp)
+	case IPRound:
+		v.ip.fold(m.L, m.R, challenge.(IPChallenge).X)
+	}
+	return challenge, false, nil
+}
+
+func (v *verifier) Accepted() bool {
+	return v.done && v.accepted
+}
+
+// checkOpening checks conditions (65) and (67) of the opening.
+func (v *verifier) checkOpening(m BPOpening) bool {
+	params := v.params
+	SP := params.GP
+	y, z, x := v.y, v.z, v.x
+
+	zSquared := SP.NewScalar().Multiply(z, z)
+	xSquared := SP.NewScalar().Multiply(x, x)
+
+	// ////////////////////////////////////////////////////////////////////////////
+	// Check that tprime  = t(x) = t0 + t1x + t2x^2  ----------  Condition (65) //
+	// ////////////////////////////////////////////////////////////////////////////
+
+	lhs := PedersenCommit(m.Tprime, m.Taux, params.H, params.GP)
+	rhs := SP.Element().Scale(v.V, zSquared)
+	rhs.Add(rhs, SP.Element().BaseScale(params.delta(y, z)))
+	rhs.Add(rhs, SP.Element().Scale(v.poly.T1, x))
+	rhs.Add(rhs, SP.Element().Scale(v.poly.T2, xSquared))
+	if !rhs.IsEqual(lhs) {
+		return false
+	}
+
+	// Compute P - lhs  #################### Condition (66) ######################
+	// P = A . S^x . g^(-z) . (h')^(z . yPow + z^2 . 2Pow)
+
+	lP := SP.Element().Add(v.commit.A, SP.Element().Scale(v.commit.S, x))
+	vmz, _ := VectorCopy(SP.NewScalar().Negate(z), params.N)
+	gpmz, _ := VectorExp(params.Gg, vmz, params.GP)
+	lP.Add(lP, gpmz)
+
+	// z.y^n + z^2.2^n
+	vz, _ := VectorCopy(z, params.N)
+	zyn, _ := VectorMul(powerOf(y, params.N, params.GP), vz, SP)
+	z22n, _ := VectorScalarMul(powerOf(SP.NewScalar().SetUint64(2), params.N, params.GP), zSquared, SP)
+	zynz22n, _ := VectorAdd(zyn, z22n, SP)
+	hpExp, _ := VectorExp(v.ipp.Hh, zynz22n, params.GP)
+	lP.Add(lP, hpExp)
+
+	// Compute P - rhs  #################### Condition (67) ######################
+	rP := SP.Element().Scale(params.H, m.Mu)
+	rP.Add(rP, m.P)
+	return rP.Subtract(rP, lP).IsIdentity()
+}
+
+// randomChallenger samples the challenges of the range proof from r.
+func randomChallenger(SP group.Group, r io.Reader) interactive.Challenger {
+	scalar := func() (group.Scalar, error) {
+		for {
+			k, err := rand.Int(r, SP.N())
+			if err != nil {
+				return nil, err
+			}
+			// Challenges are inverted, so zero is resampled.
+			if k.Sign() != 0 {
+				return SP.NewScalar().SetBigInt(k), nil
+			}
+		}
+	}
+	return func(m interactive.Message) (interactive.Message, error) {
+		x, err := scalar()
+		if err != nil {
+			return nil, err
+		}
+		switch m.(type) {
+		case BPCommit:
+			y, err := scalar()
+			if err != nil {
+				return nil, err
+			}
+			return BPChallengeYZ{Y: y, Z: x}, nil
+		case BPPolyCommit:
+			return BPChallenge{X: x}, nil
+		case BPOpening, IPRound:
+			return IPChallenge{X: x}, nil
+		default:
+			return nil, interactive.Unexpected(m)
+		}
+	}
+}
+
+// fiatShamir derives the challenges of the range proof of V for params in
+// the given domain as ProveInDomain and VerifyInDomain do.
+func fiatShamir(params BulletProofSetupParams, V group.Element, domain []byte) interactive.Challenger {
+	t := newTranscript(domain, params, V)
+	var hp []group.Element
+	return func(m interactive.Message) (interactive.Message, error) {
+		switch m := m.(type) {
+		case BPCommit:
+			y := t.challenge(m.A, m.S) // (49)
+			z := t.challenge()         // (50)
+			hp = updateGenerators(params.Hh, y, params.N, params.GP)
+			return BPChallengeYZ{Y: y, Z: z}, nil
+		case BPPolyCommit:
+			return BPChallenge{X: t.challenge(m.T1, m.T2)}, nil // (55) & (56)
+		case BPOpening:
+			if hp == nil {
+				return nil, interactive.Unexpected(m)
+			}
+			return IPChallenge{X: t.challenge(m.Taux, m.Mu, m.Tprime, m.P)}, nil // (6) & (7)
+		case IPRound:
+			return IPChallenge{X: t.challenge(m.L, m.R)}, nil // (26)
+		default:
+			return nil, interactive.Unexpected(m)
+		}
+	}
+}
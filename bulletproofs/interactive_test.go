@@ -0,0 +1,161 @@
+package bulletproofs
+
+import (
+	"context"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/interactive"
+	"math/big"
+	"testing"
+
+	. "github.com/takakv/msc-poc/util"
+)
+
+// proveInteractively runs the range proof for x over a pipe against a
+// verifier of the commitment V.
+func proveInteractively(t *testing.T, x *big.Int, V group.Element, gamma group.Scalar, params BulletProofSetupParams) bool {
+	t.Helper()
+	p, err := NewProver(x, gamma, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(params, V, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := interactive.Pipe()
+	ch := make(chan error)
+	go func() {
+		_, err := interactive.Prove(context.Background(), p, a)
+		ch <- err
+	}()
+	accepted, err := interactive.Verify(context.Background(), v, b)
+	if err := <-ch; err != nil {
+		t.Fatalf("prover failed: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("verifier failed: %v", err)
+	}
+	return accepted
+}
+
+func TestInteractive(t *testing.T) {
+	params := setupRange(t, MAX_RANGE_END)
+	SP := params.GP
+	commit := func(x *big.Int) (group.Element, group.Scalar) {
+		gamma := SP.RandomScalar()
+		return PedersenCommit(SP.NewScalar().SetBigInt(x), gamma, params.H, SP), gamma
+	}
+
+	x := big.NewInt(123456)
+	V, gamma := commit(x)
+	if !proveInteractively(t, x, V, gamma, params) {
+		t.Error("honest prover was rejected")
+	}
+	other, _ := commit(big.NewInt(123457))
+	if proveInteractively(t, x, other, gamma, params) {
+		t.Error("prover was accepted for another commitment")
+	}
+	x = big.NewInt(MAX_RANGE_END)
+	V, gamma = commit(x)
+	if proveInteractively(t, x, V, gamma, params) {
+		t.Error("prover was accepted for a secret out of range")
+	}
+}
+
+func TestVerifyTranscript(t *testing.T) {
+	params := setupRange(t, MAX_RANGE_END)
+	proof, _, err := Prove(big.NewInt(18), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := proof.Verify(); !ok || err != nil {
+		t.Fatalf("valid proof did not verify: %v", err)
+	}
+
+	// The inner product argument must be for t'.
+	proof.InnerProductProof.Cc = params.GP.NewScalar().SetUint64(1)
+	if ok, _ := proof.Verify(); ok {
+		t.Error("inner product argument for another value was accepted")
+	}
+}
+
+func TestDomain(t *testing.T) {
+	params := setupRange(t, MAX_RANGE_END)
+	proof, _, err := ProveInDomain(big.NewInt(18), params, []byte("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := proof.VerifyInDomain([]byte("alice")); !ok || err != nil {
+		t.Fatalf("proof does not verify in its domain: %v", err)
+	}
+	if ok, _ := proof.VerifyInDomain([]byte("bob")); ok {
+		t.Error("proof verified in another domain")
+	}
+	if ok, _ := proof.Verify(); ok {
+		t.Error("proof verified without a domain")
+	}
+
+	encoded, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := BulletProofUnmarshalBinary(encoded, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := decoded.VerifyInDomain([]byte("alice")); !ok || err != nil {
+		t.Errorf("decoded proof does not verify: %v", err)
+	}
+	if ok, err := decoded.VerifyInDomain([]byte("bob")); ok || err != nil {
+		t.Errorf("decoded proof in another domain should be rejected without an error: %v", err)
+	}
+}
+
+// TestTranscriptBindsCommitment alters taux of a proof, and solves
+// condition (65) for another commitment V' with the challenges of the
+// proof. Only hashing V into the transcript rules the forgery out.
+func TestTranscriptBindsCommitment(t *testing.T) {
+	params := setupRange(t, MAX_RANGE_END)
+	SP := params.GP
+	proof, _, err := Prove(big.NewInt(18), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := newVerifier(params, proof.V)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.challenger = fiatShamir(params, proof.V, nil)
+	challenges, _, err := interactive.Replay(v, proof.messages())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// V' = (t'G + taux'H - delta(y, z)G - xT1 - x^2T2)/z^2.
+	yz, x := challenges[0].(BPChallengeYZ), challenges[1].(BPChallenge).X
+	forged := proof
+	forged.Taux = SP.RandomScalar()
+	V := PedersenCommit(proof.Tprime, forged.Taux, params.H, SP)
+	V.Subtract(V, SP.Element().BaseScale(params.delta(yz.Y, yz.Z)))
+	V.Subtract(V, SP.Element().Scale(proof.T1, x))
+	V.Subtract(V, SP.Element().Scale(proof.T2, SP.NewScalar().Multiply(x, x)))
+	forged.V = SP.Element().Scale(V, SP.NewScalar().Invert(SP.NewScalar().Multiply(yz.Z, yz.Z)))
+
+	// With the challenges of the proof, the forgery satisfies every condition.
+	if v, err = newVerifier(params, forged.V); err != nil {
+		t.Fatal(err)
+	}
+	v.challenger = func(interactive.Message) (interactive.Message, error) {
+		c := challenges[0]
+		challenges = challenges[1:]
+		return c, nil
+	}
+	if _, ok, err := interactive.Replay(v, forged.messages()); !ok || err != nil {
+		t.Fatalf("forged proof is not accepting: %v", err)
+	}
+	if ok, _ := forged.Verify(); ok {
+		t.Error("proof verified for a commitment chosen after the challenges")
+	}
+}
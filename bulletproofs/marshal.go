@@ -2,10 +2,23 @@ package bulletproofs
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/takakv/msc-poc/group"
-	"math/big"
+	"math/bits"
+
+	. "github.com/takakv/msc-poc/util"
 )
 
+type setupParamsJSON struct {
+	N  int64
+	G  json.RawMessage
+	H  json.RawMessage
+	Gg []json.RawMessage
+	Hh []json.RawMessage
+	GP json.RawMessage
+}
+
 type innerProductParamsJSON struct {
 	Uu json.RawMessage
 	Gg []json.RawMessage
@@ -15,12 +28,12 @@ type innerProductParamsJSON struct {
 
 type innerProductProofJSON struct {
 	P      json.RawMessage
-	Cc     *big.Int
-	A      *big.Int `json:"a"`
-	B      *big.Int `json:"b"`
+	Cc     json.RawMessage
+	A      json.RawMessage `json:"a"`
+	B      json.RawMessage `json:"b"`
 	L      []json.RawMessage
 	R      []json.RawMessage
-	Params innerProductParamsJSON
+	Params json.RawMessage
 }
 
 type bulletProofJSON struct {
@@ -29,79 +42,217 @@ type bulletProofJSON struct {
 	S                 json.RawMessage
 	T1                json.RawMessage
 	T2                json.RawMessage
-	Taux              *big.Int
-	Mu                *big.Int
-	Tprime            *big.Int
-	InnerProductProof innerProductProofJSON
+	Taux              json.RawMessage
+	Mu                json.RawMessage
+	Tprime            json.RawMessage
+	InnerProductProof json.RawMessage
 	Params            json.RawMessage
 }
 
-func ipParamsFromRawMessage(j innerProductParamsJSON, g group.Group) InnerProductParams {
-	params := InnerProductParams{
-		Uu: g.Element(),
-		Gg: make([]group.Element, len(j.Gg)),
-		Hh: make([]group.Element, len(j.Hh)),
-		GP: g,
+// checkElements decodes the encoded elements and returns an error if they
+// differ from the expected ones.
+func checkElements(d *FieldDecoder, field string, raws []json.RawMessage, expected []group.Element, g group.Group) {
+	decoded := d.Elements(field, raws, g, len(expected))
+	for i := range decoded {
+		if decoded[i] != nil && !decoded[i].IsEqual(expected[i]) {
+			d.Fail(fmt.Sprintf("%s[%d]", field, i), errors.New("generator does not match the parameters"))
+			return
+		}
 	}
+}
 
-	_ = params.Uu.UnmarshalJSON(j.Uu)
-	for i := range j.Gg {
-		params.Gg[i] = g.Element()
-		params.Hh[i] = g.Element()
-		_ = params.Gg[i].UnmarshalJSON(j.Gg[i])
-		_ = params.Hh[i].UnmarshalJSON(j.Hh[i])
+// checkIPParams verifies that the encoded inner product parameters are the
+// ones recorded by the prover. The proof is always verified against the
+// parameters derived by the verifier.
+func checkIPParams(b []byte, params InnerProductParams) error {
+	var j innerProductParamsJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
+		return err
 	}
 
-	return params
+	var d FieldDecoder
+	checkElements(&d, "Uu", []json.RawMessage{j.Uu}, []group.Element{params.Uu}, params.GP)
+	checkElements(&d, "Gg", j.Gg, params.Gg, params.GP)
+	checkElements(&d, "Hh", j.Hh, params.Hh, params.GP)
+	d.Object("GP", func() error { return checkGroup(j.GP, params.GP) })
+	return d.Err()
 }
 
-func ipProofFromRawMessage(j innerProductProofJSON, g group.Group) InnerProductProof {
-	proof := InnerProductProof{
-		L:      make([]group.Element, len(j.L)),
-		R:      make([]group.Element, len(j.R)),
-		P:      g.Element(),
-		Cc:     j.Cc,
-		A:      j.A,
-		B:      j.B,
-		Params: ipParamsFromRawMessage(j.Params, g),
+// checkSetupParams verifies that the encoded setup parameters are the ones
+// the verifier uses.
+func checkSetupParams(b []byte, params BulletProofSetupParams) error {
+	var j setupParamsJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
+		return err
 	}
 
-	for i := range proof.L {
-		proof.L[i] = g.Element()
-		proof.R[i] = g.Element()
-		_ = proof.L[i].UnmarshalJSON(j.L[i])
-		_ = proof.R[i].UnmarshalJSON(j.R[i])
+	var d FieldDecoder
+	if j.N != params.N {
+		d.Fail("N", errors.New("range bit-length does not match the parameters"))
 	}
-	_ = proof.P.UnmarshalJSON(j.P)
+	checkElements(&d, "G", []json.RawMessage{j.G}, []group.Element{params.G}, params.GP)
+	checkElements(&d, "H", []json.RawMessage{j.H}, []group.Element{params.H}, params.GP)
+	checkElements(&d, "Gg", j.Gg, params.Gg, params.GP)
+	checkElements(&d, "Hh", j.Hh, params.Hh, params.GP)
+	d.Object("GP", func() error { return checkGroup(j.GP, params.GP) })
+	return d.Err()
+}
 
-	return proof
+func checkGroup(b []byte, g group.Group) error {
+	decoded, err := group.UnmarshalGroupJSON(b)
+	if err != nil {
+		return err
+	}
+	if decoded.Name() != g.Name() {
+		return fmt.Errorf("group %s does not match %s", decoded.Name(), g.Name())
+	}
+	return nil
 }
 
-func BulletProofUnmarshalJSON(b []byte, params BulletProofSetupParams) (BulletProof, error) {
-	var tmp bulletProofJSON
-	err := json.Unmarshal(b, &tmp)
+func ipProofFromJSON(b []byte, params BulletProofSetupParams) (InnerProductProof, error) {
+	var j innerProductProofJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
+		return InnerProductProof{}, err
+	}
+
+	ipp, err := proofInnerProductParams(params)
 	if err != nil {
+		return InnerProductProof{}, err
+	}
+	rounds := bits.Len(uint(params.N)) - 1
+
+	var d FieldDecoder
+	proof := InnerProductProof{
+		P:      d.Element("P", j.P, params.GP),
+		Cc:     d.Scalar("Cc", j.Cc, params.GP),
+		A:      d.Scalar("a", j.A, params.GP),
+		B:      d.Scalar("b", j.B, params.GP),
+		L:      d.Elements("L", j.L, params.GP, rounds),
+		R:      d.Elements("R", j.R, params.GP, rounds),
+		Params: ipp,
+	}
+	d.Object("Params", func() error { return checkIPParams(j.Params, ipp) })
+	if err = d.Err(); err != nil {
+		return InnerProductProof{}, err
+	}
+	return proof, nil
+}
+
+// BulletProofUnmarshalJSON recovers a proof from its JSON representation.
+// The encoded parameters must match the given ones.
+func BulletProofUnmarshalJSON(b []byte, params BulletProofSetupParams) (BulletProof, error) {
+	var j bulletProofJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
 		return BulletProof{}, err
 	}
 
-	decodedProof := BulletProof{
-		V:                 params.GP.Element(),
-		A:                 params.GP.Element(),
-		S:                 params.GP.Element(),
-		T1:                params.GP.Element(),
-		T2:                params.GP.Element(),
-		Taux:              tmp.Taux,
-		Mu:                tmp.Mu,
-		Tprime:            tmp.Tprime,
-		InnerProductProof: ipProofFromRawMessage(tmp.InnerProductProof, params.GP),
-		Params:            params,
+	SP := params.GP
+	var d FieldDecoder
+	proof := BulletProof{
+		V:      d.Element("V", j.V, SP),
+		A:      d.Element("A", j.A, SP),
+		S:      d.Element("S", j.S, SP),
+		T1:     d.Element("T1", j.T1, SP),
+		T2:     d.Element("T2", j.T2, SP),
+		Taux:   d.Scalar("Taux", j.Taux, SP),
+		Mu:     d.Scalar("Mu", j.Mu, SP),
+		Tprime: d.Scalar("Tprime", j.Tprime, SP),
+		Params: params,
+	}
+	d.Object("InnerProductProof", func() (err error) {
+		proof.InnerProductProof, err = ipProofFromJSON(j.InnerProductProof, params)
+		return err
+	})
+	d.Object("Params", func() error { return checkSetupParams(j.Params, params) })
+	if err := d.Err(); err != nil {
+		return BulletProof{}, err
 	}
+	return proof, nil
+}
+
+// MaxDecodedBits bounds the range bit-length of decoded parameters, since
+// deriving the generators again is expensive for long ranges.
+const MaxDecodedBits = 64
+
+// SetupParamsUnmarshalJSON recovers the public parameters from their JSON
+// representation. Since the parameters are fully determined by the range
+// and the group, they are derived again with Setup, and the encoded
+// generators must match the derived ones. The range bit-length must be a
+// power of 2 of at most MaxDecodedBits.
+func SetupParamsUnmarshalJSON(b []byte) (BulletProofSetupParams, error) {
+	return setupParamsFromJSON(b, 0, false)
+}
+
+// SetupParamsUnmarshalJSONBits is SetupParamsUnmarshalJSON for parameters
+// of the expected range bit-length n, which may exceed MaxDecodedBits. The
+// encoded bit-length must be n.
+func SetupParamsUnmarshalJSONBits(b []byte, n int64) (BulletProofSetupParams, error) {
+	return setupParamsFromJSON(b, n, false)
+}
+
+// BlindedSetupParamsUnmarshalJSON is SetupParamsUnmarshalJSON for the
+// parameters of SetupBitsWithBlinding. The blinding generator H can be any
+// element other than the identity, and the caller must check that it is
+// the expected one.
+func BlindedSetupParamsUnmarshalJSON(b []byte) (BulletProofSetupParams, error) {
+	return setupParamsFromJSON(b, 0, true)
+}
+
+// BlindedSetupParamsUnmarshalJSONBits is SetupParamsUnmarshalJSONBits for
+// the parameters of SetupBitsWithBlinding.
+func BlindedSetupParamsUnmarshalJSONBits(b []byte, n int64) (BulletProofSetupParams, error) {
+	return setupParamsFromJSON(b, n, true)
+}
+
+// setupParamsFromJSON decodes parameters of the range bit-length n, or of
+// any up to MaxDecodedBits if n is zero.
+func setupParamsFromJSON(b []byte, n int64, blinded bool) (BulletProofSetupParams, error) {
+	var tmp setupParamsJSON
+	err := UnmarshalStrict(b, &tmp)
+	if err != nil {
+		return BulletProofSetupParams{}, err
+	}
+
+	g, err := group.UnmarshalGroupJSON(tmp.GP)
+	if err != nil {
+		return BulletProofSetupParams{}, err
+	}
+
+	// The bit-length is checked before the generators are derived.
+	if n != 0 && tmp.N != n {
+		return BulletProofSetupParams{}, WrapPath("N", fmt.Errorf("range bit-length is not %d", n))
+	}
+	if n == 0 && (tmp.N <= 0 || tmp.N > MaxDecodedBits || !IsPowerOfTwo(tmp.N)) {
+		return BulletProofSetupParams{}, WrapPath("N", errors.New("invalid range bit-length"))
+	}
+	if int64(len(tmp.Gg)) != tmp.N || int64(len(tmp.Hh)) != tmp.N {
+		return BulletProofSetupParams{}, errors.New("parameters do not match the range")
+	}
+
+	var params BulletProofSetupParams
+	if blinded {
+		H := g.Element()
+		if err = H.UnmarshalJSON(tmp.H); err != nil {
+			return BulletProofSetupParams{}, WrapPath("H", err)
+		}
+		if params, err = SetupBitsWithBlinding(tmp.N, g, H); err != nil {
+			return BulletProofSetupParams{}, WrapPath("H", err)
+		}
+	} else if params, err = SetupBits(tmp.N, g); err != nil {
+		return BulletProofSetupParams{}, err
+	}
+
+	encoded := append([]json.RawMessage{tmp.G, tmp.H}, tmp.Gg...)
+	encoded = append(encoded, tmp.Hh...)
+	derived := append([]group.Element{params.G, params.H}, params.Gg...)
+	derived = append(derived, params.Hh...)
 
-	_ = decodedProof.V.UnmarshalJSON(tmp.V)
-	_ = decodedProof.A.UnmarshalJSON(tmp.A)
-	_ = decodedProof.S.UnmarshalJSON(tmp.S)
-	_ = decodedProof.T1.UnmarshalJSON(tmp.T1)
-	_ = decodedProof.T2.UnmarshalJSON(tmp.T2)
+	for i := range encoded {
+		el := g.Element()
+		if el.UnmarshalJSON(encoded[i]) != nil || !el.IsEqual(derived[i]) {
+			return BulletProofSetupParams{}, errors.New("parameters do not match the group")
+		}
+	}
 
-	return decodedProof, nil
+	return params, nil
 }
@@ -18,7 +18,6 @@
 package bulletproofs
 
 import (
-	"crypto/rand"
 	"fmt"
 	"github.com/takakv/msc-poc/group"
 	"math/big"
@@ -36,9 +35,9 @@ type MultiBulletProof struct {
 	S                 group.Element
 	T1                group.Element
 	T2                group.Element
-	Taux              *big.Int
-	Mu                *big.Int
-	Tprime            *big.Int
+	Taux              group.Scalar
+	Mu                group.Scalar
+	Tprime            group.Scalar
 	InnerProductProof InnerProductProof
 	Params            BulletProofSetupParams
 }
@@ -48,16 +47,16 @@ MultiProve computes the aggregated ZKRP for multiple values.
 The documentation and comments are based on the ePrint version of the Bulletproofs paper:
 https://eprint.iacr.org/2017/1066.pdf
 */
-func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletProof, []*big.Int, error) {
+func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletProof, []group.Scalar, error) {
 	proof := MultiBulletProof{}
 
-	mod := params.GP.N()
+	SP := params.GP
 
 	m := len(secrets)
 	bitsPerValue := int(params.N) / m
 
 	commitments := make([]group.Element, m)
-	gammas := make([]*big.Int, m)
+	gammas := make([]group.Scalar, m)
 	aLConcat := make([]int64, params.N)
 	aRConcat := make([]int64, params.N)
 
@@ -67,8 +66,8 @@ func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletP
 
 	for j := range secrets {
 		// Sample randomness gamma and commit to v.
-		gamma, _ := rand.Int(rand.Reader, mod)
-		commitments[j] = PedersenCommit(secrets[j], gamma, params.H, params.GP)
+		gamma := SP.RandomScalar()
+		commitments[j] = PedersenCommit(SP.NewScalar().SetBigInt(secrets[j]), gamma, params.H, params.GP)
 		gammas[j] = gamma
 
 		// aL, aR
@@ -82,27 +81,29 @@ func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletP
 	}
 
 	// Commitment: (A, alpha)
-	alpha, _ := rand.Int(rand.Reader, mod)                                                            // (43)
-	A := commitVector(aRConcat, aRConcat, alpha, params.H, params.Gg, params.Hh, params.N, params.GP) // (44)
+	alpha := SP.RandomScalar()                                                                        // (43)
+	A := commitVector(aLConcat, aRConcat, alpha, params.H, params.Gg, params.Hh, params.N, params.GP) // (44)
 
 	// sL, sR and commitment: (S, rho)
-	sL := sampleRandomVector(params.N, params.GP)                                          // (45)
-	sR := sampleRandomVector(params.N, params.GP)                                          // (45)
-	rho, _ := rand.Int(rand.Reader, mod)                                                   // (46)
-	S := commitVectorBig(sL, sR, rho, params.H, params.Gg, params.Hh, params.N, params.GP) // (47)
+	sL := sampleRandomVector(params.N, params.GP)                                             // (45)
+	sR := sampleRandomVector(params.N, params.GP)                                             // (45)
+	rho := SP.RandomScalar()                                                                  // (46)
+	S := commitVectorScalar(sL, sR, rho, params.H, params.Gg, params.Hh, params.N, params.GP) // (47)
 
 	proof.A = A // (48)
 	proof.S = S // (48)
 
 	// Fiat-Shamir heuristic to compute challenges y and z.
-	y, z, _ := HashBP(A, S) // (49) & (50)
+	t := newTranscript(nil, params, commitments...)
+	y := t.challenge(A, S) // (49)
+	z := t.challenge()     // (50)
 
 	// ////////////////////////////////////////////////////////////////////////////
 	// Second phase: page 20                                                     //
 	// ////////////////////////////////////////////////////////////////////////////
 
-	tau1, _ := rand.Int(rand.Reader, mod) // (52)
-	tau2, _ := rand.Int(rand.Reader, mod) // (52)
+	tau1 := SP.RandomScalar() // (52)
+	tau2 := SP.RandomScalar() // (52)
 
 	// The paper does not describe how to compute t1 and t2.
 	// The below approach is taken from Bünz's own reference code.
@@ -118,38 +119,40 @@ func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletP
 	yPow := powerOf(y, params.N, params.GP)
 
 	// 2Pow . z ^ 2
-	powersOf2 := powerOf(big.NewInt(2), int64(bitsPerValue), params.GP)
+	powersOf2 := powerOf(SP.NewScalar().SetUint64(2), int64(bitsPerValue), params.GP)
 
-	zPowersTimesTwoVec := make([]*big.Int, params.N)
+	// zPowers = (z^2, z^3, ..., z^(m+1))
+	zPowers := powerOf(z, int64(m)+2, params.GP)[2:]
+
+	zPowersTimesTwoVec := make([]group.Scalar, params.N)
 	for j := 0; j < m; j++ {
-		zp := new(big.Int).Exp(z, big.NewInt(2+int64(j)), mod)
 		for i := 0; i < bitsPerValue; i++ {
-			zPowersTimesTwoVec[j*bitsPerValue+i] = new(big.Int).Mod(new(big.Int).Mul(powersOf2[i], zp), mod)
+			zPowersTimesTwoVec[j*bitsPerValue+i] = SP.NewScalar().Multiply(powersOf2[i], zPowers[j])
 		}
 	}
 
-	// Vectors of big integers are needed for some functions.
-	aLb, _ := VectorConvertToBig(aLConcat, params.N)
-	aRb, _ := VectorConvertToBig(aRConcat, params.N)
+	// Vectors of scalars are needed for some functions.
+	aLs, _ := VectorConvertToScalar(aLConcat, params.N, SP)
+	aRs, _ := VectorConvertToScalar(aRConcat, params.N, SP)
 
 	// l(x) = (aL - z . 1Pow) + sL . x
-	l0 := VectorAddConst(aLb, new(big.Int).Neg(z), mod)
+	l0 := VectorAddConst(aLs, SP.NewScalar().Negate(z), SP)
 	l1 := sL
 
 	// aRzn = aR + z . 1Pow
 	vecZ, _ := VectorCopy(z, params.N)
-	aRzn, _ := VectorAdd(vecZ, aRb, mod)
+	aRzn, _ := VectorAdd(vecZ, aRs, SP)
 
 	// r(x) = yPow ∘ (aR + z . 1Pow + sR . x) + z^2 . 2Pow
-	r0, _ := VectorMul(yPow, aRzn, mod)
-	r0, _ = VectorAdd(r0, zPowersTimesTwoVec, mod)
-	r1, _ := VectorMul(yPow, sR, mod)
+	r0, _ := VectorMul(yPow, aRzn, SP)
+	r0, _ = VectorAdd(r0, zPowersTimesTwoVec, SP)
+	r1, _ := VectorMul(yPow, sR, SP)
 
-	t1left := VectorInnerProduct(l1, r0, mod)  // <l1, r0>
-	t1right := VectorInnerProduct(l0, r1, mod) // <l0, r1>
+	t1left := VectorInnerProduct(l1, r0, SP)  // <l1, r0>
+	t1right := VectorInnerProduct(l0, r1, SP) // <l0, r1>
 
-	t1 := new(big.Int).Mod(new(big.Int).Add(t1left, t1right), mod)
-	t2 := VectorInnerProduct(l1, r1, mod)
+	t1 := SP.NewScalar().Add(t1left, t1right)
+	t2 := VectorInnerProduct(l1, r1, SP)
 
 	T1 := PedersenCommit(t1, tau1, params.H, params.GP) // (53)
 	T2 := PedersenCommit(t2, tau2, params.H, params.GP) // (53)
@@ -158,43 +161,35 @@ func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletP
 	proof.T2 = T2 // (54)
 
 	// Fiat-Shamir heuristic to compute 'random' challenge x
-	x, _, _ := HashBP(T1, T2) // (55) & (56)
+	x := t.challenge(T1, T2) // (55) & (56)
 
 	// ////////////////////////////////////////////////////////////////////////////
 	// Third phase: page 20                                                      //
 	// ////////////////////////////////////////////////////////////////////////////
 
 	// l = l(x) = (aL - z . 1Pow) + sL . x // (58)
-	sLx, _ := VectorScalarMul(sL, x, mod) // sL . x
-	bl, _ := VectorAdd(l0, sLx, mod)      // l(x)
+	sLx, _ := VectorScalarMul(sL, x, SP) // sL . x
+	bl, _ := VectorAdd(l0, sLx, SP)      // l(x)
 
 	// r = r(x) = yPow ∘ (aR + z . 1Pow + sR . x) + z^2 . 2Pow // (59)
-	sRx, _ := VectorScalarMul(sR, x, mod)            // sR . x
-	tmp, _ := VectorAdd(aRzn, sRx, mod)              // (aR + z . 1Pow + sR . x)
-	tmp, _ = VectorMul(yPow, tmp, mod)               // yPow ∘ (aR + z . 1Pow + sR . x)
-	br, _ := VectorAdd(tmp, zPowersTimesTwoVec, mod) // r(x)
+	sRx, _ := VectorScalarMul(sR, x, SP)            // sR . x
+	tmp, _ := VectorAdd(aRzn, sRx, SP)              // (aR + z . 1Pow + sR . x)
+	tmp, _ = VectorMul(yPow, tmp, SP)               // yPow ∘ (aR + z . 1Pow + sR . x)
+	br, _ := VectorAdd(tmp, zPowersTimesTwoVec, SP) // r(x)
 
 	// th = <bl, br>
 	th, _ := ScalarProduct(bl, br, params.GP) // (60)
 
 	// tau_x = tau2 . x^2 + tau1 . x + z^2 . gamma // (61)
-	tauX := new(big.Int).Mul(tau2, new(big.Int).Mul(x, x))
-	tauX.Add(tauX, new(big.Int).Mul(tau1, x))
-
-	vecRandomnessTotal := big.NewInt(0)
-	for j := 0; j < m; j++ {
-		zp := new(big.Int).Exp(z, big.NewInt(2+int64(j)), mod)
-		tmp1 := new(big.Int).Mul(gammas[j], zp)
-		vecRandomnessTotal = new(big.Int).Mod(new(big.Int).Add(vecRandomnessTotal, tmp1), mod)
-	}
+	tauX := SP.NewScalar().Multiply(tau2, SP.NewScalar().Multiply(x, x))
+	tauX.Add(tauX, SP.NewScalar().Multiply(tau1, x))
 
+	vecRandomnessTotal := VectorInnerProduct(gammas, zPowers, SP)
 	tauX.Add(tauX, vecRandomnessTotal)
-	tauX.Mod(tauX, mod)
 
 	// mu = alpha + rho . x // (62)
-	mu := new(big.Int).Mul(rho, x)
+	mu := SP.NewScalar().Multiply(rho, x)
 	mu.Add(mu, alpha)
-	mu.Mod(mu, mod)
 
 	// ////////////////////////////////////////////////////////////////////////////
 	// Logarithmic phase: Section 4.2                                            //
@@ -209,7 +204,11 @@ func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletP
 		return proof, gammas, setupErr
 	}
 	commit := commitInnerProduct(params.Gg, hp, bl, br, params.GP)
-	ipProof, _ := proveInnerProduct(bl, br, commit, th, ipp)
+	ipProof := proveIPRounds(bl, br, t.challenge(tauX, mu, th, commit), ipp, t)
+	ipProof.P = commit
+	ipProof.Cc = th
+	ipProof.Params = ipp
+	ipProof.Params.Hh = params.Hh
 
 	proof.Vs = commitments
 	proof.Taux = tauX
@@ -225,18 +224,23 @@ func MultiProve(secrets []*big.Int, params BulletProofSetupParams) (MultiBulletP
 Verify returns true if and only if the proof is valid.
 */
 func (proof *MultiBulletProof) Verify() (bool, error) {
+	if err := proof.validate(); err != nil {
+		return false, err
+	}
 	params := proof.Params
-	mod := params.GP.N()
+	SP := params.GP
 
 	m := len(proof.Vs)
 	bitsPerValue := int(params.N) / m
 
 	// Recover x, y, z using Fiat-Shamir heuristic
-	x, _, _ := HashBP(proof.T1, proof.T2)
-	y, z, _ := HashBP(proof.A, proof.S)
+	t := newTranscript(nil, params, proof.Vs...)
+	y := t.challenge(proof.A, proof.S)
+	z := t.challenge()
+	x := t.challenge(proof.T1, proof.T2)
 
-	zSquared := new(big.Int).Mod(new(big.Int).Mul(z, z), mod)
-	xSquared := new(big.Int).Mod(new(big.Int).Mul(x, x), mod)
+	zSquared := SP.NewScalar().Multiply(z, z)
+	xSquared := SP.NewScalar().Multiply(x, x)
 
 	// Switch generators
 	hp := updateGenerators(params.Hh, y, params.N, params.GP) // (64)
@@ -252,7 +256,7 @@ func (proof *MultiBulletProof) Verify() (bool, error) {
 	powersOfz := powerOf(z, int64(m), params.GP)
 	rhs := params.GP.Identity()
 	for j := 0; j < m; j++ {
-		tmp := params.GP.Element().Scale(proof.Vs[j], new(big.Int).Mul(zSquared, powersOfz[j]))
+		tmp := params.GP.Element().Scale(proof.Vs[j], SP.NewScalar().Multiply(zSquared, powersOfz[j]))
 		rhs.Add(rhs, tmp)
 	}
 
@@ -279,25 +283,25 @@ func (proof *MultiBulletProof) Verify() (bool, error) {
 	ASx := params.GP.Element().Add(proof.A, Sx)
 
 	// g^-z
-	mz := new(big.Int).Sub(mod, z)
+	mz := SP.NewScalar().Negate(z)
 	vmz, _ := VectorCopy(mz, params.N)
 	gpmz, _ := VectorExp(params.Gg, vmz, params.GP)
 
 	// z.y^n
 	vz, _ := VectorCopy(z, params.N)
 	vy := powerOf(y, params.N, params.GP)
-	zyn, _ := VectorMul(vy, vz, mod)
+	zyn, _ := VectorMul(vy, vz, SP)
 
 	// (h')^(z . y^n)
 	hpExp, _ := VectorExp(hp, zyn, params.GP)
 
-	powersOfTwo := powerOf(new(big.Int).SetInt64(2), int64(bitsPerValue), params.GP)
+	powersOfTwo := powerOf(SP.NewScalar().SetUint64(2), int64(bitsPerValue), params.GP)
 	prod := params.GP.Identity()
 
 	for j := 0; j < m; j++ {
 		hpSlide := hp[j*bitsPerValue : (j+1)*bitsPerValue]
-		zp := new(big.Int).Exp(z, big.NewInt(2+int64(j)), mod)
-		exp := VectorAddConst(powersOfTwo, zp, mod)
+		zp := SP.NewScalar().Multiply(zSquared, powersOfz[j])
+		exp, _ := VectorScalarMul(powersOfTwo, zp, SP)
 		val, _ := VectorExp(hpSlide, exp, params.GP)
 		prod.Add(prod, val)
 	}
@@ -318,7 +322,13 @@ func (proof *MultiBulletProof) Verify() (bool, error) {
 	fmt.Println("Check 67:", c67)
 
 	// Verify Inner Product Proof ################################################
-	ok, _ := proof.InnerProductProof.Verify()
+	ipProof := proof.InnerProductProof
+	ipp, err := setupInnerProduct(params.Gg, hp, params.N, params.GP)
+	if err != nil {
+		return false, err
+	}
+	ok := ipProof.Cc.IsEqual(proof.Tprime) &&
+		ipProof.verifyRounds(t.challenge(proof.Taux, proof.Mu, proof.Tprime, ipProof.P), ipp, t)
 	fmt.Println("Check 68:", ok)
 
 	result := c65 && c67 && ok
@@ -327,25 +337,24 @@ func (proof *MultiBulletProof) Verify() (bool, error) {
 }
 
 // delta(y,z) = (z - z^2) . < 1Pow(nm), yPow(nm) > - sum_{j=0}^{m-1} (z^{j+3} . < 1Pow, 2Pow >)
-func (params *BulletProofSetupParams) deltaMul(y, z *big.Int, m int64) *big.Int {
-	mod := params.GP.N()
-	result := new(big.Int)
+func (params *BulletProofSetupParams) deltaMul(y, z group.Scalar, m int64) group.Scalar {
+	SP := params.GP
 
 	// Do a confusing swap: take nm <- n and n <- n/m.
 	// This is because params.N is always the upper bit bound,
 	// and so nm must not exceed it.
 	nm := params.N / m
 
-	onePow, _ := VectorCopy(new(big.Int).SetInt64(1), nm)
-	twoPow := powerOf(big.NewInt(2), nm, params.GP)
+	onePow, _ := VectorCopy(SP.NewScalar().SetUint64(1), nm)
+	twoPow := powerOf(SP.NewScalar().SetUint64(2), nm, params.GP)
 
-	zSquared := new(big.Int).Mod(new(big.Int).Mul(z, z), mod)
+	zSquared := SP.NewScalar().Multiply(z, z)
 
 	// (z-z^2)
-	t1 := new(big.Int).Mod(new(big.Int).Sub(z, zSquared), mod)
+	t1 := SP.NewScalar().Subtract(z, zSquared)
 
 	// < 11Pow(n/m), yPow(n/m) >
-	onePowNM, _ := VectorCopy(new(big.Int).SetInt64(1), params.N)
+	onePowNM, _ := VectorCopy(SP.NewScalar().SetUint64(1), params.N)
 	yPowNM := powerOf(y, params.N, params.GP)
 	t2, _ := ScalarProduct(onePowNM, yPowNM, params.GP)
 
@@ -353,15 +362,13 @@ func (params *BulletProofSetupParams) deltaMul(y, z *big.Int, m int64) *big.Int
 	sp12, _ := ScalarProduct(onePow, twoPow, params.GP)
 
 	// sum_{j=0}^{m-1} z^{j+3} . < 1Pow, 2Pow >
-	t3 := big.NewInt(0)
+	zPowers := powerOf(z, m+3, params.GP)
+	t3 := SP.NewScalar()
 	for j := int64(0); j < m; j++ {
-		zp := new(big.Int).Exp(z, big.NewInt(j+3), params.GP.N())
-		tmp := new(big.Int).Mod(new(big.Int).Mul(zp, sp12), params.GP.N())
-		t3.Mod(new(big.Int).Add(t3, tmp), params.GP.N())
+		tmp := SP.NewScalar().Multiply(zPowers[j+3], sp12)
+		t3.Add(t3, tmp)
 	}
 
-	result.Mod(t2.Mul(t2, t1), mod)
-	result.Mod(result.Sub(result, t3), mod)
-
-	return result
+	result := SP.NewScalar().Multiply(t2, t1)
+	return result.Subtract(result, t3)
 }
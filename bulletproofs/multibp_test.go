@@ -24,3 +24,46 @@ func proveAndVerifyRanges(vals []*big.Int, params BulletProofSetupParams) bool {
 	ok, _ := proof.Verify()
 	return ok
 }
+
+func TestMultiBinaryEncodeDecode(t *testing.T) {
+	rangeEnd := int64(math.Pow(2, 32))
+	vals := []*big.Int{big.NewInt(3), big.NewInt(15)}
+
+	params := setupRange(t, rangeEnd)
+	proof, _, _ := MultiProve(vals, params)
+	encoded, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal("encode error:", err)
+	}
+
+	decodedProof, err := MultiBulletProofUnmarshalBinary(encoded, params)
+	if err != nil {
+		t.Fatal("decode error:", err)
+	}
+	if ok, _ := decodedProof.Verify(); !ok {
+		t.Errorf("decoded proof should verify successfully")
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	params := setupRange(t, int64(math.Pow(2, 32)))
+	proof, _, _ := MultiProve([]*big.Int{big.NewInt(3), big.NewInt(15)}, params)
+
+	empty := proof
+	empty.Vs = nil
+	if ok, err := empty.Verify(); ok || err == nil {
+		t.Errorf("proof without commitments should be rejected with an error")
+	}
+
+	short := proof
+	short.InnerProductProof.L = short.InnerProductProof.L[1:]
+	if ok, err := short.Verify(); ok || err == nil {
+		t.Errorf("proof with a short L vector should be rejected with an error")
+	}
+
+	incomplete := proof
+	incomplete.Mu = nil
+	if ok, err := incomplete.Verify(); ok || err == nil {
+		t.Errorf("incomplete proof should be rejected with an error")
+	}
+}
@@ -18,67 +18,93 @@
 package bulletproofs
 
 import (
-	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/takakv/msc-poc/group"
+	"hash"
 	"math/big"
-
-	"github.com/ing-bank/zkrp/util/bn"
-	"github.com/ing-bank/zkrp/util/intconversion"
 )
 
 /*
 powerOf returns a vector composed by powers of x.
 */
-func powerOf(x *big.Int, n int64, SP group.Group) []*big.Int {
+func powerOf(x group.Scalar, n int64, SP group.Group) []group.Scalar {
 	var (
 		i      int64
-		result []*big.Int
+		result []group.Scalar
 	)
-	result = make([]*big.Int, n)
-	current := intconversion.BigFromBase10("1")
+	result = make([]group.Scalar, n)
+	current := SP.NewScalar().SetUint64(1)
 	i = 0
 	for i < n {
 		result[i] = current
-		current = bn.Multiply(current, x)
-		current = bn.Mod(current, SP.N())
+		current = SP.NewScalar().Multiply(current, x)
 		i = i + 1
 	}
 	return result
 }
 
-/*
-HashBP is responsible for the computing a Zp element given elements from GT and G1.
-*/
-func HashBP(A, S group.Element) (*big.Int, *big.Int, error) {
+// writeDomain writes the length-prefixed domain of a bound proof to the
+// digest. Nothing is written for an empty domain.
+func writeDomain(digest hash.Hash, domain []byte) {
+	if len(domain) > 0 {
+		digest.Write(binary.AppendUvarint(nil, uint64(len(domain))))
+		digest.Write(domain)
+	}
+}
 
-	digest1 := sha256.New()
-	var buffer bytes.Buffer
-	buffer.WriteString(A.String())
-	buffer.WriteString(S.String())
-	digest1.Write(buffer.Bytes())
-	output1 := digest1.Sum(nil)
-	tmp1 := output1[0:]
-	result1 := new(big.Int).SetBytes(tmp1)
+// transcript derives the challenges of a range proof by Fiat–Shamir. The
+// first hash binds the domain and the statement, and every challenge is
+// chained on the previous one, so that it fixes all earlier messages.
+type transcript struct {
+	state []byte
+	SP    group.Group
+}
 
-	digest2 := sha256.New()
-	var buffer2 bytes.Buffer
-	buffer2.WriteString(A.String())
-	buffer2.WriteString(S.String())
-	buffer2.WriteString(result1.String())
-	digest2.Write(buffer.Bytes())
-	output2 := digest2.Sum(nil)
-	tmp2 := output2[0:]
-	result2 := new(big.Int).SetBytes(tmp2)
+// newTranscript starts the transcript of a range proof of the commitments
+// Vs for params in the domain.
+func newTranscript(domain []byte, params BulletProofSetupParams, Vs ...group.Element) *transcript {
+	digest := sha256.New()
+	writeDomain(digest, domain)
+	digest.Write(binary.AppendUvarint(nil, uint64(params.N)))
+	writeElements(digest, params.G, params.H)
+	writeElements(digest, params.Gg...)
+	writeElements(digest, params.Hh...)
+	digest.Write(binary.AppendUvarint(nil, uint64(len(Vs))))
+	writeElements(digest, Vs...)
+	return &transcript{state: digest.Sum(nil), SP: params.GP}
+}
 
-	return result1, result2, nil
+// challenge hashes the messages after the previous challenge, and returns
+// the digest as the next challenge.
+func (t *transcript) challenge(messages ...fmt.Stringer) group.Scalar {
+	digest := sha256.New()
+	digest.Write(t.state)
+	for _, m := range messages {
+		s := m.String()
+		digest.Write(binary.AppendUvarint(nil, uint64(len(s))))
+		digest.Write([]byte(s))
+	}
+	t.state = digest.Sum(nil)
+	return t.SP.NewScalar().SetBigInt(new(big.Int).SetBytes(t.state))
+}
+
+// writeElements writes the length-prefixed string encodings of the
+// elements to the digest.
+func writeElements(digest hash.Hash, elements ...group.Element) {
+	for _, x := range elements {
+		s := x.String()
+		digest.Write(binary.AppendUvarint(nil, uint64(len(s))))
+		digest.Write([]byte(s))
+	}
 }
 
 /*
 VectorExp computes Prod_i^n{a[i]^b[i]}.
 */
-func VectorExp(a []group.Element, b []*big.Int, SP group.Group) (group.Element, error) {
+func VectorExp(a []group.Element, b []group.Scalar, SP group.Group) (group.Element, error) {
 	var (
 		result  group.Element
 		i, n, m int64
@@ -102,9 +128,9 @@ func VectorExp(a []group.Element, b []*big.Int, SP group.Group) (group.Element,
 /*
 ScalarProduct return the inner product between a and b.
 */
-func ScalarProduct(a, b []*big.Int, SP group.Group) (*big.Int, error) {
+func ScalarProduct(a, b []group.Scalar, SP group.Group) (group.Scalar, error) {
 	var (
-		result  *big.Int
+		result  group.Scalar
 		i, n, m int64
 	)
 	n = int64(len(a))
@@ -113,11 +139,10 @@ func ScalarProduct(a, b []*big.Int, SP group.Group) (*big.Int, error) {
 		return nil, errors.New("Size of first argument is different from size of second argument.")
 	}
 	i = 0
-	result = intconversion.BigFromBase10("0")
+	result = SP.NewScalar()
 	for i < n {
-		ab := bn.Multiply(a[i], b[i])
+		ab := SP.NewScalar().Multiply(a[i], b[i])
 		result.Add(result, ab)
-		result = bn.Mod(result, SP.N())
 		i = i + 1
 	}
 	return result, nil
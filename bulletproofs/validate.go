@@ -0,0 +1,91 @@
+package bulletproofs
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/takakv/msc-poc/group"
+)
+
+// errMalformedProof is returned by Verify for proofs that are incomplete or
+// whose vectors do not match the parameters.
+var errMalformedProof = errors.New("malformed proof")
+
+func hasNil(elements []group.Element, scalars ...group.Scalar) bool {
+	for _, x := range elements {
+		if x == nil {
+			return true
+		}
+	}
+	for _, s := range scalars {
+		if s == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that the proof is complete and that its vectors have the
+// lengths the parameters require, so that Verify cannot panic.
+func (proof *InnerProductProof) validate() error {
+	params := proof.Params
+	n := len(params.Gg)
+	if params.GP == nil || params.Uu == nil || n == 0 || !IsPowerOfTwo(int64(n)) || len(params.Hh) != n {
+		return errMalformedProof
+	}
+	rounds := bits.Len(uint(n)) - 1
+	if len(proof.L) != rounds || len(proof.R) != rounds {
+		return errMalformedProof
+	}
+	elements := append([]group.Element{proof.P}, proof.L...)
+	elements = append(elements, proof.R...)
+	elements = append(elements, params.Gg...)
+	elements = append(elements, params.Hh...)
+	if hasNil(elements, proof.Cc, proof.A, proof.B) {
+		return errMalformedProof
+	}
+	return nil
+}
+
+func (params *BulletProofSetupParams) validate() error {
+	if params.GP == nil || params.N <= 0 || int64(len(params.Gg)) != params.N || int64(len(params.Hh)) != params.N {
+		return errMalformedProof
+	}
+	elements := append([]group.Element{params.G, params.H}, params.Gg...)
+	if hasNil(append(elements, params.Hh...)) {
+		return errMalformedProof
+	}
+	return nil
+}
+
+func (proof *BulletProof) validate() error {
+	if err := proof.Params.validate(); err != nil {
+		return err
+	}
+	if hasNil([]group.Element{proof.V, proof.A, proof.S, proof.T1, proof.T2},
+		proof.Taux, proof.Mu, proof.Tprime) {
+		return errMalformedProof
+	}
+	if int64(len(proof.InnerProductProof.Params.Gg)) != proof.Params.N {
+		return errMalformedProof
+	}
+	return proof.InnerProductProof.validate()
+}
+
+func (proof *MultiBulletProof) validate() error {
+	if err := proof.Params.validate(); err != nil {
+		return err
+	}
+	m := len(proof.Vs)
+	if m == 0 || proof.Params.N%int64(m) != 0 {
+		return errMalformedProof
+	}
+	if hasNil(append([]group.Element{proof.A, proof.S, proof.T1, proof.T2}, proof.Vs...),
+		proof.Taux, proof.Mu, proof.Tprime) {
+		return errMalformedProof
+	}
+	if int64(len(proof.InnerProductProof.Params.Gg)) != proof.Params.N {
+		return errMalformedProof
+	}
+	return proof.InnerProductProof.validate()
+}
@@ -21,19 +21,17 @@ import (
 	"errors"
 	"github.com/takakv/msc-poc/group"
 	"math/big"
-
-	"github.com/ing-bank/zkrp/util/bn"
 )
 
 /*
 VectorCopy returns a vector composed by copies of a.
 */
-func VectorCopy(a *big.Int, n int64) ([]*big.Int, error) {
+func VectorCopy(a group.Scalar, n int64) ([]group.Scalar, error) {
 	var (
 		i      int64
-		result []*big.Int
+		result []group.Scalar
 	)
-	result = make([]*big.Int, n)
+	result = make([]group.Scalar, n)
 	i = 0
 	for i < n {
 		result[i] = a
@@ -43,17 +41,17 @@ func VectorCopy(a *big.Int, n int64) ([]*big.Int, error) {
 }
 
 /*
-VectorConvertToBig converts an array of int64 to an array of big.Int.
+VectorConvertToScalar converts an array of int64 to an array of scalars of SP.
 */
-func VectorConvertToBig(a []int64, n int64) ([]*big.Int, error) {
+func VectorConvertToScalar(a []int64, n int64, SP group.Group) ([]group.Scalar, error) {
 	var (
 		i      int64
-		result []*big.Int
+		result []group.Scalar
 	)
-	result = make([]*big.Int, n)
+	result = make([]group.Scalar, n)
 	i = 0
 	for i < n {
-		result[i] = new(big.Int).SetInt64(a[i])
+		result[i] = SP.NewScalar().SetBigInt(big.NewInt(a[i]))
 		i = i + 1
 	}
 	return result, nil
@@ -62,9 +60,9 @@ func VectorConvertToBig(a []int64, n int64) ([]*big.Int, error) {
 /*
 VectorAdd computes vector addition componentwisely.
 */
-func VectorAdd(a, b []*big.Int, mod *big.Int) ([]*big.Int, error) {
+func VectorAdd(a, b []group.Scalar, SP group.Group) ([]group.Scalar, error) {
 	var (
-		result  []*big.Int
+		result  []group.Scalar
 		i, n, m int64
 	)
 	n = int64(len(a))
@@ -73,10 +71,9 @@ func VectorAdd(a, b []*big.Int, mod *big.Int) ([]*big.Int, error) {
 		return nil, errors.New("Size of first argument is different from size of second argument.")
 	}
 	i = 0
-	result = make([]*big.Int, n)
+	result = make([]group.Scalar, n)
 	for i < n {
-		result[i] = bn.Add(a[i], b[i])
-		result[i] = bn.Mod(result[i], mod)
+		result[i] = SP.NewScalar().Add(a[i], b[i])
 		i = i + 1
 	}
 	return result, nil
@@ -85,9 +82,9 @@ func VectorAdd(a, b []*big.Int, mod *big.Int) ([]*big.Int, error) {
 /*
 VectorSub computes vector addition componentwisely.
 */
-func VectorSub(a, b []*big.Int, mod *big.Int) ([]*big.Int, error) {
+func VectorSub(a, b []group.Scalar, SP group.Group) ([]group.Scalar, error) {
 	var (
-		result  []*big.Int
+		result  []group.Scalar
 		i, n, m int64
 	)
 	n = int64(len(a))
@@ -96,20 +93,18 @@ func VectorSub(a, b []*big.Int, mod *big.Int) ([]*big.Int, error) {
 		return nil, errors.New("Size of first argument is different from size of second argument.")
 	}
 	i = 0
-	result = make([]*big.Int, n)
+	result = make([]group.Scalar, n)
 	for i < n {
-		result[i] = bn.Sub(a[i], b[i])
-		result[i] = bn.Mod(result[i], mod)
+		result[i] = SP.NewScalar().Subtract(a[i], b[i])
 		i = i + 1
 	}
 	return result, nil
 }
 
-func VectorAddConst(a []*big.Int, c *big.Int, mod *big.Int) []*big.Int {
-	result := make([]*big.Int, len(a))
+func VectorAddConst(a []group.Scalar, c group.Scalar, SP group.Group) []group.Scalar {
+	result := make([]group.Scalar, len(a))
 	for i := range result {
-		result[i] = new(big.Int).Add(a[i], c)
-		result[i].Mod(result[i], mod)
+		result[i] = SP.NewScalar().Add(a[i], c)
 	}
 	return result
 }
@@ -117,17 +112,16 @@ func VectorAddConst(a []*big.Int, c *big.Int, mod *big.Int) []*big.Int {
 /*
 VectorScalarMul computes vector scalar multiplication componentwisely.
 */
-func VectorScalarMul(a []*big.Int, b *big.Int, mod *big.Int) ([]*big.Int, error) {
+func VectorScalarMul(a []group.Scalar, b group.Scalar, SP group.Group) ([]group.Scalar, error) {
 	var (
-		result []*big.Int
+		result []group.Scalar
 		i, n   int64
 	)
 	n = int64(len(a))
 	i = 0
-	result = make([]*big.Int, n)
+	result = make([]group.Scalar, n)
 	for i < n {
-		result[i] = bn.Multiply(a[i], b)
-		result[i] = bn.Mod(result[i], mod)
+		result[i] = SP.NewScalar().Multiply(a[i], b)
 		i = i + 1
 	}
 	return result, nil
@@ -136,9 +130,9 @@ func VectorScalarMul(a []*big.Int, b *big.Int, mod *big.Int) ([]*big.Int, error)
 /*
 VectorMul computes vector multiplication componentwisely.
 */
-func VectorMul(a, b []*big.Int, mod *big.Int) ([]*big.Int, error) {
+func VectorMul(a, b []group.Scalar, SP group.Group) ([]group.Scalar, error) {
 	var (
-		result  []*big.Int
+		result  []group.Scalar
 		i, n, m int64
 	)
 	n = int64(len(a))
@@ -147,22 +141,20 @@ func VectorMul(a, b []*big.Int, mod *big.Int) ([]*big.Int, error) {
 		return nil, errors.New("Size of first argument is different from size of second argument.")
 	}
 	i = 0
-	result = make([]*big.Int, n)
+	result = make([]group.Scalar, n)
 	for i < n {
-		result[i] = bn.Multiply(a[i], b[i])
-		result[i] = bn.Mod(result[i], mod)
+		result[i] = SP.NewScalar().Multiply(a[i], b[i])
 		i = i + 1
 	}
 	return result, nil
 }
 
-func VectorInnerProduct(a, b []*big.Int, mod *big.Int) *big.Int {
-	result := big.NewInt(0)
+func VectorInnerProduct(a, b []group.Scalar, SP group.Group) group.Scalar {
+	result := SP.NewScalar()
+	tmp := SP.NewScalar()
 	for i := range a {
-		tmp := new(big.Int).Mul(a[i], b[i])
-		result.Add(result, tmp.Mod(tmp, mod))
+		result.Add(result, tmp.Multiply(a[i], b[i]))
 	}
-	result.Mod(result, mod)
 	return result
 }
 
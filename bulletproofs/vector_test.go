@@ -30,33 +30,34 @@ Test method VectorCopy, which simply copies the first input argument to size n v
 */
 func TestVectorCopy(t *testing.T) {
 	var (
-		result []*big.Int
+		result []group.Scalar
 	)
-	result, _ = VectorCopy(big.NewInt(1), 3)
-	ok := result[0].Cmp(big.NewInt(1)) == 0
-	ok = ok && (result[1].Cmp(big.NewInt(1)) == 0)
-	ok = ok && (result[2].Cmp(big.NewInt(1)) == 0)
+	one := group.SecP256k1().NewScalar().SetUint64(1)
+	result, _ = VectorCopy(one, 3)
+	ok := result[0].IsEqual(one)
+	ok = ok && result[1].IsEqual(one)
+	ok = ok && result[2].IsEqual(one)
 	if ok != true {
 		t.Errorf("Assert failure: expected true, actual: %t", ok)
 	}
 }
 
 /*
-Test method VectorConvertToBig.
+Test method VectorConvertToScalar.
 */
-func TestVectorConvertToBig(t *testing.T) {
+func TestVectorConvertToScalar(t *testing.T) {
 	var (
-		result []*big.Int
+		result []group.Scalar
 		a      []int64
 	)
 	a = make([]int64, 3)
 	a[0] = 3
 	a[1] = 4
-	a[2] = 5
-	result, _ = VectorConvertToBig(a, 3)
-	ok := result[0].Cmp(big.NewInt(3)) == 0
-	ok = ok && (result[1].Cmp(big.NewInt(4)) == 0)
-	ok = ok && (result[2].Cmp(big.NewInt(5)) == 0)
+	a[2] = -5
+	result, _ = VectorConvertToScalar(a, 3, group.SecP256k1())
+	ok := result[0].BigInt().Cmp(big.NewInt(3)) == 0
+	ok = ok && (result[1].BigInt().Cmp(big.NewInt(4)) == 0)
+	ok = ok && (result[2].BigInt().Cmp(new(big.Int).Sub(group.SecP256k1().N(), big.NewInt(5))) == 0)
 	if ok != true {
 		t.Errorf("Assert failure: expected true, actual: %t", ok)
 	}
@@ -66,23 +67,14 @@ func TestVectorConvertToBig(t *testing.T) {
 Tests Vector addition.
 */
 func TestVectorAdd(t *testing.T) {
-	var (
-		a, b []*big.Int
-	)
 	var SecP256k1Group = group.SecP256k1()
 
-	a = make([]*big.Int, 3)
-	b = make([]*big.Int, 3)
-	a[0] = new(big.Int).SetInt64(7)
-	a[1] = new(big.Int).SetInt64(8)
-	a[2] = new(big.Int).SetInt64(9)
-	b[0] = new(big.Int).SetInt64(3)
-	b[1] = new(big.Int).SetInt64(30)
-	b[2] = new(big.Int).SetInt64(40)
-	result, _ := VectorAdd(a, b, SecP256k1Group.N())
-	ok := result[0].Cmp(new(big.Int).SetInt64(10)) == 0
-	ok = ok && (result[1].Cmp(intconversion.BigFromBase10("38")) == 0)
-	ok = ok && (result[2].Cmp(intconversion.BigFromBase10("49")) == 0)
+	a, _ := VectorConvertToScalar([]int64{7, 8, 9}, 3, SecP256k1Group)
+	b, _ := VectorConvertToScalar([]int64{3, 30, 40}, 3, SecP256k1Group)
+	result, _ := VectorAdd(a, b, SecP256k1Group)
+	ok := result[0].BigInt().Cmp(new(big.Int).SetInt64(10)) == 0
+	ok = ok && (result[1].BigInt().Cmp(intconversion.BigFromBase10("38")) == 0)
+	ok = ok && (result[2].BigInt().Cmp(intconversion.BigFromBase10("49")) == 0)
 	if ok != true {
 		t.Errorf("Assert failure: expected true, actual: %t", ok)
 	}
@@ -92,23 +84,14 @@ func TestVectorAdd(t *testing.T) {
 Tests Vector subtraction.
 */
 func TestVectorSub(t *testing.T) {
-	var (
-		a, b []*big.Int
-	)
 	var SecP256k1Group = group.SecP256k1()
 
-	a = make([]*big.Int, 3)
-	b = make([]*big.Int, 3)
-	a[0] = new(big.Int).SetInt64(7)
-	a[1] = new(big.Int).SetInt64(8)
-	a[2] = new(big.Int).SetInt64(9)
-	b[0] = new(big.Int).SetInt64(3)
-	b[1] = new(big.Int).SetInt64(30)
-	b[2] = new(big.Int).SetInt64(40)
-	result, _ := VectorSub(a, b, SecP256k1Group.N())
-	ok := result[0].Cmp(new(big.Int).SetInt64(4)) == 0
-	ok = ok && (result[1].Cmp(intconversion.BigFromBase10("115792089237316195423570985008687907852837564279074904382605163141518161494315")) == 0)
-	ok = ok && (result[2].Cmp(intconversion.BigFromBase10("115792089237316195423570985008687907852837564279074904382605163141518161494306")) == 0)
+	a, _ := VectorConvertToScalar([]int64{7, 8, 9}, 3, SecP256k1Group)
+	b, _ := VectorConvertToScalar([]int64{3, 30, 40}, 3, SecP256k1Group)
+	result, _ := VectorSub(a, b, SecP256k1Group)
+	ok := result[0].BigInt().Cmp(new(big.Int).SetInt64(4)) == 0
+	ok = ok && (result[1].BigInt().Cmp(intconversion.BigFromBase10("115792089237316195423570985008687907852837564279074904382605163141518161494315")) == 0)
+	ok = ok && (result[2].BigInt().Cmp(intconversion.BigFromBase10("115792089237316195423570985008687907852837564279074904382605163141518161494306")) == 0)
 	if ok != true {
 		t.Errorf("Assert failure: expected true, actual: %t", ok)
 	}
@@ -118,23 +101,14 @@ func TestVectorSub(t *testing.T) {
 Tests Vector componentwise multiplication.
 */
 func TestVectorMul(t *testing.T) {
-	var (
-		a, b []*big.Int
-	)
 	var SecP256k1Group = group.SecP256k1()
 
-	a = make([]*big.Int, 3)
-	b = make([]*big.Int, 3)
-	a[0] = new(big.Int).SetInt64(7)
-	a[1] = new(big.Int).SetInt64(8)
-	a[2] = new(big.Int).SetInt64(9)
-	b[0] = new(big.Int).SetInt64(3)
-	b[1] = new(big.Int).SetInt64(30)
-	b[2] = new(big.Int).SetInt64(40)
-	result, _ := VectorMul(a, b, SecP256k1Group.N())
-	ok := result[0].Cmp(new(big.Int).SetInt64(21)) == 0
-	ok = ok && (result[1].Cmp(new(big.Int).SetInt64(240)) == 0)
-	ok = ok && (result[2].Cmp(new(big.Int).SetInt64(360)) == 0)
+	a, _ := VectorConvertToScalar([]int64{7, 8, 9}, 3, SecP256k1Group)
+	b, _ := VectorConvertToScalar([]int64{3, 30, 40}, 3, SecP256k1Group)
+	result, _ := VectorMul(a, b, SecP256k1Group)
+	ok := result[0].BigInt().Cmp(new(big.Int).SetInt64(21)) == 0
+	ok = ok && (result[1].BigInt().Cmp(new(big.Int).SetInt64(240)) == 0)
+	ok = ok && (result[2].BigInt().Cmp(new(big.Int).SetInt64(360)) == 0)
 
 	if ok != true {
 		t.Errorf("Assert failure: expected true, actual: %t", ok)
@@ -0,0 +1,285 @@
+// Package collector implements an HTTP service that collects ballots and
+// verifies their proofs.
+package collector
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/takakv/msc-poc/ballot"
+	"github.com/takakv/msc-poc/util"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBallotSize is the default limit on the size of a submitted
+// ballot. JSON ballots are a few tens of kilobytes.
+const DefaultMaxBallotSize = 256 << 10
+
+// Server serves the collector API:
+//
+//	POST /ballots       submit a ballot, and receive its ID and receipt
+//	GET  /ballots/{id}  query the status of a ballot
+//	GET  /params        fetch the public parameters
+type Server struct {
+	// MaxBallotSize limits the size of submitted ballots in bytes.
+	MaxBallotSize int64
+	// Authenticate, if set, returns the identity of the submitter of a
+	// request, which must be the voter ID of the ballot. Since the proofs
+	// only bind a ballot to the voter ID that it names, it must be set
+	// unless submissions are authenticated before they reach the server.
+	Authenticate func(*http.Request) (string, error)
+	// Queue, if set, verifies the ballots asynchronously. Submissions are
+	// then answered before verification, with the ballot pending.
+	Queue *Queue
+
+	pp     ballot.PublicParameters
+	params []byte
+	store  Store
+}
+
+// NewServer creates a collector for the election with parameters pp,
+// which records ballots in store.
+func NewServer(pp ballot.PublicParameters, store Store) (*Server, error) {
+	params, err := json.Marshal(pp)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		MaxBallotSize: DefaultMaxBallotSize,
+		pp:            pp,
+		params:        params,
+		store:         store,
+	}, nil
+}
+
+// BearerTokens returns an Authenticate hook for the voter list tokens,
+// which maps every voter ID to a secret token. Submitters identify
+// themselves with the header "Authorization: Bearer <token>".
+func BearerTokens(tokens map[string]string) func(*http.Request) (string, error) {
+	voters := make(map[string]string, len(tokens))
+	for id, token := range tokens {
+		voters[token] = id
+	}
+	return func(r *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return "", errors.New("missing bearer token")
+		}
+		id, ok := voters[token]
+		if !ok || token == "" {
+			return "", errors.New("unknown bearer token")
+		}
+		return id, nil
+	}
+}
+
+// Error is the body of an error response.
+type Error struct {
+	// Code identifies the kind of error.
+	Code string `json:"code"`
+	// Message describes the error.
+	Message string `json:"message"`
+	// Field is the path of the offending field of a malformed ballot.
+	Field string `json:"field,omitempty"`
+}
+
+type errorResponse struct {
+	Error Error `json:"error"`
+}
+
+// SubmitResponse is the body of the response to a ballot submission.
+// Ballots whose proofs do not verify are recorded as invalid, and the
+// response describes the failure.
+type SubmitResponse struct {
+	ID      string `json:"id"`
+	Receipt string `json:"receipt"`
+	Status  Status `json:"status"`
+	Error   *Error `json:"error,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, err error) {
+	e := Error{Code: code, Message: err.Error()}
+	var pe *util.PathError
+	if errors.As(err, &pe) {
+		e.Field = pe.Path
+		e.Message = pe.Err.Error()
+	}
+	writeJSON(w, status, errorResponse{e})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed string
+	switch {
+	case r.URL.Path == "/ballots":
+		allowed = http.MethodPost
+		if r.Method == allowed {
+			s.submit(w, r)
+			return
+		}
+	case strings.HasPrefix(r.URL.Path, "/ballots/"):
+		allowed = http.MethodGet
+		if r.Method == allowed {
+			s.status(w, strings.TrimPrefix(r.URL.Path, "/ballots/"))
+			return
+		}
+	case r.URL.Path == "/params":
+		allowed = http.MethodGet
+		if r.Method == allowed {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(s.params)
+			return
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not_found", errors.New("no such endpoint"))
+		return
+	}
+
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", errors.New("method not allowed"))
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type",
+			errors.New("ballots must be submitted as application/json"))
+		return
+	}
+	var voterID string
+	if s.Authenticate != nil {
+		id, err := s.Authenticate(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		voterID = id
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.MaxBallotSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "too_large", errors.New("ballot is too large"))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "bad_request", err)
+		return
+	}
+
+	bd, err := ballot.BallotDataUnmarshalJSON(body, s.pp)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "malformed_ballot", err)
+		return
+	}
+	if s.Authenticate != nil && bd.VoterID != voterID {
+		writeError(w, http.StatusForbidden, "wrong_voter",
+			util.WrapPath("voterId", errors.New("ballot is not cast by the authenticated voter")))
+		return
+	}
+
+	// The receipt commits to the canonical encoding of the ballot, so that
+	// it does not depend on how the JSON was formatted.
+	encoded, err := bd.MarshalBinary()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "malformed_ballot", err)
+		return
+	}
+	record := Record{
+		ID:       newID(),
+		Receipt:  receipt(encoded),
+		Status:   StatusPending,
+		Received: time.Now().UTC(),
+		Ballot:   encoded,
+	}
+
+	if s.Queue != nil {
+		s.enqueue(w, record, bd)
+		return
+	}
+
+	if _, err = ballot.Verify(bd, s.pp); err != nil {
+		record.Status = StatusInvalid
+		record.Reason = err.Error()
+	} else {
+		record.Status = StatusValid
+	}
+
+	if !s.add(w, record) {
+		return
+	}
+
+	resp := SubmitResponse{ID: record.ID, Receipt: record.Receipt, Status: record.Status}
+	if record.Status == StatusInvalid {
+		resp.Error = &Error{Code: "invalid_ballot", Message: record.Reason}
+		writeJSON(w, http.StatusUnprocessableEntity, resp)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// add stores the record, and reports whether it succeeded. Otherwise, it
+// writes the error response.
+func (s *Server) add(w http.ResponseWriter, record Record) bool {
+	err := s.store.Add(record)
+	if errors.Is(err, ErrDuplicate) {
+		writeError(w, http.StatusConflict, "duplicate_ballot", err)
+		return false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", errors.New("failed to store the ballot"))
+		return false
+	}
+	return true
+}
+
+// enqueue stores the pending record, and queues its ballot for verification.
+func (s *Server) enqueue(w http.ResponseWriter, record Record, bd ballot.BallotData) {
+	if !s.add(w, record) {
+		return
+	}
+	if err := s.Queue.Submit(record.ID, bd); err != nil {
+		// The ballot is not kept, so that it can be submitted again.
+		_ = s.store.Delete(record.ID)
+		if errors.Is(err, ErrQueueFull) {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeError(w, http.StatusServiceUnavailable, "unavailable", err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, SubmitResponse{ID: record.ID, Receipt: record.Receipt, Status: record.Status})
+}
+
+func (s *Server) status(w http.ResponseWriter, id string) {
+	record, err := s.store.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", errors.New("failed to read the ballot"))
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// receipt returns the hex-encoded SHA-256 digest of the encoded ballot.
+func receipt(encoded []byte) string {
+	digest := sha256.Sum256(encoded)
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/takakv/msc-poc/ballot"
+	"github.com/takakv/msc-poc/group"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, ballot.PublicParameters) {
+	t.Helper()
+	pp, err := ballot.Setup(group.P256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(pp, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts, pp
+}
+
+func post(t *testing.T, ts *httptest.Server, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/ballots", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(resp.Body)
+	return resp, buf.Bytes()
+}
+
+func get(t *testing.T, ts *httptest.Server, path string, v any) int {
+	t.Helper()
+	resp, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestSubmit(t *testing.T) {
+	ts, pp := newTestServer(t)
+
+	vote, _, err := ballot.Cast(pp.CandidateMin, "voter", pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(vote)
+
+	resp, data := post(t, ts, body)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, data)
+	}
+	var submitted SubmitResponse
+	if err = json.Unmarshal(data, &submitted); err != nil {
+		t.Fatal(err)
+	}
+	if submitted.Status != StatusValid || submitted.ID == "" || len(submitted.Receipt) != 64 {
+		t.Errorf("unexpected response %+v", submitted)
+	}
+
+	var record Record
+	if status := get(t, ts, "/ballots/"+submitted.ID, &record); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if record.Status != StatusValid || record.Receipt != submitted.Receipt {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	// The same ballot cannot be submitted twice, even if it is re-formatted.
+	var indented bytes.Buffer
+	_ = json.Indent(&indented, body, "", "  ")
+	if resp, _ = post(t, ts, indented.Bytes()); resp.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate ballot: unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestSubmitInvalid(t *testing.T) {
+	ts, pp := newTestServer(t)
+
+	// The proofs of a ballot do not verify for another ciphertext.
+	vote, _, _ := ballot.Cast(pp.CandidateMin, "voter", pp)
+	other, _, _ := ballot.Cast(pp.CandidateMax, "voter", pp)
+	vote.Ballot, vote.PoK = other.Ballot, other.PoK
+	body, _ := json.Marshal(vote)
+
+	resp, data := post(t, ts, body)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("unexpected status %d: %s", resp.StatusCode, data)
+	}
+	var submitted SubmitResponse
+	_ = json.Unmarshal(data, &submitted)
+	if submitted.Status != StatusInvalid || submitted.Error == nil || submitted.Error.Code != "invalid_ballot" {
+		t.Errorf("unexpected response %s", data)
+	}
+
+	var record Record
+	get(t, ts, "/ballots/"+submitted.ID, &record)
+	if record.Status != StatusInvalid || record.Reason != ballot.ErrVoteProof.Error() {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	fixture, err := os.ReadFile("../testdata/P256rp.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	malformed := bytes.Replace(fixture, []byte(`"lbProof"`), []byte(`"lowerProof"`), 1)
+
+	tests := []struct {
+		name   string
+		body   []byte
+		status int
+		code   string
+	}{
+		{"empty", nil, http.StatusBadRequest, "malformed_ballot"},
+		{"not JSON", []byte("ballot"), http.StatusBadRequest, "malformed_ballot"},
+		{"malformed", malformed, http.StatusBadRequest, "malformed_ballot"},
+		{"too large", bytes.Repeat([]byte(" "), DefaultMaxBallotSize+1), http.StatusRequestEntityTooLarge, "too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data := post(t, ts, tt.body)
+			var e errorResponse
+			if err := json.Unmarshal(data, &e); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || e.Error.Code != tt.code {
+				t.Errorf("got %d %q, wanted %d %q", resp.StatusCode, e.Error.Code, tt.status, tt.code)
+			}
+		})
+	}
+
+	var e errorResponse
+	if status := get(t, ts, "/ballots/unknown", &e); status != http.StatusNotFound || e.Error.Code != "not_found" {
+		t.Errorf("unknown ballot: got %d %q", status, e.Error.Code)
+	}
+
+	resp, err := http.Post(ts.URL+"/ballots", "text/plain", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("wrong content type: unexpected status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/params", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodGet {
+		t.Errorf("wrong method: unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestMalformedField(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	fixture, _ := os.ReadFile("../testdata/P256rp.json")
+	var m map[string]json.RawMessage
+	_ = json.Unmarshal(fixture, &m)
+	m["ballot"] = json.RawMessage(`{"u": "one", "v": 1}`)
+	body, _ := json.Marshal(m)
+
+	_, data := post(t, ts, body)
+	var e errorResponse
+	_ = json.Unmarshal(data, &e)
+	if e.Error.Field != "ballot.u" {
+		t.Errorf("error does not point to the field: %s", data)
+	}
+}
+
+func TestParams(t *testing.T) {
+	ts, pp := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/params")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(resp.Body)
+
+	decoded, err := ballot.ParamsUnmarshalJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.RPParams.IsEqual(&pp.RPParams) {
+		t.Error("served parameters differ")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	pp, err := ballot.Setup(group.P256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(pp, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Authenticate = func(r *http.Request) (string, error) {
+		id := r.Header.Get("X-Voter")
+		if id == "" {
+			return "", errors.New("missing voter identity")
+		}
+		return id, nil
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	vote, _, err := ballot.Cast(pp.CandidateMin, "alice", pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(vote)
+	submit := func(voter string) (int, Error) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/ballots", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if voter != "" {
+			req.Header.Set("X-Voter", voter)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var e errorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, e.Error
+	}
+
+	if status, e := submit(""); status != http.StatusUnauthorized || e.Code != "unauthorized" {
+		t.Errorf("unauthenticated: unexpected status %d and error %+v", status, e)
+	}
+	// A valid ballot of another voter must not be accepted.
+	if status, e := submit("bob"); status != http.StatusForbidden || e.Code != "wrong_voter" || e.Field != "voterId" {
+		t.Errorf("other voter: unexpected status %d and error %+v", status, e)
+	}
+	if status, _ := submit("alice"); status != http.StatusCreated {
+		t.Errorf("authenticated voter: unexpected status %d", status)
+	}
+}
+
+func TestBearerTokens(t *testing.T) {
+	auth := BearerTokens(map[string]string{"alice": "secret", "bob": ""})
+	for header, want := range map[string]string{
+		"Bearer secret": "alice",
+		"Bearer other":  "",
+		"Bearer ":       "",
+		"secret":        "",
+		"":              "",
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/ballots", nil)
+		r.Header.Set("Authorization", header)
+		id, err := auth(r)
+		if id != want || (err == nil) != (want != "") {
+			t.Errorf("%q: got %q and %v", header, id, err)
+		}
+	}
+}
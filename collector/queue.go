@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/takakv/msc-poc/ballot"
+	"runtime"
+	"sync"
+)
+
+// DefaultQueueSize is the default number of ballots that can wait for
+// verification.
+const DefaultQueueSize = 1024
+
+var (
+	ErrQueueFull   = errors.New("verification queue is full")
+	ErrQueueClosed = errors.New("verification queue is closed")
+)
+
+type job struct {
+	id string
+	bd ballot.BallotData
+}
+
+// Queue verifies ballots concurrently, and records the results in a store.
+// Ballots wait in a bounded queue until one of the workers is free.
+type Queue struct {
+	pp    ballot.PublicParameters
+	store Store
+	jobs  chan job
+
+	// mu guards closed, so that no job is sent on a closed channel.
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewQueue starts workers that verify ballots for the election with
+// parameters pp, and update their status in store. At most size ballots
+// wait for verification. If size or workers is not positive,
+// DefaultQueueSize or GOMAXPROCS is used instead.
+func NewQueue(pp ballot.PublicParameters, store Store, size, workers int) *Queue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	q := &Queue{
+		pp:    pp,
+		store: store,
+		jobs:  make(chan job, size),
+	}
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for j := range q.jobs {
+		status, reason := StatusValid, ""
+		if _, err := ballot.Verify(j.bd, q.pp); err != nil {
+			status, reason = StatusInvalid, err.Error()
+		}
+		// The record may have been deleted in the meantime.
+		_ = q.store.Update(j.id, status, reason)
+	}
+}
+
+// Submit queues the ballot of the pending record id for verification.
+// It does not block, and returns ErrQueueFull if the queue is full.
+func (q *Queue) Submit(id string, bd ballot.BallotData) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.jobs <- job{id, bd}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Enqueue is like Submit, but waits for room in the queue until ctx is done.
+func (q *Queue) Enqueue(ctx context.Context, id string, bd ballot.BallotData) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.jobs <- job{id, bd}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reverify marks the records with the given IDs as pending, and queues
+// their ballots for verification again. It waits for room in the queue
+// until ctx is done. A record that cannot be queued keeps its status, and
+// the errors of all such records are returned together.
+func (q *Queue) Reverify(ctx context.Context, ids []string) error {
+	var errs []error
+	for _, id := range ids {
+		if err := q.reverify(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("ballot %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (q *Queue) reverify(ctx context.Context, id string) error {
+	record, err := q.store.Get(id)
+	if err != nil {
+		return err
+	}
+	bd, err := ballot.BallotDataUnmarshalBinary(record.Ballot, q.pp)
+	if err != nil {
+		return err
+	}
+	// The record is marked before it is queued, since a worker may update
+	// it as soon as it is queued.
+	if err = q.store.Update(id, StatusPending, ""); err != nil {
+		return err
+	}
+	if err = q.Enqueue(ctx, id, bd); err != nil {
+		_ = q.store.Update(id, record.Status, record.Reason)
+		return err
+	}
+	return nil
+}
+
+// Close stops accepting ballots, and waits until the queued ones are
+// verified.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
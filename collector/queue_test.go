@@ -0,0 +1,225 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/takakv/msc-poc/ballot"
+	"github.com/takakv/msc-poc/group"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// gatedStore blocks updates until the gate is opened, and signals when
+// an update starts waiting.
+type gatedStore struct {
+	*MemoryStore
+	waiting chan struct{}
+	gate    chan struct{}
+}
+
+func newGatedStore() *gatedStore {
+	return &gatedStore{
+		MemoryStore: NewMemoryStore(),
+		waiting:     make(chan struct{}, 16),
+		gate:        make(chan struct{}),
+	}
+}
+
+func (s *gatedStore) Update(id string, status Status, reason string) error {
+	if status != StatusPending {
+		s.waiting <- struct{}{}
+		<-s.gate
+	}
+	return s.MemoryStore.Update(id, status, reason)
+}
+
+// castPending casts a ballot, which is valid unless tampered is set, and
+// stores it as pending.
+func castPending(t *testing.T, pp ballot.PublicParameters, store Store, tampered bool) (string, ballot.BallotData) {
+	t.Helper()
+	bd, _, err := ballot.Cast(ballot.RandomChoice(pp), "voter", pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tampered {
+		other, _, _ := ballot.Cast(ballot.RandomChoice(pp), "voter", pp)
+		bd.Ballot, bd.PoK = other.Ballot, other.PoK
+	}
+	encoded, err := bd.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := newID()
+	if err = store.Add(Record{ID: id, Receipt: receipt(encoded), Status: StatusPending, Ballot: encoded}); err != nil {
+		t.Fatal(err)
+	}
+	return id, bd
+}
+
+func checkStatus(t *testing.T, store Store, id string, want Status) {
+	t.Helper()
+	record, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != want {
+		t.Errorf("ballot %s is %s, wanted %s", id, record.Status, want)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	pp, err := ballot.Setup(group.P256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	q := NewQueue(pp, store, 0, 0)
+
+	want := make(map[string]Status)
+	for i := 0; i < 8; i++ {
+		tampered := i%3 == 0
+		id, bd := castPending(t, pp, store, tampered)
+		want[id] = StatusValid
+		if tampered {
+			want[id] = StatusInvalid
+		}
+		if err = q.Submit(id, bd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+
+	for id, status := range want {
+		checkStatus(t, store, id, status)
+	}
+	if err = q.Submit("", ballot.BallotData{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("submission after closing: got %v", err)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	pp, _ := ballot.Setup(group.P256())
+	store := newGatedStore()
+	q := NewQueue(pp, store, 1, 1)
+
+	// The worker blocks on the first ballot, and the second fills the queue.
+	id1, bd1 := castPending(t, pp, store, false)
+	if err := q.Submit(id1, bd1); err != nil {
+		t.Fatal(err)
+	}
+	<-store.waiting
+	id2, bd2 := castPending(t, pp, store, false)
+	if err := q.Submit(id2, bd2); err != nil {
+		t.Fatal(err)
+	}
+	id3, bd3 := castPending(t, pp, store, false)
+	if err := q.Submit(id3, bd3); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, wanted %v", err, ErrQueueFull)
+	}
+	checkStatus(t, store, id2, StatusPending)
+
+	close(store.gate)
+	if err := q.Enqueue(context.Background(), id3, bd3); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	for _, id := range []string{id1, id2, id3} {
+		checkStatus(t, store, id, StatusValid)
+	}
+}
+
+func TestReverify(t *testing.T) {
+	pp, _ := ballot.Setup(group.P256())
+	store := NewMemoryStore()
+	valid, _ := castPending(t, pp, store, false)
+	invalid, _ := castPending(t, pp, store, true)
+
+	q := NewQueue(pp, store, 1, 0)
+	if err := q.Reverify(context.Background(), store.IDs()); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	checkStatus(t, store, valid, StatusValid)
+	checkStatus(t, store, invalid, StatusInvalid)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q = NewQueue(pp, store, 1, 1)
+	defer q.Close()
+	if err := q.Reverify(ctx, []string{"unknown"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown ballot: got %v", err)
+	}
+}
+
+// TestReverifyFull cancels the reverification of records while the queue
+// is full, which must leave them as they were.
+func TestReverifyFull(t *testing.T) {
+	pp, _ := ballot.Setup(group.P256())
+	store := NewMemoryStore()
+	valid, _ := castPending(t, pp, store, false)
+	invalid, _ := castPending(t, pp, store, true)
+	_ = store.Update(valid, StatusValid, "")
+	_ = store.Update(invalid, StatusInvalid, "reason")
+
+	// Without workers, a single job fills the queue.
+	q := &Queue{pp: pp, store: store, jobs: make(chan job, 1)}
+	q.jobs <- job{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := q.Reverify(ctx, []string{valid, invalid}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, wanted %v", err, context.Canceled)
+	}
+	checkStatus(t, store, valid, StatusValid)
+	checkStatus(t, store, invalid, StatusInvalid)
+	if record, _ := store.Get(invalid); record.Reason != "reason" {
+		t.Errorf("reason was not restored: %q", record.Reason)
+	}
+}
+
+func TestSubmitQueued(t *testing.T) {
+	pp, _ := ballot.Setup(group.P256())
+	store := newGatedStore()
+	s, err := NewServer(pp, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Queue = NewQueue(pp, store, 1, 1)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	submit := func(want int) SubmitResponse {
+		t.Helper()
+		vote, _, _ := ballot.Cast(ballot.RandomChoice(pp), "voter", pp)
+		body, _ := json.Marshal(vote)
+		resp, data := post(t, ts, body)
+		if resp.StatusCode != want {
+			t.Fatalf("unexpected status %d: %s", resp.StatusCode, data)
+		}
+		var submitted SubmitResponse
+		_ = json.Unmarshal(data, &submitted)
+		return submitted
+	}
+
+	first := submit(http.StatusAccepted)
+	if first.Status != StatusPending {
+		t.Errorf("unexpected response %+v", first)
+	}
+	<-store.waiting
+	second := submit(http.StatusAccepted)
+	submit(http.StatusServiceUnavailable)
+	if n := len(store.IDs()); n != 2 {
+		t.Errorf("rejected ballot was stored: %d ballots", n)
+	}
+
+	close(store.gate)
+	s.Queue.Close()
+	for _, id := range []string{first.ID, second.ID} {
+		var record Record
+		get(t, ts, "/ballots/"+id, &record)
+		if record.Status != StatusValid {
+			t.Errorf("unexpected record %+v", record)
+		}
+	}
+}
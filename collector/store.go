@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Status is the verification status of a ballot. A ballot is pending
+// until its proofs are verified, after which it is valid or invalid.
+// Re-verification returns it to pending.
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusValid   Status = "valid"
+	StatusInvalid Status = "invalid"
+)
+
+var (
+	ErrNotFound  = errors.New("ballot not found")
+	ErrDuplicate = errors.New("ballot was already submitted")
+)
+
+// Record is the stored state of a submitted ballot.
+type Record struct {
+	ID       string    `json:"id"`
+	Receipt  string    `json:"receipt"`
+	Status   Status    `json:"status"`
+	Reason   string    `json:"reason,omitempty"`
+	Received time.Time `json:"received"`
+	// Ballot is the binary encoding of the ballot.
+	Ballot []byte `json:"-"`
+}
+
+// Store keeps the submitted ballots. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Add stores a new record, and returns ErrDuplicate if a record with
+	// the same receipt exists.
+	Add(r Record) error
+	// Get returns the record with the given ID.
+	Get(id string) (Record, error)
+	// Update replaces the status and reason of the record with the given ID.
+	Update(id string, status Status, reason string) error
+	// Delete removes the record with the given ID.
+	Delete(id string) error
+	// IDs lists the IDs of all records.
+	IDs() []string
+}
+
+// MemoryStore is a Store that keeps the records in memory.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	records  map[string]Record
+	receipts map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:  make(map[string]Record),
+		receipts: make(map[string]string),
+	}
+}
+
+func (s *MemoryStore) Add(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.receipts[r.Receipt]; ok {
+		return ErrDuplicate
+	}
+	s.records[r.ID] = r
+	s.receipts[r.Receipt] = r.ID
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[id]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *MemoryStore) Update(id string, status Status, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	r.Status = status
+	r.Reason = reason
+	s.records[id] = r
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.records, id)
+	delete(s.receipts, r.Receipt)
+	return nil
+}
+
+func (s *MemoryStore) IDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	return ids
+}
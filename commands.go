@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/takakv/msc-poc/ballot"
+	"github.com/takakv/msc-poc/collector"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/simulator"
+	"github.com/takakv/msc-poc/voteproof"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// command holds the options shared by all commands.
+type command struct {
+	paramsFile string
+	out        io.Writer
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func (c *command) loadParams() (ballot.PublicParameters, error) {
+	b, err := os.ReadFile(c.paramsFile)
+	if err != nil {
+		return ballot.PublicParameters{}, err
+	}
+	pp, err := ballot.ParamsUnmarshalJSON(b)
+	if err != nil {
+		return ballot.PublicParameters{}, fmt.Errorf("%s: %w", c.paramsFile, err)
+	}
+	return pp, nil
+}
+
+// loadTokens reads a voter list, a JSON object that maps voter IDs to
+// their bearer tokens.
+func loadTokens(name string) (map[string]string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var tokens map[string]string
+	if err = json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return tokens, nil
+}
+
+func writeJSON(name string, v any, perm os.FileMode) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, b, perm)
+}
+
+// lookupGroups resolves a comma-separated list of group names.
+func lookupGroups(names string) ([]group.Group, error) {
+	var groups []group.Group
+	for _, name := range strings.Split(names, ",") {
+		g, err := group.Lookup(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// parseModes resolves a comma-separated list of ballot modes.
+func parseModes(names string) ([]ballot.Mode, bool) {
+	var modes []ballot.Mode
+	for _, name := range strings.Split(names, ",") {
+		mode := ballot.Mode(strings.TrimSpace(name))
+		if mode != ballot.CrossGroup && mode != ballot.SingleGroup {
+			return nil, false
+		}
+		modes = append(modes, mode)
+	}
+	return modes, true
+}
+
+// setupMode generates the public parameters of an election in g with
+// ballots of the given mode. The requirements only apply to the vote proof
+// of cross-group ballots.
+func setupMode(g group.Group, mode ballot.Mode, req voteproof.Requirements) (ballot.PublicParameters, error) {
+	if mode == ballot.SingleGroup {
+		return ballot.SetupSingleGroup(g)
+	}
+	return ballot.SetupFor(g, req)
+}
+
+// requirementFlags defines the flags of the requirements of the vote proof
+// parameters, and returns a function that reads them after parsing.
+func requirementFlags(fs *flag.FlagSet) func() (voteproof.Requirements, bool) {
+	def := ballot.DefaultRequirements
+	security := fs.Int("security", def.Security, "target security level in bits")
+	challenge := fs.Uint("challenge", uint(def.ChallengeLen), "challenge length in bits, or 0 for the longest that fits")
+	return func() (voteproof.Requirements, bool) {
+		if *challenge > math.MaxUint16 {
+			return voteproof.Requirements{}, false
+		}
+		return voteproof.Requirements{Security: *security, ChallengeLen: uint16(*challenge)}, true
+	}
+}
+
+func (c *command) setup(args []string) error {
+	fs := newFlagSet("setup")
+	groupName := fs.String("group", "P-256", "group of the range proofs")
+	electionID := fs.String("election", "", "identifier of the election")
+	modeName := fs.String("mode", string(ballot.CrossGroup), "ballot mode: cross-group or single-group")
+	requirements := requirementFlags(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	req, ok := requirements()
+	modes, okMode := parseModes(*modeName)
+	if !ok || !okMode || len(modes) != 1 {
+		return errUsage
+	}
+
+	g, err := group.Lookup(*groupName)
+	if err != nil {
+		return err
+	}
+	pp, err := setupMode(g, modes[0], req)
+	if err != nil {
+		return err
+	}
+	pp.ElectionID = *electionID
+	if err = writeJSON(c.paramsFile, pp, 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Wrote %s public parameters for %s to %s\n", pp.Mode, g.Name(), c.paramsFile)
+	if pp.Mode == ballot.SingleGroup {
+		// The range proofs are for the ciphertext, without a vote proof.
+		return nil
+	}
+	report, err := pp.RPParams.Report()
+	if err != nil {
+		return err
+	}
+	return report.Write(c.out)
+}
+
+// plan reports the vote proof parameters that meet the requirements in
+// each group, or why there are none.
+func (c *command) plan(args []string) error {
+	fs := newFlagSet("plan")
+	groupNames := fs.String("groups", "secp256k1,ristretto255,P-256,P-384", "comma-separated groups")
+	rangeText := fs.String("range", "", "candidate range lo,hi (default that of setup)")
+	requirements := requirementFlags(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	req, ok := requirements()
+	if !ok {
+		return errUsage
+	}
+	var lo, hi *big.Int
+	if *rangeText != "" {
+		bounds := strings.Split(*rangeText, ",")
+		if len(bounds) != 2 {
+			return errUsage
+		}
+		lo, ok = new(big.Int).SetString(strings.TrimSpace(bounds[0]), 10)
+		if !ok {
+			return errUsage
+		}
+		if hi, ok = new(big.Int).SetString(strings.TrimSpace(bounds[1]), 10); !ok {
+			return errUsage
+		}
+	}
+
+	groups, err := lookupGroups(*groupNames)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		var pp ballot.PublicParameters
+		if lo == nil {
+			pp, err = ballot.SetupFor(g, req)
+		} else {
+			pp, err = ballot.SetupRangeFor(g, lo, hi, req)
+		}
+		fmt.Fprintf(c.out, "%s:\n", g.Name())
+		if err != nil {
+			fmt.Fprintf(c.out, "Refused: %v\n\n", err)
+			continue
+		}
+		report, err := pp.RPParams.Report()
+		if err != nil {
+			return err
+		}
+		if err = report.Write(c.out); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out)
+	}
+	return nil
+}
+
+func (c *command) cast(args []string) error {
+	fs := newFlagSet("cast")
+	choiceText := fs.String("choice", "", "candidate number")
+	voterID := fs.String("voter", "", "identity of the voter")
+	out := fs.String("out", "ballot.json", "ballot file")
+	secretsFile := fs.String("secrets", "ballot.secrets.json", "file for the ballot's randomness")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	choice, ok := new(big.Int).SetString(*choiceText, 10)
+	if !ok || *voterID == "" {
+		return errUsage
+	}
+
+	pp, err := c.loadParams()
+	if err != nil {
+		return err
+	}
+	vote, secrets, err := ballot.Cast(choice, *voterID, pp)
+	if err != nil {
+		return err
+	}
+
+	if err = writeJSON(*out, vote, 0644); err != nil {
+		return err
+	}
+	// The randomness reveals the choice.
+	if err = writeJSON(*secretsFile, secrets, 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Wrote ballot to %s and its randomness to %s\n", *out, *secretsFile)
+	return nil
+}
+
+func (c *command) verify(args []string) error {
+	fs := newFlagSet("verify")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	pp, err := c.loadParams()
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	vote, err := ballot.BallotDataUnmarshalJSON(b, pp)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	timings, err := ballot.Verify(vote, pp)
+	fmt.Fprintln(c.out, "Verify time BP (lower):", timings.BpLower)
+	fmt.Fprintln(c.out, "Verify time BP (upper):", timings.BpUpper)
+	fmt.Fprintln(c.out, "Verify time RP:", timings.VoteProof)
+	fmt.Fprintln(c.out, "Verify time total:", timings.Total())
+	if err != nil {
+		fmt.Fprintln(c.out, "Ballot is invalid:", err)
+		return err
+	}
+	fmt.Fprintln(c.out, "Ballot is valid")
+	return nil
+}
+
+func (c *command) bench(args []string) error {
+	fs := newFlagSet("bench")
+	groupNames := fs.String("groups", "", "comma-separated groups to benchmark instead of the parameters file")
+	modeNames := fs.String("modes", string(ballot.CrossGroup), "comma-separated ballot modes to benchmark with -groups")
+	iterCount := fs.Int("iters", 1000, "number of ballots per group")
+	format := fs.String("format", "text", "output format: text, csv or json")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *iterCount <= 0 {
+		return errUsage
+	}
+	if *format != "text" && *format != "csv" && *format != "json" {
+		return errUsage
+	}
+	modes, ok := parseModes(*modeNames)
+	if !ok {
+		return errUsage
+	}
+	// Progress messages would corrupt the structured reports.
+	progress := c.out
+	if *format != "text" {
+		progress = io.Discard
+	}
+
+	var params []ballot.PublicParameters
+	if *groupNames == "" {
+		pp, err := c.loadParams()
+		if err != nil {
+			return err
+		}
+		params = append(params, pp)
+	} else {
+		groups, err := lookupGroups(*groupNames)
+		if err != nil {
+			return err
+		}
+		for _, g := range groups {
+			for _, mode := range modes {
+				fmt.Fprintf(progress, "Generating %s public parameters for group: %s\n", mode, g.Name())
+				pp, err := setupMode(g, mode, ballot.DefaultRequirements)
+				if err != nil {
+					fmt.Fprintln(progress, "Skipping execution for", g.Name(), "due to", err)
+					continue
+				}
+				params = append(params, pp)
+			}
+		}
+	}
+
+	if *format != "text" {
+		var reports []groupReport
+		for _, pp := range params {
+			r, err := benchmarkReport(pp, *iterCount)
+			if err != nil {
+				return err
+			}
+			reports = append(reports, r)
+		}
+		if *format == "csv" {
+			return writeReportCSV(c.out, reports)
+		}
+		return writeReportJSON(c.out, reports)
+	}
+
+	for i, pp := range params {
+		if i != 0 {
+			fmt.Fprint(c.out, "\n")
+		}
+		if err := benchmark(c.out, pp, *iterCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchmarkVoter is the voter who casts the ballots of the benchmarks.
+const benchmarkVoter = "benchmark"
+
+// benchmark casts and verifies ballots, and reports the average times.
+func benchmark(w io.Writer, pp ballot.PublicParameters, iterCount int) error {
+	const sepLen = 60
+
+	success := true
+	var castTotal time.Duration = 0
+	var bpVerTotal time.Duration = 0
+	var rpVerTotal time.Duration = 0
+
+	for j := 0; j < iterCount; j++ {
+		start := time.Now()
+		vote, _, err := ballot.Cast(ballot.RandomChoice(pp), benchmarkVoter, pp)
+		if err != nil {
+			return err
+		}
+		castTotal += time.Since(start)
+
+		times, err := ballot.Verify(vote, pp)
+		bpVerTotal += times.Bulletproofs()
+		rpVerTotal += times.VoteProof
+		success = success && err == nil
+	}
+
+	fmt.Fprintln(w, strings.Repeat("=", sepLen))
+	fmt.Fprintln(w, "Group:", pp.ECGroupParams.I.Name())
+	fmt.Fprintln(w, "Mode:", pp.Mode)
+	fmt.Fprintln(w, strings.Repeat("-", sepLen))
+	fmt.Fprintln(w, "Vote casting")
+
+	fmt.Fprintln(w, "Cast time:", castTotal/time.Duration(iterCount))
+
+	fmt.Fprintln(w, strings.Repeat("-", sepLen))
+	fmt.Fprintln(w, "Vote verification")
+
+	bpAvg := bpVerTotal / time.Duration(iterCount)
+	rpAvg := rpVerTotal / time.Duration(iterCount)
+
+	fmt.Fprintln(w, "Verify time BP:", bpAvg)
+	fmt.Fprintln(w, "Verify time RP:", rpAvg)
+	fmt.Fprintln(w, "Verify time total:", bpAvg+rpAvg)
+
+	fmt.Fprintln(w, strings.Repeat("-", sepLen))
+	fmt.Fprintln(w, "Votes were correctly formed:", success)
+	fmt.Fprintln(w, strings.Repeat("=", sepLen))
+	return nil
+}
+
+func (c *command) sizes(args []string) error {
+	fs := newFlagSet("sizes")
+	groupNames := fs.String("groups", "secp256k1,ristretto255,P-256,P-384", "comma-separated groups")
+	modeNames := fs.String("modes", "cross-group,single-group", "comma-separated ballot modes")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	modes, ok := parseModes(*modeNames)
+	if !ok {
+		return errUsage
+	}
+
+	groups, err := lookupGroups(*groupNames)
+	if err != nil {
+		return err
+	}
+	return reportSizes(c.out, groups, modes)
+}
+
+func (c *command) serve(args []string) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	workers := fs.Int("workers", 0, "number of verification workers (default GOMAXPROCS)")
+	queueSize := fs.Int("queue", collector.DefaultQueueSize, "number of ballots that can wait for verification")
+	tokensFile := fs.String("tokens", "", "JSON file that maps voter IDs to bearer tokens")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	pp, err := c.loadParams()
+	if err != nil {
+		return err
+	}
+	store := collector.NewMemoryStore()
+	s, err := collector.NewServer(pp, store)
+	if err != nil {
+		return err
+	}
+	if *tokensFile != "" {
+		tokens, err := loadTokens(*tokensFile)
+		if err != nil {
+			return err
+		}
+		s.Authenticate = collector.BearerTokens(tokens)
+	} else {
+		fmt.Fprintln(c.out, "WARNING: submissions are not authenticated, so anyone can cast a ballot for any voter.")
+		fmt.Fprintln(c.out, "WARNING: set the voter list with -tokens unless a proxy authenticates the voters.")
+	}
+	s.Queue = collector.NewQueue(pp, store, *queueSize, *workers)
+	defer s.Queue.Close()
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintln(c.out, "Collecting ballots on", *addr)
+	return server.ListenAndServe()
+}
+
+func (c *command) simulate(args []string) error {
+	fs := newFlagSet("simulate")
+	url := fs.String("url", "", "collector to submit to, instead of an in-process one")
+	workers := fs.Int("workers", 0, "number of verification workers of the in-process collector")
+	queueSize := fs.Int("queue", collector.DefaultQueueSize, "number of ballots that can wait for verification in the in-process collector")
+	tokensFile := fs.String("tokens", "", "JSON file that maps the voter IDs voter-0, voter-1, ... to bearer tokens")
+	var cfg simulator.Config
+	fs.IntVar(&cfg.Voters, "voters", 100, "number of voters")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 0, "number of concurrent submissions (default GOMAXPROCS)")
+	distName := fs.String("dist", "uniform", "distribution of the choices: uniform or zipf")
+	fs.Float64Var(&cfg.RevoteRatio, "revote", 0, "fraction of voters who vote twice")
+	fs.Float64Var(&cfg.MalformedRate, "malformed", 0, "fraction of malformed ballots")
+	fs.Float64Var(&cfg.TamperedRate, "tampered", 0, "fraction of ballots with invalid proofs")
+	fs.Int64Var(&cfg.Seed, "seed", 1, "seed of the voters' choices")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	dist, err := simulator.LookupDistribution(*distName)
+	if err != nil {
+		return err
+	}
+	cfg.Distribution = dist
+
+	pp, err := c.loadParams()
+	if err != nil {
+		return err
+	}
+
+	if *tokensFile != "" {
+		if cfg.Tokens, err = loadTokens(*tokensFile); err != nil {
+			return err
+		}
+	}
+
+	var submitter simulator.Submitter = simulator.HTTPSubmitter{URL: *url}
+	if *url == "" {
+		if cfg.Tokens == nil {
+			if cfg.Tokens, err = simulator.NewTokens(cfg.Voters); err != nil {
+				return err
+			}
+		}
+		store := collector.NewMemoryStore()
+		s, err := collector.NewServer(pp, store)
+		if err != nil {
+			return err
+		}
+		s.Authenticate = collector.BearerTokens(cfg.Tokens)
+		s.Queue = collector.NewQueue(pp, store, *queueSize, *workers)
+		defer s.Queue.Close()
+		submitter = simulator.HandlerSubmitter{Handler: s}
+	}
+
+	report, err := simulator.Run(context.Background(), cfg, pp, submitter)
+	if err != nil {
+		return err
+	}
+	return report.Write(c.out)
+}
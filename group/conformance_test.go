@@ -0,0 +1,23 @@
+package group_test
+
+import (
+	"testing"
+
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/group/grouptest"
+)
+
+func TestConformance(t *testing.T) {
+	groups := []group.Group{group.TestModPSubgroup1024}
+	for _, name := range group.Names() {
+		g, err := group.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		groups = append(groups, g)
+	}
+
+	for _, g := range groups {
+		t.Run(g.Name(), func(t *testing.T) { grouptest.Run(t, g) })
+	}
+}
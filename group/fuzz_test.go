@@ -0,0 +1,116 @@
+package group_test
+
+import (
+	"testing"
+
+	"github.com/takakv/msc-poc/group"
+)
+
+func fuzzGroups(f *testing.F) []group.Group {
+	groups := []group.Group{group.TestModPSubgroup1024}
+	for _, name := range group.Names() {
+		g, err := group.Lookup(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// checkDecoded checks that a successfully decoded element is usable and
+// survives a round trip through every encoding.
+func checkDecoded(t *testing.T, g group.Group, X group.Element) {
+	Y := g.Element().Add(X, g.Generator())
+	Y.Subtract(Y, g.Generator())
+	if !Y.IsEqual(X) {
+		t.Fatal("decoded element does not behave as a group element")
+	}
+
+	for _, marshal := range []func() ([]byte, error){X.MarshalBinary, X.MarshalBinaryCompress} {
+		enc, err := marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = g.Element().UnmarshalBinary(enc); err != nil {
+			t.Fatal("re-encoded element was rejected:", err)
+		}
+	}
+	enc, err := X.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Element().UnmarshalJSON(enc); err != nil {
+		t.Fatal("re-encoded element was rejected:", err)
+	}
+}
+
+func FuzzElementBinary(f *testing.F) {
+	groups := fuzzGroups(f)
+	for i, g := range groups {
+		for _, X := range []group.Element{g.Identity(), g.Generator(), g.Random()} {
+			enc, _ := X.MarshalBinary()
+			f.Add(uint8(i), enc)
+			enc, _ = X.MarshalBinaryCompress()
+			f.Add(uint8(i), enc)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, i uint8, data []byte) {
+		g := groups[int(i)%len(groups)]
+		X := g.Element()
+		if X.UnmarshalBinary(data) != nil {
+			return
+		}
+		checkDecoded(t, g, X)
+	})
+}
+
+func FuzzElementJSON(f *testing.F) {
+	groups := fuzzGroups(f)
+	for i, g := range groups {
+		for _, X := range []group.Element{g.Identity(), g.Generator(), g.Random()} {
+			enc, _ := X.MarshalJSON()
+			f.Add(uint8(i), enc)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, i uint8, data []byte) {
+		g := groups[int(i)%len(groups)]
+		X := g.Element()
+		if X.UnmarshalJSON(data) != nil {
+			return
+		}
+		checkDecoded(t, g, X)
+	})
+}
+
+func FuzzScalar(f *testing.F) {
+	groups := fuzzGroups(f)
+	for i, g := range groups {
+		enc, _ := g.RandomScalar().MarshalBinary()
+		f.Add(uint8(i), enc)
+		enc, _ = g.RandomScalar().MarshalJSON()
+		f.Add(uint8(i), enc)
+	}
+
+	f.Fuzz(func(t *testing.T, i uint8, data []byte) {
+		g := groups[int(i)%len(groups)]
+		for _, unmarshal := range []func(s group.Scalar) error{
+			func(s group.Scalar) error { return s.UnmarshalBinary(data) },
+			func(s group.Scalar) error { return s.UnmarshalJSON(data) },
+		} {
+			s := g.NewScalar()
+			if unmarshal(s) != nil {
+				continue
+			}
+			if s.BigInt().Sign() < 0 || s.BigInt().Cmp(g.N()) >= 0 {
+				t.Fatal("decoded scalar is not reduced")
+			}
+			enc, _ := s.MarshalBinary()
+			if err := g.NewScalar().UnmarshalBinary(enc); err != nil {
+				t.Fatal("re-encoded scalar was rejected:", err)
+			}
+		}
+	})
+}
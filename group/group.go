@@ -16,10 +16,10 @@ type Element interface {
 	Negate(X Element) Element
 	// Scale performs the group operation s times with X,
 	// sets the receiver to the result, and returns it.
-	Scale(X Element, s *big.Int) Element
+	Scale(X Element, s Scalar) Element
 	// BaseScale performs the group operation s times with the
-	//group's generator, sets the receiver to the result, and returns it.
-	BaseScale(s *big.Int) Element
+	// group's generator, sets the receiver to the result, and returns it.
+	BaseScale(s Scalar) Element
 	// Set the receiver to X, and returns it.
 	Set(X Element) Element
 	// SetBytes recovers a group element from a byte representation,
@@ -42,8 +42,12 @@ type Element interface {
 	String() string
 	// BinaryMarshaler returns a byte representation of the element.
 	encoding.BinaryMarshaler
+	// MarshalBinaryCompress returns a compressed byte representation of the
+	// element. Groups without a more compact representation return the same
+	// bytes as MarshalBinary.
+	MarshalBinaryCompress() ([]byte, error)
 	// BinaryUnmarshaler recovers an element from a byte representation
-	// produced by encoding.BinaryMarshaler.
+	// produced by either encoding.BinaryMarshaler or MarshalBinaryCompress.
 	encoding.BinaryUnmarshaler
 	// Marshaler returns a JSON representation of the element.
 	json.Marshaler
@@ -52,6 +56,50 @@ type Element interface {
 	json.Unmarshaler
 }
 
+// Scalar represents an integer modulo the order of a prime-order group.
+// Scalars are always reduced, and can only be combined with scalars and
+// elements of the same group.
+type Scalar interface {
+	// Add sets the receiver to x + y, and returns it.
+	Add(x, y Scalar) Scalar
+	// Subtract sets the receiver to x - y, and returns it.
+	Subtract(x, y Scalar) Scalar
+	// Multiply sets the receiver to x * y, and returns it.
+	Multiply(x, y Scalar) Scalar
+	// Negate sets the receiver to -x, and returns it.
+	Negate(x Scalar) Scalar
+	// Invert sets the receiver to 1/x, and returns it.
+	// The inverse of zero is zero.
+	Invert(x Scalar) Scalar
+	// Set the receiver to x, and returns it.
+	Set(x Scalar) Scalar
+	// SetUint64 sets the receiver to n reduced modulo the group
+	// order, and returns it.
+	SetUint64(n uint64) Scalar
+	// SetBigInt sets the receiver to n reduced modulo the group order,
+	// and returns it. Negative values are reduced to non-negative ones.
+	SetBigInt(n *big.Int) Scalar
+	// BigInt returns the scalar as an integer in [0, N).
+	BigInt() *big.Int
+	// IsEqual returns true if the receiver is equal to x.
+	IsEqual(x Scalar) bool
+	// IsZero returns true if the receiver is zero.
+	IsZero() bool
+	// String returns the scalar as a decimal integer.
+	String() string
+	// BinaryMarshaler returns the canonical fixed-length big-endian
+	// representation of the scalar.
+	encoding.BinaryMarshaler
+	// BinaryUnmarshaler recovers a scalar from its canonical representation,
+	// and rejects encodings of the wrong length or of unreduced values.
+	encoding.BinaryUnmarshaler
+	// Marshaler returns a JSON integer representation of the scalar.
+	json.Marshaler
+	// Unmarshaler recovers a scalar from a JSON integer,
+	// and rejects values outside [0, N).
+	json.Unmarshaler
+}
+
 // Group represents a prime-order group over a prime-order field.
 // The group can be either multiplicative or additive.
 type Group interface {
@@ -69,11 +117,20 @@ type Group interface {
 	// random scalar r and returning rG.
 	Random() Element
 
+	// NewScalar creates a new scalar set to zero.
+	NewScalar() Scalar
+	// RandomScalar returns a uniformly sampled scalar.
+	RandomScalar() Scalar
+	// HashToScalar hashes a message (msg) using a domain separation
+	// string (dst) and produces a uniformly distributed scalar.
+	HashToScalar(msg, dst []byte) Scalar
+
 	// P returns the prime-order of the field.
 	P() *big.Int
 	// N returns the prime-order of the group.
 	N() *big.Int
 
-	// Marshaler returns a JSON description of the group.
+	// Marshaler returns a JSON description of the group,
+	// which can be recovered with UnmarshalGroupJSON.
 	json.Marshaler
 }
@@ -1,30 +1,32 @@
 package group
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"testing"
 )
 
-var RFC3526ModPGroup3072 = NewModPGroup(
-	"RFC3526ModPGroup3072",
-	`FFFFFFFF FFFFFFFF C90FDAA2 2168C234 C4C6628B 80DC1CD1
-		29024E08 8A67CC74 020BBEA6 3B139B22 514A0879 8E3404DD
-		EF9519B3 CD3A431B 302B0A6D F25F1437 4FE1356D 6D51C245
-		E485B576 625E7EC6 F44C42E9 A637ED6B 0BFF5CB6 F406B7ED
-		EE386BFB 5A899FA5 AE9F2411 7C4B1FE6 49286651 ECE45B3D
-		C2007CB8 A163BF05 98DA4836 1C55D39A 69163FA8 FD24CF5F
-		83655D23 DCA3AD96 1C62F356 208552BB 9ED52907 7096966D
-		670C354E 4ABC9804 F1746C08 CA18217C 32905E46 2E36CE3B
-		E39E772C 180E8603 9B2783A2 EC07A28F B5C55DF0 6F4C52C9
-		DE2BCBF6 95581718 3995497C EA956AE5 15D22618 98FA0510
-		15728E5A 8AAAC42D AD33170D 04507A33 A85521AB DF1CBA64
-		ECFB8504 58DBEF0A 8AEA7157 5D060C7D B3970F85 A6E1E4C7
-		ABF5AE8C DB0933D7 1E8C94E0 4A25619D CEE3D226 1AD2EE6B
-		F12FFA06 D98A0864 D8760273 3EC86A64 521F2B18 177B200C
-		BBE11757 7A615D6C 770988C0 BAD946E2 08E24FA0 74E5AB31
-		43DB5BFC E0FD108E 4B82D120 A93AD2CA FFFFFFFF FFFFFFFF
-		`, "2")
+var RFC3526Group = RFC3526ModPGroup3072()
+
+// A small Schnorr group, so that the subgroup code paths are exercised.
+var TestModPSubgroup1024 = NewModPSubgroup(
+	"TestModPSubgroup1024",
+	`D5CC6025 F1081814 D1ADE6C3 48831EFF D87DABD5 B95E8706
+		0A12CC5D 6B9EA854 82F96B51 CADB80AD 238C56CC 232EACD1
+		D521786B 3F6EF6C5 C2A910C3 F34C3A7D EFF341CD 642ADB02
+		907141D7 1A2E5DEF 21E40479 063CFCD8 4B4664A1 64AD343A
+		DAC5E411 E40BE407 F91F0619 3F9700F0 D654A674 118C75F3
+		049820AD A8DE57A9`,
+	`8B8BBB27 C59C69C8 C24E1FB6 9EB006B5 540FCB85`,
+	`5E8EC1C3 46D7D443 28BE661C 5AD72918 07C83C2A FBC858EE
+		B93DB106 AFC945DD BA221A82 CC341F65 C318E2E5 A06ABEBB
+		E4C65247 C34A82CE 317A9AD2 4F9D7CC7 7596E99B 7F224F8F
+		94CE6852 4057F446 7956FA3C 767D5AAC 7D3E75E7 4F5F1C88
+		14899C0B A3F77C39 54917B2A 281A577D D888FB81 D52068EB
+		055D7D02 75FFF319`)
 
 var SecP256k1Group = SecP256k1()
 var P384Group = P384()
@@ -32,177 +34,276 @@ var P256Group = P256()
 var R255Group = Ristretto255()
 
 var allGroups = []Group{
-	RFC3526ModPGroup3072,
-	// SecP256k1Group,
+	RFC3526Group,
+	TestModPSubgroup1024,
+	SecP256k1Group,
 	P256Group,
 	P384Group,
 	R255Group,
 }
 
-func TestGroup(t *testing.T) {
-	const testTimes = 1 << 7
-	for _, g := range allGroups {
-		n := g.Name()
-		// t.Run(n+"/Add", func(tt *testing.T) { testAdd(tt, testTimes, g) })
-		t.Run(n+"/Neg", func(tt *testing.T) { testNeg(tt, testTimes, g) })
-		// t.Run(n+"/Mul", func(tt *testing.T) { testMul(tt, testTimes, g) })
-		// t.Run(n+"/MulGen", func(tt *testing.T) { testMulGen(tt, testTimes, g) })
-		// t.Run(n+"/CMov", func(tt *testing.T) { testCMov(tt, testTimes, g) })
-		// t.Run(n+"/CSelect", func(tt *testing.T) { testCSelect(tt, testTimes, g) })
-		t.Run(n+"/Order", func(tt *testing.T) { testOrder(tt, testTimes, g) })
-		t.Run(n+"/Set", func(tt *testing.T) { testSet(tt, g) })
-		t.Run(n+"/MarshalBinary", func(tt *testing.T) { testMarshalBinary(tt, testTimes, g) })
-		t.Run(n+"/MarshalJSON", func(tt *testing.T) { testMarshalJSON(tt, testTimes, g) })
-		// t.Run(n+"/Scalar", func(tt *testing.T) { testScalar(tt, testTimes, g) })
+func TestNewElements(t *testing.T) {
+	els := []struct {
+		name string
+		el   func(Group) Element
+	}{
+		{"identity", func(g Group) Element { return g.Identity() }},
+		{"generator", func(g Group) Element { return g.Generator() }},
+		{"random", func(g Group) Element { return g.Random() }},
+	}
+
+	g := SecP256k1Group // RFC3526ModPGroup3072
+	for _, e := range els {
+		t.Run(fmt.Sprintf("%s-%s", "ModPGroup", e.name), func(t *testing.T) {
+			x := e.el(g)
+			if x == nil {
+				t.Error("new element")
+			}
+		})
 	}
 }
 
-func testNeg(t *testing.T, testTimes int, g Group) {
-	Q := g.Element()
-	for i := 0; i < testTimes; i++ {
-		P := g.Random()
-		Q.Set(P)
-		Q.Subtract(Q, P)
-		got := Q.IsIdentity()
-		want := true
-		if got != want {
-			t.Error("testNeg | Got:", got, "Wanted:", want)
-		}
+func TestMath(t *testing.T) {
+	g := SecP256k1Group // RFC3526ModPGroup3072
+
+	a := g.Element().BaseScale(g.NewScalar().SetUint64(2))
+	b := g.Element().Add(g.Generator(), g.Generator())
+	ok := a.IsEqual(b)
+	if ok != true {
+		t.Error("doubling error")
+	}
+
+	a = g.Element().Add(a, g.Generator())
+	b = g.Element().BaseScale(g.NewScalar().SetUint64(3))
+	ok = a.IsEqual(b)
+	if ok != true {
+		t.Error("error in adding or scaling")
+	}
+
+	e := g.Identity()
+	r1 := g.Random()
+	r2 := g.Random()
+	e.Add(r1, r2)
+	e.Subtract(e, r2)
+	ok = e.IsEqual(r1)
+	if ok != true {
+		t.Error("error in subtracting")
 	}
 }
 
-func testOrder(t *testing.T, testTimes int, g Group) {
-	I := g.Identity()
-	Q := g.Element()
-	minusOne := big.NewInt(-1)
-	for i := 0; i < testTimes; i++ {
-		P := g.Random()
+func TestModPSubgroup(t *testing.T) {
+	for _, g := range []Group{RFC3526Group, TestModPSubgroup1024} {
+		q := g.N()
 
-		Q.Scale(P, minusOne)
-		got := Q.Add(Q, P)
-		want := I
-		if !got.IsEqual(want) {
-			t.Error("testOrder | Got:", got, "Wanted:", want)
+		// Exponents are reduced modulo the subgroup order.
+		x := g.Random()
+		a := g.Element().Scale(x, g.NewScalar().SetBigInt(new(big.Int).Add(q, big.NewInt(5))))
+		b := g.Element().Scale(x, g.NewScalar().SetUint64(5))
+		if !a.IsEqual(b) {
+			t.Error(g.Name(), "| exponent not reduced modulo the group order")
+		}
+		gq := g.Element().Set(g.Generator())
+		gq.(*ModPElement).val.Exp(gq.(*ModPElement).val, q, g.P())
+		if !gq.IsIdentity() {
+			t.Error(g.Name(), "| generator does not have order q")
+		}
+
+		// Elements outside the subgroup are rejected.
+		pm1 := new(big.Int).Sub(g.P(), big.NewInt(1))
+		for _, v := range []*big.Int{big.NewInt(0), pm1, g.P()} {
+			enc, _ := v.MarshalJSON()
+			if err := g.Element().UnmarshalJSON(enc); err == nil {
+				t.Error(g.Name(), "| accepted non-member", v)
+			}
+			if err := g.Element().UnmarshalBinary(v.Bytes()); err == nil {
+				t.Error(g.Name(), "| accepted non-member", v)
+			}
 		}
 	}
 }
 
-func testSet(t *testing.T, g Group) {
-	P := g.Random()
-	Q := g.Element()
-	Q.Set(P)
-	if !Q.IsEqual(P) {
-		t.Error("testSet | Got:", false, "Wanted:", true)
+// seededInt returns the i-th candidate of the seed, as described in
+// ModPGroup3072q256.
+func seededInt(seed string, i uint32, bits int) *big.Int {
+	var buf []byte
+	for block := uint32(0); len(buf)*8 < bits; block++ {
+		h := sha256.New()
+		h.Write([]byte(seed))
+		h.Write(binary.BigEndian.AppendUint32(nil, i))
+		h.Write(binary.BigEndian.AppendUint32(nil, block))
+		buf = h.Sum(buf)
 	}
+	x := new(big.Int).SetBytes(buf[:bits/8])
+	return x.SetBit(x, bits-1, 1)
 }
 
-func testMarshalBinary(t *testing.T, testTimes int, g Group) {
-	I := g.Identity()
-	got, err := I.MarshalBinary()
-	if err != nil {
-		t.Error("testMarshalBinary | I:", I)
+func TestModPGroup3072q256(t *testing.T) {
+	if testing.Short() {
+		t.Skip("deriving the parameters takes a few seconds")
 	}
-
-	II := g.Element()
-	err = II.UnmarshalBinary(got)
-	if err != nil || !I.IsEqual(II) {
-		t.Error("testMarshalBinary | I:", I, "II:", II)
+	one := big.NewInt(1)
+	var p, q, g *big.Int
+	for i := uint32(0); ; i++ {
+		if q = seededInt("ModPGroup3072q256 q", i, 256); q.ProbablyPrime(32) {
+			break
+		}
 	}
-
-	gotEl := g.Element()
-	for i := 0; i < testTimes; i++ {
-		x := g.Random()
-		enc, err := x.MarshalBinary()
-		if err != nil {
-			t.Error(err)
+	twoQ := new(big.Int).Lsh(q, 1)
+	for i := uint32(0); ; i++ {
+		x := seededInt("ModPGroup3072q256 p", i, 3072)
+		p = x.Add(x.Sub(x, new(big.Int).Mod(x, twoQ)), one)
+		if p.BitLen() == 3072 && p.ProbablyPrime(32) {
+			break
 		}
-
-		err = gotEl.UnmarshalBinary(enc)
-		if !x.IsEqual(gotEl) {
-			t.Error("testMarshalBinary | Got:", gotEl, "Wanted:", x)
+	}
+	e := new(big.Int).Div(new(big.Int).Sub(p, one), q)
+	for i := uint32(0); ; i++ {
+		if g = new(big.Int).Exp(seededInt("ModPGroup3072q256 g", i, 3072), e, p); g.Cmp(one) != 0 {
+			break
 		}
 	}
+
+	G := ModPGroup3072q256()
+	if G.P().Cmp(p) != 0 || G.N().Cmp(q) != 0 {
+		t.Error("moduli do not match the seeds")
+	}
+	if G.Generator().(*ModPElement).val.Cmp(g) != 0 {
+		t.Error("generator does not match the seeds")
+	}
 }
 
-func testMarshalJSON(t *testing.T, testTimes int, g Group) {
-	I := g.Identity()
-	got, err := I.MarshalJSON()
+func TestModPGroupDescription(t *testing.T) {
+	enc, err := TestModPSubgroup1024.MarshalJSON()
 	if err != nil {
-		t.Error("testMarshalJSON | I:", I)
+		t.Fatal(err)
 	}
 
-	II := g.Element()
-	err = II.UnmarshalJSON(got)
-	if err != nil || !I.IsEqual(II) {
-		fmt.Println(err)
-		t.Error("testMarshalJSON | I:", I, "II:", II)
+	var desc ModPGroupDescription
+	if err = json.Unmarshal(enc, &desc); err != nil {
+		t.Fatal(err)
 	}
+	if desc.Name != TestModPSubgroup1024.Name() ||
+		desc.P.Cmp(TestModPSubgroup1024.P()) != 0 ||
+		desc.Q.Cmp(TestModPSubgroup1024.N()) != 0 ||
+		desc.G.Cmp(TestModPSubgroup1024.Generator().(*ModPElement).val) != 0 {
+		t.Error("group description does not match the group")
+	}
+}
 
-	gotEl := g.Element()
-	for i := 0; i < testTimes; i++ {
-		x := g.Random()
-		enc, err := x.MarshalJSON()
+func TestUnmarshalGroupJSON(t *testing.T) {
+	builtins := []Group{RFC3526Group, SecP256k1Group, P256Group, P384Group, R255Group, ModPGroup3072q256()}
+	for _, g := range builtins {
+		enc, err := g.MarshalJSON()
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-
-		err = gotEl.UnmarshalJSON(enc)
+		got, err := UnmarshalGroupJSON(enc)
 		if err != nil {
-			t.Error(err)
+			t.Error(g.Name(), "|", err)
 			continue
 		}
+		if got.Name() != g.Name() || got.N().Cmp(g.N()) != 0 || got.P().Cmp(g.P()) != 0 {
+			t.Error(g.Name(), "| recovered a different group:", got.Name())
+		}
+	}
 
-		if !x.IsEqual(gotEl) {
-			t.Error("testMarshalJSON | Got:", gotEl, "Wanted:", x)
+	// Custom modular groups are reconstructed from their description.
+	enc, _ := TestModPSubgroup1024.MarshalJSON()
+	got, err := UnmarshalGroupJSON(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.(*ModPGroup).equals(TestModPSubgroup1024) {
+		t.Error("custom group was not recovered")
+	}
+
+	invalid := []string{
+		`{"group":"unknown"}`,
+		`{"group":""}`,
+		`{"group":"RFC3526ModPGroup3072","p":23,"q":11,"g":4}`,
+		`{"group":"P-256","p":23,"q":11,"g":4}`,
+		`{"group":"custom","p":23,"q":11}`,
+		`{"group":"custom","p":23,"q":7,"g":4}`,
+		`{"group":"custom","p":23,"q":11,"g":5}`,
+		`{"group":"custom","p":21,"q":5,"g":4}`,
+	}
+	for _, s := range invalid {
+		if _, err := UnmarshalGroupJSON([]byte(s)); err == nil {
+			t.Error("accepted invalid description", s)
 		}
 	}
 }
 
-func TestNewElements(t *testing.T) {
-	els := []struct {
-		name string
-		el   func(Group) Element
-	}{
-		{"identity", func(g Group) Element { return g.Identity() }},
-		{"generator", func(g Group) Element { return g.Generator() }},
-		{"random", func(g Group) Element { return g.Random() }},
-	}
+func TestPrecompute(t *testing.T) {
+	groups := append(allGroups, ModPGroup3072q256())
+	for _, g := range groups {
+		X := g.Random()
+		P := Precompute(X)
+		if !P.IsEqual(X) {
+			t.Fatal(g.Name(), "| precomputed element differs from the original")
+		}
+		if Precompute(P) != P {
+			t.Error(g.Name(), "| precomputation was not reused")
+		}
 
-	g := SecP256k1Group // RFC3526ModPGroup3072
-	for _, e := range els {
-		t.Run(fmt.Sprintf("%s-%s", "ModPGroup", e.name), func(t *testing.T) {
-			x := e.el(g)
-			if x == nil {
-				t.Error("new element")
+		var scalars []Scalar
+		for _, v := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(-1), new(big.Int).Lsh(g.N(), 3)} {
+			scalars = append(scalars, g.NewScalar().SetBigInt(v))
+		}
+		for i := 0; i < 8; i++ {
+			scalars = append(scalars, g.RandomScalar())
+		}
+		for _, s := range scalars {
+			want := g.Element().Scale(X, s)
+			got := g.Element().Scale(P, s)
+			if !got.IsEqual(want) {
+				t.Error(g.Name(), "| precomputed scaling is incorrect for", s)
 			}
-		})
+			if !g.Element().BaseScale(s).IsEqual(g.Element().Scale(g.Generator(), s)) {
+				t.Error(g.Name(), "| base scaling is incorrect for", s)
+			}
+		}
+
+		// Modifying the element must discard its table.
+		Y := g.Random()
+		s := g.NewScalar().SetUint64(12345)
+		want := g.Element().Scale(g.Element().Add(X, Y), s)
+		P.Add(P, Y)
+		if !g.Element().Scale(P, s).IsEqual(want) {
+			t.Error(g.Name(), "| stale table used after modification")
+		}
 	}
 }
 
-func TestMath(t *testing.T) {
-	g := SecP256k1Group // RFC3526ModPGroup3072
+func TestSecP256k1Decompress(t *testing.T) {
+	g := SecP256k1()
+	X := g.Random()
+	enc, _ := X.MarshalBinaryCompress()
 
-	a := g.Element().BaseScale(big.NewInt(2))
-	b := g.Element().Add(g.Generator(), g.Generator())
-	ok := a.IsEqual(b)
-	if ok != true {
-		t.Error("doubling error")
+	// Flipping the parity yields the inverse.
+	enc[0] ^= 1
+	Y := g.Element()
+	if err := Y.UnmarshalBinary(enc); err != nil {
+		t.Fatal(err)
+	}
+	if !Y.IsEqual(g.Element().Negate(X)) {
+		t.Error("flipped parity did not decode to the inverse")
 	}
 
-	a = g.Element().Add(a, g.Generator())
-	b = g.Element().BaseScale(big.NewInt(3))
-	ok = a.IsEqual(b)
-	if ok != true {
-		t.Error("error in adding or scaling")
+	// x = 5 gives y^2 = 132, which is not a square modulo p.
+	bad := make([]byte, 33)
+	bad[0], bad[32] = 2, 5
+	if g.Element().UnmarshalBinary(bad) == nil {
+		t.Error("accepted an x-coordinate that is not on the curve")
 	}
 
-	e := g.Identity()
-	r1 := g.Random()
-	r2 := g.Random()
-	e.Add(r1, r2)
-	e.Subtract(e, r2)
-	ok = e.IsEqual(r1)
-	if ok != true {
-		t.Error("error in subtracting")
+	bad[0] = 4
+	if g.Element().UnmarshalBinary(bad) == nil {
+		t.Error("accepted an invalid tag")
+	}
+
+	tooLarge := append([]byte{2}, g.P().Bytes()...)
+	if g.Element().UnmarshalBinary(tooLarge) == nil {
+		t.Error("accepted an x-coordinate that is not reduced")
 	}
 }
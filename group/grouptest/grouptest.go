@@ -0,0 +1,511 @@
+// Package grouptest implements conformance tests for implementations of
+// group.Group, so that any group can be checked against the behaviour the
+// proof systems rely on.
+package grouptest
+
+import (
+	"bytes"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/takakv/msc-poc/group"
+)
+
+// Iterations is the number of random inputs each randomised check uses.
+var Iterations = 32
+
+// Run runs all conformance tests against g as subtests of t.
+func Run(t *testing.T, g group.Group) {
+	t.Run("Identity", func(t *testing.T) { TestIdentity(t, g) })
+	t.Run("Associativity", func(t *testing.T) { TestAssociativity(t, g) })
+	t.Run("Inverses", func(t *testing.T) { TestInverses(t, g) })
+	t.Run("Distributivity", func(t *testing.T) { TestDistributivity(t, g) })
+	t.Run("BaseScale", func(t *testing.T) { TestBaseScale(t, g) })
+	t.Run("Aliasing", func(t *testing.T) { TestAliasing(t, g) })
+	t.Run("Scalar", func(t *testing.T) { TestScalar(t, g) })
+	t.Run("Encoding", func(t *testing.T) { TestEncoding(t, g) })
+	t.Run("Compression", func(t *testing.T) { TestCompression(t, g) })
+	t.Run("LeadingZeros", func(t *testing.T) { TestLeadingZeros(t, g) })
+	t.Run("MapToGroup", func(t *testing.T) { TestMapToGroup(t, g) })
+	t.Run("Mixing", func(t *testing.T) { TestMixing(t, g) })
+}
+
+// TestIdentity checks that the identity is neutral and is produced by
+// scaling with zero.
+func TestIdentity(t *testing.T, g group.Group) {
+	I := g.Identity()
+	if !I.IsIdentity() {
+		t.Fatal("Identity is not the identity")
+	}
+	if g.Generator().IsIdentity() {
+		t.Error("generator is the identity")
+	}
+
+	zero := g.NewScalar()
+	if !g.Element().BaseScale(zero).IsIdentity() {
+		t.Error("0 * G is not the identity")
+	}
+	if !g.Element().Negate(I).IsIdentity() {
+		t.Error("-I is not the identity")
+	}
+	if !g.Element().Add(I, I).IsIdentity() {
+		t.Error("I + I is not the identity")
+	}
+
+	for i := 0; i < Iterations; i++ {
+		X := g.Random()
+		if X.IsIdentity() {
+			t.Error("random element is the identity")
+		}
+		if !g.Element().Add(X, I).IsEqual(X) || !g.Element().Add(I, X).IsEqual(X) {
+			t.Error("I is not neutral")
+		}
+		if !g.Element().Scale(X, zero).IsIdentity() {
+			t.Error("0 * X is not the identity")
+		}
+		if !g.Element().Scale(I, g.RandomScalar()).IsIdentity() {
+			t.Error("s * I is not the identity")
+		}
+	}
+}
+
+// TestAssociativity checks that the group operation is associative and
+// commutative.
+func TestAssociativity(t *testing.T, g group.Group) {
+	for i := 0; i < Iterations; i++ {
+		X, Y, Z := g.Random(), g.Random(), g.Random()
+
+		left := g.Element().Add(g.Element().Add(X, Y), Z)
+		right := g.Element().Add(X, g.Element().Add(Y, Z))
+		if !left.IsEqual(right) {
+			t.Error("(X + Y) + Z != X + (Y + Z)")
+		}
+		if !g.Element().Add(X, Y).IsEqual(g.Element().Add(Y, X)) {
+			t.Error("X + Y != Y + X")
+		}
+	}
+}
+
+// TestInverses checks negation and subtraction.
+func TestInverses(t *testing.T, g group.Group) {
+	minusOne := g.NewScalar().SetBigInt(big.NewInt(-1))
+	for i := 0; i < Iterations; i++ {
+		X, Y := g.Random(), g.Random()
+
+		negX := g.Element().Negate(X)
+		if !g.Element().Add(X, negX).IsIdentity() {
+			t.Error("X + (-X) is not the identity")
+		}
+		if !g.Element().Subtract(X, X).IsIdentity() {
+			t.Error("X - X is not the identity")
+		}
+		if !g.Element().Negate(negX).IsEqual(X) {
+			t.Error("-(-X) != X")
+		}
+		if !g.Element().Scale(X, minusOne).IsEqual(negX) {
+			t.Error("(-1) * X != -X")
+		}
+		if !g.Element().Subtract(X, Y).IsEqual(g.Element().Add(X, g.Element().Negate(Y))) {
+			t.Error("X - Y != X + (-Y)")
+		}
+		if !g.Element().Add(g.Element().Subtract(X, Y), Y).IsEqual(X) {
+			t.Error("(X - Y) + Y != X")
+		}
+	}
+}
+
+// TestDistributivity checks that scaling distributes over both scalar and
+// element addition, and is compatible with scalar multiplication.
+func TestDistributivity(t *testing.T, g group.Group) {
+	for i := 0; i < Iterations; i++ {
+		X, Y := g.Random(), g.Random()
+		s, r := g.RandomScalar(), g.RandomScalar()
+
+		left := g.Element().Scale(X, g.NewScalar().Add(s, r))
+		right := g.Element().Add(g.Element().Scale(X, s), g.Element().Scale(X, r))
+		if !left.IsEqual(right) {
+			t.Error("(s + r) * X != s * X + r * X")
+		}
+
+		left = g.Element().Scale(g.Element().Add(X, Y), s)
+		right = g.Element().Add(g.Element().Scale(X, s), g.Element().Scale(Y, s))
+		if !left.IsEqual(right) {
+			t.Error("s * (X + Y) != s * X + s * Y")
+		}
+
+		left = g.Element().Scale(X, g.NewScalar().Multiply(s, r))
+		right = g.Element().Scale(g.Element().Scale(X, r), s)
+		if !left.IsEqual(right) {
+			t.Error("(s * r) * X != s * (r * X)")
+		}
+	}
+
+	// Scaling by the group order is reduced to scaling by zero.
+	X := g.Random()
+	if !g.Element().Scale(X, g.NewScalar().SetBigInt(g.N())).IsIdentity() {
+		t.Error("N * X is not the identity")
+	}
+}
+
+// TestBaseScale checks that BaseScale agrees with scaling the generator.
+func TestBaseScale(t *testing.T, g group.Group) {
+	G := g.Generator()
+	if !g.Element().BaseScale(g.NewScalar().SetUint64(1)).IsEqual(G) {
+		t.Error("1 * G != G")
+	}
+	if !g.Element().BaseScale(g.NewScalar().SetUint64(2)).IsEqual(g.Element().Add(G, G)) {
+		t.Error("2 * G != G + G")
+	}
+
+	for i := 0; i < Iterations; i++ {
+		s := g.RandomScalar()
+		if !g.Element().BaseScale(s).IsEqual(g.Element().Scale(G, s)) {
+			t.Error("BaseScale(s) != Scale(G, s)")
+		}
+	}
+
+	// The generator must not be changed by operations on its copies.
+	X := g.Generator()
+	X.Add(X, X)
+	if !g.Generator().IsEqual(G) || X.IsEqual(G) {
+		t.Error("Generator returned a shared element")
+	}
+}
+
+// TestAliasing checks that receivers may alias the arguments.
+func TestAliasing(t *testing.T, g group.Group) {
+	for i := 0; i < Iterations; i++ {
+		X, Y := g.Random(), g.Random()
+		s := g.RandomScalar()
+
+		want := g.Element().Add(X, Y)
+		got := g.Element().Set(X)
+		if !got.Add(got, Y).IsEqual(want) {
+			t.Error("X.Add(X, Y) is incorrect")
+		}
+
+		want = g.Element().Add(X, X)
+		got = g.Element().Set(X)
+		if !got.Add(got, got).IsEqual(want) {
+			t.Error("X.Add(X, X) is incorrect")
+		}
+
+		want = g.Element().Scale(X, s)
+		got = g.Element().Set(X)
+		if !got.Scale(got, s).IsEqual(want) {
+			t.Error("X.Scale(X, s) is incorrect")
+		}
+
+		want = g.Element().Negate(X)
+		got = g.Element().Set(X)
+		if !got.Negate(got).IsEqual(want) {
+			t.Error("X.Negate(X) is incorrect")
+		}
+
+		// Set copies the value.
+		got = g.Element().Set(X)
+		got.Add(got, Y)
+		if got.IsEqual(X) {
+			t.Error("Set did not copy the element")
+		}
+	}
+}
+
+// TestScalar checks that scalar arithmetic agrees with integer arithmetic
+// modulo the group order, and that scalar encodings are strict.
+func TestScalar(t *testing.T, g group.Group) {
+	N := g.N()
+	for i := 0; i < Iterations; i++ {
+		a := g.RandomScalar()
+		b := g.RandomScalar()
+		aBig, bBig := a.BigInt(), b.BigInt()
+
+		checks := []struct {
+			name string
+			got  group.Scalar
+			want *big.Int
+		}{
+			{"Add", g.NewScalar().Add(a, b), new(big.Int).Add(aBig, bBig)},
+			{"Subtract", g.NewScalar().Subtract(a, b), new(big.Int).Sub(aBig, bBig)},
+			{"Multiply", g.NewScalar().Multiply(a, b), new(big.Int).Mul(aBig, bBig)},
+			{"Negate", g.NewScalar().Negate(a), new(big.Int).Neg(aBig)},
+			{"Invert", g.NewScalar().Invert(a), new(big.Int).ModInverse(aBig, N)},
+			{"SetBigInt", g.NewScalar().SetBigInt(new(big.Int).Sub(aBig, N)), aBig},
+			{"Set", g.NewScalar().Set(a), aBig},
+		}
+		for _, c := range checks {
+			if c.got.BigInt().Cmp(c.want.Mod(c.want, N)) != 0 {
+				t.Error(c.name, "| Got:", c.got, "Wanted:", c.want)
+			}
+		}
+		if a.String() != aBig.String() {
+			t.Error("String is not the decimal value")
+		}
+
+		enc, err := a.MarshalBinary()
+		if err != nil || len(enc) != (N.BitLen()+7)/8 {
+			t.Error("invalid binary encoding", err)
+		}
+		dec := g.NewScalar()
+		if err = dec.UnmarshalBinary(enc); err != nil || !dec.IsEqual(a) {
+			t.Error("binary round trip failed", err)
+		}
+		enc, _ = a.MarshalJSON()
+		dec = g.NewScalar()
+		if err = dec.UnmarshalJSON(enc); err != nil || !dec.IsEqual(a) {
+			t.Error("JSON round trip failed", err)
+		}
+	}
+
+	if !g.NewScalar().IsZero() || !g.NewScalar().Invert(g.NewScalar()).IsZero() {
+		t.Error("zero is not handled correctly")
+	}
+	if !g.NewScalar().SetUint64(1).IsEqual(g.NewScalar().SetBigInt(big.NewInt(1))) {
+		t.Error("SetUint64 and SetBigInt disagree")
+	}
+
+	// Small values have leading zeroes in their binary encoding.
+	one := g.NewScalar().SetUint64(1)
+	enc, _ := one.MarshalBinary()
+	dec := g.NewScalar()
+	if err := dec.UnmarshalBinary(enc); err != nil || !dec.IsEqual(one) {
+		t.Error("binary round trip of 1 failed", err)
+	}
+
+	// Unreduced and malformed encodings are rejected.
+	nBytes := N.FillBytes(make([]byte, (N.BitLen()+7)/8))
+	if g.NewScalar().UnmarshalBinary(nBytes) == nil {
+		t.Error("accepted an unreduced binary encoding")
+	}
+	if g.NewScalar().UnmarshalBinary(nBytes[1:]) == nil ||
+		g.NewScalar().UnmarshalBinary(append([]byte{0}, enc...)) == nil {
+		t.Error("accepted a binary encoding of the wrong length")
+	}
+	nJSON, _ := N.MarshalJSON()
+	for _, enc := range []string{string(nJSON), "-1", "null", `"1"`} {
+		if g.NewScalar().UnmarshalJSON([]byte(enc)) == nil {
+			t.Error("accepted invalid JSON encoding", enc)
+		}
+	}
+
+	h1 := g.HashToScalar([]byte("msg"), []byte("dst"))
+	h2 := g.HashToScalar([]byte("msg"), []byte("dst"))
+	h3 := g.HashToScalar([]byte("msg"), []byte("other"))
+	if !h1.IsEqual(h2) || h1.IsEqual(h3) {
+		t.Error("HashToScalar is not a deterministic function")
+	}
+}
+
+// TestEncoding checks that the binary and JSON encodings round-trip,
+// including the identity and the generator.
+func TestEncoding(t *testing.T, g group.Group) {
+	els := []group.Element{g.Identity(), g.Generator()}
+	for i := 0; i < Iterations; i++ {
+		els = append(els, g.Random())
+	}
+	for _, X := range els {
+		checkRoundTrip(t, g, X)
+	}
+
+	// Binary encodings are deterministic.
+	X := g.Random()
+	enc1, _ := X.MarshalBinary()
+	enc2, _ := g.Element().Set(X).MarshalBinary()
+	if !bytes.Equal(enc1, enc2) {
+		t.Error("binary encoding is not deterministic")
+	}
+
+	if g.Element().UnmarshalBinary(nil) == nil {
+		t.Error("accepted an empty encoding")
+	}
+}
+
+// TestCompression checks that compressed encodings are no longer than the
+// regular ones, distinguish an element from its inverse, and decode to
+// elements that are indistinguishable from the original.
+func TestCompression(t *testing.T, g group.Group) {
+	for i := 0; i < Iterations; i++ {
+		X := g.Random()
+		enc, err := X.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cenc, err := X.MarshalBinaryCompress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cenc) > len(enc) {
+			t.Errorf("compressed encoding is longer: %d > %d", len(cenc), len(enc))
+		}
+
+		negEnc, _ := g.Element().Negate(X).MarshalBinaryCompress()
+		if bytes.Equal(cenc, negEnc) {
+			t.Error("compressed encoding does not distinguish inverses")
+		}
+
+		Y := g.Element()
+		if err = Y.UnmarshalBinary(cenc); err != nil {
+			t.Fatal(err)
+		}
+		if Y.String() != X.String() {
+			t.Error("decompressed element has a different string representation")
+		}
+		if yEnc, _ := Y.MarshalBinary(); !bytes.Equal(yEnc, enc) {
+			t.Error("decompressed element has a different encoding")
+		}
+	}
+
+	I, err := g.Identity().MarshalBinaryCompress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Y := g.Element()
+	if err = Y.UnmarshalBinary(I); err != nil || !Y.IsIdentity() {
+		t.Error("compressed identity round trip failed", err)
+	}
+}
+
+// TestLeadingZeros checks that elements whose encodings contain leading
+// zero bytes round-trip. Such elements are found by enumerating multiples
+// of the generator, so that fixed-length encodings are exercised.
+func TestLeadingZeros(t *testing.T, g group.Group) {
+	const tries = 4096
+
+	G := g.Generator()
+	X := g.Element().Set(G)
+	found := 0
+	maxLen := 0
+	for i := 1; i <= tries && found < 4; i++ {
+		enc, err := X.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc) > maxLen {
+			maxLen = len(enc)
+		}
+		if len(enc) < maxLen || hasLeadingZero(enc) {
+			found++
+			checkRoundTrip(t, g, X)
+		}
+		X = g.Element().Add(X, G)
+	}
+	if found == 0 {
+		t.Log("no element with leading zeroes was found")
+	}
+}
+
+// hasLeadingZero returns true if the encoding begins with a zero byte, or
+// if it begins with a tag byte followed by a zero byte.
+func hasLeadingZero(enc []byte) bool {
+	return len(enc) > 1 && (enc[0] == 0 || enc[1] == 0)
+}
+
+func checkRoundTrip(t *testing.T, g group.Group, X group.Element) {
+	t.Helper()
+
+	enc, err := X.MarshalBinary()
+	if err != nil {
+		t.Error("MarshalBinary:", err)
+		return
+	}
+	Y := g.Element()
+	if err = Y.UnmarshalBinary(enc); err != nil || !Y.IsEqual(X) {
+		t.Error("binary round trip failed for", X, err)
+	}
+
+	enc, err = X.MarshalBinaryCompress()
+	if err != nil {
+		t.Error("MarshalBinaryCompress:", err)
+		return
+	}
+	Y = g.Element()
+	if err = Y.UnmarshalBinary(enc); err != nil || !Y.IsEqual(X) {
+		t.Error("compressed round trip failed for", X, err)
+	}
+
+	enc, err = X.MarshalJSON()
+	if err != nil {
+		t.Error("MarshalJSON:", err)
+		return
+	}
+	Y = g.Element()
+	if err = Y.UnmarshalJSON(enc); err != nil || !Y.IsEqual(X) {
+		t.Error("JSON round trip failed for", X, err)
+	}
+}
+
+// TestMapToGroup checks that MapToGroup is a deterministic function whose
+// outputs are not trivial.
+func TestMapToGroup(t *testing.T, g group.Group) {
+	seen := make([]group.Element, 0, 8)
+	for i := 0; i < 8; i++ {
+		msg := "grouptest" + strconv.Itoa(i)
+		X, err := g.Element().MapToGroup(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		Y, err := g.Element().MapToGroup(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !X.IsEqual(Y) {
+			t.Error("MapToGroup is not deterministic for", msg)
+		}
+		if X.IsIdentity() || X.IsEqual(g.Generator()) {
+			t.Error("MapToGroup returned a trivial element for", msg)
+		}
+		for _, Z := range seen {
+			if X.IsEqual(Z) {
+				t.Error("MapToGroup collision for", msg)
+			}
+		}
+		seen = append(seen, X)
+		checkRoundTrip(t, g, X)
+	}
+}
+
+// TestMixing checks that combining elements or scalars of g with those of
+// the other registered groups panics.
+func TestMixing(t *testing.T, g group.Group) {
+	for _, name := range group.Names() {
+		if name == g.Name() {
+			continue
+		}
+		o, err := group.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		MixingWith(t, g, o)
+	}
+}
+
+// MixingWith checks that combining elements or scalars of g with those of
+// the distinct group o panics.
+func MixingWith(t *testing.T, g, o group.Group) {
+	t.Helper()
+
+	X, Y := g.Random(), o.Random()
+	s, r := g.RandomScalar(), o.RandomScalar()
+
+	mustPanic(t, o, "Add", func() { g.Element().Add(X, Y) })
+	mustPanic(t, o, "Subtract", func() { g.Element().Subtract(X, Y) })
+	mustPanic(t, o, "Set", func() { g.Element().Set(Y) })
+	mustPanic(t, o, "IsEqual", func() { X.IsEqual(Y) })
+	mustPanic(t, o, "Scale with a foreign scalar", func() { g.Element().Scale(X, r) })
+	mustPanic(t, o, "Scale of a foreign element", func() { g.Element().Scale(Y, s) })
+	mustPanic(t, o, "BaseScale", func() { g.Element().BaseScale(r) })
+	mustPanic(t, o, "scalar Add", func() { g.NewScalar().Add(s, r) })
+	mustPanic(t, o, "scalar Multiply", func() { g.NewScalar().Multiply(s, r) })
+	mustPanic(t, o, "scalar Set", func() { g.NewScalar().Set(r) })
+}
+
+func mustPanic(t *testing.T, o group.Group, op string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Error(op, "with", o.Name(), "did not panic")
+		}
+	}()
+	f()
+}
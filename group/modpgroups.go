@@ -0,0 +1,83 @@
+package group
+
+import "sync"
+
+var rfc3526ModPGroup3072 = sync.OnceValue(func() Group {
+	return NewModPGroup(
+		"RFC3526ModPGroup3072",
+		`FFFFFFFF FFFFFFFF C90FDAA2 2168C234 C4C6628B 80DC1CD1
+		29024E08 8A67CC74 020BBEA6 3B139B22 514A0879 8E3404DD
+		EF9519B3 CD3A431B 302B0A6D F25F1437 4FE1356D 6D51C245
+		E485B576 625E7EC6 F44C42E9 A637ED6B 0BFF5CB6 F406B7ED
+		EE386BFB 5A899FA5 AE9F2411 7C4B1FE6 49286651 ECE45B3D
+		C2007CB8 A163BF05 98DA4836 1C55D39A 69163FA8 FD24CF5F
+		83655D23 DCA3AD96 1C62F356 208552BB 9ED52907 7096966D
+		670C354E 4ABC9804 F1746C08 CA18217C 32905E46 2E36CE3B
+		E39E772C 180E8603 9B2783A2 EC07A28F B5C55DF0 6F4C52C9
+		DE2BCBF6 95581718 3995497C EA956AE5 15D22618 98FA0510
+		15728E5A 8AAAC42D AD33170D 04507A33 A85521AB DF1CBA64
+		ECFB8504 58DBEF0A 8AEA7157 5D060C7D B3970F85 A6E1E4C7
+		ABF5AE8C DB0933D7 1E8C94E0 4A25619D CEE3D226 1AD2EE6B
+		F12FFA06 D98A0864 D8760273 3EC86A64 521F2B18 177B200C
+		BBE11757 7A615D6C 770988C0 BAD946E2 08E24FA0 74E5AB31
+		43DB5BFC E0FD108E 4B82D120 A93AD2CA FFFFFFFF FFFFFFFF
+		`, "2")
+})
+
+var modPGroup3072q256 = sync.OnceValue(func() Group {
+	return NewModPSubgroup(
+		"ModPGroup3072q256",
+		`8B92EF76 47DA3CD1 EB5BA271 125FB790 DB818088 AEF00DB7
+		7B6BCEC4 FBCB2D01 C55D9361 D1E37DB5 16F8EAF7 5523D663
+		CBB06DCA 069C999A 5A86245B 1C5FE39B 4B973845 964E1302
+		6F8FBE80 6FC91371 EE656BC6 B1DEFCB9 D50F2B84 84B81252
+		49686978 BEFF9746 DA3FCED2 E779A937 85B69259 EB00355C
+		55ACDA6E 81D49DAE 7B3CBD3C 2F65F457 57099428 8809C236
+		D2ACE522 2F9541E6 B8F5C8F3 F66F0B61 5D120EF7 C8DDB36E
+		769777FA 371B86CA EC8760C3 962B4225 F8A59AED 03742D24
+		63620472 64BAC957 E720A710 EB3C9561 170D4406 CB532624
+		60AD4E48 2377428D 88C57A2F 84E123F7 0AF7BE9A 371CC6CD
+		72E7A8A6 88319361 BA502F99 8B1404EE 63F4499B 88378BF0
+		B770B051 BA569BE4 898FE435 9E93DC5F 618C683F 9EBD3A60
+		166A32BC 82D4D52E D661BFF7 644BC449 55011E54 57E62603
+		145A3057 5993AE38 4A707855 6DDA795B 9EA3E35C 1E9A044A
+		C3F0EA71 A699B227 D8BC95B2 5CB82463 F9545E4E 6FD3920C
+		FFEE9A9D 8A42D929 AA0EFEC0 6A9072E1 3C69F292 04C5A269
+		`,
+		`8031EE2A 403784CB 3E5EBF63 71C379BA F8992DE6 62701066
+		4AA9B7CC C1B23FB1`,
+		`8B5D306A 8E20B9F7 5EC84E00 C6959D50 B43AA57E DB90D159
+		F4A330D0 DC6B2C07 0C51C4A0 4400AA30 04B19C83 B46A1297
+		CBB9F289 CD11BBBC 33C394AB B16B5F64 A5ACEE65 883D2647
+		B4A5C391 A54CBD2D 5F74B665 66B6794D CC01AB44 A0A1FBDD
+		45673AD9 4D3F16A6 F40C7177 63A27046 062F7A7A C8094DCF
+		B8D5193C 5DB8CFCE 16A4AD2E 5E3EC215 BD73B97F BA5B15B1
+		7BA881A7 29609297 A990718E 8A0771E4 76C92C88 D252C5C8
+		49AAD2C2 2AE31702 D83573ED 49DAA21D 7CEE6934 7E206AE2
+		6D5AAC41 4C89C74C A7AC9A43 D8707D96 D019CBCF F71FEC12
+		12372C1A 2DE8A001 EDD30FC9 B7BD87F0 70921F34 D4B333D8
+		8C78872D 7FAE6D9E 4BA135B6 2B30013A 63F5B8D0 748B62AB
+		F655062E D41F9046 C4F051AA 25E4839E DBD698B6 BFA95B2C
+		89B95696 71663C5C A58DAF03 3B837ED1 D7B42507 84F976DE
+		BB15FC6D AF2B024B B11DC5DA A1B6F6C3 AD13D15E EC8DE6AF
+		95B2C8BA A46C285C E3F607CA C5DA900F C0D8B88A AC7C9B72
+		2CE28C35 F398CBDF A703C70F 6CF446FE 70C868BF F70C9310`)
+})
+
+// RFC3526ModPGroup3072 returns the 3072-bit safe-prime group of RFC 3526.
+func RFC3526ModPGroup3072() Group {
+	return rfc3526ModPGroup3072()
+}
+
+// ModPGroup3072q256 returns a 3072-bit prime field group with a 256-bit
+// prime-order subgroup, so that exponents are short. The parameters are
+// derived from the seeds "ModPGroup3072q256 q", "ModPGroup3072q256 p" and
+// "ModPGroup3072q256 g". The i-th candidate of a seed concatenates the
+// SHA-256 digests of the seed, i and a block counter as 32-bit big-endian
+// integers, truncated to the bit-length with its top bit set. q is the
+// first prime candidate of 256 bits, p the first prime x - (x mod 2q) + 1
+// of 3072 bits for a candidate x, and g the first y^((p-1)/q) other than 1
+// for a candidate y of 3072 bits. TestModPGroup3072q256 derives them again.
+func ModPGroup3072q256() Group {
+	return modPGroup3072q256()
+}
@@ -1,15 +1,23 @@
 package group
 
 import (
-	"crypto/rand"
+	"crypto"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"strings"
+	"sync"
+
+	"github.com/cloudflare/circl/expander"
 )
 
+// modPMapDST separates MapToGroup from other uses of the hash function.
+const modPMapDST = "msc-poc-ModP-MapToGroup"
+
 type ModPElement struct {
 	group *ModPGroup
 	val   *big.Int
+	table *fixedBaseTable[*big.Int] // Fixed-base table, see Precompute.
 }
 
 type ModPGroup struct {
@@ -17,6 +25,9 @@ type ModPGroup struct {
 	fieldOrder *big.Int
 	groupOrder *big.Int
 	name       string
+
+	genTableOnce sync.Once
+	genTable     *fixedBaseTable[*big.Int]
 }
 
 func (g *ModPGroup) Name() string {
@@ -24,7 +35,12 @@ func (g *ModPGroup) Name() string {
 }
 
 func (g *ModPGroup) MarshalJSON() ([]byte, error) {
-	return json.Marshal(&GroupId{g.name})
+	return json.Marshal(&ModPGroupDescription{
+		Name: g.name,
+		P:    g.fieldOrder,
+		Q:    g.groupOrder,
+		G:    g.gen,
+	})
 }
 
 func (g *ModPGroup) equals(h Group) bool {
@@ -35,7 +51,52 @@ func (g *ModPGroup) equals(h Group) bool {
 	if !ok {
 		return false
 	}
-	return g.fieldOrder.Cmp(gh.fieldOrder) == 0 && g.gen.Cmp(gh.gen) == 0
+	return g.fieldOrder.Cmp(gh.fieldOrder) == 0 &&
+		g.groupOrder.Cmp(gh.groupOrder) == 0 &&
+		g.gen.Cmp(gh.gen) == 0
+}
+
+// isSafePrime returns true if the field order is p = 2q + 1.
+func (g *ModPGroup) isSafePrime() bool {
+	tmp := new(big.Int).Lsh(g.groupOrder, 1)
+	tmp.Add(tmp, big.NewInt(1))
+	return tmp.Cmp(g.fieldOrder) == 0
+}
+
+// contains returns true if x is an element of the prime-order subgroup.
+func (g *ModPGroup) contains(x *big.Int) bool {
+	if x.Sign() <= 0 || x.Cmp(g.fieldOrder) >= 0 {
+		return false
+	}
+	// For safe primes the subgroup consists of the quadratic residues,
+	// and the Jacobi symbol is much cheaper than an exponentiation.
+	if g.isSafePrime() {
+		return big.Jacobi(x, g.fieldOrder) == 1
+	}
+	return new(big.Int).Exp(x, g.groupOrder, g.fieldOrder).Cmp(big.NewInt(1)) == 0
+}
+
+// newTable computes the fixed-base table of x.
+func (g *ModPGroup) newTable(x *big.Int) *fixedBaseTable[*big.Int] {
+	// Large windows make tables for long exponents too big.
+	window := uint(6)
+	if g.groupOrder.BitLen() > 512 {
+		window = 4
+	}
+	mul := func(a, b *big.Int) *big.Int {
+		r := new(big.Int).Mul(a, b)
+		return r.Mod(r, g.fieldOrder)
+	}
+	return newFixedBaseTable(x, big.NewInt(1), g.groupOrder.BitLen(), window, mul)
+}
+
+// generatorTable returns the fixed-base table of the generator,
+// which is computed on first use.
+func (g *ModPGroup) generatorTable() *fixedBaseTable[*big.Int] {
+	g.genTableOnce.Do(func() {
+		g.genTable = g.newTable(g.gen)
+	})
+	return g.genTable
 }
 
 func (g *ModPGroup) P() *big.Int {
@@ -61,12 +122,23 @@ func (g *ModPGroup) Identity() Element {
 }
 
 func (g *ModPGroup) Random() Element {
-	r, _ := rand.Int(rand.Reader, g.groupOrder)
 	e := g.Identity()
-	e.BaseScale(r)
+	e.BaseScale(g.RandomScalar())
 	return e
 }
 
+func (g *ModPGroup) NewScalar() Scalar {
+	return newBigScalar(g.groupOrder)
+}
+
+func (g *ModPGroup) RandomScalar() Scalar {
+	return randomBigScalar(g.groupOrder)
+}
+
+func (g *ModPGroup) HashToScalar(msg, dst []byte) Scalar {
+	return hashToBigScalar(g.groupOrder, msg, dst)
+}
+
 func (g *ModPGroup) Element() Element {
 	e := new(ModPElement)
 	e.group = g
@@ -88,6 +160,7 @@ func (e *ModPElement) check(a Element) *ModPElement {
 func (e *ModPElement) Add(a Element, b Element) Element {
 	ex := e.check(a)
 	ey := e.check(b)
+	e.table = nil
 	e.val.Mul(ex.val, ey.val)
 	e.val.Mod(e.val, e.group.fieldOrder)
 	return e
@@ -102,6 +175,7 @@ func (e *ModPElement) Subtract(a Element, b Element) Element {
 
 func (e *ModPElement) Negate(a Element) Element {
 	ex := e.check(a)
+	e.table = nil
 	e.val.ModInverse(ex.val, e.group.fieldOrder)
 	return e
 }
@@ -113,26 +187,48 @@ func (e *ModPElement) IsEqual(b Element) bool {
 
 func (e *ModPElement) Set(a Element) Element {
 	ex := e.check(a)
+	e.table = nil
 	e.val.Set(ex.val)
 	return e
 }
 
 func (e *ModPElement) SetBytes(b []byte) Element {
+	e.table = nil
 	e.val.SetBytes(b)
 	return e
 }
 
-func (e *ModPElement) Scale(a Element, s *big.Int) Element {
+func (e *ModPElement) Scale(a Element, s Scalar) Element {
 	ex := e.check(a)
-	e.val.Exp(ex.val, s, e.group.fieldOrder)
+	if ex.table != nil {
+		val := ex.table.scale(toBig(s, e.group.groupOrder))
+		e.table = nil
+		e.val.Set(val)
+		return e
+	}
+	e.table = nil
+	e.val.Exp(ex.val, toBig(s, e.group.groupOrder), e.group.fieldOrder)
 	return e
 }
 
-func (e *ModPElement) BaseScale(s *big.Int) Element {
-	e.val.Exp(e.group.gen, s, e.group.fieldOrder)
+func (e *ModPElement) BaseScale(s Scalar) Element {
+	e.table = nil
+	e.val.Set(e.group.generatorTable().scale(toBig(s, e.group.groupOrder)))
 	return e
 }
 
+func (e *ModPElement) precompute() Element {
+	return &ModPElement{
+		group: e.group,
+		val:   new(big.Int).Set(e.val),
+		table: e.group.newTable(new(big.Int).Set(e.val)),
+	}
+}
+
+func (e *ModPElement) isPrecomputed() bool {
+	return e.table != nil
+}
+
 func (e *ModPElement) GroupOrder() *big.Int {
 	return e.group.groupOrder
 }
@@ -149,16 +245,46 @@ func (e *ModPElement) IsIdentity() bool {
 	return e.val.Cmp(big.NewInt(1)) == 0
 }
 
+// MapToGroup hashes s to an integer modulo p as hash_to_field of RFC 9380,
+// and raises it to the cofactor (p - 1) / q to land in the subgroup.
 func (e *ModPElement) MapToGroup(s string) (Element, error) {
-	panic("not implemented")
+	g := e.group
+	cofactor := new(big.Int).Sub(g.fieldOrder, big.NewInt(1))
+	cofactor.Div(cofactor, g.groupOrder)
+
+	length := uint((g.fieldOrder.BitLen() + 128 + 7) / 8)
+	exp := expander.NewExpanderMD(crypto.SHA256, []byte(modPMapDST))
+	for ctr := byte(0); ctr < 255; ctr++ {
+		val := new(big.Int).SetBytes(exp.Expand(append([]byte(s), ctr), length))
+		val.Mod(val, g.fieldOrder)
+		val.Exp(val, cofactor, g.fieldOrder)
+		// Values of low order map to the identity, which is not a generator.
+		if val.Sign() != 0 && val.Cmp(big.NewInt(1)) != 0 {
+			e.table = nil
+			e.val = val
+			return e, nil
+		}
+	}
+	return nil, errors.New("failed to map to the group")
 }
 
 func (e *ModPElement) MarshalBinary() ([]byte, error) {
 	return e.val.Bytes(), nil
 }
 
+// MarshalBinaryCompress returns the same encoding as MarshalBinary, since
+// residues modulo p have no more compact representation.
+func (e *ModPElement) MarshalBinaryCompress() ([]byte, error) {
+	return e.MarshalBinary()
+}
+
 func (e *ModPElement) UnmarshalBinary(data []byte) error {
-	e.val = new(big.Int).SetBytes(data)
+	val := new(big.Int).SetBytes(data)
+	if !e.group.contains(val) {
+		return errors.New("element is not a member of the group")
+	}
+	e.table = nil
+	e.val = val
 	return nil
 }
 
@@ -167,31 +293,81 @@ func (e *ModPElement) MarshalJSON() ([]byte, error) {
 }
 
 func (e *ModPElement) UnmarshalJSON(data []byte) error {
-	err := e.val.UnmarshalJSON(data)
-	return err
+	val := new(big.Int)
+	err := val.UnmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	if !e.group.contains(val) {
+		return errors.New("element is not a member of the group")
+	}
+	e.table = nil
+	e.val = val
+	return nil
 }
 
+// NewModPGroup creates the subgroup of quadratic residues modulo the safe
+// prime p = 2q + 1. The field order and generator are given in hexadecimal.
 func NewModPGroup(name string, fieldOrder, generator string) Group {
-	repr := strings.Join(strings.Fields(fieldOrder), "")
+	ffOrder, ok := new(big.Int).SetString(strings.Join(strings.Fields(fieldOrder), ""), 16)
+	if !ok {
+		panic("invalid group definition")
+	}
 
-	ffOrder, ok := new(big.Int).SetString(repr, 16)
+	genOrder := new(big.Int).Set(ffOrder)
+	genOrder.Sub(genOrder, big.NewInt(1))
+	genOrder.Div(genOrder, big.NewInt(2))
+
+	return NewModPSubgroup(name, fieldOrder, genOrder.Text(16), generator)
+}
+
+// NewModPSubgroup creates the subgroup of prime order q modulo the prime
+// p = kq + 1, also known as a Schnorr group. The field order, group order
+// and generator are given in hexadecimal. Choosing q much shorter than p
+// keeps exponents short without weakening the group.
+func NewModPSubgroup(name string, fieldOrder, groupOrder, generator string) Group {
+	ffOrder, ok := new(big.Int).SetString(strings.Join(strings.Fields(fieldOrder), ""), 16)
 	if !ok {
 		panic("invalid group definition")
 	}
 
-	gen, ok := new(big.Int).SetString(generator, 16)
+	genOrder, ok := new(big.Int).SetString(strings.Join(strings.Fields(groupOrder), ""), 16)
+	if !ok {
+		panic("invalid group order")
+	}
+
+	gen, ok := new(big.Int).SetString(strings.Join(strings.Fields(generator), ""), 16)
 	if !ok {
 		panic("invalid generator")
 	}
 
-	genOrder := new(big.Int).Set(ffOrder)
-	genOrder.Sub(genOrder, big.NewInt(1))
-	genOrder.Div(genOrder, big.NewInt(2))
+	G, err := newModPGroup(name, ffOrder, genOrder, gen)
+	if err != nil {
+		panic(err)
+	}
+	return G
+}
+
+func newModPGroup(name string, p, q, g *big.Int) (*ModPGroup, error) {
+	one := big.NewInt(1)
+
+	// The group order must divide p - 1.
+	pm1 := new(big.Int).Sub(p, one)
+	if p.Sign() <= 0 || q.Sign() <= 0 || new(big.Int).Mod(pm1, q).Sign() != 0 {
+		return nil, errors.New("group order does not divide p - 1")
+	}
+	if !p.ProbablyPrime(1) || !q.ProbablyPrime(1) {
+		return nil, errors.New("field and group orders must be prime")
+	}
 
 	G := new(ModPGroup)
-	G.fieldOrder = ffOrder
-	G.groupOrder = genOrder
-	G.gen = gen
+	G.fieldOrder = p
+	G.groupOrder = q
+	G.gen = g
 	G.name = name
-	return G
+
+	if !G.contains(g) || g.Cmp(one) == 0 {
+		return nil, errors.New("generator does not generate the subgroup")
+	}
+	return G, nil
 }
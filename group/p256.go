@@ -57,6 +57,22 @@ func (g *p256Group) Random() Element {
 	}
 }
 
+func (g *p256Group) NewScalar() Scalar {
+	return newCirclScalar(group.P256, g.curveOrder)
+}
+
+func (g *p256Group) RandomScalar() Scalar {
+	s := newCirclScalar(group.P256, g.curveOrder)
+	s.val = group.P256.RandomScalar(rand.Reader)
+	return s
+}
+
+func (g *p256Group) HashToScalar(msg, dst []byte) Scalar {
+	s := newCirclScalar(group.P256, g.curveOrder)
+	s.val = group.P256.HashToScalar(msg, dst)
+	return s
+}
+
 func (g *p256Group) Element() Element {
 	return &p256Point{
 		curve: g,
@@ -109,16 +125,14 @@ func (e *p256Point) SetBytes(b []byte) Element {
 	return e
 }
 
-func (e *p256Point) Scale(x Element, s *big.Int) Element {
+func (e *p256Point) Scale(x Element, s Scalar) Element {
 	ex := e.check(x)
-	scalar := group.P256.NewScalar()
-	e.val = group.P256.NewElement().Mul(ex.val, scalar.SetBigInt(s))
+	e.val = group.P256.NewElement().Mul(ex.val, toCircl(s, group.P256))
 	return e
 }
 
-func (e *p256Point) BaseScale(s *big.Int) Element {
-	scalar := group.P256.NewScalar()
-	e.val = group.P256.NewElement().MulGen(scalar.SetBigInt(s))
+func (e *p256Point) BaseScale(s Scalar) Element {
+	e.val = group.P256.NewElement().MulGen(toCircl(s, group.P256))
 	return e
 }
 
@@ -138,7 +152,7 @@ func (e *p256Point) MapToGroup(s string) (Element, error) {
 }
 
 func (e *p256Point) String() string {
-	tmp, _ := e.val.MarshalBinary()
+	tmp, _ := e.val.Copy().MarshalBinary()
 	return string(tmp)
 }
 
@@ -146,17 +160,25 @@ func (e *p256Point) IsIdentity() bool {
 	return e.val.IsIdentity()
 }
 
+// MarshalBinary encodes a copy of the point, since CIRCL reduces the
+// coordinates in place, which would race with concurrent readers.
 func (e *p256Point) MarshalBinary() ([]byte, error) {
-	return e.val.MarshalBinary()
+	return e.val.Copy().MarshalBinary()
+}
+
+func (e *p256Point) MarshalBinaryCompress() ([]byte, error) {
+	return e.val.Copy().MarshalBinaryCompress()
 }
 
+// UnmarshalBinary accepts both the uncompressed and the compressed SEC 1
+// encoding, and rejects points that are not on the curve.
 func (e *p256Point) UnmarshalBinary(data []byte) error {
 	err := e.val.UnmarshalBinary(data)
 	return err
 }
 
 func (e *p256Point) MarshalJSON() ([]byte, error) {
-	tmp, _ := e.val.MarshalBinary()
+	tmp, _ := e.val.Copy().MarshalBinary()
 	xVal := big.NewInt(0)
 	yVal := big.NewInt(0)
 
@@ -180,8 +202,7 @@ func (e *p256Point) MarshalJSON() ([]byte, error) {
 }
 
 func (e *p256Point) UnmarshalJSON(data []byte) error {
-	point := ECPoint{}
-	err := json.Unmarshal(data, &point)
+	point, err := unmarshalECPoint(data)
 	if err != nil {
 		return err
 	}
@@ -196,6 +217,9 @@ func (e *p256Point) UnmarshalJSON(data []byte) error {
 
 	xBytes := point.X.Bytes()
 	yBytes := point.Y.Bytes()
+	if len(xBytes) > byteLen || len(yBytes) > byteLen {
+		return fmt.Errorf("point coordinates are too long")
+	}
 
 	tmp := make([]byte, 1+2*byteLen)
 	tmp[0] = 4
@@ -1,12 +1,15 @@
 package group
 
 import (
-	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"github.com/ing-bank/zkrp/crypto/p256"
 	"math/big"
 )
 
+// p256k1CoordLen is the length of an encoded coordinate.
+const p256k1CoordLen = 32
+
 type p256k1Group struct {
 	fieldOrder *big.Int
 	curveOrder *big.Int
@@ -49,12 +52,23 @@ func (g *p256k1Group) Identity() Element {
 }
 
 func (g *p256k1Group) Random() Element {
-	r, _ := rand.Int(rand.Reader, g.curveOrder)
 	e := g.Identity()
-	e.BaseScale(r)
+	e.BaseScale(g.RandomScalar())
 	return e
 }
 
+func (g *p256k1Group) NewScalar() Scalar {
+	return newBigScalar(g.curveOrder)
+}
+
+func (g *p256k1Group) RandomScalar() Scalar {
+	return randomBigScalar(g.curveOrder)
+}
+
+func (g *p256k1Group) HashToScalar(msg, dst []byte) Scalar {
+	return hashToBigScalar(g.curveOrder, msg, dst)
+}
+
 func (g *p256k1Group) Element() Element {
 	p := new(p256k1Point)
 	p.curve = g
@@ -131,14 +145,14 @@ func (e *p256k1Point) SetBytes(b []byte) Element {
 	return e
 }
 
-func (e *p256k1Point) Scale(a Element, s *big.Int) Element {
+func (e *p256k1Point) Scale(a Element, s Scalar) Element {
 	ca := e.check(a)
-	e.val = new(p256.P256).ScalarMult(ca.val, s)
+	e.val = new(p256.P256).ScalarMult(ca.val, toBig(s, e.curve.curveOrder))
 	return e
 }
 
-func (e *p256k1Point) BaseScale(s *big.Int) Element {
-	e.val = new(p256.P256).ScalarBaseMult(s)
+func (e *p256k1Point) BaseScale(s Scalar) Element {
+	e.val = new(p256.P256).ScalarBaseMult(toBig(s, e.curve.curveOrder))
 	return e
 }
 
@@ -151,11 +165,12 @@ func (e *p256k1Point) FieldOrder() *big.Int {
 }
 
 func (e *p256k1Point) MapToGroup(s string) (Element, error) {
-	tmp, _ := p256.MapToGroup(s)
-	tmpX := tmp.X.Bytes()
-	tmpY := tmp.Y.Bytes()
-	res := e.curve.Element().SetBytes(append(tmpX, tmpY...))
-	return res, nil
+	tmp, err := p256.MapToGroup(s)
+	if err != nil {
+		return nil, err
+	}
+	e.val = tmp
+	return e, nil
 }
 
 func (e *p256k1Point) String() string {
@@ -169,30 +184,104 @@ func (e *p256k1Point) IsIdentity() bool {
 	return e.val.X.Cmp(big.NewInt(0)) == 0 && e.val.Y.Cmp(big.NewInt(0)) == 0
 }
 
+// MarshalBinary encodes the point as the fixed-length concatenation of its
+// coordinates. The point at infinity is encoded as all zeroes.
 func (e *p256k1Point) MarshalBinary() ([]byte, error) {
-	xBytes := make([]byte, 32)
-	if e.val.X != nil {
-		xBytes = e.val.X.Bytes()
+	buf := make([]byte, 2*p256k1CoordLen)
+	if e.IsIdentity() {
+		return buf, nil
 	}
+	e.val.X.FillBytes(buf[:p256k1CoordLen])
+	e.val.Y.FillBytes(buf[p256k1CoordLen:])
+	return buf, nil
+}
 
-	yBytes := make([]byte, 32)
-	if e.val.Y != nil {
-		yBytes = e.val.Y.Bytes()
+// MarshalBinaryCompress encodes the point as in SEC 1: a tag byte holding
+// the parity of the y-coordinate followed by the x-coordinate. The point at
+// infinity is encoded as a single zero byte.
+func (e *p256k1Point) MarshalBinaryCompress() ([]byte, error) {
+	if e.IsIdentity() {
+		return []byte{0}, nil
 	}
-
-	return append(xBytes, yBytes...), nil
+	buf := make([]byte, 1+p256k1CoordLen)
+	buf[0] = 2 | byte(e.val.Y.Bit(0))
+	e.val.X.FillBytes(buf[1:])
+	return buf, nil
 }
 
+// UnmarshalBinary accepts both the uncompressed and the compressed encoding.
 func (e *p256k1Point) UnmarshalBinary(data []byte) error {
-	e.SetBytes(data)
-	return nil
+	switch {
+	case len(data) == 2*p256k1CoordLen:
+		x := new(big.Int).SetBytes(data[:p256k1CoordLen])
+		y := new(big.Int).SetBytes(data[p256k1CoordLen:])
+		return e.setCoordinates(x, y)
+	case len(data) == 1 && data[0] == 0:
+		e.val = new(p256.P256).SetInfinity()
+		return nil
+	case len(data) == 1+p256k1CoordLen && (data[0] == 2 || data[0] == 3):
+		return e.decompress(new(big.Int).SetBytes(data[1:]), uint(data[0]&1))
+	default:
+		return errors.New("invalid point encoding")
+	}
+}
+
+// decompress sets the receiver to the point with x-coordinate x and the
+// y-coordinate of the given parity, which is recovered from y^2 = x^3 + 7.
+func (e *p256k1Point) decompress(x *big.Int, parity uint) error {
+	p := e.curve.fieldOrder
+	if x.Cmp(p) >= 0 {
+		return errors.New("point coordinates are out of range")
+	}
+
+	y2 := new(big.Int).Exp(x, big.NewInt(3), p)
+	y2.Add(y2, big.NewInt(7))
+	y2.Mod(y2, p)
+	y := new(big.Int).ModSqrt(y2, p)
+	if y == nil {
+		return errors.New("point is not on the curve")
+	}
+	if y.Bit(0) != parity {
+		y.Sub(p, y)
+	}
+	return e.setCoordinates(x, y)
 }
 
 func (e *p256k1Point) MarshalJSON() ([]byte, error) {
-	return json.Marshal(e.val)
+	point := ECPoint{X: big.NewInt(0), Y: big.NewInt(0)}
+	if !e.IsIdentity() {
+		point.X.Set(e.val.X)
+		point.Y.Set(e.val.Y)
+	}
+	return json.Marshal(&point)
 }
 
 func (e *p256k1Point) UnmarshalJSON(data []byte) error {
+	point, err := unmarshalECPoint(data)
+	if err != nil {
+		return err
+	}
+	return e.setCoordinates(point.X, point.Y)
+}
+
+// setCoordinates sets the receiver to the affine point (x, y), where
+// (0, 0) denotes the point at infinity, and rejects points not on the curve.
+func (e *p256k1Point) setCoordinates(x, y *big.Int) error {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		e.val = new(p256.P256).SetInfinity()
+		return nil
+	}
+
+	if x.Sign() < 0 || y.Sign() < 0 ||
+		x.Cmp(e.curve.fieldOrder) >= 0 || y.Cmp(e.curve.fieldOrder) >= 0 {
+		return errors.New("point coordinates are out of range")
+	}
+
+	val := &p256.P256{X: new(big.Int).Set(x), Y: new(big.Int).Set(y)}
+	if !val.IsOnCurve() {
+		return errors.New("point is not on the curve")
+	}
+	e.val = val
 	return nil
 }
 
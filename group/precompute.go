@@ -0,0 +1,79 @@
+package group
+
+import "math/big"
+
+// precomputer is implemented by elements that support fixed-base tables.
+type precomputer interface {
+	precompute() Element
+	isPrecomputed() bool
+}
+
+// Precompute returns a copy of X together with a table of multiples of X,
+// so that repeatedly scaling X is considerably faster. The table is dropped
+// if the returned element is modified. Groups for which the table lookups
+// are not faster than their scalar multiplication, or whose scalar
+// multiplication is constant-time, return X unchanged.
+func Precompute(X Element) Element {
+	p, ok := X.(precomputer)
+	if !ok || p.isPrecomputed() {
+		return X
+	}
+	return p.precompute()
+}
+
+// fixedBaseTable holds the multiples j * 2^(w*i) * X of a fixed base X for
+// every window i and digit j < 2^w. A scalar multiplication then costs one
+// group operation per non-zero window of the scalar and no doublings.
+// Lookups depend on the digits of the scalar, so tables are only used by
+// groups whose arithmetic is not constant-time in the first place.
+type fixedBaseTable[T any] struct {
+	window uint
+	rows   [][]T
+	add    func(x, y T) T
+}
+
+// newFixedBaseTable builds the table of a base for scalars of up to the
+// given bit-length. The add function must return a new element.
+func newFixedBaseTable[T any](base T, identity T, bits int, window uint, add func(x, y T) T) *fixedBaseTable[T] {
+	t := &fixedBaseTable[T]{
+		window: window,
+		rows:   make([][]T, (bits+int(window)-1)/int(window)),
+		add:    add,
+	}
+
+	cur := base
+	for i := range t.rows {
+		row := make([]T, 1<<window)
+		row[0] = identity
+		row[1] = cur
+		for j := 2; j < len(row); j++ {
+			row[j] = add(row[j-1], cur)
+		}
+		t.rows[i] = row
+		// The base of the next window is 2^w times the current base.
+		cur = add(row[len(row)-1], cur)
+	}
+	return t
+}
+
+// scale returns sX for a non-negative scalar s that fits into the table.
+func (t *fixedBaseTable[T]) scale(s *big.Int) T {
+	acc := t.rows[0][0]
+	first := true
+	for i, row := range t.rows {
+		digit := 0
+		for b := uint(0); b < t.window; b++ {
+			digit |= int(s.Bit(i*int(t.window)+int(b))) << b
+		}
+		if digit == 0 {
+			continue
+		}
+		if first {
+			acc = row[digit]
+			first = false
+			continue
+		}
+		acc = t.add(acc, row[digit])
+	}
+	return acc
+}
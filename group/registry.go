@@ -0,0 +1,104 @@
+package group
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func() Group)
+)
+
+func init() {
+	Register("P-256", P256)
+	Register("P-384", P384)
+	Register("secp256k1", SecP256k1)
+	Register("ristretto255", Ristretto255)
+	Register("RFC3526ModPGroup3072", RFC3526ModPGroup3072)
+	Register("ModPGroup3072q256", ModPGroup3072q256)
+}
+
+// Register makes a group constructor available by name, so that the group
+// can be recovered from its JSON description. Register panics if the name
+// is empty or already taken.
+func Register(name string, constructor func() Group) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" || constructor == nil {
+		panic("invalid group registration")
+	}
+	if _, ok := registry[name]; ok {
+		panic("group already registered: " + name)
+	}
+	registry[name] = constructor
+}
+
+// Lookup returns the registered group with the given name.
+func Lookup(name string) (Group, error) {
+	registryMu.RLock()
+	constructor, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown group %q", name)
+	}
+	return constructor(), nil
+}
+
+// Names returns the names of all registered groups in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UnmarshalGroupJSON recovers a group from the JSON description produced by
+// its json.Marshaler. Registered groups are resolved by name. Modular groups
+// that are not registered are reconstructed from their (p, q, g) description.
+// If a description is given for a registered group, it must match the group.
+func UnmarshalGroupJSON(b []byte) (Group, error) {
+	var desc ModPGroupDescription
+	err := json.Unmarshal(b, &desc)
+	if err != nil {
+		return nil, err
+	}
+
+	if desc.Name == "" {
+		return nil, errors.New("group name is missing")
+	}
+
+	hasParams := desc.P != nil || desc.Q != nil || desc.G != nil
+	complete := desc.P != nil && desc.Q != nil && desc.G != nil
+	if hasParams && !complete {
+		return nil, errors.New("incomplete group description")
+	}
+
+	g, err := Lookup(desc.Name)
+	if err != nil {
+		if !hasParams {
+			return nil, err
+		}
+		return newModPGroup(desc.Name, desc.P, desc.Q, desc.G)
+	}
+
+	if hasParams {
+		mg, ok := g.(*ModPGroup)
+		if !ok || mg.fieldOrder.Cmp(desc.P) != 0 ||
+			mg.groupOrder.Cmp(desc.Q) != 0 || mg.gen.Cmp(desc.G) != 0 {
+			return nil, fmt.Errorf("description does not match group %q", desc.Name)
+		}
+	}
+
+	return g, nil
+}
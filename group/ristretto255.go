@@ -3,7 +3,6 @@ package group
 import (
 	"crypto/rand"
 	"encoding/json"
-	"fmt"
 	"github.com/cloudflare/circl/group"
 	"math/big"
 )
@@ -57,6 +56,22 @@ func (g *r255Group) Random() Element {
 	}
 }
 
+func (g *r255Group) NewScalar() Scalar {
+	return newCirclScalar(group.Ristretto255, g.curveOrder)
+}
+
+func (g *r255Group) RandomScalar() Scalar {
+	s := newCirclScalar(group.Ristretto255, g.curveOrder)
+	s.val = group.Ristretto255.RandomScalar(rand.Reader)
+	return s
+}
+
+func (g *r255Group) HashToScalar(msg, dst []byte) Scalar {
+	s := newCirclScalar(group.Ristretto255, g.curveOrder)
+	s.val = group.Ristretto255.HashToScalar(msg, dst)
+	return s
+}
+
 func (g *r255Group) Element() Element {
 	return &r255Point{
 		curve: g,
@@ -109,16 +124,15 @@ func (e *r255Point) SetBytes(b []byte) Element {
 	return e
 }
 
-func (e *r255Point) Scale(x Element, s *big.Int) Element {
+func (e *r255Point) Scale(x Element, s Scalar) Element {
 	ex := e.check(x)
-	scalar := group.Ristretto255.NewScalar()
-	e.val = group.Ristretto255.NewElement().Mul(ex.val, scalar.SetBigInt(s))
+	scalar := toCircl(s, group.Ristretto255)
+	e.val = group.Ristretto255.NewElement().Mul(ex.val, scalar)
 	return e
 }
 
-func (e *r255Point) BaseScale(s *big.Int) Element {
-	scalar := group.Ristretto255.NewScalar()
-	e.val = group.Ristretto255.NewElement().MulGen(scalar.SetBigInt(s))
+func (e *r255Point) BaseScale(s Scalar) Element {
+	e.val = group.Ristretto255.NewElement().MulGen(toCircl(s, group.Ristretto255))
 	return e
 }
 
@@ -150,60 +164,34 @@ func (e *r255Point) MarshalBinary() ([]byte, error) {
 	return e.val.MarshalBinary()
 }
 
+// MarshalBinaryCompress returns the canonical encoding, which is already
+// compressed.
+func (e *r255Point) MarshalBinaryCompress() ([]byte, error) {
+	return e.val.MarshalBinaryCompress()
+}
+
 func (e *r255Point) UnmarshalBinary(data []byte) error {
 	err := e.val.UnmarshalBinary(data)
 	return err
 }
 
 func (e *r255Point) MarshalJSON() ([]byte, error) {
-	tmp, _ := e.val.MarshalBinary()
-	xVal := big.NewInt(0)
-	yVal := big.NewInt(0)
-
-	// If the point is not 0.
-	if tmp[0] != 0 {
-		xBytes := tmp[1 : 32+1]
-		yBytes := tmp[1+32:]
-		if len(xBytes) != 32 || len(xBytes) != len(yBytes) {
-			return nil, fmt.Errorf("error in underlying binary marshalling")
-		}
-		xVal.SetBytes(xBytes)
-		yVal.SetBytes(yBytes)
-	}
-
-	point := ECPoint{
-		X: xVal,
-		Y: yVal,
+	// Ristretto255 elements have no affine coordinate representation,
+	// so the canonical 32-byte encoding is used instead.
+	tmp, err := e.val.MarshalBinary()
+	if err != nil {
+		return nil, err
 	}
-
-	return json.Marshal(&point)
+	return json.Marshal(tmp)
 }
 
 func (e *r255Point) UnmarshalJSON(data []byte) error {
-	point := ECPoint{}
-	err := json.Unmarshal(data, &point)
+	var tmp []byte
+	err := json.Unmarshal(data, &tmp)
 	if err != nil {
 		return err
 	}
-
-	// The special case encoding of the point at infinity.
-	if point.X.Cmp(big.NewInt(0)) == 0 && point.Y.Cmp(big.NewInt(0)) == 0 {
-		err = e.val.UnmarshalBinary([]byte{0})
-		return err
-	}
-
-	byteLen := 32
-
-	xBytes := point.X.Bytes()
-	yBytes := point.Y.Bytes()
-
-	tmp := make([]byte, 1+2*byteLen)
-	tmp[0] = 4
-	// Copy while maintaining leading zeroes.
-	copy(tmp[1+byteLen-len(xBytes):byteLen+1], point.X.Bytes())
-	copy(tmp[1+2*byteLen-len(yBytes):], point.Y.Bytes())
-	err = e.val.UnmarshalBinary(tmp)
-	return err
+	return e.val.UnmarshalBinary(tmp)
 }
 
 func Ristretto255() Group {
@@ -0,0 +1,312 @@
+package group
+
+import (
+	"crypto"
+	"crypto/rand"
+	_ "crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/circl/expander"
+	"github.com/cloudflare/circl/group"
+)
+
+// bigScalar implements Scalar with big integers modulo the group order.
+// It is used by groups for which CIRCL provides no scalar implementation.
+type bigScalar struct {
+	order *big.Int
+	val   *big.Int
+}
+
+func newBigScalar(order *big.Int) *bigScalar {
+	return &bigScalar{order: order, val: new(big.Int)}
+}
+
+func randomBigScalar(order *big.Int) *bigScalar {
+	s := newBigScalar(order)
+	r, err := rand.Int(rand.Reader, order)
+	if err != nil {
+		panic(err)
+	}
+	s.val = r
+	return s
+}
+
+// hashToBigScalar hashes a message to a scalar as hash_to_field of RFC 9380,
+// so that the reduction modulo the group order is statistically uniform.
+func hashToBigScalar(order *big.Int, msg, dst []byte) *bigScalar {
+	length := uint((order.BitLen() + 128 + 7) / 8)
+	exp := expander.NewExpanderMD(crypto.SHA256, dst)
+	s := newBigScalar(order)
+	s.val.SetBytes(exp.Expand(msg, length))
+	s.val.Mod(s.val, order)
+	return s
+}
+
+func (s *bigScalar) check(a Scalar) *bigScalar {
+	sa, ok := a.(*bigScalar)
+	if !ok {
+		panic("incompatible scalar type")
+	}
+	if s.order != sa.order && s.order.Cmp(sa.order) != 0 {
+		panic("incompatible scalar groups")
+	}
+	return sa
+}
+
+func (s *bigScalar) reduce() Scalar {
+	s.val.Mod(s.val, s.order)
+	return s
+}
+
+func (s *bigScalar) Add(a, b Scalar) Scalar {
+	sa, sb := s.check(a), s.check(b)
+	s.val.Add(sa.val, sb.val)
+	return s.reduce()
+}
+
+func (s *bigScalar) Subtract(a, b Scalar) Scalar {
+	sa, sb := s.check(a), s.check(b)
+	s.val.Sub(sa.val, sb.val)
+	return s.reduce()
+}
+
+func (s *bigScalar) Multiply(a, b Scalar) Scalar {
+	sa, sb := s.check(a), s.check(b)
+	s.val.Mul(sa.val, sb.val)
+	return s.reduce()
+}
+
+func (s *bigScalar) Negate(a Scalar) Scalar {
+	sa := s.check(a)
+	s.val.Neg(sa.val)
+	return s.reduce()
+}
+
+func (s *bigScalar) Invert(a Scalar) Scalar {
+	sa := s.check(a)
+	if sa.val.Sign() == 0 {
+		s.val.SetInt64(0)
+		return s
+	}
+	s.val.ModInverse(sa.val, s.order)
+	return s
+}
+
+func (s *bigScalar) Set(a Scalar) Scalar {
+	sa := s.check(a)
+	s.val.Set(sa.val)
+	return s
+}
+
+func (s *bigScalar) SetUint64(n uint64) Scalar {
+	s.val.SetUint64(n)
+	return s.reduce()
+}
+
+func (s *bigScalar) SetBigInt(n *big.Int) Scalar {
+	s.val.Set(n)
+	return s.reduce()
+}
+
+func (s *bigScalar) BigInt() *big.Int {
+	return new(big.Int).Set(s.val)
+}
+
+func (s *bigScalar) IsEqual(a Scalar) bool {
+	sa := s.check(a)
+	return s.val.Cmp(sa.val) == 0
+}
+
+func (s *bigScalar) IsZero() bool {
+	return s.val.Sign() == 0
+}
+
+func (s *bigScalar) String() string {
+	return s.val.String()
+}
+
+func (s *bigScalar) MarshalBinary() ([]byte, error) {
+	return marshalScalar(s.val, s.order), nil
+}
+
+func (s *bigScalar) UnmarshalBinary(data []byte) error {
+	val, err := unmarshalScalar(data, s.order)
+	if err != nil {
+		return err
+	}
+	s.val = val
+	return nil
+}
+
+func (s *bigScalar) MarshalJSON() ([]byte, error) {
+	return s.val.MarshalJSON()
+}
+
+func (s *bigScalar) UnmarshalJSON(data []byte) error {
+	val, err := unmarshalScalarJSON(data, s.order)
+	if err != nil {
+		return err
+	}
+	s.val = val
+	return nil
+}
+
+// circlScalar implements Scalar with the scalars of a CIRCL group.
+type circlScalar struct {
+	group group.Group
+	order *big.Int
+	val   group.Scalar
+}
+
+func newCirclScalar(g group.Group, order *big.Int) *circlScalar {
+	return &circlScalar{group: g, order: order, val: g.NewScalar()}
+}
+
+// toCircl returns the CIRCL scalar of a, which must belong to the group g.
+func toCircl(a Scalar, g group.Group) group.Scalar {
+	sa, ok := a.(*circlScalar)
+	if !ok || sa.group != g {
+		panic("incompatible scalar type")
+	}
+	return sa.val
+}
+
+func (s *circlScalar) Add(a, b Scalar) Scalar {
+	s.val.Add(toCircl(a, s.group), toCircl(b, s.group))
+	return s
+}
+
+func (s *circlScalar) Subtract(a, b Scalar) Scalar {
+	s.val.Sub(toCircl(a, s.group), toCircl(b, s.group))
+	return s
+}
+
+func (s *circlScalar) Multiply(a, b Scalar) Scalar {
+	s.val.Mul(toCircl(a, s.group), toCircl(b, s.group))
+	return s
+}
+
+func (s *circlScalar) Negate(a Scalar) Scalar {
+	s.val.Neg(toCircl(a, s.group))
+	return s
+}
+
+func (s *circlScalar) Invert(a Scalar) Scalar {
+	s.val.Inv(toCircl(a, s.group))
+	return s
+}
+
+func (s *circlScalar) Set(a Scalar) Scalar {
+	s.val.Set(toCircl(a, s.group))
+	return s
+}
+
+func (s *circlScalar) SetUint64(n uint64) Scalar {
+	s.val.SetUint64(n)
+	return s
+}
+
+func (s *circlScalar) SetBigInt(n *big.Int) Scalar {
+	s.val.SetBigInt(n)
+	return s
+}
+
+func (s *circlScalar) BigInt() *big.Int {
+	b, _ := s.val.MarshalBinary()
+	if s.group == group.Ristretto255 {
+		// Ristretto255 scalars are encoded in little-endian order.
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func (s *circlScalar) IsEqual(a Scalar) bool {
+	return s.val.IsEqual(toCircl(a, s.group))
+}
+
+func (s *circlScalar) IsZero() bool {
+	return s.val.IsZero()
+}
+
+func (s *circlScalar) String() string {
+	return s.BigInt().String()
+}
+
+func (s *circlScalar) MarshalBinary() ([]byte, error) {
+	return marshalScalar(s.BigInt(), s.order), nil
+}
+
+func (s *circlScalar) UnmarshalBinary(data []byte) error {
+	val, err := unmarshalScalar(data, s.order)
+	if err != nil {
+		return err
+	}
+	s.val.SetBigInt(val)
+	return nil
+}
+
+func (s *circlScalar) MarshalJSON() ([]byte, error) {
+	return s.BigInt().MarshalJSON()
+}
+
+func (s *circlScalar) UnmarshalJSON(data []byte) error {
+	val, err := unmarshalScalarJSON(data, s.order)
+	if err != nil {
+		return err
+	}
+	s.val.SetBigInt(val)
+	return nil
+}
+
+// scalarLen returns the length of the binary encoding of scalars
+// modulo the given group order.
+func scalarLen(order *big.Int) int {
+	return (order.BitLen() + 7) / 8
+}
+
+// marshalScalar encodes a reduced scalar as a fixed-length big-endian integer.
+func marshalScalar(val, order *big.Int) []byte {
+	return val.FillBytes(make([]byte, scalarLen(order)))
+}
+
+// unmarshalScalar decodes a fixed-length big-endian integer, and rejects
+// encodings of the wrong length or of values that are not reduced.
+func unmarshalScalar(data []byte, order *big.Int) (*big.Int, error) {
+	if len(data) != scalarLen(order) {
+		return nil, errors.New("invalid scalar length")
+	}
+	val := new(big.Int).SetBytes(data)
+	if val.Cmp(order) >= 0 {
+		return nil, errors.New("scalar is not reduced")
+	}
+	return val, nil
+}
+
+// unmarshalScalarJSON decodes a scalar from a JSON integer, and rejects
+// negative values and values that are not reduced.
+func unmarshalScalarJSON(data []byte, order *big.Int) (*big.Int, error) {
+	if string(data) == "null" {
+		return nil, errors.New("scalar is missing")
+	}
+	val := new(big.Int)
+	err := val.UnmarshalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if val.Sign() < 0 || val.Cmp(order) >= 0 {
+		return nil, errors.New("scalar is out of range")
+	}
+	return val, nil
+}
+
+// toBig returns the value of a, which must be a scalar modulo the given order.
+func toBig(a Scalar, order *big.Int) *big.Int {
+	sa, ok := a.(*bigScalar)
+	if !ok || (sa.order != order && sa.order.Cmp(order) != 0) {
+		panic("incompatible scalar type")
+	}
+	return sa.val
+}
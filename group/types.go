@@ -1,6 +1,11 @@
 package group
 
-import "math/big"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
 
 // ECPoint is needed for JSON marshalling EC points.
 type ECPoint struct {
@@ -8,7 +13,30 @@ type ECPoint struct {
 	Y *big.Int `json:"y"`
 }
 
+// unmarshalECPoint decodes the coordinates of a point, and rejects unknown
+// fields and missing coordinates.
+func unmarshalECPoint(data []byte) (ECPoint, error) {
+	point := ECPoint{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&point); err != nil {
+		return ECPoint{}, err
+	}
+	if point.X == nil || point.Y == nil {
+		return ECPoint{}, errors.New("missing point coordinates")
+	}
+	return point, nil
+}
+
 // GroupId is needed for JSON marshalling groups.
 type GroupId struct {
 	Name string `json:"group"`
 }
+
+// ModPGroupDescription is needed for JSON marshalling modular groups.
+type ModPGroupDescription struct {
+	Name string   `json:"group"`
+	P    *big.Int `json:"p"`
+	Q    *big.Int `json:"q"`
+	G    *big.Int `json:"g"`
+}
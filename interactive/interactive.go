@@ -0,0 +1,181 @@
+// Package interactive runs proofs as live protocols between a prover and
+// a verifier. Provers and verifiers are state machines that consume each
+// other's messages, so the same machines can be driven over a connection
+// with verifier-chosen challenges, or locally with challenges derived by
+// Fiat–Shamir to produce and check non-interactive proofs.
+package interactive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Message is a move of a protocol. Its concrete type is defined by the
+// protocol.
+type Message any
+
+// Verdict is the last message of a protocol run, with which the verifier
+// tells the prover whether it accepted the proof.
+type Verdict struct {
+	Accepted bool
+}
+
+// Prover is the state machine of a prover.
+type Prover interface {
+	// Next consumes the verifier's last challenge, which is nil at the
+	// start, and returns the prover's next message.
+	Next(challenge Message) (Message, error)
+	// Done returns true once the prover has sent its last message.
+	Done() bool
+}
+
+// Verifier is the state machine of a verifier.
+type Verifier interface {
+	// Next consumes the prover's next message, and returns the next
+	// challenge, or done once the proof is complete. It returns an error
+	// for messages that the protocol does not expect.
+	Next(m Message) (challenge Message, done bool, err error)
+	// Accepted returns true if the complete proof was accepted.
+	Accepted() bool
+}
+
+// Challenger derives the challenge for a prover's message. Verifiers of
+// live protocols sample challenges at random, and non-interactive proofs
+// hash the transcript instead.
+type Challenger func(m Message) (Message, error)
+
+// Conn carries the messages of a protocol run to the other party.
+type Conn interface {
+	Send(ctx context.Context, m Message) error
+	Receive(ctx context.Context) (Message, error)
+}
+
+var ErrUnexpectedMessage = errors.New("interactive: unexpected message")
+
+// Unexpected returns an error for a message that the protocol does not
+// expect in its current state.
+func Unexpected(m Message) error {
+	return fmt.Errorf("%w of type %T", ErrUnexpectedMessage, m)
+}
+
+type pipe struct {
+	in  <-chan Message
+	out chan<- Message
+}
+
+// Pipe returns both ends of an in-memory connection. Messages are passed
+// by reference and are not copied.
+func Pipe() (Conn, Conn) {
+	a := make(chan Message)
+	b := make(chan Message)
+	return &pipe{in: a, out: b}, &pipe{in: b, out: a}
+}
+
+func (p *pipe) Send(ctx context.Context, m Message) error {
+	select {
+	case p.out <- m:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *pipe) Receive(ctx context.Context) (Message, error) {
+	select {
+	case m := <-p.in:
+		return m, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Prove runs the prover over conn until the verifier's verdict, and returns
+// whether the proof was accepted.
+func Prove(ctx context.Context, p Prover, conn Conn) (bool, error) {
+	var challenge Message
+	for {
+		m, err := p.Next(challenge)
+		if err != nil {
+			return false, err
+		}
+		if err = conn.Send(ctx, m); err != nil {
+			return false, err
+		}
+		if challenge, err = conn.Receive(ctx); err != nil {
+			return false, err
+		}
+		if v, ok := challenge.(Verdict); ok {
+			return v.Accepted, nil
+		}
+		if p.Done() {
+			return false, Unexpected(challenge)
+		}
+	}
+}
+
+// Verify runs the verifier over conn until the proof is complete, sends the
+// verdict to the prover, and returns it. If the prover deviates from the
+// protocol, the proof is rejected with an error.
+func Verify(ctx context.Context, v Verifier, conn Conn) (bool, error) {
+	for {
+		m, err := conn.Receive(ctx)
+		if err != nil {
+			return false, err
+		}
+		challenge, done, err := v.Next(m)
+		if err != nil {
+			_ = conn.Send(ctx, Verdict{})
+			return false, err
+		}
+		if done {
+			return v.Accepted(), conn.Send(ctx, Verdict{Accepted: v.Accepted()})
+		}
+		if err = conn.Send(ctx, challenge); err != nil {
+			return false, err
+		}
+	}
+}
+
+// Run drives the prover with challenges derived by c, and returns the
+// transcript: the prover's messages, and the challenges between them.
+func Run(ctx context.Context, p Prover, c Challenger) ([]Message, []Message, error) {
+	var messages, challenges []Message
+	var challenge Message
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		m, err := p.Next(challenge)
+		if err != nil {
+			return nil, nil, err
+		}
+		messages = append(messages, m)
+		if p.Done() {
+			return messages, challenges, nil
+		}
+		if challenge, err = c(m); err != nil {
+			return nil, nil, err
+		}
+		challenges = append(challenges, challenge)
+	}
+}
+
+// Replay feeds the prover's messages of a transcript to the verifier, and
+// returns the challenges that it answered them with, and whether it accepted
+// the proof. Transcripts that end early or continue after the verifier is
+// done are rejected.
+func Replay(v Verifier, messages []Message) ([]Message, bool, error) {
+	var challenges []Message
+	for i, m := range messages {
+		challenge, done, err := v.Next(m)
+		if err != nil {
+			return nil, false, err
+		}
+		if done {
+			return challenges, i == len(messages)-1 && v.Accepted(), nil
+		}
+		challenges = append(challenges, challenge)
+	}
+	return challenges, false, nil
+}
@@ -0,0 +1,130 @@
+package interactive
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// counter is a toy protocol in which the prover counts up from zero, and
+// the verifier doubles every count as its challenge. The prover answers the
+// last challenge with a final count, which the verifier accepts if it is
+// the challenge plus one.
+type counter struct {
+	rounds int
+	sent   int
+	lie    bool
+}
+
+func (p *counter) Next(challenge Message) (Message, error) {
+	n := 0
+	if challenge != nil {
+		c, ok := challenge.(int)
+		if !ok {
+			return nil, Unexpected(challenge)
+		}
+		n = c + 1
+	}
+	p.sent++
+	if p.lie && p.Done() {
+		n++
+	}
+	return n, nil
+}
+
+func (p *counter) Done() bool {
+	return p.sent > p.rounds
+}
+
+type countVerifier struct {
+	rounds    int
+	received  int
+	challenge int
+	accepted  bool
+}
+
+func (v *countVerifier) Next(m Message) (Message, bool, error) {
+	n, ok := m.(int)
+	if !ok || v.received > v.rounds {
+		return nil, false, Unexpected(m)
+	}
+	v.received++
+	if v.received > v.rounds {
+		v.accepted = n == v.challenge+1
+		return nil, true, nil
+	}
+	v.challenge = 2 * n
+	return v.challenge, false, nil
+}
+
+func (v *countVerifier) Accepted() bool {
+	return v.accepted
+}
+
+func double(m Message) (Message, error) {
+	return 2 * m.(int), nil
+}
+
+func runPipe(t *testing.T, p Prover, v Verifier) (bool, bool, error) {
+	t.Helper()
+	a, b := Pipe()
+	type result struct {
+		accepted bool
+		err      error
+	}
+	ch := make(chan result)
+	go func() {
+		accepted, err := Prove(context.Background(), p, a)
+		ch <- result{accepted, err}
+	}()
+	verified, err := Verify(context.Background(), v, b)
+	r := <-ch
+	if r.err != nil {
+		t.Fatalf("prover failed: %v", r.err)
+	}
+	return r.accepted, verified, err
+}
+
+func TestPipe(t *testing.T) {
+	accepted, verified, err := runPipe(t, &counter{rounds: 3}, &countVerifier{rounds: 3})
+	if err != nil || !accepted || !verified {
+		t.Errorf("honest run was rejected: %t, %t, %v", accepted, verified, err)
+	}
+	accepted, verified, err = runPipe(t, &counter{rounds: 3, lie: true}, &countVerifier{rounds: 3})
+	if err != nil || accepted || verified {
+		t.Errorf("dishonest run was accepted: %t, %t, %v", accepted, verified, err)
+	}
+}
+
+func TestRunAndReplay(t *testing.T) {
+	messages, challenges, err := Run(context.Background(), &counter{rounds: 2}, double)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 || len(challenges) != 2 {
+		t.Fatalf("expected 3 messages and 2 challenges, got %v and %v", messages, challenges)
+	}
+
+	replayed, accepted, err := Replay(&countVerifier{rounds: 2}, messages)
+	if err != nil || !accepted {
+		t.Errorf("transcript was rejected: %v", err)
+	}
+	for i := range challenges {
+		if replayed[i] != challenges[i] {
+			t.Errorf("challenge %d was replayed as %v, expected %v", i, replayed[i], challenges[i])
+		}
+	}
+
+	if _, accepted, _ = Replay(&countVerifier{rounds: 2}, messages[:2]); accepted {
+		t.Error("truncated transcript was accepted")
+	}
+	if _, accepted, _ = Replay(&countVerifier{rounds: 1}, messages); accepted {
+		t.Error("transcript with trailing messages was accepted")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err = Run(ctx, &counter{rounds: 2}, double); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
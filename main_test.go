@@ -1,66 +1,148 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"github.com/takakv/msc-poc/group"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 )
 
-func generateAndMarshal(pp PublicParameters) {
-	vote := castVote(pp)
+func TestCLI(t *testing.T) {
+	dir := t.TempDir()
+	params := filepath.Join(dir, "params.json")
+	ballotFile := filepath.Join(dir, "ballot.json")
+	secrets := filepath.Join(dir, "secrets.json")
 
-	jsonData, _ := json.Marshal(vote)
-	fmt.Println(string(jsonData))
-
-	verify := verifyVote(vote, pp.RPParams)
-	if !verify {
-		fmt.Println("failed to verify generated data")
+	steps := [][]string{
+		{"-params", params, "setup", "-group", "ristretto255", "-election", "test"},
+		{"-params", params, "plan", "-groups", "P-256,P-384", "-range", "0,65535", "-challenge", "0"},
+		{"-params", params, "cast", "--choice", "150", "--voter", "alice", "--out", ballotFile, "--secrets", secrets},
+		{"-params", params, "verify", ballotFile},
+		{"-params", params, "bench", "--iters", "1"},
+		{"-params", params, "simulate", "-voters", "4", "-tampered", "0.5"},
+	}
+	var out bytes.Buffer
+	for _, args := range steps {
+		if err := run(args, &out); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	if !strings.Contains(out.String(), "Ballot is valid") {
+		t.Error("ballot was not reported as valid")
+	}
+	if !regexp.MustCompile(`Challenge length \(Bc\): +248 bits\n`).MatchString(out.String()) {
+		t.Error("parameters were not planned for P-384")
+	}
+	if !regexp.MustCompile(`Unexpected outcomes: +0\n`).MatchString(out.String()) {
+		t.Errorf("simulation had unexpected outcomes:\n%s", out.String())
 	}
-}
 
-func unmarshalAndVerify(b []byte, pp PublicParameters) error {
-	dataset, err := BallotDataUnmarshalJSON(b, pp)
+	info, err := os.Stat(secrets)
 	if err != nil {
-		return err
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		t.Error("secrets file is readable by others")
 	}
 
-	verify := verifyVote(dataset, pp.RPParams)
-	if !verify {
-		return errors.New("failed to verify data")
+	// A ballot does not verify under the parameters of another group.
+	other := filepath.Join(dir, "other.json")
+	if err = run([]string{"-params", other, "setup", "-group", "P-256"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if run([]string{"-params", other, "verify", ballotFile}, &out) == nil {
+		t.Error("ballot verified under other parameters")
 	}
 
-	return nil
+	for _, args := range [][]string{{}, {"unknown"}, {"verify"}, {"cast", "--choice", "first"}, {"cast", "--choice", "150"},
+		{"plan", "-range", "1"}, {"setup", "-challenge", "65536"}, {"bench", "-format", "xml"},
+		{"setup", "-mode", "other"}, {"bench", "-modes", "cross-group,other"}} {
+		if err = run(args, &out); !errors.Is(err, errUsage) {
+			t.Errorf("%v: expected a usage error, got %v", args, err)
+		}
+	}
 }
 
-func TestTestData(t *testing.T) {
-	p256data, err := os.ReadFile("./testdata/P256rp.json")
-	if err != nil {
-		t.Fatal(err)
+func TestSingleGroupCLI(t *testing.T) {
+	dir := t.TempDir()
+	params := filepath.Join(dir, "params.json")
+	ballotFile := filepath.Join(dir, "ballot.json")
+
+	steps := [][]string{
+		{"-params", params, "setup", "-group", "P-256", "-mode", "single-group"},
+		{"-params", params, "cast", "-choice", "150", "-voter", "alice", "-out", ballotFile,
+			"-secrets", filepath.Join(dir, "secrets.json")},
+		{"-params", params, "verify", ballotFile},
+		{"-params", params, "simulate", "-voters", "4", "-tampered", "0.5"},
+		{"sizes", "-groups", "P-256", "-modes", "single-group"},
+	}
+	var out bytes.Buffer
+	for _, args := range steps {
+		if err := run(args, &out); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	if !strings.Contains(out.String(), "Ballot is valid") {
+		t.Error("ballot was not reported as valid")
+	}
+	if !regexp.MustCompile(`Unexpected outcomes: +0\n`).MatchString(out.String()) {
+		t.Errorf("simulation had unexpected outcomes:\n%s", out.String())
 	}
 
-	p384data, err := os.ReadFile("./testdata/P384rp.json")
-	if err != nil {
+	// Both modes are benchmarked side by side.
+	out.Reset()
+	if err := run([]string{"bench", "-groups", "P-256", "-modes", "cross-group,single-group", "-iters", "1",
+		"-format", "json"}, &out); err != nil {
 		t.Fatal(err)
 	}
+	var reports []groupReport
+	if err := json.Unmarshal(out.Bytes(), &reports); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[0].Mode != "cross-group" || reports[1].Mode != "single-group" ||
+		reports[1].Sizes["total"].Binary >= reports[0].Sizes["total"].Binary {
+		t.Errorf("unexpected reports %+v", reports)
+	}
+}
 
-	P256Group := group.P256()
-	P384Group := group.P384()
-
-	groups := []group.Group{P256Group, P384Group}
-	data := [][]byte{p256data, p384data}
+func TestBenchReport(t *testing.T) {
+	params := filepath.Join(t.TempDir(), "params.json")
+	var out bytes.Buffer
+	if err := run([]string{"-params", params, "setup", "-group", "P-256"}, &out); err != nil {
+		t.Fatal(err)
+	}
 
-	for i, g := range groups {
-		pp, err := setup(g)
-		if err != nil {
-			t.Error(err)
+	out.Reset()
+	if err := run([]string{"-params", params, "bench", "-iters", "2", "-format", "json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var reports []groupReport
+	if err := json.Unmarshal(out.Bytes(), &reports); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Group != "P-256" || reports[0].Sizes["total"].Binary == 0 {
+		t.Fatalf("unexpected report %+v", reports)
+	}
+	for _, s := range reports[0].Operations {
+		if s.Iterations != 2 || s.Mean <= 0 || s.Median > s.P95 {
+			t.Errorf("unexpected statistics %+v", s)
 		}
+	}
 
-		err = unmarshalAndVerify(data[i], pp)
-		if err != nil {
-			t.Error(err)
-		}
+	out.Reset()
+	if err := run([]string{"-params", params, "bench", "-iters", "1", "-format", "csv"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(reports[0].Operations)+1 || rows[1][0] != "P-256" {
+		t.Errorf("unexpected CSV report %v", rows)
 	}
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/takakv/msc-poc/ballot"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// opStats summarises the runs of an operation.
+type opStats struct {
+	Name        string        `json:"operation"`
+	Iterations  int           `json:"iterations"`
+	Mean        time.Duration `json:"meanNs"`
+	Median      time.Duration `json:"medianNs"`
+	P95         time.Duration `json:"p95Ns"`
+	StdDev      time.Duration `json:"stdDevNs"`
+	AllocsPerOp uint64        `json:"allocsPerOp"`
+	BytesPerOp  uint64        `json:"bytesPerOp"`
+}
+
+// encodedSize holds the JSON and binary sizes of an encoded value.
+type encodedSize struct {
+	JSON   int `json:"json"`
+	Binary int `json:"binary"`
+}
+
+// groupReport holds the benchmark results for the parameters of one group.
+type groupReport struct {
+	Group      string                 `json:"group"`
+	Mode       ballot.Mode            `json:"mode"`
+	Sizes      map[string]encodedSize `json:"sizes"`
+	Operations []opStats              `json:"operations"`
+}
+
+// measure runs op iterCount times, after a warm-up run.
+func measure(op ballot.Operation, iterCount int) (opStats, error) {
+	if err := op.Run(); err != nil {
+		return opStats{}, fmt.Errorf("%s: %w", op.Name, err)
+	}
+
+	samples := make([]time.Duration, iterCount)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := range samples {
+		start := time.Now()
+		if err := op.Run(); err != nil {
+			return opStats{}, fmt.Errorf("%s: %w", op.Name, err)
+		}
+		samples[i] = time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+
+	s := opStats{
+		Name:        op.Name,
+		Iterations:  iterCount,
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / uint64(iterCount),
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / uint64(iterCount),
+	}
+
+	var total time.Duration
+	for _, d := range samples {
+		total += d
+	}
+	s.Mean = total / time.Duration(iterCount)
+
+	var variance float64
+	for _, d := range samples {
+		diff := float64(d - s.Mean)
+		variance += diff * diff
+	}
+	s.StdDev = time.Duration(math.Sqrt(variance / float64(iterCount)))
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	s.Median = samples[(iterCount-1)/2]
+	s.P95 = samples[int(math.Ceil(0.95*float64(iterCount)))-1]
+	return s, nil
+}
+
+// benchmarkReport measures each operation on the ballots of pp, and the
+// sizes of their encodings.
+func benchmarkReport(pp ballot.PublicParameters, iterCount int) (groupReport, error) {
+	vote, _, err := ballot.Cast(ballot.RandomChoice(pp), benchmarkVoter, pp)
+	if err != nil {
+		return groupReport{}, err
+	}
+	s, err := measureBallot(vote)
+	if err != nil {
+		return groupReport{}, err
+	}
+	r := groupReport{
+		Group: pp.ECGroupParams.I.Name(),
+		Mode:  pp.Mode,
+		Sizes: map[string]encodedSize{
+			"ciphertext": {s.Ballot[0], s.Ballot[1]},
+			"lbProof":    {s.BpLower[0], s.BpLower[1]},
+			"ubProof":    {s.BpUpper[0], s.BpUpper[1]},
+			"total":      {s.Total[0], s.Total[1]},
+		},
+	}
+	if vote.VoteProof != nil {
+		r.Sizes["voteProof"] = encodedSize{s.VoteProof[0], s.VoteProof[1]}
+	}
+
+	ops, err := ballot.Operations(pp)
+	if err != nil {
+		return groupReport{}, err
+	}
+	for _, op := range ops {
+		stats, err := measure(op, iterCount)
+		if err != nil {
+			return groupReport{}, err
+		}
+		r.Operations = append(r.Operations, stats)
+	}
+	return r, nil
+}
+
+func writeReportJSON(w io.Writer, reports []groupReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// writeReportCSV writes one row per group, mode and operation. Each row repeats
+// the total ballot sizes, so that the rows can be compared on their own.
+func writeReportCSV(w io.Writer, reports []groupReport) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"group", "mode", "operation", "iterations", "mean_ns", "median_ns", "p95_ns", "stddev_ns",
+		"allocs_per_op", "bytes_per_op", "ballot_json_bytes", "ballot_binary_bytes"})
+
+	for _, r := range reports {
+		total := r.Sizes["total"]
+		for _, s := range r.Operations {
+			_ = cw.Write([]string{
+				r.Group,
+				string(r.Mode),
+				s.Name,
+				strconv.Itoa(s.Iterations),
+				strconv.FormatInt(int64(s.Mean), 10),
+				strconv.FormatInt(int64(s.Median), 10),
+				strconv.FormatInt(int64(s.P95), 10),
+				strconv.FormatInt(int64(s.StdDev), 10),
+				strconv.FormatUint(s.AllocsPerOp, 10),
+				strconv.FormatUint(s.BytesPerOp, 10),
+				strconv.Itoa(total.JSON),
+				strconv.Itoa(total.Binary),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
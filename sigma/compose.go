@@ -0,0 +1,318 @@
+package sigma
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/big"
+)
+
+// and proves all of its protocols with a common challenge.
+type and struct {
+	ps []Protocol
+}
+
+// or proves one of its protocols, and simulates the others.
+type or struct {
+	ps []Protocol
+}
+
+// OrWitness is the witness of a disjunction: the index of a protocol
+// whose statement is true, and the witness for it.
+type OrWitness struct {
+	Branch  int
+	Witness Witness
+}
+
+type orState struct {
+	branch     int
+	challenges []*big.Int // Challenges of the simulated protocols.
+	responses  []Message  // Responses of the simulated protocols.
+	state      State      // State of the proven protocol.
+}
+
+// checkComposition checks that the protocols use challenges of the same
+// length, so that they can share or split them.
+func checkComposition(ps []Protocol) error {
+	if len(ps) == 0 {
+		return ErrInvalidRelation
+	}
+	for _, p := range ps {
+		if p == nil || p.ChallengeLen() != ps[0].ChallengeLen() {
+			return ErrInvalidRelation
+		}
+	}
+	return nil
+}
+
+// And returns the conjunction of the protocols, which proves all of their
+// statements. Its witness is a []Witness with a witness for every protocol.
+func And(ps ...Protocol) (Protocol, error) {
+	if err := checkComposition(ps); err != nil {
+		return nil, err
+	}
+	return &and{ps: ps}, nil
+}
+
+// Or returns the disjunction of the protocols, which proves that one of
+// their statements is true without revealing which. Its witness is an
+// OrWitness. The challenge is split as by Cramer, Damgård and
+// Schoenmakers: the challenges of the protocols sum to the common challenge
+// modulo 2^ChallengeLen(), and the prover simulates all protocols but the
+// one it has a witness for.
+func Or(ps ...Protocol) (Protocol, error) {
+	if err := checkComposition(ps); err != nil {
+		return nil, err
+	}
+	return &or{ps: ps}, nil
+}
+
+// shapes returns the shapes of one move of every protocol.
+func shapes(ps []Protocol, shape func(Protocol) Shape) []Shape {
+	s := make([]Shape, len(ps))
+	for i, p := range ps {
+		s[i] = shape(p)
+	}
+	return s
+}
+
+func commitmentShape(p Protocol) Shape { return p.CommitmentShape() }
+func responseShape(p Protocol) Shape   { return p.ResponseShape() }
+
+// concatShapes returns the shape of the concatenated moves of the protocols.
+func concatShapes(ps []Protocol, shape func(Protocol) Shape) Shape {
+	var s Shape
+	for _, p := range ps {
+		s = s.append(shape(p))
+	}
+	return s
+}
+
+func appendComposition(b []byte, kind byte, ps []Protocol) ([]byte, error) {
+	b = append(b, kind)
+	b = binary.AppendUvarint(b, uint64(len(ps)))
+	var err error
+	for _, p := range ps {
+		if b, err = p.AppendStatement(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (a *and) ChallengeLen() int {
+	return a.ps[0].ChallengeLen()
+}
+
+func (a *and) CommitmentShape() Shape {
+	return concatShapes(a.ps, commitmentShape)
+}
+
+func (a *and) ResponseShape() Shape {
+	return concatShapes(a.ps, responseShape)
+}
+
+func (a *and) AppendStatement(b []byte) ([]byte, error) {
+	return appendComposition(b, 'A', a.ps)
+}
+
+func (a *and) Commit(w Witness) (Message, State, error) {
+	ws, ok := w.([]Witness)
+	if !ok || len(ws) != len(a.ps) {
+		return Message{}, nil, ErrInvalidWitness
+	}
+	var commitment Message
+	states := make([]State, len(a.ps))
+	for i, p := range a.ps {
+		m, s, err := p.Commit(ws[i])
+		if err != nil {
+			return Message{}, nil, err
+		}
+		commitment = commitment.append(m)
+		states[i] = s
+	}
+	return commitment, states, nil
+}
+
+func (a *and) Respond(s State, c *big.Int) (Message, error) {
+	states, ok := s.([]State)
+	if !ok || len(states) != len(a.ps) {
+		return Message{}, ErrInvalidState
+	}
+	var response Message
+	for i, p := range a.ps {
+		m, err := p.Respond(states[i], c)
+		if err != nil {
+			return Message{}, err
+		}
+		response = response.append(m)
+	}
+	return response, nil
+}
+
+func (a *and) Verify(commitment Message, c *big.Int, response Message) bool {
+	if !commitment.Matches(a.CommitmentShape()) || !response.Matches(a.ResponseShape()) {
+		return false
+	}
+	commitments := split(commitment, shapes(a.ps, commitmentShape))
+	responses := split(response, shapes(a.ps, responseShape))
+	for i, p := range a.ps {
+		if !p.Verify(commitments[i], c, responses[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *and) Simulate(c *big.Int) (Message, Message, error) {
+	var commitment, response Message
+	for _, p := range a.ps {
+		cm, rm, err := p.Simulate(c)
+		if err != nil {
+			return Message{}, Message{}, err
+		}
+		commitment = commitment.append(cm)
+		response = response.append(rm)
+	}
+	return commitment, response, nil
+}
+
+func (o *or) ChallengeLen() int {
+	return o.ps[0].ChallengeLen()
+}
+
+func (o *or) CommitmentShape() Shape {
+	return concatShapes(o.ps, commitmentShape)
+}
+
+// ResponseShape returns the shape of the responses, which start with the
+// challenges of all but the last protocol, followed by their responses.
+// The challenge of the last protocol is implied by the others.
+func (o *or) ResponseShape() Shape {
+	var s Shape
+	for range o.ps[1:] {
+		s.Ints = append(s.Ints, o.ChallengeLen())
+	}
+	return s.append(concatShapes(o.ps, responseShape))
+}
+
+func (o *or) AppendStatement(b []byte) ([]byte, error) {
+	return appendComposition(b, 'O', o.ps)
+}
+
+// randomChallenge samples a challenge uniformly.
+func (o *or) randomChallenge() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(o.ChallengeLen())))
+}
+
+// remainingChallenge returns the challenge that completes the others to c.
+func (o *or) remainingChallenge(c *big.Int, others []*big.Int) *big.Int {
+	rest := new(big.Int).Set(c)
+	for _, ci := range others {
+		if ci != nil {
+			rest.Sub(rest, ci)
+		}
+	}
+	return rest.Mod(rest, new(big.Int).Lsh(big.NewInt(1), uint(o.ChallengeLen())))
+}
+
+// response assembles the response from the challenges and responses of
+// every protocol.
+func (o *or) response(challenges []*big.Int, responses []Message) Message {
+	response := Message{Ints: append([]*big.Int{}, challenges[:len(o.ps)-1]...)}
+	for _, m := range responses {
+		response = response.append(m)
+	}
+	return response
+}
+
+func (o *or) Commit(w Witness) (Message, State, error) {
+	ow, ok := w.(OrWitness)
+	if !ok || ow.Branch < 0 || ow.Branch >= len(o.ps) {
+		return Message{}, nil, ErrInvalidWitness
+	}
+
+	st := &orState{
+		branch:     ow.Branch,
+		challenges: make([]*big.Int, len(o.ps)),
+		responses:  make([]Message, len(o.ps)),
+	}
+	var commitment Message
+	for i, p := range o.ps {
+		var m Message
+		var err error
+		if i == ow.Branch {
+			m, st.state, err = p.Commit(ow.Witness)
+		} else if st.challenges[i], err = o.randomChallenge(); err == nil {
+			m, st.responses[i], err = p.Simulate(st.challenges[i])
+		}
+		if err != nil {
+			return Message{}, nil, err
+		}
+		commitment = commitment.append(m)
+	}
+	return commitment, st, nil
+}
+
+func (o *or) Respond(s State, c *big.Int) (Message, error) {
+	st, ok := s.(*orState)
+	if !ok || len(st.challenges) != len(o.ps) {
+		return Message{}, ErrInvalidState
+	}
+	if !checkChallenge(o, c) {
+		return Message{}, ErrInvalidChallenge
+	}
+
+	challenges := append([]*big.Int{}, st.challenges...)
+	responses := append([]Message{}, st.responses...)
+	challenges[st.branch] = o.remainingChallenge(c, st.challenges)
+	m, err := o.ps[st.branch].Respond(st.state, challenges[st.branch])
+	if err != nil {
+		return Message{}, err
+	}
+	responses[st.branch] = m
+	return o.response(challenges, responses), nil
+}
+
+func (o *or) Verify(commitment Message, c *big.Int, response Message) bool {
+	if !checkChallenge(o, c) || !commitment.Matches(o.CommitmentShape()) || !response.Matches(o.ResponseShape()) {
+		return false
+	}
+	n := len(o.ps) - 1
+	challenges := append(response.Ints[:n:n], o.remainingChallenge(c, response.Ints[:n]))
+	response.Ints = response.Ints[n:]
+
+	commitments := split(commitment, shapes(o.ps, commitmentShape))
+	responses := split(response, shapes(o.ps, responseShape))
+	for i, p := range o.ps {
+		if !p.Verify(commitments[i], challenges[i], responses[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *or) Simulate(c *big.Int) (Message, Message, error) {
+	if !checkChallenge(o, c) {
+		return Message{}, Message{}, ErrInvalidChallenge
+	}
+	challenges := make([]*big.Int, len(o.ps))
+	for i := range o.ps[1:] {
+		var err error
+		if challenges[i], err = o.randomChallenge(); err != nil {
+			return Message{}, Message{}, err
+		}
+	}
+	challenges[len(o.ps)-1] = o.remainingChallenge(c, challenges)
+
+	var commitment Message
+	responses := make([]Message, len(o.ps))
+	for i, p := range o.ps {
+		m, r, err := p.Simulate(challenges[i])
+		if err != nil {
+			return Message{}, Message{}, err
+		}
+		commitment = commitment.append(m)
+		responses[i] = r
+	}
+	return commitment, o.response(challenges, responses), nil
+}
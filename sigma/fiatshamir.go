@@ -0,0 +1,118 @@
+package sigma
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// DefaultMaxAttempts is the default bound on the attempts of Prove.
+const DefaultMaxAttempts = 64
+
+// Oracle derives the challenge of protocol p from its commitment.
+type Oracle func(p Protocol, commitment Message) (*big.Int, error)
+
+// Proof is a non-interactive proof: a transcript whose challenge is
+// derived from the commitment by an oracle.
+type Proof struct {
+	Commitment Message
+	Challenge  *big.Int
+	Response   Message
+}
+
+// Options controls the rejection sampling of Prove.
+type Options struct {
+	// MaxAttempts bounds the number of attempts. If it is not positive,
+	// DefaultMaxAttempts is used.
+	MaxAttempts int
+	// OnAbort, if set, is called after each aborted attempt with the
+	// number of attempts so far.
+	OnAbort func(attempt int)
+}
+
+// HashOracle returns an oracle that hashes the domain, the statement and
+// the commitment with SHA-256, and truncates the digest to the challenge
+// length. Challenges can be at most 256 bits long.
+func HashOracle(domain string) Oracle {
+	return func(p Protocol, commitment Message) (*big.Int, error) {
+		bits := p.ChallengeLen()
+		if bits > 8*sha256.Size {
+			return nil, errors.New("sigma: challenge is longer than the hash")
+		}
+		b, err := p.AppendStatement(appendString(nil, domain))
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range commitment.Elements {
+			if b, err = appendElement(b, x); err != nil {
+				return nil, err
+			}
+		}
+		for _, x := range commitment.Ints {
+			b = appendString(b, string(x.Bytes()))
+		}
+		for _, s := range commitment.Scalars {
+			b = appendString(b, string(s.BigInt().Bytes()))
+		}
+
+		digest := sha256.Sum256(b)
+		c := new(big.Int).SetBytes(digest[:(bits+7)/8])
+		return c.Rsh(c, uint(7-(bits+7)%8)), nil
+	}
+}
+
+// Prove computes a non-interactive proof with witness w. Attempts that
+// abort are retried until one succeeds, ctx is done, or opts.MaxAttempts
+// attempts have aborted. If opts is nil, the defaults are used.
+func Prove(ctx context.Context, p Protocol, w Witness, oracle Oracle, opts *Options) (Proof, error) {
+	maxAttempts := DefaultMaxAttempts
+	var onAbort func(int)
+	if opts != nil {
+		if opts.MaxAttempts > 0 {
+			maxAttempts = opts.MaxAttempts
+		}
+		onAbort = opts.OnAbort
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return Proof{}, err
+		}
+
+		commitment, state, err := p.Commit(w)
+		if err != nil {
+			return Proof{}, err
+		}
+		challenge, err := oracle(p, commitment)
+		if err != nil {
+			return Proof{}, err
+		}
+		response, err := p.Respond(state, challenge)
+		if errors.Is(err, ErrAbort) {
+			if onAbort != nil {
+				onAbort(attempt)
+			}
+			continue
+		}
+		if err != nil {
+			return Proof{}, err
+		}
+		return Proof{Commitment: commitment, Challenge: challenge, Response: response}, nil
+	}
+
+	return Proof{}, ErrTooManyAborts
+}
+
+// Verify returns true if the challenge is derived from the commitment and
+// the transcript is accepting.
+func (proof *Proof) Verify(p Protocol, oracle Oracle) bool {
+	if proof.Challenge == nil || !proof.Commitment.Matches(p.CommitmentShape()) {
+		return false
+	}
+	challenge, err := oracle(p, proof.Commitment)
+	if err != nil || challenge.Cmp(proof.Challenge) != 0 {
+		return false
+	}
+	return p.Verify(proof.Commitment, proof.Challenge, proof.Response)
+}
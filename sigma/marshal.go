@@ -0,0 +1,140 @@
+package sigma
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	. "github.com/takakv/msc-poc/util"
+)
+
+type messageJSON struct {
+	Elements []json.RawMessage `json:",omitempty"`
+	Ints     []*big.Int        `json:",omitempty"`
+	Scalars  []json.RawMessage `json:",omitempty"`
+}
+
+type proofJSON struct {
+	Commitment json.RawMessage
+	Challenge  *big.Int
+	Response   json.RawMessage
+}
+
+func intWidth(bits int) int {
+	return (bits + 7) / 8
+}
+
+func encodeMessage(e *Encoder, m Message, s Shape) {
+	for _, x := range m.Elements {
+		e.Element(x)
+	}
+	for i, x := range m.Ints {
+		e.Int(x, intWidth(s.Ints[i]))
+	}
+	for _, x := range m.Scalars {
+		e.Scalar(x)
+	}
+}
+
+func decodeMessage(d *Decoder, s Shape) Message {
+	var m Message
+	for _, g := range s.Elements {
+		m.Elements = append(m.Elements, d.Element(g))
+	}
+	for _, bits := range s.Ints {
+		m.Ints = append(m.Ints, d.Int(intWidth(bits)))
+	}
+	for _, g := range s.Scalars {
+		m.Scalars = append(m.Scalars, d.Scalar(g))
+	}
+	return m
+}
+
+// MarshalBinary encodes a proof for p in the binary wire format. The
+// shapes of p fix the layout, so only the entries of the moves are encoded.
+func MarshalBinary(p Protocol, proof Proof) ([]byte, error) {
+	cs, rs := p.CommitmentShape(), p.ResponseShape()
+	if !proof.Commitment.Matches(cs) || !proof.Response.Matches(rs) {
+		return nil, errors.New("sigma: proof does not match the protocol")
+	}
+	e := NewEncoder(WireSigmaTranscript)
+	encodeMessage(e, proof.Commitment, cs)
+	e.Int(proof.Challenge, intWidth(p.ChallengeLen()))
+	encodeMessage(e, proof.Response, rs)
+	return e.Bytes()
+}
+
+// UnmarshalBinary decodes a proof for p in the binary wire format.
+func UnmarshalBinary(p Protocol, b []byte) (Proof, error) {
+	d := NewDecoder(b, WireSigmaTranscript)
+	var proof Proof
+	proof.Commitment = decodeMessage(d, p.CommitmentShape())
+	proof.Challenge = d.Int(intWidth(p.ChallengeLen()))
+	rs := p.ResponseShape()
+	proof.Response = decodeMessage(d, rs)
+	if err := d.Finish(); err != nil {
+		return Proof{}, err
+	}
+	if !proof.Response.Matches(rs) {
+		return Proof{}, errors.New("integer exceeds its bound")
+	}
+	return proof, nil
+}
+
+func messageFromJSON(b []byte, s Shape) (Message, error) {
+	var j messageJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
+		return Message{}, err
+	}
+	if len(j.Elements) != len(s.Elements) {
+		return Message{}, WrapPath("Elements", fmt.Errorf("vector length is %d, wanted %d", len(j.Elements), len(s.Elements)))
+	}
+	if len(j.Ints) != len(s.Ints) {
+		return Message{}, WrapPath("Ints", fmt.Errorf("vector length is %d, wanted %d", len(j.Ints), len(s.Ints)))
+	}
+	if len(j.Scalars) != len(s.Scalars) {
+		return Message{}, WrapPath("Scalars", fmt.Errorf("vector length is %d, wanted %d", len(j.Scalars), len(s.Scalars)))
+	}
+
+	var d FieldDecoder
+	var m Message
+	for i, g := range s.Elements {
+		m.Elements = append(m.Elements, d.Element(fmt.Sprintf("Elements[%d]", i), j.Elements[i], g))
+	}
+	for i, bits := range s.Ints {
+		m.Ints = append(m.Ints, d.Uint(fmt.Sprintf("Ints[%d]", i), j.Ints[i], bits))
+	}
+	for i, g := range s.Scalars {
+		m.Scalars = append(m.Scalars, d.Scalar(fmt.Sprintf("Scalars[%d]", i), j.Scalars[i], g))
+	}
+	if err := d.Err(); err != nil {
+		return Message{}, err
+	}
+	return m, nil
+}
+
+// UnmarshalJSON recovers a proof for p from its JSON representation, as
+// produced by json.Marshal.
+func UnmarshalJSON(p Protocol, b []byte) (Proof, error) {
+	var j proofJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
+		return Proof{}, err
+	}
+
+	var proof Proof
+	var d FieldDecoder
+	d.Object("Commitment", func() (err error) {
+		proof.Commitment, err = messageFromJSON(j.Commitment, p.CommitmentShape())
+		return err
+	})
+	proof.Challenge = d.Uint("Challenge", j.Challenge, p.ChallengeLen())
+	d.Object("Response", func() (err error) {
+		proof.Response, err = messageFromJSON(j.Response, p.ResponseShape())
+		return err
+	})
+	if err := d.Err(); err != nil {
+		return Proof{}, err
+	}
+	return proof, nil
+}
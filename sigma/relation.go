@@ -0,0 +1,401 @@
+package sigma
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/takakv/msc-poc/group"
+	"math/big"
+)
+
+// DefaultChallengeLen is the challenge length of relations that do not
+// set one.
+const DefaultChallengeLen = 128
+
+// Var is a secret variable of a relation. A variable is either a scalar
+// of Group, or, if Bits is positive, a non-negative integer of at most
+// Bits bits. Integer variables can be shared by equations in different
+// groups, which proves that the same integer is used in all of them.
+type Var struct {
+	Group group.Group
+	Bits  int
+}
+
+// Term is a variable scaled by a base element. A nil base stands for the
+// generator of the group of the equation.
+type Term struct {
+	Var  int
+	Base group.Element
+}
+
+// Equation asserts that Image is the sum of its terms in Group, where the
+// group operation is written additively.
+type Equation struct {
+	Group group.Group
+	Image group.Element
+	Terms []Term
+}
+
+// Relation proves knowledge of variables that satisfy all its equations.
+// Its witness is a []*big.Int with the value of every variable. Provers
+// do not need the images of the equations, unless the oracle binds them.
+//
+// The responses of integer variables are computed over the integers by
+// rejection sampling: they are Slack bits longer than the variable and the
+// challenge, and each of them aborts the attempt with probability
+// 2^-Slack, whatever its value and the challenge.
+// They are reduced into the groups only to evaluate the equations.
+// NB! The prover is only known to use the same integer in all groups if
+// it is otherwise shown to be small, e.g. with range proofs.
+type Relation struct {
+	Vars      []Var
+	Equations []Equation
+	// ChallengeBits is the length of the challenges. If it is zero,
+	// DefaultChallengeLen is used.
+	ChallengeBits int
+	// Slack is the abort parameter of integer variables.
+	Slack int
+}
+
+type relationState struct {
+	x []*big.Int // Witness.
+	k []*big.Int // Nonces.
+}
+
+// ChallengeLen returns the length of the challenges in bits.
+func (r *Relation) ChallengeLen() int {
+	if r.ChallengeBits == 0 {
+		return DefaultChallengeLen
+	}
+	return r.ChallengeBits
+}
+
+// responseBits returns the bit-length bound of the response of an integer
+// variable.
+func (r *Relation) responseBits(v Var) int {
+	return v.Bits + r.ChallengeLen() + r.Slack
+}
+
+// validate checks that the relation is well-formed, so that the moves
+// cannot panic, and that challenges fit in the groups.
+func (r *Relation) validate() error {
+	bc := r.ChallengeLen()
+	if bc < 1 || len(r.Equations) == 0 {
+		return ErrInvalidRelation
+	}
+	for _, v := range r.Vars {
+		if v.Bits < 0 || (v.Bits == 0 && v.Group == nil) || (v.Bits > 0 && r.Slack < 1) {
+			return ErrInvalidRelation
+		}
+	}
+	for _, eq := range r.Equations {
+		if eq.Group == nil || len(eq.Terms) == 0 || bc >= eq.Group.N().BitLen() {
+			return ErrInvalidRelation
+		}
+		for _, t := range eq.Terms {
+			if t.Var < 0 || t.Var >= len(r.Vars) {
+				return ErrInvalidRelation
+			}
+			v := r.Vars[t.Var]
+			if v.Bits == 0 && v.Group.Name() != eq.Group.Name() {
+				return ErrInvalidRelation
+			}
+		}
+	}
+	return nil
+}
+
+// checkImages checks that the images of all equations are set.
+func (r *Relation) checkImages() error {
+	for _, eq := range r.Equations {
+		if eq.Image == nil {
+			return ErrInvalidRelation
+		}
+	}
+	return nil
+}
+
+// CommitmentShape returns the shape of the commitments, which have an
+// element for every equation.
+func (r *Relation) CommitmentShape() Shape {
+	var s Shape
+	for _, eq := range r.Equations {
+		s.Elements = append(s.Elements, eq.Group)
+	}
+	return s
+}
+
+// ResponseShape returns the shape of the responses, which have an integer
+// for every integer variable and a scalar for every other variable, in
+// the order of the variables.
+func (r *Relation) ResponseShape() Shape {
+	var s Shape
+	for _, v := range r.Vars {
+		if v.Bits > 0 {
+			s.Ints = append(s.Ints, r.responseBits(v))
+		} else {
+			s.Scalars = append(s.Scalars, v.Group)
+		}
+	}
+	return s
+}
+
+func appendElement(b []byte, x group.Element) ([]byte, error) {
+	if x == nil {
+		return binary.AppendUvarint(b, 0), nil
+	}
+	enc, err := x.MarshalBinaryCompress()
+	if err != nil {
+		return nil, err
+	}
+	b = binary.AppendUvarint(b, uint64(len(enc)))
+	return append(b, enc...), nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// AppendStatement appends the parameters, variables and equations of the
+// relation to b.
+func (r *Relation) AppendStatement(b []byte) ([]byte, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	if err := r.checkImages(); err != nil {
+		return nil, err
+	}
+	b = append(b, 'R')
+	b = binary.AppendUvarint(b, uint64(r.ChallengeLen()))
+	b = binary.AppendUvarint(b, uint64(r.Slack))
+	b = binary.AppendUvarint(b, uint64(len(r.Vars)))
+	for _, v := range r.Vars {
+		b = binary.AppendUvarint(b, uint64(v.Bits))
+		if v.Bits == 0 {
+			b = appendString(b, v.Group.Name())
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(r.Equations)))
+	var err error
+	for _, eq := range r.Equations {
+		b = appendString(b, eq.Group.Name())
+		if b, err = appendElement(b, eq.Image); err != nil {
+			return nil, err
+		}
+		b = binary.AppendUvarint(b, uint64(len(eq.Terms)))
+		for _, t := range eq.Terms {
+			b = binary.AppendUvarint(b, uint64(t.Var))
+			if b, err = appendElement(b, t.Base); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// evaluate returns the sum of the terms of every equation for the given
+// values of the variables, which are reduced into the groups.
+func (r *Relation) evaluate(values []*big.Int) []group.Element {
+	sums := make([]group.Element, len(r.Equations))
+	for i, eq := range r.Equations {
+		g := eq.Group
+		for _, t := range eq.Terms {
+			s := g.NewScalar().SetBigInt(values[t.Var])
+			var x group.Element
+			if t.Base == nil {
+				x = g.Element().BaseScale(s)
+			} else {
+				x = g.Element().Scale(t.Base, s)
+			}
+			if sums[i] == nil {
+				sums[i] = x
+			} else {
+				sums[i] = g.Element().Add(sums[i], x)
+			}
+		}
+	}
+	return sums
+}
+
+// Commit checks the witness and commits to random nonces.
+func (r *Relation) Commit(w Witness) (Message, State, error) {
+	if err := r.validate(); err != nil {
+		return Message{}, nil, err
+	}
+	x, ok := w.([]*big.Int)
+	if !ok || len(x) != len(r.Vars) {
+		return Message{}, nil, ErrInvalidWitness
+	}
+
+	k := make([]*big.Int, len(r.Vars))
+	for i, v := range r.Vars {
+		var bound *big.Int
+		if v.Bits > 0 {
+			if x[i] == nil || x[i].Sign() < 0 || x[i].BitLen() > v.Bits {
+				return Message{}, nil, ErrInvalidWitness
+			}
+			bound = new(big.Int).Lsh(big.NewInt(1), uint(r.responseBits(v)))
+		} else {
+			if x[i] == nil || x[i].Sign() < 0 || x[i].Cmp(v.Group.N()) >= 0 {
+				return Message{}, nil, ErrInvalidWitness
+			}
+			bound = v.Group.N()
+		}
+		var err error
+		if k[i], err = rand.Int(rand.Reader, bound); err != nil {
+			return Message{}, nil, err
+		}
+	}
+
+	return Message{Elements: r.evaluate(k)}, &relationState{x: x, k: k}, nil
+}
+
+// integerBounds returns the inclusive lower and exclusive upper bound of
+// the responses of an integer variable that do not leak it.
+func (r *Relation) integerBounds(v Var) (*big.Int, *big.Int) {
+	lower := new(big.Int).Lsh(big.NewInt(1), uint(v.Bits+r.ChallengeLen()))
+	upper := new(big.Int).Lsh(big.NewInt(1), uint(r.responseBits(v)))
+	return lower, upper
+}
+
+// Respond computes the responses k + cx. Scalar responses are reduced, and
+// integer responses abort if they fall outside of the safe range.
+func (r *Relation) Respond(s State, c *big.Int) (Message, error) {
+	st, ok := s.(*relationState)
+	if !ok || len(st.x) != len(r.Vars) {
+		return Message{}, ErrInvalidState
+	}
+	if !checkChallenge(r, c) {
+		return Message{}, ErrInvalidChallenge
+	}
+
+	var response Message
+	for i, v := range r.Vars {
+		z := new(big.Int).Add(st.k[i], new(big.Int).Mul(c, st.x[i]))
+		if v.Bits > 0 {
+			lower, upper := r.integerBounds(v)
+			if z.Cmp(lower) == -1 || z.Cmp(upper) != -1 {
+				return Message{}, ErrAbort
+			}
+			response.Ints = append(response.Ints, z)
+		} else {
+			response.Scalars = append(response.Scalars, v.Group.NewScalar().SetBigInt(z))
+		}
+	}
+	return response, nil
+}
+
+// responseValues returns the responses in the order of the variables.
+func (r *Relation) responseValues(response Message) []*big.Int {
+	values := make([]*big.Int, len(r.Vars))
+	ints, scalars := response.Ints, response.Scalars
+	for i, v := range r.Vars {
+		if v.Bits > 0 {
+			values[i], ints = ints[0], ints[1:]
+		} else {
+			values[i], scalars = scalars[0].BigInt(), scalars[1:]
+		}
+	}
+	return values
+}
+
+// Verify checks that the integer responses are in the safe range, and that
+// every equation holds for the responses: the sum of the terms equals
+// c·Image + K, where K is the commitment of the equation.
+func (r *Relation) Verify(commitment Message, c *big.Int, response Message) bool {
+	if r.validate() != nil || r.checkImages() != nil || !checkChallenge(r, c) ||
+		!commitment.Matches(r.CommitmentShape()) || !response.Matches(r.ResponseShape()) {
+		return false
+	}
+
+	values := r.responseValues(response)
+	for i, v := range r.Vars {
+		if v.Bits > 0 {
+			if lower, _ := r.integerBounds(v); values[i].Cmp(lower) == -1 {
+				return false
+			}
+		}
+	}
+
+	for i, left := range r.evaluate(values) {
+		eq := r.Equations[i]
+		right := eq.Group.Element().Scale(eq.Image, eq.Group.NewScalar().SetBigInt(c))
+		right = eq.Group.Element().Add(right, commitment.Elements[i])
+		if !left.IsEqual(right) {
+			return false
+		}
+	}
+	return true
+}
+
+// Simulate samples the responses uniformly from their accepted ranges,
+// and solves the equations for the commitment.
+func (r *Relation) Simulate(c *big.Int) (Message, Message, error) {
+	if err := r.validate(); err != nil {
+		return Message{}, Message{}, err
+	}
+	if err := r.checkImages(); err != nil {
+		return Message{}, Message{}, err
+	}
+	if !checkChallenge(r, c) {
+		return Message{}, Message{}, ErrInvalidChallenge
+	}
+
+	var response Message
+	for _, v := range r.Vars {
+		if v.Bits > 0 {
+			lower, upper := r.integerBounds(v)
+			z, err := rand.Int(rand.Reader, new(big.Int).Sub(upper, lower))
+			if err != nil {
+				return Message{}, Message{}, err
+			}
+			response.Ints = append(response.Ints, z.Add(z, lower))
+		} else {
+			response.Scalars = append(response.Scalars, v.Group.RandomScalar())
+		}
+	}
+
+	return r.solve(c, response), response, nil
+}
+
+// solve returns the commitment for which every equation holds with
+// challenge c and the response.
+func (r *Relation) solve(c *big.Int, response Message) Message {
+	var commitment Message
+	for i, left := range r.evaluate(r.responseValues(response)) {
+		eq := r.Equations[i]
+		right := eq.Group.Element().Scale(eq.Image, eq.Group.NewScalar().SetBigInt(c))
+		commitment.Elements = append(commitment.Elements, eq.Group.Element().Subtract(left, right))
+	}
+	return commitment
+}
+
+// Recompute returns the only commitment for which the transcript with
+// challenge c and the response is accepting, so that non-interactive
+// proofs can omit it: they are verified by deriving the challenge of the
+// recomputed commitment again. It fails if no commitment is accepted with
+// the response.
+func (r *Relation) Recompute(c *big.Int, response Message) (Message, error) {
+	if err := r.validate(); err != nil {
+		return Message{}, err
+	}
+	if err := r.checkImages(); err != nil {
+		return Message{}, err
+	}
+	if !checkChallenge(r, c) {
+		return Message{}, ErrInvalidChallenge
+	}
+	if !response.Matches(r.ResponseShape()) {
+		return Message{}, ErrInvalidResponse
+	}
+	ints := response.Ints
+	for _, v := range r.Vars {
+		if v.Bits > 0 {
+			if lower, _ := r.integerBounds(v); ints[0].Cmp(lower) == -1 {
+				return Message{}, ErrInvalidResponse
+			}
+			ints = ints[1:]
+		}
+	}
+	return r.solve(c, response), nil
+}
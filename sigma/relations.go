@@ -0,0 +1,46 @@
+package sigma
+
+import (
+	"github.com/takakv/msc-poc/group"
+)
+
+// DLog returns the relation of Schnorr's proof of knowledge of x such that
+// X = xB in g. A nil B stands for the generator of g.
+func DLog(g group.Group, B, X group.Element) *Relation {
+	return &Relation{
+		Vars:      []Var{{Group: g}},
+		Equations: []Equation{{Group: g, Image: X, Terms: []Term{{Var: 0, Base: B}}}},
+	}
+}
+
+// DLEQ returns the relation of the Chaum–Pedersen proof that X1 = xB1
+// and X2 = xB2 for the same x in g.
+func DLEQ(g group.Group, B1, X1, B2, X2 group.Element) *Relation {
+	return &Relation{
+		Vars: []Var{{Group: g}},
+		Equations: []Equation{
+			{Group: g, Image: X1, Terms: []Term{{Var: 0, Base: B1}}},
+			{Group: g, Image: X2, Terms: []Term{{Var: 0, Base: B2}}},
+		},
+	}
+}
+
+// Plaintext returns the relation that proves knowledge of the message m
+// and randomness r of the ElGamal ciphertext (U, V) = (rG, mG + rPK) in g.
+// Its witness is [m, r].
+func Plaintext(g group.Group, PK, U, V group.Element) *Relation {
+	return &Relation{
+		Vars: []Var{{Group: g}, {Group: g}},
+		Equations: []Equation{
+			{Group: g, Image: U, Terms: []Term{{Var: 1}}},
+			{Group: g, Image: V, Terms: []Term{{Var: 0}, {Var: 1, Base: PK}}},
+		},
+	}
+}
+
+// Decryption returns the relation that proves that M is the decryption of
+// the ElGamal ciphertext (U, V) under the secret key of PK: that PK = skG
+// and V - M = skU. Its witness is [sk].
+func Decryption(g group.Group, PK, U, V, M group.Element) *Relation {
+	return DLEQ(g, nil, PK, U, g.Element().Subtract(V, M))
+}
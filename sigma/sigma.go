@@ -0,0 +1,145 @@
+// Package sigma implements three-move public-coin proofs of knowledge of
+// preimages of linear relations over groups, and their composition.
+//
+// A Protocol is run as commit, challenge and respond. Relations are stated
+// declaratively as a Relation, and protocols are composed with And and Or.
+// Prove and Verify compile any protocol into a non-interactive proof with
+// the Fiat–Shamir transform, and every protocol can be simulated for a
+// given challenge, which shows that it is honest-verifier zero-knowledge.
+package sigma
+
+import (
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"math/big"
+)
+
+var (
+	// ErrAbort is returned by Respond if the attempt must be restarted
+	// with a new commitment, so that the response does not leak the
+	// witness.
+	ErrAbort            = errors.New("sigma: attempt aborted")
+	ErrTooManyAborts    = errors.New("sigma: too many aborted attempts")
+	ErrInvalidWitness   = errors.New("sigma: witness does not fit the relation")
+	ErrInvalidRelation  = errors.New("sigma: invalid relation")
+	ErrInvalidState     = errors.New("sigma: prover state does not belong to the protocol")
+	ErrInvalidChallenge = errors.New("sigma: challenge is out of range")
+	ErrInvalidResponse  = errors.New("sigma: response is out of range")
+)
+
+// Witness is the secret input of a prover. Its type depends on the
+// protocol: see Relation, And and Or.
+type Witness any
+
+// State is the secret state that a prover keeps between its moves.
+type State any
+
+// Message is a move of a protocol. The number and order of its entries
+// are fixed by the Shape of the move.
+type Message struct {
+	Elements []group.Element `json:",omitempty"`
+	Ints     []*big.Int      `json:",omitempty"` // Non-negative integers.
+	Scalars  []group.Scalar  `json:",omitempty"`
+}
+
+// Shape describes the messages of a move: the group of each element, the
+// bit-length bound of each integer, and the group of each scalar.
+type Shape struct {
+	Elements []group.Group
+	Ints     []int
+	Scalars  []group.Group
+}
+
+// Protocol is a sigma protocol for a fixed statement.
+type Protocol interface {
+	// ChallengeLen returns the length of the challenges in bits.
+	// Challenges are integers in [0, 2^ChallengeLen()).
+	ChallengeLen() int
+	// CommitmentShape and ResponseShape describe the prover's moves.
+	CommitmentShape() Shape
+	ResponseShape() Shape
+	// AppendStatement appends an unambiguous encoding of the statement
+	// to b, so that challenges can be bound to it.
+	AppendStatement(b []byte) ([]byte, error)
+
+	// Commit starts a proof with witness w, and returns the commitment
+	// and the state to respond with.
+	Commit(w Witness) (Message, State, error)
+	// Respond answers challenge c. It returns ErrAbort if the response
+	// would leak the witness, in which case the proof must be restarted.
+	Respond(s State, c *big.Int) (Message, error)
+	// Verify returns true if the transcript is accepting.
+	Verify(commitment Message, c *big.Int, response Message) bool
+	// Simulate returns an accepting transcript for challenge c without
+	// the witness. Its distribution is that of honest transcripts.
+	Simulate(c *big.Int) (Message, Message, error)
+}
+
+// append returns the concatenation of the messages.
+func (m Message) append(o Message) Message {
+	return Message{
+		Elements: append(append([]group.Element{}, m.Elements...), o.Elements...),
+		Ints:     append(append([]*big.Int{}, m.Ints...), o.Ints...),
+		Scalars:  append(append([]group.Scalar{}, m.Scalars...), o.Scalars...),
+	}
+}
+
+// append returns the shape of the concatenation of messages.
+func (s Shape) append(o Shape) Shape {
+	return Shape{
+		Elements: append(append([]group.Group{}, s.Elements...), o.Elements...),
+		Ints:     append(append([]int{}, s.Ints...), o.Ints...),
+		Scalars:  append(append([]group.Group{}, s.Scalars...), o.Scalars...),
+	}
+}
+
+// Matches returns true if m has the entries that s describes, and its
+// integers are within their bounds, so that m can be processed safely.
+func (m Message) Matches(s Shape) bool {
+	if len(m.Elements) != len(s.Elements) || len(m.Ints) != len(s.Ints) || len(m.Scalars) != len(s.Scalars) {
+		return false
+	}
+	for _, x := range m.Elements {
+		if x == nil {
+			return false
+		}
+	}
+	for i, x := range m.Ints {
+		if x == nil || x.Sign() < 0 || x.BitLen() > s.Ints[i] {
+			return false
+		}
+	}
+	for i, x := range m.Scalars {
+		if x == nil || !checkScalar(x, s.Scalars[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// split splits a message that matches the concatenation of shapes into
+// messages of each shape.
+func split(m Message, shapes []Shape) []Message {
+	parts := make([]Message, len(shapes))
+	for i, s := range shapes {
+		parts[i] = Message{
+			Elements: m.Elements[:len(s.Elements)],
+			Ints:     m.Ints[:len(s.Ints)],
+			Scalars:  m.Scalars[:len(s.Scalars)],
+		}
+		m.Elements = m.Elements[len(s.Elements):]
+		m.Ints = m.Ints[len(s.Ints):]
+		m.Scalars = m.Scalars[len(s.Scalars):]
+	}
+	return parts
+}
+
+// checkScalar returns true if s is a scalar of g.
+func checkScalar(s group.Scalar, g group.Group) bool {
+	return s.BigInt().Cmp(g.N()) < 0
+}
+
+// checkChallenge returns true if c is a challenge of p.
+func checkChallenge(p Protocol, c *big.Int) bool {
+	return c != nil && c.Sign() >= 0 && c.BitLen() <= p.ChallengeLen()
+}
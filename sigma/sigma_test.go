@@ -0,0 +1,301 @@
+package sigma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+var oracle = HashOracle("sigma test")
+
+// elGamal encrypts m under pk, and returns the ciphertext and randomness.
+func elGamal(g group.Group, pk group.Element, m group.Scalar) (group.Element, group.Element, group.Scalar) {
+	r := g.RandomScalar()
+	U := g.Element().BaseScale(r)
+	V := g.Element().Add(g.Element().BaseScale(m), g.Element().Scale(pk, r))
+	return U, V, r
+}
+
+func prove(t *testing.T, p Protocol, w Witness) Proof {
+	t.Helper()
+	proof, err := Prove(context.Background(), p, w, oracle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func TestRelations(t *testing.T) {
+	for _, g := range []group.Group{group.P256(), group.Ristretto255(), group.ModPGroup3072q256()} {
+		sk := g.RandomScalar()
+		pk := g.Element().BaseScale(sk)
+		m := g.RandomScalar()
+		U, V, r := elGamal(g, pk, m)
+		M := g.Element().BaseScale(m)
+
+		relations := []struct {
+			name     string
+			relation *Relation
+			witness  []*big.Int
+		}{
+			{"dlog", DLog(g, nil, pk), []*big.Int{sk.BigInt()}},
+			{"plaintext", Plaintext(g, pk, U, V), []*big.Int{m.BigInt(), r.BigInt()}},
+			{"decryption", Decryption(g, pk, U, V, M), []*big.Int{sk.BigInt()}},
+		}
+		for _, rel := range relations {
+			proof := prove(t, rel.relation, rel.witness)
+			if !proof.Verify(rel.relation, oracle) {
+				t.Errorf("%s/%s: valid proof did not verify", g.Name(), rel.name)
+			}
+			if proof.Verify(rel.relation, HashOracle("other domain")) {
+				t.Errorf("%s/%s: proof verified in another domain", g.Name(), rel.name)
+			}
+		}
+
+		// Proofs are bound to the statement.
+		other := Decryption(g, pk, U, V, g.Element().Add(M, g.Generator()))
+		proof := prove(t, other, []*big.Int{sk.BigInt()})
+		if proof.Verify(other, oracle) {
+			t.Errorf("%s: wrong decryption was proven", g.Name())
+		}
+		proof = prove(t, relations[2].relation, []*big.Int{sk.BigInt()})
+		if proof.Verify(other, oracle) {
+			t.Errorf("%s: proof verified for another statement", g.Name())
+		}
+	}
+}
+
+// crossGroupRelation commits to x in two groups, and returns the relation
+// with its witness.
+func crossGroupRelation(x *big.Int, slack int) (*Relation, []*big.Int) {
+	g1, g2 := group.P256(), group.SecP256k1()
+	h1, h2 := g1.Random(), g2.Random()
+	r1, r2 := g1.RandomScalar(), g2.RandomScalar()
+	commit := func(g group.Group, h group.Element, r group.Scalar) group.Element {
+		return g.Element().Add(g.Element().BaseScale(g.NewScalar().SetBigInt(x)), g.Element().Scale(h, r))
+	}
+	rel := &Relation{
+		Vars: []Var{{Bits: 32}, {Group: g1}, {Group: g2}},
+		Equations: []Equation{
+			{Group: g1, Image: commit(g1, h1, r1), Terms: []Term{{Var: 0}, {Var: 1, Base: h1}}},
+			{Group: g2, Image: commit(g2, h2, r2), Terms: []Term{{Var: 0}, {Var: 2, Base: h2}}},
+		},
+		Slack: slack,
+	}
+	return rel, []*big.Int{x, r1.BigInt(), r2.BigInt()}
+}
+
+func TestIntegerVariables(t *testing.T) {
+	rel, w := crossGroupRelation(big.NewInt(0xcafe), 2)
+
+	aborts := 0
+	opts := &Options{MaxAttempts: 1000, OnAbort: func(int) { aborts++ }}
+	var proof Proof
+	var err error
+	// Each attempt aborts with probability 1/4, so some of 64 proofs abort
+	// unless aborting is broken.
+	for i := 0; i < 64 && aborts == 0; i++ {
+		if proof, err = Prove(context.Background(), rel, w, oracle, opts); err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(rel, oracle) {
+			t.Fatal("valid proof did not verify")
+		}
+	}
+	if aborts == 0 {
+		t.Error("no attempt aborted with a slack of 2 bits")
+	}
+
+	// The integer must be the same in both groups.
+	other, _ := crossGroupRelation(big.NewInt(0xcaff), 2)
+	rel.Equations[1] = other.Equations[1]
+	if proof, _ = Prove(context.Background(), rel, w, oracle, opts); proof.Verify(rel, oracle) {
+		t.Error("proof verified for different integers")
+	}
+
+	w[0] = new(big.Int).Lsh(big.NewInt(1), 32)
+	if _, err = Prove(context.Background(), rel, w, oracle, nil); !errors.Is(err, ErrInvalidWitness) {
+		t.Errorf("expected %v for a long integer, got %v", ErrInvalidWitness, err)
+	}
+}
+
+func TestComposition(t *testing.T) {
+	g := group.Ristretto255()
+	x1, x2 := g.RandomScalar(), g.RandomScalar()
+	p1 := DLog(g, nil, g.Element().BaseScale(x1))
+	p2 := DLog(g, nil, g.Element().BaseScale(x2))
+	p3 := DLog(g, nil, g.Random())
+
+	conj, err := And(p1, p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof := prove(t, conj, []Witness{[]*big.Int{x1.BigInt()}, []*big.Int{x2.BigInt()}})
+	if !proof.Verify(conj, oracle) {
+		t.Error("valid conjunction did not verify")
+	}
+	conj13, _ := And(p1, p3)
+	proof = prove(t, conj13, []Witness{[]*big.Int{x1.BigInt()}, []*big.Int{x2.BigInt()}})
+	if proof.Verify(conj13, oracle) {
+		t.Error("conjunction with a false statement verified")
+	}
+
+	disj, err := Or(p3, p1, p3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof = prove(t, disj, OrWitness{Branch: 1, Witness: []*big.Int{x1.BigInt()}})
+	if !proof.Verify(disj, oracle) {
+		t.Error("valid disjunction did not verify")
+	}
+	proof = prove(t, disj, OrWitness{Branch: 0, Witness: []*big.Int{x1.BigInt()}})
+	if proof.Verify(disj, oracle) {
+		t.Error("disjunction without a true statement verified")
+	}
+
+	// Compositions nest, and may mix integer variables in.
+	rel, w := crossGroupRelation(big.NewInt(7), 32)
+	rel.ChallengeBits = DefaultChallengeLen
+	nested, err := Or(conj, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof = prove(t, nested, OrWitness{Branch: 1, Witness: w})
+	if !proof.Verify(nested, oracle) {
+		t.Error("valid nested disjunction did not verify")
+	}
+
+	if _, err = And(); !errors.Is(err, ErrInvalidRelation) {
+		t.Errorf("expected %v for an empty conjunction, got %v", ErrInvalidRelation, err)
+	}
+	short := DLog(g, nil, g.Random())
+	short.ChallengeBits = 64
+	if _, err = Or(p1, short); !errors.Is(err, ErrInvalidRelation) {
+		t.Errorf("expected %v for mismatched challenges, got %v", ErrInvalidRelation, err)
+	}
+	if _, _, err = disj.Commit(OrWitness{Branch: 3}); !errors.Is(err, ErrInvalidWitness) {
+		t.Errorf("expected %v for a missing branch, got %v", ErrInvalidWitness, err)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	g := group.P256()
+	p1 := DLog(g, nil, g.Random())
+	p2 := Plaintext(g, g.Random(), g.Random(), g.Random())
+	rel, _ := crossGroupRelation(big.NewInt(3), 8)
+	rel.ChallengeBits = DefaultChallengeLen
+	conj, _ := And(p1, p2, rel)
+	disj, _ := Or(p1, conj)
+
+	// The statements are false, but simulated transcripts are accepting.
+	for _, p := range []Protocol{p1, p2, rel, conj, disj} {
+		c := big.NewInt(12345)
+		commitment, response, err := p.Simulate(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(commitment, c, response) {
+			t.Errorf("%T: simulated transcript did not verify", p)
+		}
+		if p.Verify(commitment, big.NewInt(12346), response) {
+			t.Errorf("%T: simulated transcript verified for another challenge", p)
+		}
+	}
+
+	if _, _, err := p1.Simulate(new(big.Int).Lsh(big.NewInt(1), DefaultChallengeLen)); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("expected %v, got %v", ErrInvalidChallenge, err)
+	}
+}
+
+func TestRecompute(t *testing.T) {
+	rel, w := crossGroupRelation(big.NewInt(0xcafe), 8)
+	proof := prove(t, rel, w)
+	commitment, err := rel.Recompute(proof.Challenge, proof.Response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, x := range commitment.Elements {
+		if !x.IsEqual(proof.Commitment.Elements[i]) {
+			t.Errorf("commitment %d was not recomputed", i)
+		}
+	}
+
+	// Another challenge gives another commitment, which does not hash to it.
+	c := new(big.Int).Add(proof.Challenge, big.NewInt(1))
+	if commitment, err = rel.Recompute(c, proof.Response); err != nil {
+		t.Fatal(err)
+	}
+	if derived, _ := oracle(rel, commitment); derived.Cmp(c) == 0 {
+		t.Error("recomputed commitment hashed to the challenge")
+	}
+
+	response := proof.Response
+	response.Ints = []*big.Int{big.NewInt(1)}
+	if _, err = rel.Recompute(proof.Challenge, response); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("expected %v for a small integer, got %v", ErrInvalidResponse, err)
+	}
+	response.Ints = nil
+	if _, err = rel.Recompute(proof.Challenge, response); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("expected %v for a missing integer, got %v", ErrInvalidResponse, err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	g := group.P384()
+	x := g.RandomScalar()
+	rel, w := crossGroupRelation(big.NewInt(1000), 16)
+	rel.ChallengeBits = DefaultChallengeLen
+	disj, _ := Or(DLog(g, nil, g.Element().BaseScale(x)), rel)
+	proof := prove(t, disj, OrWitness{Branch: 1, Witness: w})
+
+	b, err := MarshalBinary(disj, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalBinary(disj, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Verify(disj, oracle) {
+		t.Error("decoded binary proof did not verify")
+	}
+	if reencoded, _ := MarshalBinary(disj, decoded); string(reencoded) != string(b) {
+		t.Error("binary encoding is not canonical")
+	}
+	if _, err = UnmarshalBinary(disj, b[:len(b)-1]); err == nil {
+		t.Error("truncated proof was accepted")
+	}
+	if _, err = UnmarshalBinary(disj, append(b, 0)); err == nil {
+		t.Error("trailing data was accepted")
+	}
+	if _, err = UnmarshalBinary(rel, b); err == nil {
+		t.Error("proof for another protocol was accepted")
+	}
+
+	j, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = UnmarshalJSON(disj, j); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Verify(disj, oracle) {
+		t.Error("decoded JSON proof did not verify")
+	}
+
+	var tampered struct {
+		Commitment json.RawMessage
+		Challenge  json.Number
+		Response   map[string][]json.RawMessage
+	}
+	_ = json.Unmarshal(j, &tampered)
+	tampered.Response["Ints"] = tampered.Response["Ints"][1:]
+	j, _ = json.Marshal(tampered)
+	if _, err = UnmarshalJSON(disj, j); err == nil || !strings.HasPrefix(err.Error(), "Response.Ints") {
+		t.Errorf("expected an error for Response.Ints, got %v", err)
+	}
+}
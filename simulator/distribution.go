@@ -0,0 +1,47 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+)
+
+// Distribution draws candidate numbers in [lo, hi].
+type Distribution func(r *rand.Rand, lo, hi *big.Int) *big.Int
+
+// Uniform draws every candidate with the same probability.
+func Uniform(r *rand.Rand, lo, hi *big.Int) *big.Int {
+	span := new(big.Int).Sub(hi, lo)
+	choice := new(big.Int).Rand(r, span.Add(span, big.NewInt(1)))
+	return choice.Add(choice, lo)
+}
+
+// Zipf returns a distribution in which the k-th candidate is drawn with
+// probability proportional to 1/k^s, as is typical of elections where a
+// few candidates receive most votes. The exponent s must be greater than 1.
+// Only the first 2^64 candidates of wider ranges are drawn.
+func Zipf(s float64) Distribution {
+	return func(r *rand.Rand, lo, hi *big.Int) *big.Int {
+		span := new(big.Int).Sub(hi, lo)
+		imax := uint64(math.MaxUint64)
+		if span.IsUint64() {
+			imax = span.Uint64()
+		}
+		k := new(big.Int).SetUint64(rand.NewZipf(r, s, 1, imax).Uint64())
+		return k.Add(k, lo)
+	}
+}
+
+// LookupDistribution returns the distribution with the given name,
+// "uniform" or "zipf".
+func LookupDistribution(name string) (Distribution, error) {
+	switch name {
+	case "uniform":
+		return Uniform, nil
+	case "zipf":
+		return Zipf(1.1), nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", name)
+	}
+}
@@ -0,0 +1,370 @@
+// Package simulator load-tests a ballot collector with concurrent virtual
+// voters.
+package simulator
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/takakv/msc-poc/ballot"
+	"io"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Kind is the kind of a submitted ballot.
+type Kind string
+
+const (
+	// KindValid ballots are correctly formed.
+	KindValid Kind = "valid"
+	// KindMalformed ballots cannot be decoded.
+	KindMalformed Kind = "malformed"
+	// KindTampered ballots are well-formed, but their proofs do not verify.
+	KindTampered Kind = "tampered"
+)
+
+// Config describes a simulated election.
+type Config struct {
+	// Voters is the number of virtual voters.
+	Voters int
+	// Concurrency is the number of ballots submitted at the same time.
+	// If it is not positive, GOMAXPROCS is used.
+	Concurrency int
+	// Distribution draws the voters' choices. If nil, Uniform is used.
+	Distribution Distribution
+	// RevoteRatio is the fraction of voters who vote a second time.
+	RevoteRatio float64
+	// MalformedRate is the fraction of ballots that are malformed.
+	MalformedRate float64
+	// TamperedRate is the fraction of ballots that are tampered with.
+	TamperedRate float64
+	// Seed seeds the random choices of the voters.
+	Seed int64
+	// Tokens maps the voter IDs to the bearer tokens with which their
+	// ballots are submitted. Voters without a token submit without one.
+	Tokens map[string]string
+}
+
+func (c Config) validate() error {
+	if c.Voters <= 0 {
+		return errors.New("number of voters must be positive")
+	}
+	for _, rate := range []float64{c.RevoteRatio, c.MalformedRate, c.TamperedRate} {
+		if rate < 0 || rate > 1 {
+			return errors.New("rates must be in [0, 1]")
+		}
+	}
+	if c.MalformedRate+c.TamperedRate > 1 {
+		return errors.New("malformed and tampered rates exceed 1")
+	}
+	return nil
+}
+
+// submission is a prepared ballot.
+type submission struct {
+	voter  string
+	kind   Kind
+	revote bool
+	body   []byte
+}
+
+// Report summarises a simulation.
+type Report struct {
+	// Voters is the number of virtual voters.
+	Voters int
+	// Submissions is the number of submitted ballots, including revotes.
+	Submissions int
+	// Revotes is the number of second ballots.
+	Revotes int
+	// Sent counts the submitted ballots of each kind.
+	Sent map[Kind]int
+	// Accepted is the number of ballots that the collector found valid.
+	Accepted int
+	// Rejections counts the rejected ballots by error code. Ballots that
+	// are found invalid after they were queued count as invalid_ballot.
+	Rejections map[string]int
+	// Unexpected is the number of valid ballots that were rejected, and of
+	// malformed or tampered ballots that were accepted. Rejections because
+	// the collector is overloaded are not unexpected.
+	Unexpected int
+	// Failures is the number of submissions that did not get a response,
+	// or whose status could not be queried.
+	Failures int
+	// CastTime is the time it took to prepare the ballots.
+	CastTime time.Duration
+	// Elapsed is the time it took to submit the ballots and to receive
+	// their verification results.
+	Elapsed time.Duration
+	// Latencies holds the sorted times from the submission of each ballot
+	// until its verification result.
+	Latencies []time.Duration
+}
+
+// Throughput returns the number of submissions per second.
+func (r Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Submissions) / r.Elapsed.Seconds()
+}
+
+// Percentile returns the p-th percentile of the response times, for p
+// in [0, 100].
+func (r Report) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(r.Latencies)-1))
+	return r.Latencies[i]
+}
+
+// Write writes a human-readable summary of the report to w.
+func (r Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Voters:\t%d\n", r.Voters)
+	fmt.Fprintf(tw, "Ballots:\t%d (%d revotes)\n", r.Submissions, r.Revotes)
+	for _, kind := range []Kind{KindValid, KindMalformed, KindTampered} {
+		fmt.Fprintf(tw, "  %s:\t%d\n", kind, r.Sent[kind])
+	}
+	fmt.Fprintf(tw, "Cast time:\t%v\n", r.CastTime)
+	fmt.Fprintf(tw, "Submit and verify time:\t%v\n", r.Elapsed)
+	fmt.Fprintf(tw, "Throughput:\t%.1f ballots/s\n", r.Throughput())
+	fmt.Fprintf(tw, "Latency p50:\t%v\n", r.Percentile(50))
+	fmt.Fprintf(tw, "Latency p90:\t%v\n", r.Percentile(90))
+	fmt.Fprintf(tw, "Latency p99:\t%v\n", r.Percentile(99))
+	fmt.Fprintf(tw, "Latency max:\t%v\n", r.Percentile(100))
+	fmt.Fprintf(tw, "Accepted:\t%d\n", r.Accepted)
+
+	codes := make([]string, 0, len(r.Rejections))
+	for code := range r.Rejections {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(tw, "Rejected (%s):\t%d\n", code, r.Rejections[code])
+	}
+	fmt.Fprintf(tw, "Unexpected outcomes:\t%d\n", r.Unexpected)
+	fmt.Fprintf(tw, "Failed submissions:\t%d\n", r.Failures)
+
+	return tw.Flush()
+}
+
+// Run prepares the ballots of the simulated voters, submits them
+// concurrently with s, and reports the outcome. Ballots that the collector
+// queues for verification are polled until they are verified.
+func Run(ctx context.Context, cfg Config, pp ballot.PublicParameters, s Submitter) (Report, error) {
+	if err := cfg.validate(); err != nil {
+		return Report{}, err
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = runtime.GOMAXPROCS(0)
+	}
+	if cfg.Distribution == nil {
+		cfg.Distribution = Uniform
+	}
+
+	report := Report{
+		Voters:     cfg.Voters,
+		Sent:       make(map[Kind]int),
+		Rejections: make(map[string]int),
+	}
+
+	start := time.Now()
+	subs, err := prepare(ctx, cfg, pp)
+	if err != nil {
+		return Report{}, err
+	}
+	report.CastTime = time.Since(start)
+
+	type result struct {
+		sub     submission
+		resp    Response
+		err     error
+		latency time.Duration
+	}
+	results := make([]result, len(subs))
+
+	start = time.Now()
+	parallel(cfg.Concurrency, len(subs), func(i int) {
+		t := time.Now()
+		resp, err := s.Submit(ctx, cfg.Tokens[subs[i].voter], subs[i].body)
+		if err == nil && resp.Pending() {
+			resp, err = await(ctx, s, resp.ID)
+		}
+		results[i] = result{subs[i], resp, err, time.Since(t)}
+	})
+	report.Elapsed = time.Since(start)
+
+	for _, res := range results {
+		report.Submissions++
+		report.Sent[res.sub.kind]++
+		if res.sub.revote {
+			report.Revotes++
+		}
+		if res.err != nil {
+			report.Failures++
+			continue
+		}
+		report.Latencies = append(report.Latencies, res.latency)
+
+		if res.resp.Accepted() {
+			report.Accepted++
+		} else {
+			report.Rejections[res.resp.Code]++
+		}
+		switch {
+		case res.resp.Status == http.StatusServiceUnavailable:
+			// Back-pressure is expected under load.
+		case res.sub.kind == KindValid && !res.resp.Accepted(),
+			res.sub.kind != KindValid && res.resp.Accepted():
+			report.Unexpected++
+		}
+	}
+	sort.Slice(report.Latencies, func(i, j int) bool { return report.Latencies[i] < report.Latencies[j] })
+
+	return report, ctx.Err()
+}
+
+// await polls the status of the pending ballot id until it is verified.
+func await(ctx context.Context, s Submitter, id string) (Response, error) {
+	delay := time.Millisecond
+	for {
+		resp, err := s.Poll(ctx, id)
+		if err != nil {
+			return Response{}, err
+		}
+		if resp.Status != http.StatusOK {
+			return Response{}, fmt.Errorf("status of ballot %s: HTTP %d %s", id, resp.Status, resp.Code)
+		}
+		if !resp.Pending() {
+			return resp, nil
+		}
+		select {
+		case <-ctx.Done():
+			return Response{}, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, 100*time.Millisecond)
+	}
+}
+
+// NewTokens returns a voter list with random bearer tokens for the given
+// number of simulated voters, for Config.Tokens and collector.BearerTokens.
+func NewTokens(voters int) (map[string]string, error) {
+	tokens := make(map[string]string, voters)
+	for i := 0; i < voters; i++ {
+		b := make([]byte, 16)
+		if _, err := crand.Read(b); err != nil {
+			return nil, err
+		}
+		tokens[voterID(i)] = hex.EncodeToString(b)
+	}
+	return tokens, nil
+}
+
+// voterID returns the ID of the i-th simulated voter.
+func voterID(i int) string {
+	return fmt.Sprintf("voter-%d", i)
+}
+
+// prepare casts the ballots of all voters, including their revotes.
+func prepare(ctx context.Context, cfg Config, pp ballot.PublicParameters) ([]submission, error) {
+	// The choices are drawn up front, so that they only depend on the seed.
+	r := rand.New(rand.NewSource(cfg.Seed))
+	var plans []submission
+	var choices []*big.Int
+	for i := 0; i < cfg.Voters; i++ {
+		n := 1
+		if r.Float64() < cfg.RevoteRatio {
+			n = 2
+		}
+		for j := 0; j < n; j++ {
+			kind := KindValid
+			switch x := r.Float64(); {
+			case x < cfg.MalformedRate:
+				kind = KindMalformed
+			case x < cfg.MalformedRate+cfg.TamperedRate:
+				kind = KindTampered
+			}
+			plans = append(plans, submission{voter: voterID(i), kind: kind, revote: j != 0})
+			choices = append(choices, cfg.Distribution(r, pp.CandidateMin, pp.CandidateMax))
+		}
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	parallel(runtime.GOMAXPROCS(0), len(plans), func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		body, err := castBody(plans[i].kind, plans[i].voter, choices[i], pp)
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			return
+		}
+		plans[i].body = body
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return plans, ctx.Err()
+}
+
+// castBody casts a ballot of the given kind for the voter, and encodes it
+// as JSON.
+func castBody(kind Kind, voter string, choice *big.Int, pp ballot.PublicParameters) ([]byte, error) {
+	bd, _, err := ballot.Cast(choice, voter, pp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case KindTampered:
+		// Adding the generator to the ciphertext increments the encrypted
+		// choice, which the proofs no longer match.
+		eg := pp.ElGamalParams()
+		bd.Ballot.V = eg.I.Element().Add(bd.Ballot.V, eg.G)
+	case KindMalformed:
+		body, err := json.Marshal(bd)
+		if err != nil {
+			return nil, err
+		}
+		// Truncated ballots are not valid JSON.
+		return body[:len(body)/2], nil
+	}
+	return json.Marshal(bd)
+}
+
+// parallel calls f(i) for i in [0, n) on the given number of goroutines.
+func parallel(workers, n int, f func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
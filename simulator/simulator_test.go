@@ -0,0 +1,146 @@
+package simulator
+
+import (
+	"context"
+	"github.com/takakv/msc-poc/ballot"
+	"github.com/takakv/msc-poc/collector"
+	"github.com/takakv/msc-poc/group"
+	"math/big"
+	"math/rand"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setup(t *testing.T) ballot.PublicParameters {
+	t.Helper()
+	pp, err := ballot.Setup(group.P256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pp
+}
+
+func checkReport(t *testing.T, r Report) {
+	t.Helper()
+	if r.Unexpected != 0 || r.Failures != 0 {
+		t.Errorf("unexpected outcomes: %+v", r)
+	}
+	if r.Submissions != r.Voters+r.Revotes || len(r.Latencies) != r.Submissions {
+		t.Errorf("submissions do not add up: %+v", r)
+	}
+	if r.Sent[KindMalformed] != r.Rejections["malformed_ballot"] {
+		t.Errorf("%d malformed ballots, %d rejected", r.Sent[KindMalformed], r.Rejections["malformed_ballot"])
+	}
+}
+
+func TestRunInProcess(t *testing.T) {
+	pp := setup(t)
+	s, err := collector.NewServer(pp, collector.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Voters:        12,
+		Concurrency:   4,
+		Distribution:  Zipf(1.5),
+		RevoteRatio:   0.5,
+		MalformedRate: 0.2,
+		TamperedRate:  0.2,
+		Seed:          1,
+	}
+	r, err := Run(context.Background(), cfg, pp, HandlerSubmitter{s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, r)
+	if r.Revotes == 0 || r.Sent[KindMalformed] == 0 || r.Sent[KindTampered] == 0 {
+		t.Fatalf("seed does not exercise all kinds of ballots: %+v", r)
+	}
+	// The collector verifies synchronously, so tampered ballots are rejected.
+	if r.Accepted != r.Sent[KindValid] || r.Rejections["invalid_ballot"] != r.Sent[KindTampered] {
+		t.Errorf("unexpected outcomes: %+v", r)
+	}
+
+	var b strings.Builder
+	if err = r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Rejected (invalid_ballot)") {
+		t.Errorf("report lacks the rejections:\n%s", b.String())
+	}
+}
+
+func TestRunHTTP(t *testing.T) {
+	pp := setup(t)
+	store := collector.NewMemoryStore()
+	s, err := collector.NewServer(pp, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Queue = collector.NewQueue(pp, store, 0, 0)
+	defer s.Queue.Close()
+	tokens, err := NewTokens(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Authenticate = collector.BearerTokens(tokens)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	cfg := Config{Voters: 6, TamperedRate: 0.5, MalformedRate: 0.2, Seed: 2, Tokens: tokens}
+	r, err := Run(context.Background(), cfg, pp, HTTPSubmitter{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, r)
+	if r.Sent[KindTampered] == 0 {
+		t.Fatalf("seed does not exercise tampered ballots: %+v", r)
+	}
+	// Tampered ballots are queued, and rejected once they are verified.
+	if r.Accepted != r.Sent[KindValid] || r.Rejections["invalid_ballot"] != r.Sent[KindTampered] {
+		t.Errorf("unexpected outcomes: %+v", r)
+	}
+
+	// Without their tokens, the voters cannot submit.
+	cfg.Tokens = nil
+	if r, err = Run(context.Background(), cfg, pp, HTTPSubmitter{URL: ts.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Accepted != 0 || r.Rejections["unauthorized"] != r.Submissions {
+		t.Errorf("unauthenticated voters: %+v", r)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{Voters: 1, RevoteRatio: 1.5},
+		{Voters: 1, MalformedRate: -0.1},
+		{Voters: 1, MalformedRate: 0.6, TamperedRate: 0.6},
+	} {
+		if _, err := Run(context.Background(), cfg, ballot.PublicParameters{}, nil); err == nil {
+			t.Errorf("invalid configuration %+v was accepted", cfg)
+		}
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for _, name := range []string{"uniform", "zipf"} {
+		d, err := LookupDistribution(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lo, hi := big.NewInt(101), big.NewInt(110)
+		for i := 0; i < 1000; i++ {
+			if c := d(r, lo, hi); c.Cmp(lo) < 0 || c.Cmp(hi) > 0 {
+				t.Fatalf("%s: candidate %d is out of range", name, c)
+			}
+		}
+	}
+	if _, err := LookupDistribution("normal"); err == nil {
+		t.Error("unknown distribution was accepted")
+	}
+}
@@ -0,0 +1,132 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/takakv/msc-poc/collector"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// Response is the collector's answer to a submission or a status query.
+type Response struct {
+	// Status is the HTTP status code.
+	Status int
+	// Code is the error code of a rejected ballot.
+	Code string
+	// ID identifies a stored ballot.
+	ID string
+	// Ballot is the verification status of a stored ballot.
+	Ballot collector.Status
+}
+
+// Accepted reports whether the collector found the ballot valid.
+func (r Response) Accepted() bool {
+	return r.Ballot == collector.StatusValid
+}
+
+// Pending reports whether the ballot waits for verification.
+func (r Response) Pending() bool {
+	return r.Ballot == collector.StatusPending
+}
+
+// Submitter submits JSON ballots to a collector.
+type Submitter interface {
+	// Submit submits a ballot, authenticated with the bearer token unless
+	// it is empty.
+	Submit(ctx context.Context, token string, body []byte) (Response, error)
+	// Poll queries the status of the stored ballot id.
+	Poll(ctx context.Context, id string) (Response, error)
+}
+
+// HTTPSubmitter submits ballots to the collector listening at URL.
+type HTTPSubmitter struct {
+	// URL is the base URL of the collector, e.g. http://localhost:8080.
+	URL string
+	// Client is used for the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+func (s HTTPSubmitter) Submit(ctx context.Context, token string, body []byte) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.URL, "/")+"/ballots", bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	setHeaders(req, token)
+	return s.do(req)
+}
+
+func (s HTTPSubmitter) Poll(ctx context.Context, id string) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.URL, "/")+"/ballots/"+url.PathEscape(id), nil)
+	if err != nil {
+		return Response{}, err
+	}
+	return s.do(req)
+}
+
+func (s HTTPSubmitter) do(req *http.Request) (Response, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, err
+	}
+	return parseResponse(resp.StatusCode, data), nil
+}
+
+// HandlerSubmitter submits ballots to an in-process collector, without
+// the overhead of a network connection.
+type HandlerSubmitter struct {
+	Handler http.Handler
+}
+
+func (s HandlerSubmitter) Submit(ctx context.Context, token string, body []byte) (Response, error) {
+	req := httptest.NewRequest(http.MethodPost, "/ballots", bytes.NewReader(body)).WithContext(ctx)
+	setHeaders(req, token)
+	return s.serve(req), nil
+}
+
+func (s HandlerSubmitter) Poll(ctx context.Context, id string) (Response, error) {
+	return s.serve(httptest.NewRequest(http.MethodGet, "/ballots/"+url.PathEscape(id), nil).WithContext(ctx)), nil
+}
+
+func (s HandlerSubmitter) serve(req *http.Request) Response {
+	rec := httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, req)
+	return parseResponse(rec.Code, rec.Body.Bytes())
+}
+
+func setHeaders(req *http.Request, token string) {
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func parseResponse(status int, body []byte) Response {
+	var tmp struct {
+		ID     string           `json:"id"`
+		Status collector.Status `json:"status"`
+		Error  struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &tmp)
+	r := Response{Status: status, Code: tmp.Error.Code, ID: tmp.ID, Ballot: tmp.Status}
+	// The status of a stored ballot names no error code.
+	if r.Ballot == collector.StatusInvalid && r.Code == "" {
+		r.Code = "invalid_ballot"
+	}
+	return r
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/takakv/msc-poc/ballot"
+	"github.com/takakv/msc-poc/group"
+	"io"
+	"text/tabwriter"
+)
+
+// ballotSizes holds the encoded sizes of a ballot and its components. The
+// sizes of the vote proof are zero for single-group ballots.
+type ballotSizes struct {
+	Ballot    [2]int
+	BpLower   [2]int
+	BpUpper   [2]int
+	VoteProof [2]int
+	Compact   [2]int
+	Total     [2]int
+}
+
+type binaryMarshaler interface {
+	MarshalBinary() ([]byte, error)
+}
+
+// encodedSizes returns the JSON and binary sizes of v.
+func encodedSizes(v binaryMarshaler) ([2]int, error) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return [2]int{}, err
+	}
+	b, err := v.MarshalBinary()
+	if err != nil {
+		return [2]int{}, err
+	}
+	return [2]int{len(j), len(b)}, nil
+}
+
+func measureBallot(bd ballot.BallotData) (ballotSizes, error) {
+	var s ballotSizes
+	var err error
+	if s.Ballot, err = encodedSizes(bd.Ballot); err != nil {
+		return s, err
+	}
+	if s.BpLower, err = encodedSizes(bd.BpLower); err != nil {
+		return s, err
+	}
+	if s.BpUpper, err = encodedSizes(bd.BpUpper); err != nil {
+		return s, err
+	}
+	if bd.VoteProof != nil {
+		if s.VoteProof, err = encodedSizes(bd.VoteProof); err != nil {
+			return s, err
+		}
+		if s.Compact, err = encodedSizes(bd.VoteProof.Compact()); err != nil {
+			return s, err
+		}
+	}
+	s.Total, err = encodedSizes(bd)
+	return s, err
+}
+
+// sizeRow is a row of the size comparison.
+type sizeRow struct {
+	name string
+	size [2]int
+}
+
+// reportSizes casts a ballot in each group and mode and prints the sizes
+// of its JSON and binary encodings.
+func reportSizes(w io.Writer, groups []group.Group, modes []ballot.Mode) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Group\tMode\tPart\tJSON\tBinary\tRatio\t")
+
+	for _, g := range groups {
+		for _, mode := range modes {
+			pp, err := setupMode(g, mode, ballot.DefaultRequirements)
+			if err != nil {
+				return err
+			}
+			vote, _, err := ballot.Cast(ballot.RandomChoice(pp), benchmarkVoter, pp)
+			if err != nil {
+				return err
+			}
+			s, err := measureBallot(vote)
+			if err != nil {
+				return err
+			}
+
+			rows := []sizeRow{
+				{"ciphertext", s.Ballot},
+				{"range proof (lower)", s.BpLower},
+				{"range proof (upper)", s.BpUpper},
+			}
+			if vote.VoteProof != nil {
+				rows = append(rows, sizeRow{"sigma proof", s.VoteProof}, sizeRow{"sigma proof (compact)", s.Compact})
+			}
+			rows = append(rows, sizeRow{"total", s.Total})
+			for _, r := range rows {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%.2f\t\n", g.Name(), mode, r.name,
+					r.size[0], r.size[1], float64(r.size[0])/float64(r.size[1]))
+			}
+		}
+	}
+
+	return tw.Flush()
+}
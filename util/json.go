@@ -0,0 +1,210 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/takakv/msc-poc/group"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// PathError records where in a JSON document decoding failed.
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// WrapPath attributes err to the given field. If err already carries a
+// path, the field is prepended to it.
+func WrapPath(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if pe, ok := err.(*PathError); ok {
+		if strings.HasPrefix(pe.Path, "[") {
+			return &PathError{Path: field + pe.Path, Err: pe.Err}
+		}
+		return &PathError{Path: field + "." + pe.Path, Err: pe.Err}
+	}
+	return &PathError{Path: field, Err: err}
+}
+
+// WrapIndex attributes err to the i-th entry of a vector field.
+func WrapIndex(field string, i int, err error) error {
+	return WrapPath(fmt.Sprintf("%s[%d]", field, i), err)
+}
+
+// UnmarshalStrict decodes the JSON object b into the struct pointed to by v.
+// Unlike json.Unmarshal, it rejects unknown fields, trailing data, and
+// missing or null fields. Fields tagged with omitempty are optional.
+func UnmarshalStrict(b []byte, v any) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if fields == nil {
+		return errors.New("expected an object")
+	}
+
+	for _, name := range requiredFields(reflect.TypeOf(v).Elem()) {
+		raw, ok := lookupField(fields, name)
+		if !ok {
+			return &PathError{Path: name, Err: errors.New("missing field")}
+		}
+		if string(raw) == "null" {
+			return &PathError{Path: name, Err: errors.New("field is null")}
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the object")
+	}
+	return nil
+}
+
+// lookupField finds a field by name, ignoring case as json.Unmarshal does.
+func lookupField(fields map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	if raw, ok := fields[name]; ok {
+		return raw, true
+	}
+	for k, raw := range fields {
+		if strings.EqualFold(k, name) {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+// requiredFields lists the JSON names of the fields of the struct type t,
+// including those of embedded structs, that are not tagged with omitempty.
+func requiredFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			names = append(names, requiredFields(f.Type)...)
+			continue
+		}
+		if !f.IsExported() || strings.Contains(opts, "omitempty") {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// FieldDecoder decodes the fields of a JSON object that UnmarshalStrict
+// left as raw messages. Errors carry the path of the offending field, and
+// the first one is retained and returned by Err.
+type FieldDecoder struct {
+	err error
+}
+
+func (d *FieldDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// Element decodes the named field as an element of g.
+func (d *FieldDecoder) Element(field string, raw json.RawMessage, g group.Group) group.Element {
+	if d.err != nil {
+		return nil
+	}
+	x := g.Element()
+	if err := x.UnmarshalJSON(raw); err != nil {
+		d.fail(WrapPath(field, err))
+		return nil
+	}
+	return x
+}
+
+// Elements decodes the named vector field, which must have length n,
+// as elements of g.
+func (d *FieldDecoder) Elements(field string, raws []json.RawMessage, g group.Group, n int) []group.Element {
+	if d.err != nil {
+		return nil
+	}
+	if len(raws) != n {
+		d.fail(&PathError{Path: field, Err: fmt.Errorf("vector length is %d, wanted %d", len(raws), n)})
+		return nil
+	}
+	xs := make([]group.Element, n)
+	for i := range raws {
+		xs[i] = d.Element(fmt.Sprintf("%s[%d]", field, i), raws[i], g)
+	}
+	return xs
+}
+
+// Scalar decodes the named field as a scalar of g.
+func (d *FieldDecoder) Scalar(field string, raw json.RawMessage, g group.Group) group.Scalar {
+	if d.err != nil {
+		return nil
+	}
+	s := g.NewScalar()
+	if err := s.UnmarshalJSON(raw); err != nil {
+		d.fail(WrapPath(field, err))
+		return nil
+	}
+	return s
+}
+
+// Uint checks that the named integer field is in [0, 2^bits), and returns it.
+func (d *FieldDecoder) Uint(field string, x *big.Int, bits int) *big.Int {
+	if d.err != nil {
+		return nil
+	}
+	if x == nil {
+		d.fail(&PathError{Path: field, Err: errors.New("missing integer")})
+		return nil
+	}
+	if x.Sign() < 0 || x.BitLen() > bits {
+		d.fail(&PathError{Path: field, Err: fmt.Errorf("integer is not in [0, 2^%d)", bits)})
+		return nil
+	}
+	return x
+}
+
+// Object decodes the named field with decode, attributing its errors
+// to the field.
+func (d *FieldDecoder) Object(field string, decode func() error) {
+	if d.err != nil {
+		return
+	}
+	d.fail(WrapPath(field, decode()))
+}
+
+// Fail records an error for the named field.
+func (d *FieldDecoder) Fail(field string, err error) {
+	d.fail(&PathError{Path: field, Err: err})
+}
+
+// Err returns the first error encountered.
+func (d *FieldDecoder) Err() error {
+	return d.err
+}
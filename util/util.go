@@ -38,7 +38,7 @@ func Decompose(x *big.Int, u int64, l int64) []int64 {
 }
 
 // PedersenCommit creates a commitment to secret x using randomness r in group GP.
-func PedersenCommit(x, r *big.Int, h group.Element, GP group.Group) group.Element {
+func PedersenCommit(x, r group.Scalar, h group.Element, GP group.Group) group.Element {
 	C := GP.Element().BaseScale(x)
 	Hr := GP.Element().Scale(h, r)
 	C = GP.Element().Add(C, Hr)
@@ -0,0 +1,308 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/takakv/msc-poc/group"
+	"math/big"
+	"unicode/utf8"
+)
+
+// WireVersion is the version of the binary wire format.
+const WireVersion byte = 1
+
+// Message kinds of the binary wire format. Every encoded message starts with
+// the format version followed by its kind, so that messages of one type are
+// never mistaken for another.
+const (
+	WireElGamalCiphertext byte = iota + 1
+	WireBulletProof
+	WireInnerProductProof
+	WireMultiBulletProof
+	WireSigmaProof
+	WireBallot
+	WireSigmaTranscript
+	WireCompactSigmaProof
+)
+
+// Encoder builds a message in the binary wire format. Elements are
+// compressed, elements and nested messages are prefixed with their length as
+// a uvarint, and scalars and integers are encoded with a fixed width. The
+// first error is retained and returned by Bytes.
+type Encoder struct {
+	buf []byte
+	err error
+}
+
+// NewEncoder starts a message of the given kind.
+func NewEncoder(kind byte) *Encoder {
+	return &Encoder{buf: []byte{WireVersion, kind}}
+}
+
+func (e *Encoder) writeBytes(b []byte) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// Element appends a length-prefixed group element in its compressed form.
+func (e *Encoder) Element(x group.Element) {
+	if e.err != nil {
+		return
+	}
+	if x == nil {
+		e.err = errors.New("missing group element")
+		return
+	}
+	b, err := x.MarshalBinaryCompress()
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.writeBytes(b)
+}
+
+// Elements appends a vector of group elements prefixed with its length.
+func (e *Encoder) Elements(xs []group.Element) {
+	e.Count(len(xs))
+	for _, x := range xs {
+		e.Element(x)
+	}
+}
+
+// Scalar appends a scalar in its fixed-length encoding.
+func (e *Encoder) Scalar(s group.Scalar) {
+	if e.err != nil {
+		return
+	}
+	if s == nil {
+		e.err = errors.New("missing scalar")
+		return
+	}
+	b, err := s.MarshalBinary()
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// Int appends a non-negative integer as a big-endian integer of the given
+// byte length.
+func (e *Encoder) Int(x *big.Int, width int) {
+	if e.err != nil {
+		return
+	}
+	if x == nil || x.Sign() < 0 || (x.BitLen()+7)/8 > width {
+		e.err = fmt.Errorf("integer does not fit into %d bytes", width)
+		return
+	}
+	e.buf = append(e.buf, x.FillBytes(make([]byte, width))...)
+}
+
+// Count appends a vector length.
+func (e *Encoder) Count(n int) {
+	if e.err != nil {
+		return
+	}
+	e.buf = binary.AppendUvarint(e.buf, uint64(n))
+}
+
+// Message appends a length-prefixed nested message.
+func (e *Encoder) Message(b []byte, err error) {
+	if e.err != nil {
+		return
+	}
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.writeBytes(b)
+}
+
+// Text appends a length-prefixed UTF-8 string.
+func (e *Encoder) Text(s string) {
+	if e.err != nil {
+		return
+	}
+	e.writeBytes([]byte(s))
+}
+
+// Bytes returns the encoded message, or the first error encountered.
+func (e *Encoder) Bytes() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf, nil
+}
+
+// Decoder reads a message in the binary wire format. Decoding is strict:
+// the version and kind must match, lengths must be minimally encoded, and
+// no data may follow the message. The first error is retained and returned
+// by Finish.
+type Decoder struct {
+	buf []byte
+	err error
+}
+
+// NewDecoder starts reading a message of the given kind.
+func NewDecoder(b []byte, kind byte) *Decoder {
+	d := &Decoder{buf: b}
+	if len(b) < 2 {
+		d.err = errors.New("message is too short")
+		return d
+	}
+	if b[0] != WireVersion {
+		d.err = fmt.Errorf("unsupported wire format version %d", b[0])
+		return d
+	}
+	if b[1] != kind {
+		d.err = fmt.Errorf("unexpected message kind %d, wanted %d", b[1], kind)
+		return d
+	}
+	d.buf = b[2:]
+	return d
+}
+
+func (d *Decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *Decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	n, l := binary.Uvarint(d.buf)
+	if l <= 0 || l != len(binary.AppendUvarint(nil, n)) {
+		d.fail(errors.New("invalid length encoding"))
+		return 0
+	}
+	d.buf = d.buf[l:]
+	return n
+}
+
+func (d *Decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.buf) {
+		d.fail(errors.New("message is truncated"))
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *Decoder) readBytes() []byte {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.fail(errors.New("message is truncated"))
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// Element reads a length-prefixed element of g, which must be in the
+// compressed encoding of Encoder.Element. Other encodings of the element
+// are rejected, so that every message has a single encoding.
+func (d *Decoder) Element(g group.Group) group.Element {
+	b := d.readBytes()
+	if d.err != nil {
+		return nil
+	}
+	x := g.Element()
+	if err := x.UnmarshalBinary(b); err != nil {
+		d.fail(err)
+		return nil
+	}
+	if c, err := x.MarshalBinaryCompress(); err != nil || !bytes.Equal(b, c) {
+		d.fail(errors.New("element is not in the compressed encoding"))
+		return nil
+	}
+	return x
+}
+
+// Elements reads a vector of elements of g, which must have length n.
+func (d *Decoder) Elements(g group.Group, n int) []group.Element {
+	if d.Count() != n && d.err == nil {
+		d.fail(fmt.Errorf("vector length is not %d", n))
+	}
+	if d.err != nil {
+		return nil
+	}
+	xs := make([]group.Element, n)
+	for i := range xs {
+		xs[i] = d.Element(g)
+	}
+	return xs
+}
+
+// Scalar reads a scalar of g.
+func (d *Decoder) Scalar(g group.Group) group.Scalar {
+	s := g.NewScalar()
+	b := d.next((g.N().BitLen() + 7) / 8)
+	if d.err != nil {
+		return nil
+	}
+	if err := s.UnmarshalBinary(b); err != nil {
+		d.fail(err)
+		return nil
+	}
+	return s
+}
+
+// Int reads a non-negative integer of the given byte length.
+func (d *Decoder) Int(width int) *big.Int {
+	b := d.next(width)
+	if d.err != nil {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// Count reads a vector length.
+func (d *Decoder) Count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		// Every vector entry takes at least one byte.
+		d.fail(errors.New("vector length exceeds the message"))
+		return 0
+	}
+	return int(n)
+}
+
+// Message reads a length-prefixed nested message.
+func (d *Decoder) Message() []byte {
+	return d.readBytes()
+}
+
+// Text reads a length-prefixed UTF-8 string.
+func (d *Decoder) Text() string {
+	b := d.readBytes()
+	if d.err == nil && !utf8.Valid(b) {
+		d.fail(errors.New("invalid UTF-8 string"))
+		return ""
+	}
+	return string(b)
+}
+
+// Err returns the first error encountered.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Finish returns the first error encountered, or an error if data follows
+// the message.
+func (d *Decoder) Finish() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.buf) != 0 {
+		return errors.New("unexpected data after the message")
+	}
+	return nil
+}
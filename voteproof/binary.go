@@ -0,0 +1,91 @@
+package voteproof
+
+import (
+	. "github.com/takakv/msc-poc/util"
+)
+
+// challengeWidth returns the byte length of the challenge.
+func (params *ProofParams) challengeWidth() int {
+	return int(params.Bc) / 8
+}
+
+// responseWidth returns the byte length of the largest accepted response z.
+func (params *ProofParams) responseWidth() int {
+	return (int(params.Bx) + int(params.Bc) + params.Bb + 7) / 8
+}
+
+// MarshalBinary encodes the commitments W and Kp in the field group and
+// Kq1 and Kq2 on the curve, followed by the challenge and z as fixed-width
+// integers of the widths set by the proof parameters, and the scalars Sp,
+// Sq1 and Sq2. The parameters themselves are not encoded.
+func (proof SigmaProof) MarshalBinary() ([]byte, error) {
+	e := NewEncoder(WireSigmaProof)
+	e.Element(proof.W)
+	e.Element(proof.Kp)
+	e.Element(proof.Kq1)
+	e.Element(proof.Kq2)
+	e.Int(proof.Challenge, proof.Params.challengeWidth())
+	e.Int(proof.Z, proof.Params.responseWidth())
+	e.Scalar(proof.Sp)
+	e.Scalar(proof.Sq1)
+	e.Scalar(proof.Sq2)
+	return e.Bytes()
+}
+
+// ProofUnmarshalBinary decodes a proof in the binary wire format.
+func ProofUnmarshalBinary(b []byte, params ProofParams) (SigmaProof, error) {
+	gFF := params.GFF.I
+	gEC := params.GEC.I
+
+	d := NewDecoder(b, WireSigmaProof)
+	var proof SigmaProof
+	proof.W = d.Element(gFF)
+	proof.Kp = d.Element(gFF)
+	proof.Kq1 = d.Element(gEC)
+	proof.Kq2 = d.Element(gEC)
+	proof.Challenge = d.Int(params.challengeWidth())
+	proof.Z = d.Int(params.responseWidth())
+	proof.Sp = d.Scalar(gFF)
+	proof.Sq1 = d.Scalar(gEC)
+	proof.Sq2 = d.Scalar(gEC)
+	proof.Params = params
+
+	if err := d.Finish(); err != nil {
+		return SigmaProof{}, err
+	}
+	return proof, nil
+}
+
+// MarshalBinary encodes the proof as SigmaProof.MarshalBinary does without
+// the four commitments: only the challenge, z, Sp, Sq1 and Sq2 remain, and
+// the message kind tells both encodings apart.
+func (proof CompactProof) MarshalBinary() ([]byte, error) {
+	e := NewEncoder(WireCompactSigmaProof)
+	e.Int(proof.Challenge, proof.Params.challengeWidth())
+	e.Int(proof.Z, proof.Params.responseWidth())
+	e.Scalar(proof.Sp)
+	e.Scalar(proof.Sq1)
+	e.Scalar(proof.Sq2)
+	return e.Bytes()
+}
+
+// CompactProofUnmarshalBinary decodes a compact proof in the binary wire
+// format.
+func CompactProofUnmarshalBinary(b []byte, params ProofParams) (CompactProof, error) {
+	gFF := params.GFF.I
+	gEC := params.GEC.I
+
+	d := NewDecoder(b, WireCompactSigmaProof)
+	var proof CompactProof
+	proof.Challenge = d.Int(params.challengeWidth())
+	proof.Z = d.Int(params.responseWidth())
+	proof.Sp = d.Scalar(gFF)
+	proof.Sq1 = d.Scalar(gEC)
+	proof.Sq2 = d.Scalar(gEC)
+	proof.Params = params
+
+	if err := d.Finish(); err != nil {
+		return CompactProof{}, err
+	}
+	return proof, nil
+}
@@ -0,0 +1,91 @@
+package voteproof
+
+import (
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/sigma"
+	"math/big"
+)
+
+// CompactProof is a SigmaProof without its commitments. Since the
+// challenge is derived from the commitments, the verifier recomputes them
+// from the challenge and the responses, and checks that they hash to the
+// challenge. It omits the two elements of the field group, which make up
+// most of a SigmaProof.
+type CompactProof struct {
+	SigmaChallenge
+	SigmaResponse
+	Params ProofParams
+}
+
+// Compact returns the proof without its commitments.
+func (proof *SigmaProof) Compact() CompactProof {
+	return CompactProof{
+		SigmaChallenge: proof.SigmaChallenge,
+		SigmaResponse:  proof.SigmaResponse,
+		Params:         proof.Params,
+	}
+}
+
+// isComplete returns true if no field of the proof is missing, so that
+// Verify cannot panic on it.
+func (proof *CompactProof) isComplete() bool {
+	for _, p := range []GroupParameters{proof.Params.GFF, proof.Params.GEC} {
+		if p.I == nil || p.G == nil || p.H == nil {
+			return false
+		}
+	}
+	return proof.Challenge != nil && proof.Z != nil && proof.Sp != nil && proof.Sq1 != nil && proof.Sq2 != nil
+}
+
+// recompute returns the relation of the proof for comm, and the only
+// commitments for which the proof is an accepting transcript.
+func (proof *CompactProof) recompute(comm VerCommitments) (*sigma.Relation, SigmaCommit, error) {
+	if !proof.isComplete() || !comm.isComplete() {
+		return nil, SigmaCommit{}, errIncompleteMessage
+	}
+	statement := proof.Params.Statement(comm)
+	relation := statement.Relation()
+	commitment, err := relation.Recompute(proof.Challenge, sigma.Message{
+		Ints:    []*big.Int{proof.Z},
+		Scalars: []group.Scalar{proof.Sp, proof.Sq1, proof.Sq2},
+	})
+	if err != nil {
+		return nil, SigmaCommit{}, err
+	}
+	e := commitment.Elements
+	return relation, SigmaCommit{W: e[0], Kp: e[1], Kq1: e[2], Kq2: e[3]}, nil
+}
+
+// Expand recomputes the commitments of the proof for comm. The result
+// verifies if and only if the compact proof does.
+func (proof *CompactProof) Expand(comm VerCommitments) (SigmaProof, error) {
+	_, commit, err := proof.recompute(comm)
+	if err != nil {
+		return SigmaProof{}, err
+	}
+	return SigmaProof{
+		SigmaCommit:    commit,
+		SigmaChallenge: proof.SigmaChallenge,
+		SigmaResponse:  proof.SigmaResponse,
+		Params:         proof.Params,
+	}, nil
+}
+
+// Verify verifies the proof of secret equality across groups. As for
+// SigmaProof.Verify, the range proofs must be verified separately.
+func (proof *CompactProof) Verify(comm VerCommitments) bool {
+	return proof.VerifyInDomain(comm, nil)
+}
+
+// VerifyInDomain is Verify for a proof computed by ProveInDomain.
+func (proof *CompactProof) VerifyInDomain(comm VerCommitments, domain []byte) bool {
+	relation, commit, err := proof.recompute(comm)
+	if err != nil {
+		return false
+	}
+	// The equations hold for the recomputed commitments by construction,
+	// so only the challenge remains to be derived again.
+	elements := []group.Element{commit.W, commit.Kp, commit.Kq1, commit.Kq2}
+	c, err := domainOracle(domain)(relation, sigma.Message{Elements: elements})
+	return err == nil && c.Cmp(proof.Challenge) == 0
+}
@@ -0,0 +1,130 @@
+package voteproof
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestCompactProof(t *testing.T) {
+	params := testParams(t, 224, 15)
+	proof, comm, err := proveSecret(big.NewInt(1234), params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := proof.Compact()
+	if !compact.Verify(comm) {
+		t.Fatal("compact proof does not verify")
+	}
+
+	expanded, err := compact.Expand(comm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expanded.W.IsEqual(proof.W) || !expanded.Kp.IsEqual(proof.Kp) ||
+		!expanded.Kq1.IsEqual(proof.Kq1) || !expanded.Kq2.IsEqual(proof.Kq2) {
+		t.Error("expanded commitments differ from those of the proof")
+	}
+
+	other := comm
+	other.Xq2 = comm.Xq1
+	if compact.Verify(other) {
+		t.Error("compact proof verifies for other commitments")
+	}
+
+	altered := compact
+	altered.Challenge = new(big.Int).Add(compact.Challenge, big.NewInt(1))
+	if altered.Verify(comm) {
+		t.Error("compact proof verifies with an altered challenge")
+	}
+	altered = compact
+	altered.Z = new(big.Int).Add(compact.Z, big.NewInt(1))
+	if altered.Verify(comm) {
+		t.Error("compact proof verifies with an altered response")
+	}
+	altered = compact
+	altered.Sq1 = params.GEC.I.RandomScalar()
+	if altered.Verify(comm) {
+		t.Error("compact proof verifies with an altered scalar response")
+	}
+	altered = compact
+	altered.Z = new(big.Int).Lsh(big.NewInt(1), uint(params.Bx+params.Bc)+uint(params.Bb))
+	if _, err = altered.Expand(comm); err == nil {
+		t.Error("response out of range was expanded")
+	}
+	altered.Sp = nil
+	if altered.Verify(comm) {
+		t.Error("incomplete compact proof verifies")
+	}
+}
+
+func TestCompactProofDomain(t *testing.T) {
+	params := testParams(t, 224, 15)
+	x := big.NewInt(1234)
+	rp := params.GFF.I.RandomScalar()
+	rq1 := params.GEC.I.RandomScalar()
+	rq2 := params.GEC.I.RandomScalar()
+	comm := VerCommitments{
+		Y:   params.GFF.I.Element().BaseScale(rp),
+		Xp:  pedersenCommit(x, rp, params.GFF),
+		Xq1: pedersenCommit(x, rq1, params.GEC),
+		Xq2: pedersenCommit(x, rq2, params.GEC),
+	}
+	proof, err := ProveInDomain(context.Background(), []byte("alice"), x, rp, rq1, rq2, params,
+		&ProveOptions{MaxAttempts: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := proof.Compact()
+	if !compact.VerifyInDomain(comm, []byte("alice")) {
+		t.Error("compact proof does not verify in its domain")
+	}
+	if compact.VerifyInDomain(comm, []byte("bob")) || compact.Verify(comm) {
+		t.Error("compact proof verified in another domain")
+	}
+}
+
+func TestCompactProofEncoding(t *testing.T) {
+	params := testParams(t, 224, 15)
+	proof, comm, err := proveSecret(big.NewInt(1234), params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := proof.Compact()
+
+	b, err := compact.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The commitments in the field group dominate the length of a proof.
+	if 2*len(b) > len(full) {
+		t.Errorf("compact proof has %d bytes, full proof %d", len(b), len(full))
+	}
+	decoded, err := CompactProofUnmarshalBinary(b, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Verify(comm) {
+		t.Error("decoded compact proof does not verify")
+	}
+	if _, err = CompactProofUnmarshalBinary(full, params); err == nil {
+		t.Error("full proof decoded as a compact proof")
+	}
+
+	j, err := json.Marshal(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = CompactProofUnmarshalJSON(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Verify(comm) {
+		t.Error("compact proof decoded from JSON does not verify")
+	}
+}
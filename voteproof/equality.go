@@ -0,0 +1,202 @@
+package voteproof
+
+import (
+	"context"
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/sigma"
+	"math/big"
+)
+
+var ErrInvalidStatement = errors.New("voteproof: statement does not match the witness")
+
+// equalityOracle derives the challenges of equality proofs from the
+// statement and the commitment, so that the statement cannot be chosen
+// after the challenge.
+var equalityOracle = sigma.HashOracle("voteproof/equality")
+
+// Commitment is a Pedersen commitment X = xG + rH to the common integer x
+// in the group of Params. If ElGamal is set, the randomness is also
+// committed to as Y = rG, so that (Y, X) is an ElGamal ciphertext.
+type Commitment struct {
+	Params  GroupParameters
+	ElGamal bool
+	X       group.Element
+	Y       group.Element
+}
+
+// Statement asserts that all commitments open to the same integer of at
+// most Bx bits. The commitments can be in any number of groups.
+type Statement struct {
+	Bx          uint16 // Length of the secret.
+	Bc          uint16 // Length of the challenge.
+	Bb          int    // Abort parameter.
+	Commitments []Commitment
+}
+
+// Witness opens the commitments of a statement: R[i] is the randomness of
+// the i-th commitment to X.
+type Witness struct {
+	X *big.Int
+	R []group.Scalar
+}
+
+// EqualityProof proves that the commitments of a statement open to the
+// same integer. The i-th entries of W, K and S belong to the i-th
+// commitment, and W[i] is nil unless the commitment is ElGamal.
+type EqualityProof struct {
+	W         []group.Element
+	K         []group.Element
+	Challenge *big.Int
+	Z         *big.Int // Integer response, which must not be reduced.
+	S         []group.Scalar
+}
+
+// Oracle derives the challenges of the vote proof from the statement and
+// the commitment.
+var Oracle = domainOracle(nil)
+
+// domainOracle is Oracle for proofs that are bound to a domain, such as the
+// identity of the prover.
+func domainOracle(domain []byte) sigma.Oracle {
+	return sigma.HashOracle("voteproof/range" + string(domain))
+}
+
+// Relation returns the statement as a linear relation. Its variables are
+// the integer x followed by the randomness of every commitment, and each
+// commitment contributes the equation Y = rG if it is ElGamal, followed by
+// X = xG + rH.
+func (st *Statement) Relation() *sigma.Relation {
+	r := &sigma.Relation{
+		Vars:          []sigma.Var{{Bits: int(st.Bx)}},
+		ChallengeBits: int(st.Bc),
+		Slack:         st.Bb,
+	}
+	for _, c := range st.Commitments {
+		gp := c.Params
+		v := len(r.Vars)
+		r.Vars = append(r.Vars, sigma.Var{Group: gp.I})
+		if c.ElGamal {
+			r.Equations = append(r.Equations, sigma.Equation{
+				Group: gp.I, Image: c.Y, Terms: []sigma.Term{{Var: v}},
+			})
+		}
+		r.Equations = append(r.Equations, sigma.Equation{
+			Group: gp.I, Image: c.X, Terms: []sigma.Term{{Var: 0}, {Var: v, Base: gp.H}},
+		})
+	}
+	return r
+}
+
+// Transcript returns the proof as a non-interactive proof of Relation.
+func (proof *EqualityProof) Transcript() sigma.Proof {
+	var t sigma.Proof
+	for i := range proof.K {
+		if proof.W[i] != nil {
+			t.Commitment.Elements = append(t.Commitment.Elements, proof.W[i])
+		}
+		t.Commitment.Elements = append(t.Commitment.Elements, proof.K[i])
+	}
+	t.Challenge = proof.Challenge
+	t.Response.Ints = []*big.Int{proof.Z}
+	t.Response.Scalars = proof.S
+	return t
+}
+
+// fromTranscript recovers an equality proof for st from a proof of its
+// relation.
+func (st *Statement) fromTranscript(t sigma.Proof) EqualityProof {
+	n := len(st.Commitments)
+	proof := EqualityProof{
+		W:         make([]group.Element, n),
+		K:         make([]group.Element, n),
+		Challenge: t.Challenge,
+		Z:         t.Response.Ints[0],
+		S:         t.Response.Scalars,
+	}
+	elements := t.Commitment.Elements
+	for i, c := range st.Commitments {
+		if c.ElGamal {
+			proof.W[i], elements = elements[0], elements[1:]
+		}
+		proof.K[i], elements = elements[0], elements[1:]
+	}
+	return proof
+}
+
+func (st *Statement) validate() error {
+	if len(st.Commitments) == 0 || st.Bb < 1 || st.Bc == 0 || st.Bc%8 != 0 || st.Bc > 256 {
+		return ErrInvalidParams
+	}
+	for _, c := range st.Commitments {
+		gp := c.Params
+		if gp.I == nil || gp.G == nil || gp.H == nil || gp.N == nil {
+			return ErrInvalidParams
+		}
+	}
+	return nil
+}
+
+// values checks the witness, and returns it as the values of the variables
+// of Relation.
+func (st *Statement) values(w Witness) ([]*big.Int, error) {
+	if err := st.validate(); err != nil {
+		return nil, err
+	}
+	if w.X == nil || w.X.Sign() < 0 || w.X.BitLen() > int(st.Bx) {
+		return nil, ErrInvalidSecret
+	}
+	if len(w.R) != len(st.Commitments) {
+		return nil, ErrInvalidStatement
+	}
+	values := []*big.Int{w.X}
+	for i, c := range st.Commitments {
+		if !checkScalar(w.R[i], c.Params.N) {
+			return nil, ErrInvalidScalar
+		}
+		values = append(values, w.R[i].BigInt())
+	}
+	return values, nil
+}
+
+// ProveEquality proves that the commitments of st open to w.X. The
+// proof is computed by rejection sampling, as described at Prove. Since
+// the challenge hashes the statement, the commitments must be set.
+func ProveEquality(ctx context.Context, st Statement, w Witness, opts *ProveOptions) (EqualityProof, error) {
+	values, err := st.values(w)
+	if err != nil {
+		return EqualityProof{}, err
+	}
+
+	t, err := sigma.Prove(ctx, st.Relation(), values, equalityOracle, opts)
+	if err != nil {
+		return EqualityProof{}, err
+	}
+	return st.fromTranscript(t), nil
+}
+
+// isComplete returns true if the proof has an entry for every commitment
+// of st, so that Verify cannot panic on it.
+func (proof *EqualityProof) isComplete(st *Statement) bool {
+	n := len(st.Commitments)
+	if len(proof.W) != n || len(proof.K) != n || len(proof.S) != n {
+		return false
+	}
+	for i, c := range st.Commitments {
+		if c.ElGamal != (c.Y != nil) || c.ElGamal != (proof.W[i] != nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify verifies that the commitments of st open to the same integer.
+// NB! The integer is only known to be bounded if the bound is otherwise
+// established, e.g. by range proofs on the commitments.
+func (proof *EqualityProof) Verify(st Statement) bool {
+	if st.validate() != nil || !proof.isComplete(&st) {
+		return false
+	}
+	t := proof.Transcript()
+	return t.Verify(st.Relation(), equalityOracle)
+}
@@ -0,0 +1,118 @@
+package voteproof
+
+import (
+	"context"
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"math/big"
+	"testing"
+)
+
+// equalityStatement commits to x once in each of several groups, with an
+// ElGamal ciphertext in the first, and returns the statement and witness.
+func equalityStatement(x *big.Int) (Statement, Witness) {
+	ffg := group.ModPGroup3072q256()
+	groups := []group.Group{ffg, group.P256(), group.Ristretto255(), group.SecP256k1(), group.P256()}
+	st := Statement{Bx: 32, Bc: 128, Bb: 64}
+	w := Witness{X: x}
+	for i, g := range groups {
+		gp := groupParams(g, g.Random())
+		r := g.RandomScalar()
+		c := Commitment{Params: gp, ElGamal: i == 0, X: pedersenCommit(x, r, gp)}
+		if c.ElGamal {
+			c.Y = g.Element().BaseScale(r)
+		}
+		st.Commitments = append(st.Commitments, c)
+		w.R = append(w.R, r)
+	}
+	return st, w
+}
+
+func TestEqualityProof(t *testing.T) {
+	st, w := equalityStatement(big.NewInt(0xdeadbeef))
+	proof, err := ProveEquality(context.Background(), st, w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify(st) {
+		t.Fatal("valid proof did not verify")
+	}
+
+	// A commitment to another integer must not verify.
+	other, ow := equalityStatement(big.NewInt(0xdeadbeee))
+	st.Commitments[3] = other.Commitments[3]
+	if proof.Verify(st) {
+		t.Error("proof verified for a commitment to another integer")
+	}
+	w.R[3] = ow.R[3]
+	if proof, _ = ProveEquality(context.Background(), st, w, nil); proof.Verify(st) {
+		t.Error("proof with a wrong witness verified")
+	}
+
+	// Proofs must not verify against statements of another shape.
+	st, w = equalityStatement(big.NewInt(42))
+	proof, _ = ProveEquality(context.Background(), st, w, nil)
+	short := st
+	short.Commitments = st.Commitments[1:]
+	if proof.Verify(short) {
+		t.Error("proof verified for fewer commitments")
+	}
+	st.Commitments[0].ElGamal = false
+	if proof.Verify(st) {
+		t.Error("proof verified without the ElGamal commitment")
+	}
+}
+
+func TestProveEqualityInvalidInputs(t *testing.T) {
+	ctx := context.Background()
+	st, w := equalityStatement(big.NewInt(7))
+
+	if _, err := ProveEquality(ctx, Statement{Bx: 32, Bc: 128, Bb: 64}, w, nil); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty statement: expected %v, got %v", ErrInvalidParams, err)
+	}
+	if _, err := ProveEquality(ctx, st, Witness{X: new(big.Int).Lsh(big.NewInt(1), 32), R: w.R}, nil); !errors.Is(err, ErrInvalidSecret) {
+		t.Errorf("secret too long: expected %v, got %v", ErrInvalidSecret, err)
+	}
+	if _, err := ProveEquality(ctx, st, Witness{X: w.X, R: w.R[1:]}, nil); !errors.Is(err, ErrInvalidStatement) {
+		t.Errorf("missing randomness: expected %v, got %v", ErrInvalidStatement, err)
+	}
+	r := append([]group.Scalar{}, w.R...)
+	r[2] = nil
+	if _, err := ProveEquality(ctx, st, Witness{X: w.X, R: r}, nil); !errors.Is(err, ErrInvalidScalar) {
+		t.Errorf("missing scalar: expected %v, got %v", ErrInvalidScalar, err)
+	}
+}
+
+// TestEqualityProofBindsStatement alters a response of a proof and solves
+// the verification equation for the commitment, which a statement-free
+// challenge would accept.
+func TestEqualityProofBindsStatement(t *testing.T) {
+	st, w := equalityStatement(big.NewInt(0xdeadbeef))
+	proof, err := ProveEquality(context.Background(), st, w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// For any response s', X' = (zG + s'H - K)/c satisfies zG + s'H = K + cX'.
+	const i = 1
+	gp := st.Commitments[i].Params
+	g := gp.I
+	proof.S = append([]group.Scalar(nil), proof.S...)
+	proof.S[i] = g.RandomScalar()
+	lhs := g.Element().Add(pedersenCommit(proof.Z, proof.S[i], gp), g.Element().Negate(proof.K[i]))
+	cinv := new(big.Int).ModInverse(proof.Challenge, gp.N)
+	forged := st
+	forged.Commitments = append([]Commitment(nil), st.Commitments...)
+	forged.Commitments[i].X = g.Element().Scale(lhs, g.NewScalar().SetBigInt(cinv))
+	if forged.Commitments[i].X.IsEqual(st.Commitments[i].X) {
+		t.Fatal("forged commitment is the original one")
+	}
+
+	transcript := proof.Transcript()
+	if !forged.Relation().Verify(transcript.Commitment, transcript.Challenge, transcript.Response) {
+		t.Fatal("forged statement does not satisfy the verification equations")
+	}
+	if proof.Verify(forged) {
+		t.Error("proof verified for a statement chosen after the challenge")
+	}
+}
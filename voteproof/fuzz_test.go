@@ -0,0 +1,47 @@
+package voteproof
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func FuzzProofJSON(f *testing.F) {
+	for _, name := range []string{"../testdata/P256rp.json", "../testdata/P384rp.json"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		var ballot struct {
+			VoteProof json.RawMessage `json:"voteProof"`
+		}
+		if err = json.Unmarshal(data, &ballot); err != nil {
+			f.Fatal(err)
+		}
+		f.Add([]byte(ballot.VoteProof))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		proof, err := ProofUnmarshalJSON(data)
+		if err != nil {
+			return
+		}
+
+		// The commitments do not matter, as long as they are in the right groups.
+		comm := VerCommitments{
+			Y:   proof.Params.GFF.G,
+			Xp:  proof.Params.GFF.H,
+			Xq1: proof.Params.GEC.G,
+			Xq2: proof.Params.GEC.H,
+		}
+		_ = proof.Verify(comm)
+
+		enc, err := proof.MarshalBinary()
+		if err != nil {
+			t.Fatal("decoded proof cannot be encoded:", err)
+		}
+		if _, err = ProofUnmarshalBinary(enc, proof.Params); err != nil {
+			t.Fatal("re-encoded proof was rejected:", err)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package voteproof
+
+import (
+	"crypto/rand"
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/interactive"
+	"github.com/takakv/msc-poc/sigma"
+	"io"
+	"math/big"
+)
+
+var errIncompleteMessage = errors.New("voteproof: incomplete message")
+
+// prover is the state machine of the prover of the vote proof.
+type prover struct {
+	relation    *sigma.Relation
+	values      []*big.Int
+	state       sigma.State
+	maxAttempts int
+	onAbort     func(int)
+	attempt     int
+	done        bool
+}
+
+// verifier is the state machine of the verifier of the vote proof.
+type verifier struct {
+	relation   *sigma.Relation
+	err        error
+	challenger interactive.Challenger
+	commit     *SigmaCommit
+	challenge  *big.Int
+	attempts   int
+	done       bool
+	accepted   bool
+}
+
+// NewProver returns the prover of Prove as a state machine. It sends a
+// SigmaCommit, and answers the SigmaChallenge with a SigmaResponse. If the
+// attempt aborts, it sends a new SigmaCommit instead, until
+// opts.MaxAttempts attempts have aborted. If opts is nil, the defaults are
+// used.
+func NewProver(secret *big.Int, rp group.Scalar, rq1, rq2 group.Scalar, params ProofParams,
+	opts *ProveOptions) (interactive.Prover, error) {
+	st := params.Statement(VerCommitments{})
+	values, err := st.values(Witness{X: secret, R: []group.Scalar{rp, rq1, rq2}})
+	if err != nil {
+		return nil, err
+	}
+	// The challenges are derived from the statement, so its images must
+	// be known to the prover as well.
+	st = params.Statement(params.commit(secret, rp, rq1, rq2))
+
+	p := &prover{relation: st.Relation(), values: values, maxAttempts: DefaultMaxAttempts}
+	if opts != nil {
+		if opts.MaxAttempts > 0 {
+			p.maxAttempts = opts.MaxAttempts
+		}
+		p.onAbort = opts.OnAbort
+	}
+	return p, nil
+}
+
+func (p *prover) commit() (interactive.Message, error) {
+	p.attempt++
+	commitment, state, err := p.relation.Commit(p.values)
+	if err != nil {
+		return nil, err
+	}
+	p.state = state
+	e := commitment.Elements
+	return SigmaCommit{W: e[0], Kp: e[1], Kq1: e[2], Kq2: e[3]}, nil
+}
+
+func (p *prover) Next(challenge interactive.Message) (interactive.Message, error) {
+	switch c := challenge.(type) {
+	case nil:
+		if p.state != nil {
+			return nil, interactive.Unexpected(challenge)
+		}
+		return p.commit()
+	case SigmaChallenge:
+		if p.state == nil || p.done {
+			return nil, interactive.Unexpected(challenge)
+		}
+		response, err := p.relation.Respond(p.state, c.Challenge)
+		if errors.Is(err, sigma.ErrAbort) {
+			if p.onAbort != nil {
+				p.onAbort(p.attempt)
+			}
+			if p.attempt >= p.maxAttempts {
+				return nil, ErrTooManyAborts
+			}
+			return p.commit()
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.done = true
+		return SigmaResponse{
+			Z:   response.Ints[0],
+			Sp:  response.Scalars[0],
+			Sq1: response.Scalars[1],
+			Sq2: response.Scalars[2],
+		}, nil
+	default:
+		return nil, interactive.Unexpected(challenge)
+	}
+}
+
+func (p *prover) Done() bool {
+	return p.done
+}
+
+// randomChallenger samples challenges of the given length from r.
+func randomChallenger(bits uint16, r io.Reader) interactive.Challenger {
+	bound := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	return func(m interactive.Message) (interactive.Message, error) {
+		if _, ok := m.(SigmaCommit); !ok {
+			return nil, interactive.Unexpected(m)
+		}
+		c, err := rand.Int(r, bound)
+		if err != nil {
+			return nil, err
+		}
+		return SigmaChallenge{Challenge: c}, nil
+	}
+}
+
+// fiatShamir derives the challenges of relation in the domain by Oracle.
+func fiatShamir(relation *sigma.Relation, domain []byte) interactive.Challenger {
+	oracle := domainOracle(domain)
+	return func(m interactive.Message) (interactive.Message, error) {
+		commit, ok := m.(SigmaCommit)
+		if !ok {
+			return nil, interactive.Unexpected(m)
+		}
+		elements := []group.Element{commit.W, commit.Kp, commit.Kq1, commit.Kq2}
+		c, err := oracle(relation, sigma.Message{Elements: elements})
+		if err != nil {
+			return nil, err
+		}
+		return SigmaChallenge{Challenge: c}, nil
+	}
+}
+
+// NewVerifier returns the verifier of the vote proof for comm as a state
+// machine, which samples its challenges from r. If r is nil, crypto/rand
+// is used. A new SigmaCommit in place of the response restarts an aborted
+// attempt, up to DefaultMaxAttempts attempts, so that the soundness error
+// is DefaultMaxAttempts*2^-Bc rather than 2^-Bc.
+func NewVerifier(params ProofParams, comm VerCommitments, r io.Reader) interactive.Verifier {
+	if r == nil {
+		r = rand.Reader
+	}
+	v := newVerifier(params, comm)
+	v.challenger = randomChallenger(params.Bc, r)
+	return v
+}
+
+// newVerifier returns a verifier without a challenger.
+func newVerifier(params ProofParams, comm VerCommitments) *verifier {
+	st := params.Statement(comm)
+	return &verifier{relation: st.Relation(), err: st.validate()}
+}
+
+func (v *verifier) Next(m interactive.Message) (interactive.Message, bool, error) {
+	if v.err != nil {
+		return nil, false, v.err
+	}
+	switch m := m.(type) {
+	case SigmaCommit:
+		if v.done {
+			return nil, false, interactive.Unexpected(m)
+		}
+		if m.W == nil || m.Kp == nil || m.Kq1 == nil || m.Kq2 == nil {
+			return nil, false, errIncompleteMessage
+		}
+		if v.attempts >= DefaultMaxAttempts {
+			return nil, false, ErrTooManyAborts
+		}
+		v.attempts++
+		challenge, err := v.challenger(m)
+		if err != nil {
+			return nil, false, err
+		}
+		v.commit = &m
+		v.challenge = challenge.(SigmaChallenge).Challenge
+		return challenge, false, nil
+	case SigmaResponse:
+		if v.commit == nil || v.done {
+			return nil, false, interactive.Unexpected(m)
+		}
+		commitment := sigma.Message{Elements: []group.Element{v.commit.W, v.commit.Kp, v.commit.Kq1, v.commit.Kq2}}
+		response := sigma.Message{Ints: []*big.Int{m.Z}, Scalars: []group.Scalar{m.Sp, m.Sq1, m.Sq2}}
+		v.done = true
+		v.accepted = v.relation.Verify(commitment, v.challenge, response)
+		return nil, true, nil
+	default:
+		return nil, false, interactive.Unexpected(m)
+	}
+}
+
+func (v *verifier) Accepted() bool {
+	return v.done && v.accepted
+}
@@ -0,0 +1,133 @@
+package voteproof
+
+import (
+	"context"
+	"github.com/takakv/msc-poc/interactive"
+	"math/big"
+	"testing"
+)
+
+// commitments returns the commitments to x that the prover opens.
+func commitments(t *testing.T, x *big.Int, params ProofParams) (VerCommitments, *prover) {
+	t.Helper()
+	rp := params.GFF.I.RandomScalar()
+	rq1 := params.GEC.I.RandomScalar()
+	rq2 := params.GEC.I.RandomScalar()
+	comm := VerCommitments{
+		Y:   params.GFF.I.Element().BaseScale(rp),
+		Xp:  pedersenCommit(x, rp, params.GFF),
+		Xq1: pedersenCommit(x, rq1, params.GEC),
+		Xq2: pedersenCommit(x, rq2, params.GEC),
+	}
+	p, err := NewProver(x, rp, rq1, rq2, params, &ProveOptions{MaxAttempts: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return comm, p.(*prover)
+}
+
+func runPipe(t *testing.T, p interactive.Prover, v interactive.Verifier) bool {
+	t.Helper()
+	a, b := interactive.Pipe()
+	ch := make(chan error)
+	go func() {
+		_, err := interactive.Prove(context.Background(), p, a)
+		ch <- err
+	}()
+	accepted, err := interactive.Verify(context.Background(), v, b)
+	if err := <-ch; err != nil {
+		t.Fatalf("prover failed: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("verifier failed: %v", err)
+	}
+	return accepted
+}
+
+func TestInteractive(t *testing.T) {
+	params := testParams(t, 224, 15)
+	comm, p := commitments(t, big.NewInt(1234), params)
+	if !runPipe(t, p, NewVerifier(params, comm, nil)) {
+		t.Error("honest prover was rejected")
+	}
+
+	other, _ := commitments(t, big.NewInt(1235), params)
+	comm.Xq2 = other.Xq2
+	_, p = commitments(t, big.NewInt(1234), params)
+	if runPipe(t, p, NewVerifier(params, comm, nil)) {
+		t.Error("prover was accepted for other commitments")
+	}
+
+	// With a slack of 2 bits, most attempts abort and are restarted.
+	params = testParams(t, 224, 2)
+	comm, p = commitments(t, big.NewInt(1234), params)
+	v := NewVerifier(params, comm, nil)
+	if !runPipe(t, p, v) {
+		t.Error("honest prover was rejected after aborts")
+	}
+	t.Logf("accepted after %d attempts", v.(*verifier).attempts)
+}
+
+// TestSpecialSoundness answers one commitment with two challenges, and
+// extracts the secret from the responses.
+func TestSpecialSoundness(t *testing.T) {
+	params := testParams(t, 224, 15)
+	x := big.NewInt(4321)
+	c1 := big.NewInt(1)
+	c2 := new(big.Int).Lsh(big.NewInt(1), uint(params.Bc))
+	c2.Sub(c2, big.NewInt(1))
+	for {
+		_, p := commitments(t, x, params)
+		if _, err := p.Next(nil); err != nil {
+			t.Fatal(err)
+		}
+		rewound := *p
+		m1, err1 := p.Next(SigmaChallenge{Challenge: c1})
+		m2, err2 := rewound.Next(SigmaChallenge{Challenge: c2})
+		if err1 != nil || err2 != nil {
+			t.Fatal(err1, err2)
+		}
+		r1, ok1 := m1.(SigmaResponse)
+		r2, ok2 := m2.(SigmaResponse)
+		if !ok1 || !ok2 {
+			// An attempt aborted, so the commitment was not answered twice.
+			continue
+		}
+
+		// z1 - z2 = (c1 - c2)x over the integers.
+		dz := new(big.Int).Sub(r1.Z, r2.Z)
+		extracted, m := new(big.Int).QuoRem(dz, new(big.Int).Sub(c1, c2), new(big.Int))
+		if m.Sign() != 0 || extracted.Cmp(x) != 0 {
+			t.Errorf("extracted %v, expected %v", extracted, x)
+		}
+		return
+	}
+}
+
+func TestDomain(t *testing.T) {
+	params := testParams(t, 224, 15)
+	x := big.NewInt(1234)
+	rp := params.GFF.I.RandomScalar()
+	rq1 := params.GEC.I.RandomScalar()
+	rq2 := params.GEC.I.RandomScalar()
+	comm := VerCommitments{
+		Y:   params.GFF.I.Element().BaseScale(rp),
+		Xp:  pedersenCommit(x, rp, params.GFF),
+		Xq1: pedersenCommit(x, rq1, params.GEC),
+		Xq2: pedersenCommit(x, rq2, params.GEC),
+	}
+	proof, err := ProveInDomain(context.Background(), []byte("alice"), x, rp, rq1, rq2, params,
+		&ProveOptions{MaxAttempts: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.VerifyInDomain(comm, []byte("alice")) {
+		t.Error("proof does not verify in its domain")
+	}
+	if proof.VerifyInDomain(comm, []byte("bob")) {
+		t.Error("proof verified in another domain")
+	}
+	if proof.Verify(comm) {
+		t.Error("proof verified without a domain")
+	}
+}
@@ -2,8 +2,12 @@ package voteproof
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/takakv/msc-poc/group"
 	"math/big"
+
+	. "github.com/takakv/msc-poc/util"
 )
 
 type groupParametersJSON struct {
@@ -15,17 +19,17 @@ type groupParametersJSON struct {
 }
 
 type algebraicParametersJSON struct {
-	GFF groupParametersJSON
-	GEC groupParametersJSON
+	GFF json.RawMessage
+	GEC json.RawMessage
 }
 
 type proofParamsJSON struct {
-	Bx      uint8
+	Bx      uint16
 	Bc      uint16
 	Bg      uint16
 	Bb      int
-	RangeLo uint16
-	RangeHi uint16
+	RangeLo *big.Int
+	RangeHi *big.Int
 	algebraicParametersJSON
 }
 
@@ -36,62 +40,185 @@ type sigmaCommitJSON struct {
 	Kq2 json.RawMessage
 }
 
+type sigmaResponseJSON struct {
+	Z   *big.Int
+	Sp  json.RawMessage
+	Sq1 json.RawMessage
+	Sq2 json.RawMessage
+}
+
 type sigmaProofJSON struct {
 	sigmaCommitJSON
 	SigmaChallenge
-	SigmaResponse
-	Params proofParamsJSON
+	sigmaResponseJSON
+	Params json.RawMessage
 }
 
-func ProofUnmarshalJSON(b []byte, gFF, gEC group.Group) (SigmaProof, error) {
-	tmp := sigmaProofJSON{}
-	err := json.Unmarshal(b, &tmp)
+type compactProofJSON struct {
+	SigmaChallenge
+	sigmaResponseJSON
+	Params json.RawMessage
+}
+
+func groupParamsFromJSON(b []byte) (GroupParameters, error) {
+	var j groupParametersJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
+		return GroupParameters{}, err
+	}
+
+	g, err := group.UnmarshalGroupJSON(j.I)
 	if err != nil {
+		return GroupParameters{}, WrapPath("I", err)
+	}
+
+	if j.N.Cmp(g.N()) != 0 {
+		return GroupParameters{}, WrapPath("N", fmt.Errorf("order does not match group %s", g.Name()))
+	}
+	if j.F.Cmp(g.P()) != 0 {
+		return GroupParameters{}, WrapPath("F", fmt.Errorf("order does not match group %s", g.Name()))
+	}
+
+	var d FieldDecoder
+	gp := GroupParameters{
+		G: d.Element("G", j.G, g),
+		H: d.Element("H", j.H, g),
+		N: g.N(),
+		F: g.P(),
+		I: g,
+	}
+	if err = d.Err(); err != nil {
+		return GroupParameters{}, err
+	}
+	if !gp.G.IsEqual(g.Generator()) {
+		return GroupParameters{}, WrapPath("G", errors.New("not the generator of the group"))
+	}
+	if gp.H.IsIdentity() {
+		return GroupParameters{}, WrapPath("H", errors.New("generator is the identity"))
+	}
+	return gp, nil
+}
+
+func paramsFromJSON(b []byte) (ProofParams, error) {
+	var j proofParamsJSON
+	if err := UnmarshalStrict(b, &j); err != nil {
+		return ProofParams{}, err
+	}
+
+	var AP AlgebraicParameters
+	var d FieldDecoder
+	d.Object("GFF", func() (err error) {
+		AP.GFF, err = groupParamsFromJSON(j.GFF)
+		return err
+	})
+	d.Object("GEC", func() (err error) {
+		AP.GEC, err = groupParamsFromJSON(j.GEC)
+		return err
+	})
+	if err := d.Err(); err != nil {
+		return ProofParams{}, err
+	}
+
+	// The remaining parameters are checked by deriving them again.
+	if int(j.Bg) > AP.GEC.N.BitLen() {
+		return ProofParams{}, WrapPath("Bg", errors.New("exceeds the length of the group order"))
+	}
+	if j.Bc == 0 || j.Bc%8 != 0 {
+		return ProofParams{}, WrapPath("Bc", errors.New("challenge length is not a positive multiple of 8"))
+	}
+	if j.RangeLo.Sign() < 0 {
+		return ProofParams{}, WrapPath("RangeLo", errors.New("range bound is negative"))
+	}
+	if j.RangeLo.Cmp(j.RangeHi) > 0 {
+		return ProofParams{}, WrapPath("RangeLo", errors.New("range is empty"))
+	}
+	if j.RangeHi.BitLen() > int(j.Bx) {
+		return ProofParams{}, WrapPath("RangeHi", errors.New("range does not fit in the secret length"))
+	}
+	pp, err := Setup(j.Bx, j.Bc, j.Bg, j.RangeLo, j.RangeHi, AP)
+	if err != nil {
+		return ProofParams{}, err
+	}
+	if pp.Bb != j.Bb {
+		return ProofParams{}, WrapPath("Bb", errors.New("abort parameter does not match the other parameters"))
+	}
+	return pp, nil
+}
+
+// ParamsUnmarshalJSON recovers the proof system parameters, including
+// the groups, from their JSON representation.
+func ParamsUnmarshalJSON(b []byte) (ProofParams, error) {
+	return paramsFromJSON(b)
+}
+
+// ProofUnmarshalJSON recovers a proof from its JSON representation.
+// The groups are resolved from the descriptions embedded in the proof.
+func ProofUnmarshalJSON(b []byte) (SigmaProof, error) {
+	var tmp sigmaProofJSON
+	if err := UnmarshalStrict(b, &tmp); err != nil {
+		return SigmaProof{}, err
+	}
+
+	pp, err := paramsFromJSON(tmp.Params)
+	if err != nil {
+		return SigmaProof{}, WrapPath("Params", err)
+	}
+	gFF := pp.GFF.I
+	gEC := pp.GEC.I
+
+	var d FieldDecoder
+	proof := SigmaProof{Params: pp}
+	proof.W = d.Element("W", tmp.W, gFF)
+	proof.Kp = d.Element("Kp", tmp.Kp, gFF)
+	proof.Kq1 = d.Element("Kq1", tmp.Kq1, gEC)
+	proof.Kq2 = d.Element("Kq2", tmp.Kq2, gEC)
+	proof.Challenge = d.Uint("Challenge", tmp.Challenge, int(pp.Bc))
+	proof.Z = d.Uint("Z", tmp.Z, int(pp.Bx)+int(pp.Bc)+pp.Bb)
+	proof.Sp = d.Scalar("Sp", tmp.Sp, gFF)
+	proof.Sq1 = d.Scalar("Sq1", tmp.Sq1, gEC)
+	proof.Sq2 = d.Scalar("Sq2", tmp.Sq2, gEC)
+	if err = d.Err(); err != nil {
 		return SigmaProof{}, err
 	}
+	return proof, nil
+}
 
-	var ap AlgebraicParameters
-	ap.GFF.G = gFF.Element()
-	ap.GFF.H = gFF.Element()
-	ap.GFF.N = tmp.Params.GFF.N
-	ap.GFF.F = tmp.Params.GFF.F
-	ap.GFF.I = gFF
-	ap.GEC.G = gEC.Element()
-	ap.GEC.H = gEC.Element()
-	ap.GEC.N = tmp.Params.GEC.N
-	ap.GEC.F = tmp.Params.GEC.F
-	ap.GEC.I = gEC
-
-	_ = ap.GFF.G.UnmarshalJSON(tmp.Params.GFF.G)
-	_ = ap.GFF.H.UnmarshalJSON(tmp.Params.GFF.H)
-	_ = ap.GEC.G.UnmarshalJSON(tmp.Params.GEC.G)
-	_ = ap.GEC.H.UnmarshalJSON(tmp.Params.GEC.H)
-
-	var pp ProofParams
-	pp.Bx = tmp.Params.Bx
-	pp.Bc = tmp.Params.Bc
-	pp.Bg = tmp.Params.Bg
-	pp.Bb = tmp.Params.Bb
-	pp.RangeLo = tmp.Params.RangeLo
-	pp.RangeHi = tmp.Params.RangeHi
-	pp.GFF = ap.GFF
-	pp.GEC = ap.GEC
-
-	var proof SigmaProof
-	proof.W = gFF.Element()
-	proof.Kp = gFF.Element()
-	proof.Kq1 = gEC.Element()
-	proof.Kq2 = gEC.Element()
-	proof.Challenge = tmp.Challenge
-	proof.Z = tmp.Z
-	proof.Sp = tmp.Sp
-	proof.Sq1 = tmp.Sq1
-	proof.Sq2 = tmp.Sq2
-	proof.Params = pp
-
-	_ = proof.W.UnmarshalJSON(tmp.W)
-	_ = proof.Kp.UnmarshalJSON(tmp.Kp)
-	_ = proof.Kq1.UnmarshalJSON(tmp.Kq1)
-	_ = proof.Kq2.UnmarshalJSON(tmp.Kq2)
+// CompactProofUnmarshalJSON recovers a compact proof from its JSON
+// representation.
+func CompactProofUnmarshalJSON(b []byte) (CompactProof, error) {
+	var tmp compactProofJSON
+	if err := UnmarshalStrict(b, &tmp); err != nil {
+		return CompactProof{}, err
+	}
+
+	pp, err := paramsFromJSON(tmp.Params)
+	if err != nil {
+		return CompactProof{}, WrapPath("Params", err)
+	}
+	gFF := pp.GFF.I
+	gEC := pp.GEC.I
+
+	var d FieldDecoder
+	proof := CompactProof{Params: pp}
+	proof.Challenge = d.Uint("Challenge", tmp.Challenge, int(pp.Bc))
+	proof.Z = d.Uint("Z", tmp.Z, int(pp.Bx)+int(pp.Bc)+pp.Bb)
+	proof.Sp = d.Scalar("Sp", tmp.Sp, gFF)
+	proof.Sq1 = d.Scalar("Sq1", tmp.Sq1, gEC)
+	proof.Sq2 = d.Scalar("Sq2", tmp.Sq2, gEC)
+	if err = d.Err(); err != nil {
+		return CompactProof{}, err
+	}
 	return proof, nil
 }
+
+// IsEqual returns true if both parameter sets describe the same proof system.
+func (params *ProofParams) IsEqual(other *ProofParams) bool {
+	return params.Bx == other.Bx && params.Bc == other.Bc &&
+		params.Bg == other.Bg && params.Bb == other.Bb &&
+		params.RangeLo.Cmp(other.RangeLo) == 0 && params.RangeHi.Cmp(other.RangeHi) == 0 &&
+		params.GFF.isEqual(&other.GFF) && params.GEC.isEqual(&other.GEC)
+}
+
+func (gp *GroupParameters) isEqual(other *GroupParameters) bool {
+	return gp.I.Name() == other.I.Name() &&
+		gp.G.IsEqual(other.G) && gp.H.IsEqual(other.H)
+}
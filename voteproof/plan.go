@@ -0,0 +1,154 @@
+package voteproof
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/bits"
+	"text/tabwriter"
+)
+
+// DefaultMinSlack is the default lower bound on the abort parameter, for
+// which fewer than 1 in 256 attempts abort.
+const DefaultMinSlack = 8
+
+var (
+	ErrSecurity      = errors.New("voteproof: security level must be positive")
+	ErrChallengeLen  = errors.New("voteproof: challenge length is not a positive multiple of 8")
+	ErrGroupTooSmall = errors.New("voteproof: group order is too small")
+)
+
+// Requirements constrains the parameters chosen by Plan.
+type Requirements struct {
+	// Security is the target security level in bits. The soundness error
+	// must not exceed 2^-Security.
+	Security int
+	// ChallengeLen is the length of the challenge in bits, which must be a
+	// multiple of 8 since challenges are truncated hashes. If it is zero,
+	// the longest challenge that fits in the group order is chosen.
+	ChallengeLen uint16
+	// MinSlack is the smallest admissible abort parameter. If it is not
+	// positive, DefaultMinSlack is used.
+	MinSlack int
+}
+
+// Plan chooses the parameters of the proof system for secrets of lenSecret
+// bits in [rangeLo, rangeHi] and the groups of AP, such that they meet req.
+// The responses must fit in the order of the curve group, so that the
+// secret is extracted over the integers. Plan refuses groups whose order
+// does not leave room for the secret, the challenge and the minimum slack.
+func Plan(req Requirements, lenSecret uint16, rangeLo, rangeHi *big.Int, AP AlgebraicParameters) (ProofParams, error) {
+	if req.Security < 1 {
+		return ProofParams{}, ErrSecurity
+	}
+	if AP.GEC.N == nil || AP.GFF.N == nil {
+		return ProofParams{}, ErrInvalidParams
+	}
+	minSlack := req.MinSlack
+	if minSlack < 1 {
+		minSlack = DefaultMinSlack
+	}
+
+	bg := AP.GEC.N.BitLen()
+	// The responses are shorter than the group order, as in Setup, and the
+	// challenge is also shorter than the order of the field group.
+	room := min(bg-1-int(lenSecret)-minSlack, AP.GFF.N.BitLen()-1)
+	bc := int(req.ChallengeLen)
+	switch {
+	case bc == 0:
+		bc = max(room-room%8, 0)
+	case bc%8 != 0:
+		return ProofParams{}, ErrChallengeLen
+	case bc > room:
+		return ProofParams{}, fmt.Errorf("%w: a %d-bit challenge does not fit with a %d-bit secret and %d bits of slack",
+			ErrGroupTooSmall, bc, lenSecret, minSlack)
+	}
+	if bc < req.Security {
+		return ProofParams{}, fmt.Errorf("%w: a %d-bit challenge does not give %d bits of security",
+			ErrGroupTooSmall, bc, req.Security)
+	}
+
+	return Setup(lenSecret, uint16(bc), uint16(bg), rangeLo, rangeHi, AP)
+}
+
+// Report describes the guarantees and costs of a choice of parameters. The
+// proof is perfect zero-knowledge: the integer response is rejection
+// sampled, so that it is uniform in [2^(Bx+Bc), 2^(Bx+Bc+Bb)), and honest
+// and simulated transcripts have the same distribution.
+type Report struct {
+	Bx, Bc, Bb int
+	// Soundness is the negated logarithm of the soundness error, 2^-Bc. A
+	// non-interactive prover that makes q queries to the hash function
+	// succeeds with probability at most q*2^-Bc.
+	Soundness int
+	// LiveSoundness is Soundness against the interactive verifier, which
+	// lets the prover restart up to DefaultMaxAttempts times. Every restart
+	// gives a cheating prover another challenge, so that it succeeds with
+	// probability at most DefaultMaxAttempts*2^-Bc.
+	LiveSoundness int
+	// AbortProbability is the probability that an attempt aborts. It is
+	// 2^-Bb whatever the secret and the challenge, so that even the number
+	// of aborts does not leak the secret.
+	AbortProbability float64
+	// ExpectedAttempts is the expected number of attempts of a proof.
+	ExpectedAttempts float64
+	// ProofSize is the length of the binary encoding of a proof in bytes.
+	ProofSize int
+	// CompactSize is the length of the binary encoding of a compact proof.
+	CompactSize int
+}
+
+// Report reports the guarantees and costs of the parameters.
+func (params *ProofParams) Report() (Report, error) {
+	if params.GFF.I == nil || params.GEC.I == nil {
+		return Report{}, ErrInvalidParams
+	}
+	abort := math.Ldexp(1, -params.Bb)
+	r := Report{
+		Bx:               int(params.Bx),
+		Bc:               int(params.Bc),
+		Bb:               params.Bb,
+		Soundness:        int(params.Bc),
+		LiveSoundness:    int(params.Bc) - bits.Len(DefaultMaxAttempts-1),
+		AbortProbability: abort,
+		ExpectedAttempts: 1 / (1 - abort),
+	}
+
+	// All fields have a fixed length, so any proof has the size of one
+	// made of generators and zeros.
+	gFF, gEC := params.GFF.I, params.GEC.I
+	proof := SigmaProof{
+		SigmaCommit:    SigmaCommit{W: gFF.Generator(), Kp: gFF.Generator(), Kq1: gEC.Generator(), Kq2: gEC.Generator()},
+		SigmaChallenge: SigmaChallenge{Challenge: new(big.Int)},
+		SigmaResponse:  SigmaResponse{Z: new(big.Int), Sp: gFF.NewScalar(), Sq1: gEC.NewScalar(), Sq2: gEC.NewScalar()},
+		Params:         *params,
+	}
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		return Report{}, err
+	}
+	r.ProofSize = len(b)
+	compact := proof.Compact()
+	if b, err = compact.MarshalBinary(); err != nil {
+		return Report{}, err
+	}
+	r.CompactSize = len(b)
+	return r, nil
+}
+
+// Write writes a human-readable summary of the report to w.
+func (r Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Secret length (Bx):\t%d bits\n", r.Bx)
+	fmt.Fprintf(tw, "Challenge length (Bc):\t%d bits\n", r.Bc)
+	fmt.Fprintf(tw, "Abort parameter (Bb):\t%d bits\n", r.Bb)
+	fmt.Fprintf(tw, "Soundness error:\t2^-%d\n", r.Soundness)
+	fmt.Fprintf(tw, "Live soundness error:\t2^-%d\n", r.LiveSoundness)
+	fmt.Fprintf(tw, "Abort probability:\t%.3g\n", r.AbortProbability)
+	fmt.Fprintf(tw, "Expected attempts:\t%.6g\n", r.ExpectedAttempts)
+	fmt.Fprintf(tw, "Proof size:\t%d bytes\n", r.ProofSize)
+	fmt.Fprintf(tw, "Compact proof size:\t%d bytes\n", r.CompactSize)
+	return tw.Flush()
+}
@@ -0,0 +1,134 @@
+package voteproof
+
+import (
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func planGroups() AlgebraicParameters {
+	ffg := group.ModPGroup3072q256()
+	ecg := group.P256()
+	return AlgebraicParameters{
+		GFF: groupParams(ffg, ffg.Element().BaseScale(ffg.NewScalar().SetUint64(13))),
+		GEC: groupParams(ecg, ecg.Random()),
+	}
+}
+
+func TestPlan(t *testing.T) {
+	ap := planGroups()
+	lo, hi := big.NewInt(101), big.NewInt(2000)
+
+	params, err := Plan(Requirements{Security: 128, ChallengeLen: 224}, 16, lo, hi, ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Bc != 224 || params.Bb != 15 {
+		t.Errorf("unexpected parameters Bc=%d Bb=%d", params.Bc, params.Bb)
+	}
+	// Without a challenge length, the longest one that fits is chosen.
+	params, err = Plan(Requirements{Security: 128}, 16, lo, hi, ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Bc != 224 || params.Bb < DefaultMinSlack {
+		t.Errorf("unexpected parameters Bc=%d Bb=%d", params.Bc, params.Bb)
+	}
+	params, err = Plan(Requirements{Security: 128, MinSlack: 40}, 16, lo, hi, ap)
+	if err != nil || params.Bc != 192 || params.Bb != 47 {
+		t.Errorf("unexpected parameters Bc=%d Bb=%d: %v", params.Bc, params.Bb, err)
+	}
+
+	refused := []struct {
+		name string
+		req  Requirements
+		err  error
+	}{
+		{"no security", Requirements{}, ErrSecurity},
+		{"unaligned challenge", Requirements{Security: 128, ChallengeLen: 220}, ErrChallengeLen},
+		{"challenge too long", Requirements{Security: 128, ChallengeLen: 256}, ErrGroupTooSmall},
+		{"security too high", Requirements{Security: 240}, ErrGroupTooSmall},
+		{"challenge too short", Requirements{Security: 128, ChallengeLen: 120}, ErrGroupTooSmall},
+		{"slack too large", Requirements{Security: 128, ChallengeLen: 224, MinSlack: 16}, ErrGroupTooSmall},
+	}
+	for _, tt := range refused {
+		if _, err = Plan(tt.req, 16, lo, hi, ap); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+	// In larger curve groups, the challenge is bounded by the order of the
+	// field group.
+	ap.GEC = groupParams(group.P384(), group.P384().Random())
+	params, err = Plan(Requirements{Security: 128}, 16, lo, hi, ap)
+	if err != nil || params.Bc != 248 {
+		t.Errorf("unexpected challenge length %d: %v", params.Bc, err)
+	}
+
+	if _, err = Plan(Requirements{Security: 128}, 8, lo, hi, ap); err == nil {
+		t.Error("range that does not fit in the secret was accepted")
+	}
+}
+
+func TestReport(t *testing.T) {
+	params := testParams(t, 224, 15)
+	report, err := params.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Soundness != 224 || report.LiveSoundness != 218 || report.AbortProbability != math.Ldexp(1, -15) {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	proof, _, err := proveSecret(big.NewInt(1234), params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ProofSize != len(b) {
+		t.Errorf("reported size %d, encoded size %d", report.ProofSize, len(b))
+	}
+	if b, err = proof.Compact().MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if report.CompactSize != len(b) {
+		t.Errorf("reported compact size %d, encoded size %d", report.CompactSize, len(b))
+	}
+
+	var out strings.Builder
+	if err = report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "2^-224") {
+		t.Errorf("report does not state the soundness error:\n%s", out.String())
+	}
+}
+
+// TestAbortProbability checks that attempts abort with the reported
+// probability, for the largest secret.
+func TestAbortProbability(t *testing.T) {
+	const proofs = 200
+	params := testParams(t, 224, 2)
+	report, _ := params.Report()
+
+	aborts := 0
+	opts := &ProveOptions{MaxAttempts: 1000, OnAbort: func(int) { aborts++ }}
+	x := new(big.Int).Lsh(big.NewInt(1), uint(params.Bx))
+	x.Sub(x, big.NewInt(1))
+	for i := 0; i < proofs; i++ {
+		if _, _, err := proveSecret(x, params, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// With 200 proofs, about 67 of 267 attempts abort, and the rate has a
+	// standard deviation of about 0.03.
+	rate := float64(aborts) / float64(proofs+aborts)
+	if math.Abs(rate-report.AbortProbability) > 0.1 {
+		t.Errorf("%d of %d attempts aborted, expected a rate of %g", aborts, proofs+aborts, report.AbortProbability)
+	}
+}
@@ -0,0 +1,399 @@
+// Package prooftest checks the properties that the security of the vote
+// proof rests on: a simulator produces accepting transcripts without the
+// witness that are distributed as honest ones (honest-verifier zero
+// knowledge), and an extractor recovers the witness from two accepting
+// transcripts with the same commitment (special soundness).
+package prooftest
+
+import (
+	"crypto/rand"
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/interactive"
+	"github.com/takakv/msc-poc/sigma"
+	"github.com/takakv/msc-poc/voteproof"
+	"math/big"
+	"testing"
+)
+
+// Samples is the number of transcripts each distribution check draws from
+// the prover and from the simulator.
+var Samples = 256
+
+// Bins is the number of bins of the distribution checks.
+const Bins = 8
+
+// chiSquareCritical is the critical value of the chi-squared distribution
+// with Bins-1 degrees of freedom at a significance level of 10^-4.
+const chiSquareCritical = 29.88
+
+var (
+	ErrSameChallenge     = errors.New("prooftest: transcripts have the same challenge")
+	ErrOtherCommitment   = errors.New("prooftest: transcripts have different commitments")
+	ErrNotExtractable    = errors.New("prooftest: integer responses do not determine a secret")
+	ErrNotAccepting      = errors.New("prooftest: transcript is not accepting")
+	errIncompleteWitness = errors.New("prooftest: incomplete witness")
+)
+
+// Run runs all property tests against params as subtests of t.
+func Run(t *testing.T, params voteproof.ProofParams) {
+	t.Run("Simulator", func(t *testing.T) { TestSimulator(t, params) })
+	t.Run("Distribution", func(t *testing.T) { TestDistribution(t, params) })
+	t.Run("Extractor", func(t *testing.T) { TestExtractor(t, params) })
+}
+
+// RandomChallenge returns a uniformly random challenge of the length of
+// params.
+func RandomChallenge(params voteproof.ProofParams) *big.Int {
+	c, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(params.Bc)))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// integerBounds returns the inclusive lower and exclusive upper bound of the
+// integer responses that the verifier accepts.
+func integerBounds(params voteproof.ProofParams) (*big.Int, *big.Int) {
+	lower := new(big.Int).Lsh(big.NewInt(1), uint(params.Bx)+uint(params.Bc))
+	upper := new(big.Int).Lsh(lower, uint(params.Bb))
+	return lower, upper
+}
+
+// commit returns xG + rH.
+func commit(x *big.Int, r group.Scalar, gp voteproof.GroupParameters) group.Element {
+	g := gp.I
+	X := g.Element().Scale(gp.G, g.NewScalar().SetBigInt(x))
+	return X.Add(X, g.Element().Scale(gp.H, r))
+}
+
+// shift returns X - cY.
+func shift(X, Y group.Element, c *big.Int, g group.Group) group.Element {
+	return g.Element().Subtract(X, g.Element().Scale(Y, g.NewScalar().SetBigInt(c)))
+}
+
+// Simulate returns an accepting transcript for comm with the challenge c,
+// without knowing a witness. It samples the responses uniformly from the
+// ranges that the verifier accepts, and solves the verification equations
+// for the commitments.
+func Simulate(params voteproof.ProofParams, comm voteproof.VerCommitments, c *big.Int) (voteproof.SigmaProof, error) {
+	lower, upper := integerBounds(params)
+	z, err := rand.Int(rand.Reader, new(big.Int).Sub(upper, lower))
+	if err != nil {
+		return voteproof.SigmaProof{}, err
+	}
+	z.Add(z, lower)
+
+	ff, ec := params.GFF, params.GEC
+	var proof voteproof.SigmaProof
+	proof.Params = params
+	proof.Challenge = c
+	proof.Z = z
+	proof.Sp = ff.I.RandomScalar()
+	proof.Sq1 = ec.I.RandomScalar()
+	proof.Sq2 = ec.I.RandomScalar()
+
+	// W = spG - cY, and K = zG + sH - cX in each group.
+	proof.W = shift(ff.I.Element().Scale(ff.G, proof.Sp), comm.Y, c, ff.I)
+	proof.Kp = shift(commit(z, proof.Sp, ff), comm.Xp, c, ff.I)
+	proof.Kq1 = shift(commit(z, proof.Sq1, ec), comm.Xq1, c, ec.I)
+	proof.Kq2 = shift(commit(z, proof.Sq2, ec), comm.Xq2, c, ec.I)
+	return proof, nil
+}
+
+// Prove runs the prover of the vote proof for w against the challenge c,
+// and returns the transcript of its first attempt that does not abort.
+func Prove(params voteproof.ProofParams, w voteproof.Witness, c *big.Int) (voteproof.SigmaProof, error) {
+	if len(w.R) != 3 {
+		return voteproof.SigmaProof{}, errIncompleteWitness
+	}
+	p, err := voteproof.NewProver(w.X, w.R[0], w.R[1], w.R[2], params, nil)
+	if err != nil {
+		return voteproof.SigmaProof{}, err
+	}
+	challenge := voteproof.SigmaChallenge{Challenge: c}
+	m, err := p.Next(nil)
+	for err == nil {
+		commit, ok := m.(voteproof.SigmaCommit)
+		if !ok {
+			return voteproof.SigmaProof{}, interactive.Unexpected(m)
+		}
+		// If the attempt aborts, the prover commits anew.
+		if m, err = p.Next(challenge); err == nil {
+			if r, ok := m.(voteproof.SigmaResponse); ok {
+				return voteproof.SigmaProof{
+					SigmaCommit: commit, SigmaChallenge: challenge, SigmaResponse: r, Params: params,
+				}, nil
+			}
+		}
+	}
+	return voteproof.SigmaProof{}, err
+}
+
+// Accepts returns true if the verifier accepts the transcript for comm with
+// the challenge of the transcript. Unlike SigmaProof.Verify, the challenge
+// need not be derived by Fiat–Shamir.
+func Accepts(params voteproof.ProofParams, comm voteproof.VerCommitments, proof voteproof.SigmaProof) bool {
+	st := params.Statement(comm)
+	t := proof.Transcript()
+	return st.Relation().Verify(t.Commitment, t.Challenge, t.Response)
+}
+
+// Fork answers a single commitment to w with both challenges, as a prover
+// that is rewound would. It retries until neither answer aborts.
+func Fork(params voteproof.ProofParams, w voteproof.Witness, c1, c2 *big.Int) (voteproof.SigmaProof, voteproof.SigmaProof, error) {
+	if w.X == nil || len(w.R) != 3 {
+		return voteproof.SigmaProof{}, voteproof.SigmaProof{}, errIncompleteWitness
+	}
+	st := params.Statement(voteproof.VerCommitments{})
+	relation := st.Relation()
+	values := []*big.Int{w.X, w.R[0].BigInt(), w.R[1].BigInt(), w.R[2].BigInt()}
+
+	for attempt := 0; attempt < voteproof.DefaultMaxAttempts; attempt++ {
+		commitment, state, err := relation.Commit(values)
+		if err != nil {
+			return voteproof.SigmaProof{}, voteproof.SigmaProof{}, err
+		}
+		e := commitment.Elements
+		sc := voteproof.SigmaCommit{W: e[0], Kp: e[1], Kq1: e[2], Kq2: e[3]}
+
+		var proofs [2]voteproof.SigmaProof
+		aborted := false
+		for i, c := range []*big.Int{c1, c2} {
+			response, err := relation.Respond(state, c)
+			if errors.Is(err, sigma.ErrAbort) {
+				aborted = true
+				break
+			}
+			if err != nil {
+				return voteproof.SigmaProof{}, voteproof.SigmaProof{}, err
+			}
+			proofs[i] = voteproof.SigmaProof{
+				SigmaCommit:    sc,
+				SigmaChallenge: voteproof.SigmaChallenge{Challenge: c},
+				SigmaResponse: voteproof.SigmaResponse{
+					Z: response.Ints[0], Sp: response.Scalars[0], Sq1: response.Scalars[1], Sq2: response.Scalars[2],
+				},
+				Params: params,
+			}
+		}
+		if !aborted {
+			return proofs[0], proofs[1], nil
+		}
+	}
+	return voteproof.SigmaProof{}, voteproof.SigmaProof{}, voteproof.ErrTooManyAborts
+}
+
+// sameCommitment returns true if both transcripts have the same commitment.
+func sameCommitment(a, b voteproof.SigmaCommit) bool {
+	return a.W.IsEqual(b.W) && a.Kp.IsEqual(b.Kp) && a.Kq1.IsEqual(b.Kq1) && a.Kq2.IsEqual(b.Kq2)
+}
+
+// Extract recovers the secret and the randomness of comm from two accepting
+// transcripts with the same commitment and different challenges. Since
+// z = k + cx is not reduced, the secret is (z1 - z2)/(c1 - c2) over the
+// integers, and the randomness is (s1 - s2)/(c1 - c2) in each group.
+func Extract(params voteproof.ProofParams, comm voteproof.VerCommitments, a, b voteproof.SigmaProof) (voteproof.Witness, error) {
+	if !Accepts(params, comm, a) || !Accepts(params, comm, b) {
+		return voteproof.Witness{}, ErrNotAccepting
+	}
+	if !sameCommitment(a.SigmaCommit, b.SigmaCommit) {
+		return voteproof.Witness{}, ErrOtherCommitment
+	}
+	dc := new(big.Int).Sub(a.Challenge, b.Challenge)
+	if dc.Sign() == 0 {
+		return voteproof.Witness{}, ErrSameChallenge
+	}
+
+	x, m := new(big.Int).QuoRem(new(big.Int).Sub(a.Z, b.Z), dc, new(big.Int))
+	if m.Sign() != 0 {
+		return voteproof.Witness{}, ErrNotExtractable
+	}
+
+	randomness := func(s1, s2 group.Scalar, g group.Group) group.Scalar {
+		inv := g.NewScalar().Invert(g.NewScalar().SetBigInt(dc))
+		return g.NewScalar().Multiply(g.NewScalar().Subtract(s1, s2), inv)
+	}
+	ff, ec := params.GFF.I, params.GEC.I
+	return voteproof.Witness{X: x, R: []group.Scalar{
+		randomness(a.Sp, b.Sp, ff),
+		randomness(a.Sq1, b.Sq1, ec),
+		randomness(a.Sq2, b.Sq2, ec),
+	}}, nil
+}
+
+// Opens returns true if w opens comm: if Y = rpG and Xp = xG + rpH in the
+// ElGamal group, and Xq1 = xG + rq1H and Xq2 = xG + rq2H on the curve.
+func Opens(params voteproof.ProofParams, comm voteproof.VerCommitments, w voteproof.Witness) bool {
+	if w.X == nil || len(w.R) != 3 {
+		return false
+	}
+	ff, ec := params.GFF, params.GEC
+	return comm.Y.IsEqual(ff.I.Element().Scale(ff.G, w.R[0])) &&
+		comm.Xp.IsEqual(commit(w.X, w.R[0], ff)) &&
+		comm.Xq1.IsEqual(commit(w.X, w.R[1], ec)) &&
+		comm.Xq2.IsEqual(commit(w.X, w.R[2], ec))
+}
+
+// RandomWitness returns a random secret of Bx bits with random randomness,
+// and the commitments that it opens.
+func RandomWitness(params voteproof.ProofParams) (voteproof.Witness, voteproof.VerCommitments) {
+	x, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(params.Bx)))
+	if err != nil {
+		panic(err)
+	}
+	ff, ec := params.GFF, params.GEC
+	w := voteproof.Witness{X: x, R: []group.Scalar{ff.I.RandomScalar(), ec.I.RandomScalar(), ec.I.RandomScalar()}}
+	comm := voteproof.VerCommitments{
+		Y:   ff.I.Element().Scale(ff.G, w.R[0]),
+		Xp:  commit(x, w.R[0], ff),
+		Xq1: commit(x, w.R[1], ec),
+		Xq2: commit(x, w.R[2], ec),
+	}
+	return w, comm
+}
+
+// randomCommitments returns commitments that do not open to a common secret.
+func randomCommitments(params voteproof.ProofParams) voteproof.VerCommitments {
+	ff, ec := params.GFF.I, params.GEC.I
+	return voteproof.VerCommitments{Y: ff.Random(), Xp: ff.Random(), Xq1: ec.Random(), Xq2: ec.Random()}
+}
+
+// TestSimulator checks that simulated transcripts are accepting, also for
+// commitments that no witness opens, and only for their own challenge.
+func TestSimulator(t *testing.T, params voteproof.ProofParams) {
+	_, comm := RandomWitness(params)
+	for _, comm := range []voteproof.VerCommitments{comm, randomCommitments(params)} {
+		c := RandomChallenge(params)
+		proof, err := Simulate(params, comm, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Accepts(params, comm, proof) {
+			t.Error("simulated transcript is not accepting")
+		}
+		proof.Challenge = new(big.Int).Xor(c, big.NewInt(1))
+		if Accepts(params, comm, proof) {
+			t.Error("simulated transcript is accepting for another challenge")
+		}
+	}
+}
+
+// bin returns the bin of v in [0, n).
+func bin(v, n *big.Int) int {
+	b := new(big.Int).Mul(v, big.NewInt(Bins))
+	return int(b.Quo(b, n).Int64())
+}
+
+// homogeneous returns the two-sample chi-squared statistic of the histograms
+// of equally many samples, and whether they pass as equally distributed.
+func homogeneous(a, b [Bins]int) (float64, bool) {
+	var stat float64
+	for i := range a {
+		if n := a[i] + b[i]; n > 0 {
+			d := float64(a[i] - b[i])
+			stat += d * d / float64(n)
+		}
+	}
+	return stat, stat < chiSquareCritical
+}
+
+// histograms bins the responses of the transcripts: the integer response
+// within the accepted range, and the scalar responses within their groups.
+func histograms(params voteproof.ProofParams, proofs []voteproof.SigmaProof) [4][Bins]int {
+	lower, upper := integerBounds(params)
+	width := new(big.Int).Sub(upper, lower)
+	var h [4][Bins]int
+	for _, proof := range proofs {
+		h[0][bin(new(big.Int).Sub(proof.Z, lower), width)]++
+		h[1][bin(proof.Sp.BigInt(), params.GFF.N)]++
+		h[2][bin(proof.Sq1.BigInt(), params.GEC.N)]++
+		h[3][bin(proof.Sq2.BigInt(), params.GEC.N)]++
+	}
+	return h
+}
+
+// TestDistribution checks that the responses of honest transcripts for a
+// fixed challenge are distributed as those of simulated transcripts, for a
+// small and a large secret. The commitments of either are determined by
+// the responses, so the transcripts are then distributed alike.
+func TestDistribution(t *testing.T, params voteproof.ProofParams) {
+	names := [4]string{"z", "sp", "sq1", "sq2"}
+	c := RandomChallenge(params)
+	max := new(big.Int).Lsh(big.NewInt(1), uint(params.Bx))
+	for _, x := range []*big.Int{big.NewInt(0), max.Sub(max, big.NewInt(1))} {
+		w, comm := RandomWitness(params)
+		w.X = x
+		comm.Xp = commit(x, w.R[0], params.GFF)
+		comm.Xq1 = commit(x, w.R[1], params.GEC)
+		comm.Xq2 = commit(x, w.R[2], params.GEC)
+
+		honest := make([]voteproof.SigmaProof, Samples)
+		simulated := make([]voteproof.SigmaProof, Samples)
+		for i := range honest {
+			var err error
+			if honest[i], err = Prove(params, w, c); err != nil {
+				t.Fatal(err)
+			}
+			if simulated[i], err = Simulate(params, comm, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !Accepts(params, comm, honest[0]) || !Accepts(params, comm, simulated[0]) {
+			t.Fatal("transcript is not accepting")
+		}
+
+		hh, hs := histograms(params, honest), histograms(params, simulated)
+		for i := range hh {
+			if stat, ok := homogeneous(hh[i], hs[i]); !ok {
+				t.Errorf("x = %v: %s of honest and simulated transcripts differ: chi-squared %.2f, bins %v and %v",
+					x, names[i], stat, hh[i], hs[i])
+			}
+		}
+	}
+}
+
+// TestExtractor checks that the extractor recovers the witness from two
+// transcripts with the same commitment, and that it needs them.
+func TestExtractor(t *testing.T, params voteproof.ProofParams) {
+	w, comm := RandomWitness(params)
+	c1, c2 := RandomChallenge(params), RandomChallenge(params)
+	for c1.Cmp(c2) == 0 {
+		c2 = RandomChallenge(params)
+	}
+	a, b, err := Fork(params, w, c1, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extracted, err := Extract(params, comm, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extracted.X.Cmp(w.X) != 0 {
+		t.Errorf("extracted secret %v, expected %v", extracted.X, w.X)
+	}
+	for i := range w.R {
+		if !extracted.R[i].IsEqual(w.R[i]) {
+			t.Errorf("extracted randomness %d differs", i)
+		}
+	}
+	if !Opens(params, comm, extracted) {
+		t.Error("extracted witness does not open the commitments")
+	}
+
+	if _, err = Extract(params, comm, a, a); !errors.Is(err, ErrSameChallenge) {
+		t.Errorf("expected %v, got %v", ErrSameChallenge, err)
+	}
+	other, err := Prove(params, w, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Extract(params, comm, a, other); !errors.Is(err, ErrOtherCommitment) {
+		t.Errorf("expected %v, got %v", ErrOtherCommitment, err)
+	}
+	simulated, _ := Simulate(params, comm, c2)
+	if _, err = Extract(params, comm, a, simulated); err == nil {
+		t.Error("witness was extracted with a simulated transcript")
+	}
+}
@@ -0,0 +1,26 @@
+package voteproof_test
+
+import (
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/voteproof"
+	"github.com/takakv/msc-poc/voteproof/prooftest"
+	"math/big"
+	"testing"
+)
+
+func TestProperties(t *testing.T) {
+	ffg := group.ModPGroup3072q256()
+	for _, ecg := range []group.Group{group.P256(), group.SecP256k1()} {
+		ap := voteproof.AlgebraicParameters{
+			GFF: voteproof.GroupParameters{G: ffg.Generator(), H: ffg.Random(), N: ffg.N(), F: ffg.P(), I: ffg},
+			GEC: voteproof.GroupParameters{G: ecg.Generator(), H: ecg.Random(), N: ecg.N(), F: ecg.P(), I: ecg},
+		}
+		// A slack of 8 bits makes the bins of the integer responses
+		// sensitive to leakage of the secret.
+		params, err := voteproof.Setup(16, 224, 8+1+16+224, big.NewInt(0), big.NewInt(0xffff), ap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(ecg.Name(), func(t *testing.T) { prooftest.Run(t, params) })
+	}
+}
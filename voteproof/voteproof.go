@@ -1,12 +1,11 @@
 package voteproof
 
 import (
-	"bytes"
-	"crypto/rand"
-	"crypto/sha256"
+	"context"
 	"errors"
-	"fmt"
 	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/interactive"
+	"github.com/takakv/msc-poc/sigma"
 	"math/big"
 )
 
@@ -29,13 +28,13 @@ type AlgebraicParameters struct {
 
 // ProofParams holds the parameters of the vote correctness proof system.
 type ProofParams struct {
-	Bx                  uint8  // Length of the secret.
-	Bc                  uint16 // Length of the challenge.
-	Bg                  uint16 // Length of the order of the smaller group.
-	Bb                  int    // Abort parameter.
-	RangeLo             uint16 // Inclusive lower bound of the range.
-	RangeHi             uint16 // Inclusive upper bound of the range.
-	AlgebraicParameters        // Group descriptions.
+	Bx                  uint16   // Length of the secret.
+	Bc                  uint16   // Length of the challenge.
+	Bg                  uint16   // Length of the order of the smaller group.
+	Bb                  int      // Abort parameter.
+	RangeLo             *big.Int // Inclusive lower bound of the range.
+	RangeHi             *big.Int // Inclusive upper bound of the range.
+	AlgebraicParameters          // Group descriptions.
 }
 
 // VerCommitments holds the commitments needed to verify the correctness proof.
@@ -61,10 +60,10 @@ type SigmaChallenge struct {
 
 // SigmaResponse represents the response of the protocol.
 type SigmaResponse struct {
-	Z   *big.Int
-	Sp  *big.Int
-	Sq1 *big.Int
-	Sq2 *big.Int
+	Z   *big.Int // Integer response, which must not be reduced.
+	Sp  group.Scalar
+	Sq1 group.Scalar
+	Sq2 group.Scalar
 }
 
 // SigmaProof contains the elements involved in the sigma protocol.
@@ -77,17 +76,30 @@ type SigmaProof struct {
 }
 
 // Setup sets the common parameters for the vote correctness proof system.
-func Setup(lenSecret uint8, lenChallenge uint16, groupOrderLog uint16,
-	rangeLo uint16, rangeHi uint16, AP AlgebraicParameters) (ProofParams, error) {
+// Secrets are at most lenSecret bits long, and the range [rangeLo, rangeHi]
+// must fit in them. The abort parameter is what remains of the group order
+// after the secret and the challenge, and must be positive.
+func Setup(lenSecret uint16, lenChallenge uint16, groupOrderLog uint16,
+	rangeLo, rangeHi *big.Int, AP AlgebraicParameters) (ProofParams, error) {
+	if rangeLo == nil || rangeHi == nil || rangeLo.Sign() < 0 || rangeLo.Cmp(rangeHi) > 0 {
+		return ProofParams{}, errors.New("invalid range")
+	}
+	if rangeHi.BitLen() > int(lenSecret) {
+		return ProofParams{}, errors.New("range does not fit in the secret length")
+	}
+
 	params := ProofParams{}
 	params.Bx = lenSecret
 	params.Bc = lenChallenge
 	params.Bg = groupOrderLog
 	params.Bb = int(groupOrderLog) - 1 - int(lenSecret) - int(lenChallenge)
-	params.RangeLo = rangeLo
-	params.RangeHi = rangeHi
+	params.RangeLo = new(big.Int).Set(rangeLo)
+	params.RangeHi = new(big.Int).Set(rangeHi)
 	params.GFF = AP.GFF
 	params.GEC = AP.GEC
+	// The second generator of the field group is fixed, so precomputation
+	// pays off. Curve groups have no tables.
+	params.GFF.H = group.Precompute(params.GFF.H)
 
 	if params.Bb < 1 {
 		return params, errors.New("inconsistent parameter choice")
@@ -96,131 +108,154 @@ func Setup(lenSecret uint8, lenChallenge uint16, groupOrderLog uint16,
 	return params, nil
 }
 
-func pedersenCommit(m *big.Int, r *big.Int, gp GroupParameters) group.Element {
-	bind := gp.I.Element().BaseScale(m)
+// pedersenCommit commits to the integer m, which is reduced into the group.
+func pedersenCommit(m *big.Int, r group.Scalar, gp GroupParameters) group.Element {
+	bind := gp.I.Element().BaseScale(gp.I.NewScalar().SetBigInt(m))
 	blind := gp.I.Element().Scale(gp.H, r)
 	return gp.I.Element().Add(bind, blind)
 }
 
-func sigmaPedersenCheck(z, s, c *big.Int, k, x group.Element, gp GroupParameters) bool {
+// commit returns the commitments of the vote proof to secret with the
+// given randomness.
+func (params *ProofParams) commit(secret *big.Int, rp, rq1, rq2 group.Scalar) VerCommitments {
+	return VerCommitments{
+		Y:   params.GFF.I.Element().BaseScale(rp),
+		Xp:  pedersenCommit(secret, rp, params.GFF),
+		Xq1: pedersenCommit(secret, rq1, params.GEC),
+		Xq2: pedersenCommit(secret, rq2, params.GEC),
+	}
+}
+
+func sigmaPedersenCheck(z *big.Int, s group.Scalar, c *big.Int, k, x group.Element, gp GroupParameters) bool {
 	left := pedersenCommit(z, s, gp)
-	right := gp.I.Element().Scale(x, c)
+	right := gp.I.Element().Scale(x, gp.I.NewScalar().SetBigInt(c))
 	right = gp.I.Element().Add(right, k)
 	return left.IsEqual(right)
 }
 
-func getFSChallenge(w group.Element, Kp group.Element, Kq1, Kq2 group.Element, pow2bound uint16) *big.Int {
-	hasher := sha256.New()
-
-	var buffer bytes.Buffer
-	buffer.WriteString(w.String())
-	buffer.WriteString(Kp.String())
-	buffer.WriteString(Kq1.String())
-	buffer.WriteString(Kq2.String())
-	hasher.Write(buffer.Bytes())
-
-	challenge := hasher.Sum(nil)[:pow2bound/8]
-	return new(big.Int).SetBytes(challenge)
-}
-
-func Prove(secret *big.Int, rp *big.Int, rq1, rq2 *big.Int, params ProofParams) SigmaProof {
-	bxbc := big.NewInt(int64(uint16(params.Bx) + params.Bc))
-	// Inclusive lower bound
-	zLowerBound := new(big.Int).Exp(BigTwo, bxbc, nil)
-	// Exclusive upper bound
-	zUpperBound := new(big.Int).Exp(BigTwo, new(big.Int).Add(bxbc, big.NewInt(int64(params.Bb))), nil)
-
-	// Abort loop
-	for {
-		// Setup
-		k, _ := rand.Int(rand.Reader, zUpperBound)
-		kp := new(big.Int).Mod(k, params.GFF.N) // k mod p for efficiency
-		kq := new(big.Int).Mod(k, params.GFF.N) // k mod q for efficiency
-
-		tp, _ := rand.Int(rand.Reader, params.GFF.N)
-		tq1, _ := rand.Int(rand.Reader, params.GEC.N)
-		tq2, _ := rand.Int(rand.Reader, params.GEC.N)
-
-		// Commitment
-		w := params.GFF.I.Element().BaseScale(tp)
-		Kp := pedersenCommit(kp, tp, params.GFF)
-		Kq1 := pedersenCommit(kq, tq1, params.GEC)
-		Kq2 := pedersenCommit(kq, tq2, params.GEC)
-
-		// Challenge
-		challenge := getFSChallenge(w, Kp, Kq1, Kq2, params.Bc)
-		z := new(big.Int).Add(k, new(big.Int).Mul(challenge, secret))
-		if z.Cmp(zLowerBound) == -1 || z.Cmp(zUpperBound) != -1 {
-			fmt.Println("Aborted")
-			continue
-		}
-
-		// Response
-		sp := new(big.Int).Mod(new(big.Int).Add(tp, new(big.Int).Mul(challenge, rp)), params.GFF.N)
-		sq1 := new(big.Int).Mod(new(big.Int).Add(tq1, new(big.Int).Mul(challenge, rq1)), params.GEC.N)
-		sq2 := new(big.Int).Mod(new(big.Int).Add(tq2, new(big.Int).Mul(challenge, rq2)), params.GEC.N)
-
-		var proof SigmaProof
-		proof.W = w
-		proof.Kp = Kp
-		proof.Kq1 = Kq1
-		proof.Kq2 = Kq2
-		proof.Challenge = challenge
-		proof.Z = z
-		proof.Sp = sp
-		proof.Sq1 = sq1
-		proof.Sq2 = sq2
-		proof.Params = params
-
-		return proof
+// Statement expresses the vote proof as a proof of equality of the
+// ElGamal ciphertext (Y, Xp) in GFF and the commitments Xq1 and Xq2 in GEC.
+func (params *ProofParams) Statement(comm VerCommitments) Statement {
+	return Statement{
+		Bx: params.Bx,
+		Bc: params.Bc,
+		Bb: params.Bb,
+		Commitments: []Commitment{
+			{Params: params.GFF, ElGamal: true, X: comm.Xp, Y: comm.Y},
+			{Params: params.GEC, X: comm.Xq1},
+			{Params: params.GEC, X: comm.Xq2},
+		},
 	}
 }
 
-// Verify verifies the transcript of the proof of secret equality across groups.
-// NB! The range proof(s) that assert "smallness" of the secret must be verified
-// prior to verifying the transcript. Verify does not verify the range proof(s).
-func (proof *SigmaProof) Verify(comm VerCommitments) bool {
-	bxbc := big.NewInt(int64(uint16(proof.Params.Bx) + proof.Params.Bc))
-	// Inclusive lower bound
-	zLowerBound := new(big.Int).Exp(BigTwo, bxbc, nil)
-	// Exclusive upper bound
-	zUpperBound := new(big.Int).Exp(BigTwo, new(big.Int).Add(bxbc, big.NewInt(int64(proof.Params.Bb))), nil)
-
-	// Verify whether z lies within the safe (no-leak) range.
-	if proof.Z.Cmp(zLowerBound) == -1 || proof.Z.Cmp(zUpperBound) != -1 {
-		return false
-	}
+// Transcript returns the proof as a non-interactive proof of the relation
+// of Statement, whose challenge is derived by Oracle.
+func (proof *SigmaProof) Transcript() sigma.Proof {
+	ep := proof.equalityProof()
+	return ep.Transcript()
+}
 
-	// Verify challenge correctness.
-	challenge := getFSChallenge(proof.W, proof.Kp, proof.Kq1, proof.Kq2, proof.Params.Bc)
-	if challenge.Cmp(proof.Challenge) != 0 {
-		return false
+// equalityProof returns the proof as an instance of the equality proof.
+func (proof *SigmaProof) equalityProof() EqualityProof {
+	return EqualityProof{
+		W:         []group.Element{proof.W, nil, nil},
+		K:         []group.Element{proof.Kp, proof.Kq1, proof.Kq2},
+		Challenge: proof.Challenge,
+		Z:         proof.Z,
+		S:         []group.Scalar{proof.Sp, proof.Sq1, proof.Sq2},
 	}
+}
 
-	// Verify ElGamal ciphertext c1.
-	l := proof.Params.GFF.I.Element().BaseScale(proof.Sp)
-	r := proof.Params.GFF.I.Element().Scale(comm.Y, proof.Challenge)
-	r = proof.Params.GFF.I.Element().Add(r, proof.W)
-	if !l.IsEqual(r) {
-		return false
-	}
+// DefaultMaxAttempts is the default bound on the attempts of a proof. An
+// attempt aborts with probability about 2^(1-Bb), so running out of
+// attempts is practically impossible for honest provers. A verifier that
+// answers every attempt gives a cheating prover as many challenges, which
+// costs log2(DefaultMaxAttempts) = 6 bits of soundness.
+const DefaultMaxAttempts = sigma.DefaultMaxAttempts
+
+var (
+	ErrTooManyAborts = sigma.ErrTooManyAborts
+	ErrInvalidSecret = errors.New("voteproof: secret is not in the range of Bx bits")
+	ErrInvalidScalar = errors.New("voteproof: randomness is not a scalar of its group")
+	ErrInvalidParams = errors.New("voteproof: incomplete proof parameters")
+)
 
-	// Verify ElGamal ciphertext c2.
-	if !sigmaPedersenCheck(proof.Z, proof.Sp, proof.Challenge, proof.Kp,
-		comm.Xp, proof.Params.GFF) {
-		return false
+// ProveOptions controls the rejection sampling of Prove and ProveEquality.
+type ProveOptions = sigma.Options
+
+// checkScalar returns true if s is a scalar of the group of order n.
+func checkScalar(s group.Scalar, n *big.Int) bool {
+	return s != nil && s.BigInt().Cmp(n) < 0
+}
+
+// Prove proves that the secret encrypted with randomness rp in GFF is the
+// secret committed to with randomness rq1 and rq2 in GEC. The proof is
+// computed by rejection sampling, which is retried until an attempt
+// succeeds, ctx is done, or opts.MaxAttempts attempts have aborted.
+// If opts is nil, the defaults are used.
+func Prove(ctx context.Context, secret *big.Int, rp group.Scalar, rq1, rq2 group.Scalar, params ProofParams,
+	opts *ProveOptions) (SigmaProof, error) {
+	return ProveInDomain(ctx, nil, secret, rp, rq1, rq2, params, opts)
+}
+
+// ProveInDomain is Prove for a proof that only verifies in the given
+// domain, such as the identity of the prover. The domain is hashed into
+// the challenge.
+func ProveInDomain(ctx context.Context, domain []byte, secret *big.Int, rp group.Scalar, rq1, rq2 group.Scalar,
+	params ProofParams, opts *ProveOptions) (SigmaProof, error) {
+	p, err := NewProver(secret, rp, rq1, rq2, params, opts)
+	if err != nil {
+		return SigmaProof{}, err
+	}
+	messages, challenges, err := interactive.Run(ctx, p, fiatShamir(p.(*prover).relation, domain))
+	if err != nil {
+		return SigmaProof{}, err
 	}
 
-	// Verify range proof commitments (range proofs themselves must have already been verified).
-	if !sigmaPedersenCheck(proof.Z, proof.Sq1, proof.Challenge, proof.Kq1,
-		comm.Xq1, proof.Params.GEC) {
-		return false
+	// Earlier messages belong to aborted attempts.
+	n := len(messages)
+	var proof SigmaProof
+	proof.SigmaCommit = messages[n-2].(SigmaCommit)
+	proof.SigmaChallenge = challenges[n-2].(SigmaChallenge)
+	proof.SigmaResponse = messages[n-1].(SigmaResponse)
+	proof.Params = params
+
+	return proof, nil
+}
+
+// isComplete returns true if no field of the proof is missing, so that
+// Verify cannot panic on it.
+func (proof *SigmaProof) isComplete() bool {
+	gp := []GroupParameters{proof.Params.GFF, proof.Params.GEC}
+	for _, p := range gp {
+		if p.I == nil || p.G == nil || p.H == nil {
+			return false
+		}
 	}
+	return proof.W != nil && proof.Kp != nil && proof.Kq1 != nil && proof.Kq2 != nil &&
+		proof.Challenge != nil && proof.Z != nil &&
+		proof.Sp != nil && proof.Sq1 != nil && proof.Sq2 != nil
+}
+
+func (comm *VerCommitments) isComplete() bool {
+	return comm.Y != nil && comm.Xp != nil && comm.Xq1 != nil && comm.Xq2 != nil
+}
+
+// Verify verifies the transcript of the proof of secret equality across groups.
+// NB! The range proof(s) that assert "smallness" of the secret must be verified
+// prior to verifying the transcript. Verify does not verify the range proof(s).
+func (proof *SigmaProof) Verify(comm VerCommitments) bool {
+	return proof.VerifyInDomain(comm, nil)
+}
 
-	if !sigmaPedersenCheck(proof.Z, proof.Sq2, proof.Challenge, proof.Kq2,
-		comm.Xq2, proof.Params.GEC) {
+// VerifyInDomain is Verify for a proof computed by ProveInDomain.
+func (proof *SigmaProof) VerifyInDomain(comm VerCommitments, domain []byte) bool {
+	if !proof.isComplete() || !comm.isComplete() {
 		return false
 	}
-
-	return true
+	v := newVerifier(proof.Params, comm)
+	v.challenger = fiatShamir(v.relation, domain)
+	messages := []interactive.Message{proof.SigmaCommit, proof.SigmaResponse}
+	challenges, accepted, err := interactive.Replay(v, messages)
+	return err == nil && accepted && challenges[0].(SigmaChallenge).Challenge.Cmp(proof.Challenge) == 0
 }
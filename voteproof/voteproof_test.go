@@ -0,0 +1,233 @@
+package voteproof
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/takakv/msc-poc/group"
+	"github.com/takakv/msc-poc/sigma"
+	"math/big"
+	"testing"
+)
+
+func groupParams(g group.Group, h group.Element) GroupParameters {
+	return GroupParameters{G: g.Generator(), H: h, N: g.N(), F: g.P(), I: g}
+}
+
+// testParams sets up the proof system for secrets of 16 bits and
+// challenges of lenChallenge bits, with an abort parameter of bb bits.
+func testParams(t *testing.T, lenChallenge uint16, bb int) ProofParams {
+	t.Helper()
+	return testParamsBits(t, 16, lenChallenge, bb)
+}
+
+// testParamsBits sets up the proof system for secrets of lenSecret bits.
+func testParamsBits(t *testing.T, lenSecret, lenChallenge uint16, bb int) ProofParams {
+	t.Helper()
+	ffg := group.ModPGroup3072q256()
+	ecg := group.P256()
+	ap := AlgebraicParameters{
+		GFF: groupParams(ffg, ffg.Element().BaseScale(ffg.NewScalar().SetUint64(13))),
+		GEC: groupParams(ecg, ecg.Random()),
+	}
+	rangeHi := new(big.Int).Lsh(big.NewInt(1), uint(lenSecret))
+	rangeHi.Sub(rangeHi, big.NewInt(1))
+	params, err := Setup(lenSecret, lenChallenge, uint16(bb+1+int(lenSecret)+int(lenChallenge)), big.NewInt(0), rangeHi, ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+// proveSecret proves knowledge of x, and returns the proof with the
+// commitments that it is verified against.
+func proveSecret(x *big.Int, params ProofParams, opts *ProveOptions) (SigmaProof, VerCommitments, error) {
+	rp := params.GFF.I.RandomScalar()
+	rq1 := params.GEC.I.RandomScalar()
+	rq2 := params.GEC.I.RandomScalar()
+	comm := params.commit(x, rp, rq1, rq2)
+	proof, err := Prove(context.Background(), x, rp, rq1, rq2, params, opts)
+	return proof, comm, err
+}
+
+func TestProve(t *testing.T) {
+	params := testParams(t, 224, 15)
+	proof, comm, err := proveSecret(big.NewInt(1234), params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify(comm) {
+		t.Error("proof does not verify")
+	}
+
+	comm.Xq2 = comm.Xq1
+	if proof.Verify(comm) {
+		t.Error("proof verifies for other commitments")
+	}
+}
+
+func TestProveInvalidInputs(t *testing.T) {
+	params := testParams(t, 224, 15)
+	rp := params.GFF.I.RandomScalar()
+	rq := params.GEC.I.RandomScalar()
+	// The order of P-384 exceeds those of both groups.
+	large := group.P384().NewScalar().SetBigInt(new(big.Int).Sub(group.P384().N(), big.NewInt(1)))
+
+	tests := []struct {
+		name   string
+		secret *big.Int
+		rp     group.Scalar
+		rq1    group.Scalar
+		params ProofParams
+		err    error
+	}{
+		{"negative secret", big.NewInt(-1), rp, rq, params, ErrInvalidSecret},
+		{"large secret", big.NewInt(1 << 16), rp, rq, params, ErrInvalidSecret},
+		{"missing secret", nil, rp, rq, params, ErrInvalidSecret},
+		{"missing randomness", big.NewInt(1), nil, rq, params, ErrInvalidScalar},
+		{"large ElGamal randomness", big.NewInt(1), large, rq, params, ErrInvalidScalar},
+		{"large commitment randomness", big.NewInt(1), rp, large, params, ErrInvalidScalar},
+		{"missing parameters", big.NewInt(1), rp, rq, ProofParams{}, ErrInvalidParams},
+	}
+	for _, tt := range tests {
+		_, err := Prove(context.Background(), tt.secret, tt.rp, tt.rq1, rq, tt.params, nil)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, wanted %v", tt.name, err, tt.err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Prove(ctx, big.NewInt(1), rp, rq, rq, params, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context: got %v", err)
+	}
+}
+
+func TestProveAborts(t *testing.T) {
+	// With an abort parameter of a single bit, half of the attempts for the
+	// secret 0 abort.
+	params := testParams(t, 8, 1)
+
+	var aborts, failures int
+	opts := &ProveOptions{
+		MaxAttempts: 1,
+		OnAbort: func(attempt int) {
+			if attempt != 1 {
+				t.Errorf("attempt %d exceeds the maximum", attempt)
+			}
+			aborts++
+		},
+	}
+	for i := 0; i < 64; i++ {
+		proof, comm, err := proveSecret(big.NewInt(0), params, opts)
+		if errors.Is(err, ErrTooManyAborts) {
+			failures++
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(comm) {
+			t.Error("proof does not verify")
+		}
+	}
+	if failures == 0 || failures == 64 || aborts != failures {
+		t.Errorf("%d aborts and %d failures in 64 proofs", aborts, failures)
+	}
+}
+
+func TestWideSecrets(t *testing.T) {
+	// The secret and challenge lengths leave an abort parameter of 31 bits
+	// in the 256-bit groups.
+	for _, tt := range []struct{ lenSecret, lenChallenge uint16 }{{32, 192}, {64, 160}, {128, 96}} {
+		params := testParamsBits(t, tt.lenSecret, tt.lenChallenge, 31)
+		if int(params.Bg) > params.GEC.N.BitLen() {
+			t.Fatalf("%d bits: parameters exceed the group order", tt.lenSecret)
+		}
+
+		secret := new(big.Int).Set(params.RangeHi)
+		proof, comm, err := proveSecret(secret, params, nil)
+		if err != nil {
+			t.Fatalf("%d bits: %v", tt.lenSecret, err)
+		}
+		if !proof.Verify(comm) {
+			t.Errorf("%d bits: proof does not verify", tt.lenSecret)
+		}
+
+		data, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ProofUnmarshalJSON(data)
+		if err != nil {
+			t.Fatalf("%d bits: %v", tt.lenSecret, err)
+		}
+		if !decoded.Params.IsEqual(&params) || !decoded.Verify(comm) {
+			t.Errorf("%d bits: decoded proof differs", tt.lenSecret)
+		}
+
+		secret.Add(secret, big.NewInt(1))
+		if _, _, err = proveSecret(secret, params, nil); !errors.Is(err, ErrInvalidSecret) {
+			t.Errorf("%d bits: secret of %d bits: got %v", tt.lenSecret, secret.BitLen(), err)
+		}
+	}
+}
+
+func TestSigmaTranscript(t *testing.T) {
+	params := testParams(t, 224, 15)
+	proof, comm, err := proveSecret(big.NewInt(101), params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The vote proof is a proof of the relation of its statement.
+	st := params.Statement(comm)
+	transcript := proof.Transcript()
+	if !transcript.Verify(st.Relation(), Oracle) {
+		t.Error("transcript of a valid proof did not verify")
+	}
+	if transcript.Verify(st.Relation(), sigma.HashOracle("voteproof")) {
+		t.Error("transcript verified with another oracle")
+	}
+
+	comm.Xq2 = comm.Xq1
+	st = params.Statement(comm)
+	if transcript.Verify(st.Relation(), Oracle) {
+		t.Error("transcript verified for other commitments")
+	}
+}
+
+// TestSigmaProofBindsCiphertext alters the response of a proof in the field
+// group, and solves the verification equations for another ciphertext
+// (U, V) = (Y, Xp), which only the statement in the challenge rules out.
+func TestSigmaProofBindsCiphertext(t *testing.T) {
+	params := testParams(t, 224, 15)
+	proof, comm, err := proveSecret(big.NewInt(1234), params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Y' = (s'G - W)/c and Xp' = (zG + s'H - Kp)/c.
+	ff := params.GFF
+	g := ff.I
+	proof.Sp = g.RandomScalar()
+	cinv := g.NewScalar().SetBigInt(new(big.Int).ModInverse(proof.Challenge, ff.N))
+	y := g.Element().Add(g.Element().BaseScale(proof.Sp), g.Element().Negate(proof.W))
+	x := g.Element().Add(pedersenCommit(proof.Z, proof.Sp, ff), g.Element().Negate(proof.Kp))
+	forged := comm
+	forged.Y = g.Element().Scale(y, cinv)
+	forged.Xp = g.Element().Scale(x, cinv)
+
+	st := params.Statement(forged)
+	transcript := proof.Transcript()
+	if !st.Relation().Verify(transcript.Commitment, transcript.Challenge, transcript.Response) {
+		t.Fatal("forged ciphertext does not satisfy the verification equations")
+	}
+	if proof.Verify(forged) {
+		t.Error("proof verified for a ciphertext chosen after the challenge")
+	}
+	compact := proof.Compact()
+	if compact.Verify(forged) {
+		t.Error("compact proof verified for a ciphertext chosen after the challenge")
+	}
+}